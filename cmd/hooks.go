@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// hooksCmd represents the hooks command
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Sub commands for the Gitlab/Github hooks stream",
+	Long:  ``,
+}
+
+func init() {
+	rootCmd.AddCommand(hooksCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zapier/tfbuddy/pkg/hooks_stream"
+	tfnats "github.com/zapier/tfbuddy/pkg/nats"
+)
+
+var replaySequences []uint
+var replayAll bool
+
+// hooksReplayCmd represents the hooks replay command
+var hooksReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "List the hooks that failed processing, and publish them to the hooks stream again.",
+	Long: `Without flags, the hooks in the dead-letter stream are listed. Pass --seq to replay some of them, or --all to
+replay them all. Replayed hooks are removed from the dead-letter stream.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nc := tfnats.Connect()
+		defer nc.Close()
+		hs := hooks_stream.NewHooksStream(nc)
+
+		deadLetters, err := hs.ListDeadLetters()
+		if err != nil {
+			return err
+		}
+
+		if !replayAll && len(replaySequences) == 0 {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "SEQ\tTIME\tSUBJECT\tDELIVERIES\tERROR")
+			for _, dl := range deadLetters {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", dl.Sequence, dl.Time.UTC().Format(time.RFC3339), dl.Subject, dl.Deliveries, dl.Error)
+			}
+			return w.Flush()
+		}
+
+		if replayAll {
+			replaySequences = []uint{}
+			for _, dl := range deadLetters {
+				replaySequences = append(replaySequences, uint(dl.Sequence))
+			}
+		}
+		for _, seq := range replaySequences {
+			dl, err := hs.ReplayDeadLetter(uint64(seq))
+			if err != nil {
+				return fmt.Errorf("could not replay hook %d: %w", seq, err)
+			}
+			fmt.Printf("replayed hook %d to %s\n", seq, dl.Subject)
+		}
+		return nil
+	},
+}
+
+func init() {
+	hooksCmd.AddCommand(hooksReplayCmd)
+
+	hooksReplayCmd.Flags().UintSliceVar(&replaySequences, "seq", nil, "Sequence numbers of the dead-letter hooks to replay.")
+	hooksReplayCmd.Flags().BoolVar(&replayAll, "all", false, "Replay all the dead-letter hooks.")
+}
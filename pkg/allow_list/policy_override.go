@@ -0,0 +1,21 @@
+package allow_list
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const PolicyOverrideUsersEnv = "TFBUDDY_POLICY_OVERRIDE_USERS"
+
+// IsPolicyOverrideAllowed returns true if the user may override failed TFC policy checks with `tfc override-policy`.
+func IsPolicyOverrideAllowed(username string) bool {
+	for _, allowed := range getAllowList(PolicyOverrideUsersEnv) {
+		if strings.EqualFold(username, allowed) {
+			return true
+		}
+	}
+
+	log.Warn().Str("user", username).Msg("denying policy override because user not found in allow list.")
+	return false
+}
@@ -0,0 +1,29 @@
+package allow_list
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsPolicyOverrideAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		allowEnv string
+		want     bool
+	}{
+		{name: "allowed", username: "alice", allowEnv: "alice, bob", want: true},
+		{name: "case insensitive", username: "Bob", allowEnv: "alice, bob", want: true},
+		{name: "denied", username: "mallory", allowEnv: "alice, bob", want: false},
+		{name: "env not set", username: "alice", allowEnv: "", want: false},
+	}
+	defer os.Unsetenv(PolicyOverrideUsersEnv)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(PolicyOverrideUsersEnv, tt.allowEnv)
+			if got := IsPolicyOverrideAllowed(tt.username); got != tt.want {
+				t.Errorf("IsPolicyOverrideAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
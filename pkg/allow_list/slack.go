@@ -0,0 +1,37 @@
+package allow_list
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	SlackUserAllowListEnv    = "TFBUDDY_SLACK_USER_ALLOW_LIST"
+	SlackChannelAllowListEnv = "TFBUDDY_SLACK_CHANNEL_ALLOW_LIST"
+)
+
+// IsSlackCommandAllowed returns true if the Slack user may run `/tfc` commands from the channel. The user ID must be in
+// the user allow list and, if the channel allow list is set, the channel ID must be in it.
+func IsSlackCommandAllowed(userID, channelID string) bool {
+	if !contains(getAllowList(SlackUserAllowListEnv), userID) {
+		log.Warn().Str("user", userID).Msg("denying Slack command because user not found in allow list.")
+		return false
+	}
+	if channels := getAllowList(SlackChannelAllowListEnv); len(channels) > 0 && !contains(channels, channelID) {
+		log.Warn().Str("channel", channelID).Msg("denying Slack command because channel not found in allow list.")
+		return false
+	}
+	return true
+}
+
+// contains returns true if s is a non empty item of the list.
+func contains(list []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
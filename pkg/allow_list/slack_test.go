@@ -0,0 +1,32 @@
+package allow_list
+
+import (
+	"testing"
+)
+
+func TestIsSlackCommandAllowed(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      string
+		channelID   string
+		usersEnv    string
+		channelsEnv string
+		want        bool
+	}{
+		{name: "allowed user", userID: "U123", channelID: "C123", usersEnv: "U123, U456", want: true},
+		{name: "allowed user & channel", userID: "U456", channelID: "C123", usersEnv: "U123, U456", channelsEnv: "C123", want: true},
+		{name: "denied user", userID: "U789", channelID: "C123", usersEnv: "U123, U456", want: false},
+		{name: "denied channel", userID: "U123", channelID: "C456", usersEnv: "U123", channelsEnv: "C123", want: false},
+		{name: "env not set", userID: "U123", channelID: "C123", want: false},
+		{name: "missing user", userID: "", channelID: "C123", usersEnv: "U123,", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(SlackUserAllowListEnv, tt.usersEnv)
+			t.Setenv(SlackChannelAllowListEnv, tt.channelsEnv)
+			if got := IsSlackCommandAllowed(tt.userID, tt.channelID); got != tt.want {
+				t.Errorf("IsSlackCommandAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
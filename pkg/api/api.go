@@ -0,0 +1,111 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/hooks_stream"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+)
+
+// TokenEnvName is the bearer token required to call the API. The API is disabled if it isn't set.
+const TokenEnvName = "TFBUDDY_API_TOKEN"
+
+// EventsPath is the API route streaming server-sent events. Browsers can't set headers for server-sent events, so it is
+// the only route that also accepts the token as a query parameter.
+const EventsPath = "/events"
+
+// HooksStream reports the processing state of the VCS hooks stream and redelivers recorded hooks.
+type HooksStream interface {
+	State() (*hooks_stream.StreamState, error)
+	Redeliver(id string) (*hooks_stream.Delivery, error)
+}
+
+// Handler serves the read only JSON API over the runstream KV stores.
+type Handler struct {
+	tfc   tfc_api.ApiClient
+	rs    runstream.StreamClient
+	hooks HooksStream
+}
+
+func NewHandler(tfc tfc_api.ApiClient, rs runstream.StreamClient, hooks HooksStream) *Handler {
+	return &Handler{
+		tfc:   tfc,
+		rs:    rs,
+		hooks: hooks,
+	}
+}
+
+// Register adds the API routes under /api/v1, if an API token is configured. The API group is returned, so other
+// packages can serve authenticated routes, or nil if the API is disabled.
+func (h *Handler) Register(e *echo.Echo) *echo.Group {
+	token := os.Getenv(TokenEnvName)
+	if token == "" {
+		log.Info().Msgf("%s not set, the API is disabled", TokenEnvName)
+		return nil
+	}
+
+	validator := func(key string, c echo.Context) (bool, error) {
+		return subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1, nil
+	}
+	isEvents := func(c echo.Context) bool {
+		return c.Path() == "/api/v1"+EventsPath
+	}
+	g := e.Group("/api/v1")
+	g.Use(middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Skipper:   isEvents,
+		KeyLookup: "header:Authorization:Bearer ",
+		Validator: validator,
+	}))
+	g.Use(middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Skipper:   func(c echo.Context) bool { return !isEvents(c) },
+		KeyLookup: "header:Authorization:Bearer ,query:token",
+		Validator: validator,
+	}))
+	g.GET("/runs", h.ListRuns)
+	g.GET("/runs/:id", h.GetRun)
+	g.GET("/locks", h.ListLocks)
+	g.GET("/hooks", h.GetHooksState)
+	g.POST("/hooks/deliveries/:id/redeliver", h.RedeliverHook)
+	return g
+}
+
+// GetHooksState shows the processing state of the VCS hooks stream.
+func (h *Handler) GetHooksState(c echo.Context) error {
+	state, err := h.hooks.State()
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, state)
+}
+
+// RedeliverHook publishes a hook received in the last day to the hooks stream again, by its GitLab event UUID or GitHub
+// delivery ID, so it is processed again.
+func (h *Handler) RedeliverHook(c echo.Context) error {
+	d, err := h.hooks.Redeliver(c.Param("id"))
+	if err == hooks_stream.ErrDeliveryNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	log.Info().Str("id", d.ID).Str("subject", d.Subject).Msg("redelivered hook")
+	return c.JSON(http.StatusAccepted, &RedeliveredHook{
+		ID:         d.ID,
+		Subject:    d.Subject,
+		ReceivedAt: d.ReceivedAt,
+	})
+}
+
+// RedeliveredHook is the hook published again by RedeliverHook.
+type RedeliveredHook struct {
+	ID         string
+	Subject    string
+	ReceivedAt time.Time
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-tfe"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/hooks_stream"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+type hooksStream struct{}
+
+func (hooksStream) State() (*hooks_stream.StreamState, error) {
+	return &hooks_stream.StreamState{Messages: 2, Consumers: []*hooks_stream.ConsumerState{}}, nil
+}
+
+func (hooksStream) Redeliver(id string) (*hooks_stream.Delivery, error) {
+	if id != "5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1" {
+		return nil, hooks_stream.ErrDeliveryNotFound
+	}
+	return &hooks_stream.Delivery{
+		ID:         id,
+		Subject:    "HOOKS.gitlab.noteevents",
+		ReceivedAt: time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC),
+	}, nil
+}
+
+func testServer(t *testing.T, tfc *mocks.MockApiClient, rs *mocks.MockStreamClient) *echo.Echo {
+	os.Setenv(TokenEnvName, "s3cr3t")
+	t.Cleanup(func() { os.Unsetenv(TokenEnvName) })
+	e := echo.New()
+	g := NewHandler(tfc, rs, hooksStream{}).Register(e)
+	g.GET(EventsPath, func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	return e
+}
+
+func get(e *echo.Echo, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Auth(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	e := echo.New()
+	NewHandler(mocks.NewMockApiClient(mockCtrl), mocks.NewMockStreamClient(mockCtrl), hooksStream{}).Register(e)
+	assert.Equal(t, http.StatusNotFound, get(e, "/api/v1/hooks", "").Code, "API must be disabled without a token")
+
+	e = testServer(t, mocks.NewMockApiClient(mockCtrl), mocks.NewMockStreamClient(mockCtrl))
+	assert.Equal(t, http.StatusBadRequest, get(e, "/api/v1/hooks", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(e, "/api/v1/hooks", "wrong").Code)
+
+	assert.Equal(t, http.StatusBadRequest, get(e, "/api/v1/hooks?token=s3cr3t", "").Code, "only the event stream takes the token as a query parameter")
+
+	assert.Equal(t, http.StatusOK, get(e, "/api/v1/events?token=s3cr3t", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(e, "/api/v1/events?token=wrong", "").Code)
+	assert.Equal(t, http.StatusOK, get(e, "/api/v1/events", "s3cr3t").Code)
+
+	rec := get(e, "/api/v1/hooks", "s3cr3t")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"Messages":2,"Bytes":0,"FirstSeq":0,"LastSeq":0,"Consumers":[]}`, rec.Body.String())
+}
+
+func TestHandler_RedeliverHook(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	e := testServer(t, mocks.NewMockApiClient(mockCtrl), mocks.NewMockStreamClient(mockCtrl))
+	post := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer s3cr3t")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/api/v1/hooks/deliveries/5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1/redeliver")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.JSONEq(t, `{"ID":"5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1","Subject":"HOOKS.gitlab.noteevents","ReceivedAt":"2022-12-01T10:00:00Z"}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, post("/api/v1/hooks/deliveries/unknown/redeliver").Code)
+}
+
+func TestHandler_ListRuns(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	rs := mocks.NewMockStreamClient(mockCtrl)
+	e := testServer(t, mocks.NewMockApiClient(mockCtrl), rs)
+
+	createdAt := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	rs.EXPECT().ListMRRuns("zapier/tfbuddy", 101).Return([]*runstream.RunIndexEntry{
+		{RunID: "run-1", Organization: "zapier", Workspace: "a-ws", Action: "plan", CommitSHA: "abcd1234",
+			MergeRequestProjectNameWithNamespace: "zapier/tfbuddy", MergeRequestIID: 101, CreatedAt: createdAt},
+	}, nil)
+	rec := get(e, "/api/v1/runs?project=zapier/tfbuddy&mr=101", "s3cr3t")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"RunID":"run-1","Organization":"zapier","Workspace":"a-ws","Action":"plan","CommitSHA":"abcd1234",
+		"MergeRequestProjectNameWithNamespace":"zapier/tfbuddy","MergeRequestIID":101,"CreatedAt":"2022-12-01T10:00:00Z"}]`, rec.Body.String())
+
+	rs.EXPECT().ListWorkspaceRuns("zapier", "a-ws").Return(nil, nil)
+	rec = get(e, "/api/v1/runs?organization=zapier&workspace=a-ws", "s3cr3t")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, get(e, "/api/v1/runs?project=zapier/tfbuddy&mr=abc", "s3cr3t").Code)
+	assert.Equal(t, http.StatusBadRequest, get(e, "/api/v1/runs?workspace=a-ws", "s3cr3t").Code)
+}
+
+func TestHandler_GetRun(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	tfc := mocks.NewMockApiClient(mockCtrl)
+	rs := mocks.NewMockStreamClient(mockCtrl)
+	e := testServer(t, tfc, rs)
+
+	planning := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	rs.EXPECT().GetRunMeta("run-1").Return(&runstream.TFRunMetadata{RunID: "run-1", Workspace: "a-ws", Action: "plan"}, nil).Times(2)
+	tfc.EXPECT().GetRun("run-1").Return(&tfe.Run{ID: "run-1", Status: tfe.RunPlannedAndFinished, StatusTimestamps: &tfe.RunStatusTimestamps{
+		PlannedAndFinishedAt: planning.Add(time.Minute),
+		PlanningAt:           planning,
+	}}, nil).Times(2)
+	// without a recorded timeline the TFC timestamps are used
+	rs.EXPECT().GetRunTimeline("run-1").Return(&runstream.TFRunTimeline{RunID: "run-1"}, nil)
+
+	rec := get(e, "/api/v1/runs/run-1", "s3cr3t")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	details := &struct {
+		Metadata            *runstream.TFRunMetadata
+		Status              string
+		Timeline            []*StatusChange
+		QueueWaitSeconds    float64
+		PlanDurationSeconds float64
+	}{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), details))
+	assert.Equal(t, "run-1", details.Metadata.RunID)
+	assert.Equal(t, "planned_and_finished", details.Status)
+	assert.Equal(t, []*StatusChange{
+		{Status: "planning", At: planning},
+		{Status: "planned_and_finished", At: planning.Add(time.Minute)},
+	}, details.Timeline)
+	assert.Zero(t, details.QueueWaitSeconds)
+
+	rs.EXPECT().GetRunTimeline("run-1").Return(&runstream.TFRunTimeline{RunID: "run-1", Transitions: []*runstream.StatusTransition{
+		{Status: "pending", At: planning.Add(-30 * time.Second)},
+		{Status: "planning", At: planning},
+		{Status: "planned_and_finished", At: planning.Add(time.Minute)},
+	}}, nil)
+	rec = get(e, "/api/v1/runs/run-1", "s3cr3t")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), details))
+	assert.Len(t, details.Timeline, 3)
+	assert.Equal(t, "pending", details.Timeline[0].Status)
+	assert.Equal(t, 30.0, details.QueueWaitSeconds)
+	assert.Equal(t, 60.0, details.PlanDurationSeconds)
+}
+
+func TestHandler_ListLocks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	tfc := mocks.NewMockApiClient(mockCtrl)
+	rs := mocks.NewMockStreamClient(mockCtrl)
+	e := testServer(t, tfc, rs)
+
+	rs.EXPECT().ListRuns().Return([]*runstream.RunIndexEntry{
+		{RunID: "run-4", Organization: "zapier-staging", Workspace: "c-ws"},
+		{RunID: "run-3", Organization: "zapier", Workspace: "b-ws"},
+		{RunID: "run-2", Organization: "zapier", Workspace: "a-ws"},
+		{RunID: "run-1", Organization: "zapier", Workspace: "b-ws"},
+	}, nil)
+	tfc.EXPECT().ListWorkspaces(gomock.Any(), "zapier").Return([]*tfe.Workspace{
+		{Name: "a-ws", TagNames: []string{"team-a"}},
+		{Name: "b-ws", Locked: true, TagNames: []string{"tfbuddylock-101", "team-b"}},
+		{Name: "untracked-ws", Locked: true},
+	}, nil)
+	tfc.EXPECT().ListWorkspaces(gomock.Any(), "zapier-staging").Return(nil, errors.New("unauthorized"))
+
+	rec := get(e, "/api/v1/locks", "s3cr3t")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{
+		"Locks": [{"Organization":"zapier","Workspace":"b-ws","Locked":true,"MergeRequests":[101]}],
+		"Errors": [{"Organization":"zapier-staging","Error":"unauthorized"}]
+	}`, rec.Body.String())
+}
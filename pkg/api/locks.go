@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// lockTagPrefix is the prefix of the workspace tags TF Buddy uses to lock a workspace for a MR
+const lockTagPrefix = "tfbuddylock"
+
+// WorkspaceLock is the lock state of a workspace TF Buddy created runs for.
+type WorkspaceLock struct {
+	Organization string
+	Workspace    string
+	// Locked is true if the workspace is locked in TFC (e.g. with `tfc lock`)
+	Locked bool
+	// MergeRequests are the IIDs of the MRs holding an apply lock on the workspace
+	MergeRequests []int
+}
+
+// Locks are the workspace locks, and the organizations whose workspaces could not be listed.
+type Locks struct {
+	Locks []*WorkspaceLock
+	// Errors lists the organizations whose locks are missing from Locks
+	Errors []*OrganizationError
+}
+
+// OrganizationError is an error reading the workspaces of an organization.
+type OrganizationError struct {
+	Organization string
+	Error        string
+}
+
+// ListLocks lists the locked workspaces, among the workspaces TF Buddy created runs for. The workspaces are listed per
+// organization, with their lock state and tags, so an organization takes a TFC call per 100 workspaces.
+func (h *Handler) ListLocks(c echo.Context) error {
+	runs, err := h.rs.ListRuns()
+	if err != nil {
+		return err
+	}
+
+	orgs := []string{}
+	tracked := map[string]bool{}
+	for _, run := range runs {
+		if !tracked[run.Organization] {
+			tracked[run.Organization] = true
+			orgs = append(orgs, run.Organization)
+		}
+		tracked[run.Organization+"/"+run.Workspace] = true
+	}
+	sort.Strings(orgs)
+
+	ctx := context.Background()
+	resp := &Locks{Locks: []*WorkspaceLock{}, Errors: []*OrganizationError{}}
+	for _, org := range orgs {
+		workspaces, err := h.tfc.ListWorkspaces(ctx, org)
+		if err != nil {
+			log.Warn().Err(err).Str("organization", org).Msg("could not list workspaces")
+			resp.Errors = append(resp.Errors, &OrganizationError{Organization: org, Error: err.Error()})
+			continue
+		}
+		for _, ws := range workspaces {
+			if !tracked[org+"/"+ws.Name] {
+				continue
+			}
+			lock := &WorkspaceLock{
+				Organization:  org,
+				Workspace:     ws.Name,
+				Locked:        ws.Locked,
+				MergeRequests: []int{},
+			}
+			for _, tag := range ws.TagNames {
+				if !strings.HasPrefix(tag, lockTagPrefix+"-") {
+					continue
+				}
+				if mrIID, err := strconv.Atoi(strings.TrimPrefix(tag, lockTagPrefix+"-")); err == nil {
+					lock.MergeRequests = append(lock.MergeRequests, mrIID)
+				}
+			}
+			if lock.Locked || len(lock.MergeRequests) > 0 {
+				resp.Locks = append(resp.Locks, lock)
+			}
+		}
+	}
+	sort.Slice(resp.Locks, func(i, j int) bool {
+		if resp.Locks[i].Organization != resp.Locks[j].Organization {
+			return resp.Locks[i].Organization < resp.Locks[j].Organization
+		}
+		return resp.Locks[i].Workspace < resp.Locks[j].Workspace
+	})
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/labstack/echo/v4"
+	"github.com/nats-io/nats.go"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+// RunDetails is a run's metadata together with its current TFC status.
+type RunDetails struct {
+	Metadata runstream.RunMetadata
+	Status   string
+	Timeline []*StatusChange
+	// QueueWaitSeconds is the time the run waited before planning, omitted until the run started planning
+	QueueWaitSeconds float64 `json:",omitempty"`
+	// PlanDurationSeconds is the time the run spent planning, omitted until the plan finished
+	PlanDurationSeconds float64 `json:",omitempty"`
+}
+
+// StatusChange is a run status with the time the run entered it.
+type StatusChange struct {
+	Status string
+	At     time.Time
+}
+
+// ListRuns lists the runs of a project, MR (project & mr), workspace (organization & workspace) or commit, newest
+// first. Without a filter all runs are listed.
+func (h *Handler) ListRuns(c echo.Context) error {
+	project := c.QueryParam("project")
+	org, ws := c.QueryParam("organization"), c.QueryParam("workspace")
+	commit := c.QueryParam("commit")
+
+	var runs []*runstream.RunIndexEntry
+	var err error
+	switch {
+	case project != "" && c.QueryParam("mr") != "":
+		mrIID, convErr := strconv.Atoi(c.QueryParam("mr"))
+		if convErr != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "mr must be a Merge Request IID")
+		}
+		runs, err = h.rs.ListMRRuns(project, mrIID)
+	case project != "":
+		runs, err = h.rs.ListProjectRuns(project)
+	case org != "" && ws != "":
+		runs, err = h.rs.ListWorkspaceRuns(org, ws)
+	case commit != "":
+		runs, err = h.rs.ListCommitRuns(commit)
+	case org == "" && ws == "" && c.QueryParam("mr") == "":
+		runs, err = h.rs.ListRuns()
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "filter runs by project (and mr), organization and workspace, or commit")
+	}
+	if err != nil {
+		return err
+	}
+	if runs == nil {
+		runs = []*runstream.RunIndexEntry{}
+	}
+	return c.JSON(http.StatusOK, runs)
+}
+
+// GetRun shows a run's metadata, its current status and status timeline.
+func (h *Handler) GetRun(c echo.Context) error {
+	runID := c.Param("id")
+	rmd, err := h.rs.GetRunMeta(runID)
+	if err == nats.ErrKeyNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, "run not found")
+	}
+	if err != nil {
+		return err
+	}
+	run, err := h.tfc.GetRun(runID)
+	if err != nil {
+		return err
+	}
+	timeline, err := h.rs.GetRunTimeline(runID)
+	if err != nil {
+		return err
+	}
+
+	details := &RunDetails{
+		Metadata: rmd,
+		Status:   string(run.Status),
+		Timeline: recordedTimeline(timeline),
+	}
+	if len(details.Timeline) == 0 {
+		// runs created before their timeline was recorded only have the TFC timestamps
+		details.Timeline = statusTimeline(run.StatusTimestamps)
+	}
+	if d, ok := timeline.QueueWait(); ok {
+		details.QueueWaitSeconds = d.Seconds()
+	}
+	if d, ok := timeline.PlanDuration(); ok {
+		details.PlanDurationSeconds = d.Seconds()
+	}
+	return c.JSON(http.StatusOK, details)
+}
+
+// recordedTimeline returns the status transitions TF Buddy recorded for the run.
+func recordedTimeline(timeline *runstream.TFRunTimeline) []*StatusChange {
+	changes := []*StatusChange{}
+	for _, tr := range timeline.Transitions {
+		changes = append(changes, &StatusChange{Status: tr.Status, At: tr.At})
+	}
+	return changes
+}
+
+// statusTimeline orders the statuses the run went through by time.
+func statusTimeline(ts *tfe.RunStatusTimestamps) []*StatusChange {
+	timeline := []*StatusChange{}
+	if ts == nil {
+		return timeline
+	}
+	for status, at := range map[tfe.RunStatus]time.Time{
+		tfe.RunPlanQueued:         ts.PlanQueuedAt,
+		tfe.RunPlanning:           ts.PlanningAt,
+		tfe.RunPlanned:            ts.PlannedAt,
+		tfe.RunPlannedAndFinished: ts.PlannedAndFinishedAt,
+		tfe.RunCostEstimating:     ts.CostEstimatingAt,
+		tfe.RunCostEstimated:      ts.CostEstimatedAt,
+		tfe.RunPolicyChecked:      ts.PolicyCheckedAt,
+		tfe.RunPolicySoftFailed:   ts.PolicySoftFailedAt,
+		tfe.RunConfirmed:          ts.ConfirmedAt,
+		tfe.RunApplyQueued:        ts.ApplyQueuedAt,
+		tfe.RunApplying:           ts.ApplyingAt,
+		tfe.RunApplied:            ts.AppliedAt,
+		tfe.RunDiscarded:          ts.DiscardedAt,
+		tfe.RunCanceled:           ts.CanceledAt,
+		tfe.RunErrored:            ts.ErroredAt,
+		tfe.RunPostPlanCompleted:  ts.PostPlanCompletedAt,
+		tfe.RunPrePlanCompleted:   ts.PrePlanCompletedAt,
+		tfe.RunFetching:           ts.FetchingAt,
+		tfe.RunFetchingCompleted:  ts.FetchedAt,
+		tfe.RunPostPlanRunning:    ts.PostPlanRunningAt,
+		tfe.RunPrePlanRunning:     ts.PrePlanRunningAt,
+		tfe.RunQueuing:            ts.QueuingAt,
+	} {
+		if at.IsZero() {
+			continue
+		}
+		timeline = append(timeline, &StatusChange{Status: string(status), At: at})
+	}
+	sort.Slice(timeline, func(i, j int) bool {
+		if timeline[i].At.Equal(timeline[j].At) {
+			return timeline[i].Status < timeline[j].Status
+		}
+		return timeline[i].At.Before(timeline[j].At)
+	})
+	return timeline
+}
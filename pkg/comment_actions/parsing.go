@@ -13,8 +13,9 @@ var (
 )
 
 type CommentOpts struct {
-	Args      CommentArgs `positional-args:"yes" required:"yes"`
-	Workspace string      `short:"w" long:"workspace" description:"A specific terraform Workspace to use" required:"false"`
+	Args         CommentArgs `positional-args:"yes" required:"yes"`
+	Workspace    string      `short:"w" long:"workspace" description:"A specific terraform Workspace to use" required:"false"`
+	AllowDestroy bool        `long:"allow-destroy" description:"Acknowledge that the apply destroys or replaces protected resources" required:"false"`
 }
 
 type CommentArgs struct {
@@ -27,7 +28,7 @@ func ParseCommentCommand(noteBody string) (*CommentOpts, error) {
 	comment := strings.TrimSpace(strings.ToLower(noteBody))
 
 	words := strings.Fields(comment)
-	if len(words) < 2 || len(words) > 4 {
+	if len(words) < 2 || len(words) > 5 {
 		log.Debug().Str("comment", comment[0:10]).Msg("not a tfc command")
 		return nil, ErrNotTFCCommand
 	}
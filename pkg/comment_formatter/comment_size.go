@@ -0,0 +1,196 @@
+package comment_formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
+)
+
+const (
+	// GitlabMaxCommentLength is the maximum number of characters allowed in a Gitlab note.
+	GitlabMaxCommentLength = 1000000
+	// GithubMaxCommentLength is the maximum number of characters allowed in a Github comment.
+	GithubMaxCommentLength = 65536
+
+	// commentOverhead is reserved for the status line, headers, apply instructions & run timeline added around the plan
+	// output.
+	commentOverhead = 2048
+	// maxContinuedComments is the number of replies a plan may be split across before it is only summarized.
+	maxContinuedComments = 5
+)
+
+// MaxCommentLength returns the comment size limit of the VCS provider.
+func MaxCommentLength(vcsProvider string) int {
+	if vcsProvider == "github" {
+		return GithubMaxCommentLength
+	}
+	return GitlabMaxCommentLength
+}
+
+// FormatContinuedComment prefixes a continuation reply with its position in the split comment.
+func FormatContinuedComment(part, total int, body string) string {
+	return fmt.Sprintf(CONTINUED_COMMENT_FORMAT, part, total, body)
+}
+
+// ContinuedCommentsToPost returns the continued comments of a run status update that still have to be posted. They are
+// only posted once per run, not again for repeated status events. They are posted if that can't be checked.
+func ContinuedCommentsToPost(rs runstream.StreamClient, rmd runstream.RunMetadata, continued []string) []string {
+	if len(continued) == 0 {
+		return nil
+	}
+	first, err := rs.MarkContinuedCommentsPosted(rmd.GetRunID())
+	if err != nil {
+		log.Error().Err(err).Str("runID", rmd.GetRunID()).Msg("could not check if the continued comments were posted")
+		return continued
+	}
+	if !first {
+		log.Debug().Str("runID", rmd.GetRunID()).Msg("continued comments already posted")
+		return nil
+	}
+	return continued
+}
+
+// formatPlanChanges renders the plan JSON to fit within limit characters. Oversized plans are reduced in steps:
+// markdown resource lists are collapsed, then the plan is split across several comments and finally only summarized.
+func formatPlanChanges(b []byte, runUrl string, rmd runstream.RunMetadata, templates map[string]string, limit int) []string {
+	planTemplate := customTemplate(PlanTemplateName, templates)
+	summary := func() []string {
+		return []string{terraform_plan.PresentPlanChangesAsMarkdownWithOptions(b, runUrl, terraform_plan.MarkdownOptions{SummaryOnly: true, Template: planTemplate})}
+	}
+
+	if rmd.GetPlanFormat() == terraform_plan.PlanFormatDiff {
+		// the diff can't be collapsed, its code block is split across comments instead
+		rendered := terraform_plan.PresentPlanChangesAsDiff(b, runUrl)
+		if planTemplate != "" {
+			rendered = terraform_plan.PresentPlanChangesAsMarkdownWithOptions(b, runUrl, terraform_plan.MarkdownOptions{Template: planTemplate, Diff: rendered})
+		}
+		return splitPlanChanges(rendered, limit, summary)
+	}
+
+	full := terraform_plan.PresentPlanChangesAsMarkdownWithOptions(b, runUrl, terraform_plan.MarkdownOptions{Template: planTemplate})
+	if len(full) <= limit {
+		return []string{full}
+	}
+
+	collapsed := terraform_plan.PresentPlanChangesAsMarkdownWithOptions(b, runUrl, terraform_plan.MarkdownOptions{Collapsed: true, Template: planTemplate})
+	if len(collapsed) <= limit {
+		log.Debug().Int("length", len(full)).Int("limit", limit).Msg("plan output collapsed to fit comment")
+		return []string{collapsed}
+	}
+	return splitPlanChanges(collapsed, limit, summary)
+}
+
+// splitPlanChanges splits the rendered plan across comments, or only summarizes it if it needs too many comments.
+func splitPlanChanges(rendered string, limit int, summary func() []string) []string {
+	chunks := SplitComment(rendered, limit)
+	if len(chunks) == 1 {
+		return chunks
+	}
+	if len(chunks) <= maxContinuedComments+1 {
+		log.Debug().Int("length", len(rendered)).Int("chunks", len(chunks)).Msg("plan output split across comments")
+		return chunks
+	}
+
+	log.Warn().Int("length", len(rendered)).Int("limit", limit).Msg("plan output too large for comments, posting summary")
+	return summary()
+}
+
+// SplitComment splits body on line boundaries into chunks of at most limit characters. Code fences, lists and
+// <details> elements that are open at a split are closed at the end of the chunk and re-opened in the next one.
+func SplitComment(body string, limit int) []string {
+	if len(body) <= limit {
+		return []string{body}
+	}
+
+	chunks := []string{}
+	open := []string{}
+	sb := &strings.Builder{}
+	for _, line := range strings.SplitAfter(body, "\n") {
+		if len(line) > limit/2 && limit/2 > len(TRUNCATED_LINE_MARKER)+1 {
+			line = strings.ToValidUTF8(line[:limit/2-len(TRUNCATED_LINE_MARKER)-1], "") + TRUNCATED_LINE_MARKER + "\n"
+		}
+
+		// the chunk must still be able to close the blocks left open after this line
+		next := trackOpenBlocks(open, line)
+		if sb.Len() > 0 && sb.Len()+len(line)+len(closingLines(next)) > limit {
+			sb.WriteString(closingLines(open))
+			chunks = append(chunks, sb.String())
+			sb.Reset()
+			for _, o := range open {
+				sb.WriteString(o)
+			}
+		}
+		sb.WriteString(line)
+		open = next
+	}
+	if sb.Len() > 0 {
+		chunks = append(chunks, sb.String())
+	}
+	return chunks
+}
+
+// trackOpenBlocks pushes or pops the opening line of a block that is started or ended by line.
+func trackOpenBlocks(open []string, line string) []string {
+	trimmed := strings.TrimSpace(line)
+	inFence := len(open) > 0 && isCodeFence(open[len(open)-1])
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+
+	switch {
+	case inFence:
+		// a code block is only closed by a bare fence at least as long as the one that opened it
+		if fence := fenceLength(trimmed); fence > 0 && fence == len(trimmed) && fence >= fenceLength(open[len(open)-1]) {
+			return open[:len(open)-1]
+		}
+		return open
+	case isCodeFence(trimmed):
+		return append(open[:len(open):len(open)], line)
+	case trimmed == "<ul>", strings.HasPrefix(trimmed, "<details"):
+		return append(open[:len(open):len(open)], line)
+	case trimmed == "</ul>", trimmed == "</details>":
+		if len(open) > 0 {
+			return open[:len(open)-1]
+		}
+	}
+	return open
+}
+
+func closingLines(open []string) string {
+	sb := &strings.Builder{}
+	for i := len(open) - 1; i >= 0; i-- {
+		trimmed := strings.TrimSpace(open[i])
+		switch {
+		case isCodeFence(trimmed):
+			sb.WriteString(strings.Repeat("`", fenceLength(trimmed)) + "\n")
+		case trimmed == "<ul>":
+			sb.WriteString("</ul>\n")
+		default:
+			sb.WriteString("</details>\n")
+		}
+	}
+	return sb.String()
+}
+
+func isCodeFence(line string) bool {
+	return fenceLength(line) > 0
+}
+
+// fenceLength returns the number of backticks of a code fence line, 0 if the line is not a code fence.
+func fenceLength(line string) int {
+	trimmed := strings.TrimSpace(line)
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, "`"))
+	if n < 3 {
+		return 0
+	}
+	return n
+}
+
+// TRUNCATED_LINE_MARKER ends lines that were cut because they don't fit in a comment.
+const TRUNCATED_LINE_MARKER = " [truncated]"
+
+const CONTINUED_COMMENT_FORMAT = `*Continued (%d/%d)*
+%s`
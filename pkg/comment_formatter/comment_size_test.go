@@ -0,0 +1,114 @@
+package comment_formatter
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
+)
+
+func TestSplitComment(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "fits",
+			body:  "line 1\nline 2\n",
+			limit: 100,
+			want:  []string{"line 1\nline 2\n"},
+		},
+		{
+			name:  "split on lines",
+			body:  "line 1\nline 2\nline 3\n",
+			limit: 14,
+			want:  []string{"line 1\nline 2\n", "line 3\n"},
+		},
+		{
+			name:  "reopen code fence",
+			body:  "```diff\n+ a\n+ b\n+ c\n```\n",
+			limit: 20,
+			want:  []string{"```diff\n+ a\n+ b\n```\n", "```diff\n+ c\n```\n"},
+		},
+		{
+			name:  "reopen details list",
+			body:  "<details><summary>Show</summary>\n<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n</details>\n",
+			limit: 70,
+			want: []string{
+				"<details><summary>Show</summary>\n<ul>\n<li>a</li>\n</ul>\n</details>\n",
+				"<details><summary>Show</summary>\n<ul>\n<li>b</li>\n</ul>\n</details>\n",
+			},
+		},
+		{
+			name:  "reopen longer code fence",
+			body:  "````diff\n+ a\n```\n+ b\n````\n",
+			limit: 24,
+			want:  []string{"````diff\n+ a\n```\n````\n", "````diff\n+ b\n````\n"},
+		},
+		{
+			name:  "truncate long line",
+			body:  "line 1\n" + strings.Repeat("x", 40) + "\n",
+			limit: 45,
+			want:  []string{"line 1\n" + strings.Repeat("x", 9) + TRUNCATED_LINE_MARKER + "\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitComment(tt.body, tt.limit)
+			assert.Equal(t, tt.want, got)
+			for _, chunk := range got {
+				assert.LessOrEqual(t, len(chunk), tt.limit)
+			}
+		})
+	}
+}
+
+func Test_formatPlanChanges(t *testing.T) {
+	plan, err := os.ReadFile("../terraform_plan/testdata/TestPresentPlanChangesAsMarkdown/update-nested.tfplan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "https://app.terraform.io/app/zapier/workspaces/service-a/runs/run-a"
+	markdown := &runstream.TFRunMetadata{PlanFormat: terraform_plan.PlanFormatMarkdown}
+
+	full := formatPlanChanges(plan, url, markdown, nil, GithubMaxCommentLength)
+	assert.Len(t, full, 1)
+	assert.Contains(t, full[0], "forces replacement")
+
+	collapsed := formatPlanChanges(plan, url, markdown, nil, 1000)
+	assert.Len(t, collapsed, 1)
+	assert.Contains(t, collapsed[0], "<details><summary>Show changes</summary>")
+	assert.NotContains(t, collapsed[0], "forces replacement")
+
+	split := formatPlanChanges(plan, url, markdown, nil, 300)
+	assert.Greater(t, len(split), 1)
+	assert.LessOrEqual(t, len(split), maxContinuedComments+1)
+	assert.Contains(t, strings.Join(split, ""), "aws_instance.app")
+
+	diff := formatPlanChanges(plan, url, &runstream.TFRunMetadata{PlanFormat: terraform_plan.PlanFormatDiff}, nil, GithubMaxCommentLength)
+	assert.Len(t, diff, 1)
+	assert.Contains(t, diff[0], "```diff")
+
+	// large diffs stay diffs, their code block is split across comments
+	splitDiff := formatPlanChanges(plan, url, &runstream.TFRunMetadata{PlanFormat: terraform_plan.PlanFormatDiff}, nil, 400)
+	assert.Greater(t, len(splitDiff), 1)
+	for _, chunk := range splitDiff {
+		assert.LessOrEqual(t, len(chunk), 400)
+		assert.NotContains(t, chunk, "<details>")
+	}
+	assert.Contains(t, splitDiff[1], "```diff")
+
+	// a custom plan template wraps the diff
+	customDiff := formatPlanChanges(plan, url, &runstream.TFRunMetadata{PlanFormat: terraform_plan.PlanFormatDiff}, map[string]string{PlanTemplateName: "{{ .ReplacementCount }} replaced{{ .Diff }}"}, GithubMaxCommentLength)
+	assert.Len(t, customDiff, 1)
+	assert.True(t, strings.HasPrefix(customDiff[0], "1 replaced\n```diff"), customDiff[0])
+
+	summary := formatPlanChanges(plan, url, markdown, nil, 60)
+	assert.Len(t, summary, 1)
+	assert.Contains(t, summary[0], "The plan is too large to be displayed in a comment.")
+}
@@ -0,0 +1,32 @@
+package comment_formatter
+
+import (
+	"fmt"
+
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+// FormatMRSummaryBody renders the consolidated status table for all workspaces triggered for a MR commit, with the
+// project templates of the run that updated it.
+func FormatMRSummaryBody(summary *runstream.TFMRSummary, templates map[string]string) string {
+	data := MRSummaryTemplateData{
+		CommitSHA:                summary.CommitSHA,
+		ProjectNameWithNamespace: summary.ProjectNameWithNamespace,
+		MergeRequestIID:          summary.MergeRequestIID,
+		Workspaces:               summary.SortedWorkspaces(),
+	}
+	if delta, ok := summary.DeltaMonthlyCost(); ok {
+		data.DeltaMonthlyCost = formatCostDelta(delta)
+		data.CostThreshold = fmt.Sprintf("$%.2f", summary.CostThreshold)
+		data.CostThresholdExceeded = summary.CostThresholdExceeded()
+	}
+	return renderTemplate(MRSummaryTemplateName, templates, data)
+}
+
+// formatCostDelta formats a monthly cost change in USD with its sign.
+func formatCostDelta(delta float64) string {
+	if delta < 0 {
+		return fmt.Sprintf("-$%.2f", -delta)
+	}
+	return fmt.Sprintf("+$%.2f", delta)
+}
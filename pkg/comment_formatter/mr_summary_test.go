@@ -0,0 +1,67 @@
+package comment_formatter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+func TestFormatMRSummaryBody(t *testing.T) {
+	summary := &runstream.TFMRSummary{CommitSHA: "abcd1234"}
+	summary.SetWorkspaceRun(&runstream.WorkspaceRunSummary{
+		Organization: "zapier",
+		Workspace:    "service-b",
+		RunID:        "run-b",
+		RunURL:       "https://app.terraform.io/app/zapier/workspaces/service-b/runs/run-b",
+		Action:       "apply",
+		Status:       "applying",
+		Changes:      2,
+	})
+	summary.SetWorkspaceRun(&runstream.WorkspaceRunSummary{
+		Organization: "zapier",
+		Workspace:    "service-a",
+		RunID:        "run-a",
+		RunURL:       "https://app.terraform.io/app/zapier/workspaces/service-a/runs/run-a",
+		Action:       "plan",
+		Status:       "planned_and_finished",
+		Additions:    1,
+		Destructions: 3,
+	})
+
+	want := `
+### Terraform Cloud Summary
+**Commit**: ` + "`abcd1234`" + `
+
+| Workspace | Command | Status | Add | Change | Destroy | Run |
+| --- | --- | --- | --- | --- | --- | --- |
+| ` + "`zapier/service-a`" + ` | plan | ` + "`planned_and_finished`" + ` | 1 | 0 | 3 | [run-a](https://app.terraform.io/app/zapier/workspaces/service-a/runs/run-a) |
+| ` + "`zapier/service-b`" + ` | apply | ` + "`applying`" + ` | 0 | 2 | 0 | [run-b](https://app.terraform.io/app/zapier/workspaces/service-b/runs/run-b) |
+`
+	assert.Equal(t, want, FormatMRSummaryBody(summary, nil))
+}
+
+func TestFormatMRSummaryBody_Cost(t *testing.T) {
+	summary := &runstream.TFMRSummary{CommitSHA: "abcd1234", CostThreshold: 100}
+	summary.SetWorkspaceRun(&runstream.WorkspaceRunSummary{
+		Organization:     "zapier",
+		Workspace:        "service-a",
+		RunID:            "run-a",
+		RunURL:           "https://app.terraform.io/app/zapier/workspaces/service-a/runs/run-a",
+		Action:           "plan",
+		Status:           "planned_and_finished",
+		Additions:        1,
+		DeltaMonthlyCost: "72.5",
+	})
+	assert.Contains(t, FormatMRSummaryBody(summary, nil), "\n**Monthly cost change**: `+$72.50`\n")
+	assert.NotContains(t, FormatMRSummaryBody(summary, nil), "exceeds the threshold")
+
+	summary.SetWorkspaceRun(&runstream.WorkspaceRunSummary{
+		Organization:     "zapier",
+		Workspace:        "service-b",
+		RunID:            "run-b",
+		DeltaMonthlyCost: "40",
+	})
+	assert.Contains(t, FormatMRSummaryBody(summary, nil), "\n**Monthly cost change**: `+$112.50`\n")
+	assert.Contains(t, FormatMRSummaryBody(summary, nil), ":warning: The monthly cost increase exceeds the threshold of `$100.00`")
+}
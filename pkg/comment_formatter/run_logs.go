@@ -0,0 +1,158 @@
+package comment_formatter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+)
+
+// maxErrorLogLength limits the error excerpt, the full log is available in TFC.
+const maxErrorLogLength = 8000
+
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// RunErrorLog downloads the log of the errored plan or apply of the run, and returns its `Error:` diagnostics.
+func RunErrorLog(tfc tfc_api.ApiClient, run *tfe.Run) string {
+	ctx := context.Background()
+	var b []byte
+	var err error
+	if run.Apply != nil && run.Apply.ID != "" && run.Apply.Status == tfe.ApplyErrored {
+		b, err = tfc.GetApplyLogs(ctx, run.Apply.ID)
+	} else if run.Plan != nil && run.Plan.ID != "" {
+		b, err = tfc.GetPlanLogs(ctx, run.Plan.ID)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("runID", run.ID).Msg("could not get run logs")
+		return ""
+	}
+	return ErrorLogExcerpt(string(b))
+}
+
+// ErrorLogExcerpt strips ANSI codes from a Terraform log and extracts its error diagnostics, e.g.
+//
+//	╷
+//	│ Error: Invalid reference
+//	│
+//	│   on main.tf line 3:
+//	╵
+//
+// Structured logs, with a JSON object per line, are rendered the same way.
+func ErrorLogExcerpt(logs string) string {
+	lines := strings.Split(strings.ReplaceAll(ansiRegex.ReplaceAllString(logs, ""), "\r\n", "\n"), "\n")
+
+	diagnostics := jsonLogDiagnostics(lines)
+	var block []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "╷"):
+			block = []string{}
+		case strings.HasPrefix(trimmed, "╵") && block != nil:
+			if len(block) > 0 && strings.HasPrefix(block[0], "Error:") {
+				diagnostics = append(diagnostics, strings.TrimRight(strings.Join(block, "\n"), "\n "))
+			}
+			block = nil
+		case block != nil:
+			line = strings.TrimPrefix(trimmed, "│")
+			block = append(block, strings.TrimPrefix(line, " "))
+		}
+	}
+
+	// logs of older Terraform versions have no diagnostic boxes
+	if len(diagnostics) == 0 {
+		for i, line := range lines {
+			if strings.HasPrefix(strings.TrimSpace(line), "Error:") {
+				diagnostics = append(diagnostics, strings.TrimSpace(strings.Join(lines[i:], "\n")))
+				break
+			}
+		}
+	}
+
+	excerpt := strings.Join(diagnostics, "\n\n")
+	if len(excerpt) > maxErrorLogLength {
+		// cut on a rune boundary, so the excerpt stays valid UTF-8
+		cut := maxErrorLogLength
+		for cut > 0 && !utf8.RuneStart(excerpt[cut]) {
+			cut--
+		}
+		excerpt = excerpt[:cut] + "\n... (truncated)"
+	}
+	return excerpt
+}
+
+// jsonLogLine is a line of a structured Terraform log, only error diagnostics are read.
+type jsonLogLine struct {
+	Type       string `json:"type"`
+	Diagnostic *struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+		Detail   string `json:"detail"`
+		Range    *struct {
+			Filename string `json:"filename"`
+			Start    struct {
+				Line int `json:"line"`
+			} `json:"start"`
+		} `json:"range"`
+		Snippet *struct {
+			Context   string `json:"context"`
+			Code      string `json:"code"`
+			StartLine int    `json:"start_line"`
+		} `json:"snippet"`
+	} `json:"diagnostic"`
+}
+
+// jsonLogDiagnostics renders the error diagnostics of a structured log like the human readable log does.
+func jsonLogDiagnostics(lines []string) []string {
+	diagnostics := []string{}
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "{") {
+			continue
+		}
+		entry := &jsonLogLine{}
+		if err := json.Unmarshal([]byte(line), entry); err != nil {
+			continue
+		}
+		d := entry.Diagnostic
+		if entry.Type != "diagnostic" || d == nil || d.Severity != "error" {
+			continue
+		}
+
+		sb := &strings.Builder{}
+		sb.WriteString("Error: " + d.Summary)
+		if d.Range != nil {
+			sb.WriteString(fmt.Sprintf("\n\n  on %s line %d", d.Range.Filename, d.Range.Start.Line))
+			if d.Snippet != nil && d.Snippet.Context != "" {
+				sb.WriteString(", in " + d.Snippet.Context)
+			}
+			sb.WriteString(":")
+			if d.Snippet != nil {
+				sb.WriteString(fmt.Sprintf("\n  %d: %s", d.Snippet.StartLine, d.Snippet.Code))
+			}
+		}
+		if d.Detail != "" {
+			sb.WriteString("\n\n" + d.Detail)
+		}
+		diagnostics = append(diagnostics, sb.String())
+	}
+	return diagnostics
+}
+
+// FormatErrorLog renders the error excerpt of a run log in a collapsed code block, like the failed plan template.
+func FormatErrorLog(excerpt string) string {
+	if excerpt == "" {
+		return ""
+	}
+	out, err := executeTemplate(FailedPlanTemplateName, errorLogBlock, StatusTemplateData{ErrorLog: excerpt})
+	if err != nil {
+		log.Error().Err(err).Msg("could not render error log")
+		return ""
+	}
+	return out + "\n"
+}
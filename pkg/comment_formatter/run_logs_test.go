@@ -0,0 +1,124 @@
+package comment_formatter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+)
+
+const erroredPlanLog = "Terraform v1.3.6\non linux_amd64\nInitializing plugins and modules...\n" +
+	"\x1b[0m\x1b[1mrandom_pet.rando: Refreshing state... [id=heroic-dane]\x1b[0m\n" +
+	"\x1b[31m╷\x1b[0m\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\x1b[1m\x1b[31mError: \x1b[0m\x1b[0m\x1b[1mReference to undeclared resource\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\x1b[0m  on main.tf line 12, in resource \"random_integer\" \"pet_length\":\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m  12:   max = random_pet.missing.length\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0mA managed resource \"random_pet\" \"missing\" has not been declared in the root module.\n" +
+	"\x1b[31m╵\x1b[0m\x1b[0m\n" +
+	"\x1b[33m╷\x1b[0m\x1b[0m\n" +
+	"\x1b[33m│\x1b[0m \x1b[0m\x1b[1m\x1b[33mWarning: \x1b[0m\x1b[0m\x1b[1mDeprecated attribute\x1b[0m\n" +
+	"\x1b[33m╵\x1b[0m\x1b[0m\n"
+
+func TestErrorLogExcerpt(t *testing.T) {
+	tests := []struct {
+		name string
+		logs string
+		want string
+	}{
+		{
+			name: "diagnostics",
+			logs: erroredPlanLog,
+			want: "Error: Reference to undeclared resource\n\n" +
+				"  on main.tf line 12, in resource \"random_integer\" \"pet_length\":\n" +
+				"  12:   max = random_pet.missing.length\n\n" +
+				"A managed resource \"random_pet\" \"missing\" has not been declared in the root module.",
+		},
+		{
+			name: "legacy",
+			logs: "Terraform v0.12.31\n\nError: Missing required argument\n\n  on main.tf line 1:\n",
+			want: "Error: Missing required argument\n\n  on main.tf line 1:",
+		},
+		{
+			name: "structured",
+			logs: `{"@level":"info","@message":"Terraform 1.3.6","@module":"terraform.ui","type":"version"}
+{"@level":"warn","@message":"Warning: Deprecated attribute","type":"diagnostic","diagnostic":{"severity":"warning","summary":"Deprecated attribute","detail":""}}
+{"@level":"error","@message":"Error: Reference to undeclared resource","type":"diagnostic","diagnostic":{"severity":"error","summary":"Reference to undeclared resource","detail":"A managed resource \"random_pet\" \"missing\" has not been declared in the root module.","range":{"filename":"main.tf","start":{"line":12,"column":9,"byte":200},"end":{"line":12,"column":35,"byte":226}},"snippet":{"context":"resource \"random_integer\" \"pet_length\"","code":"  max = random_pet.missing.length","start_line":12,"highlight_start_offset":8,"highlight_end_offset":34,"values":[]}}}
+`,
+			want: "Error: Reference to undeclared resource\n\n" +
+				"  on main.tf line 12, in resource \"random_integer\" \"pet_length\":\n" +
+				"  12:   max = random_pet.missing.length\n\n" +
+				"A managed resource \"random_pet\" \"missing\" has not been declared in the root module.",
+		},
+		{
+			name: "no errors",
+			logs: "Terraform v1.3.6\nOperation failed: failed running terraform plan (exit 1)\n",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ErrorLogExcerpt(tt.logs))
+		})
+	}
+}
+
+func TestErrorLogExcerpt_Truncated(t *testing.T) {
+	// the multi-byte rune straddles the length limit
+	logs := "Error: " + strings.Repeat("x", maxErrorLogLength-len("Error: ")-1) + "é and more"
+	got := ErrorLogExcerpt(logs)
+	assert.True(t, utf8.ValidString(got))
+	assert.True(t, strings.HasSuffix(got, "x\n... (truncated)"), got[len(got)-30:])
+}
+
+// runLogsClient returns the same log for all plans & applies, other API calls are not implemented.
+type runLogsClient struct {
+	tfc_api.ApiClient
+	logs string
+}
+
+func (c *runLogsClient) GetPlanLogs(ctx context.Context, planID string) ([]byte, error) {
+	return []byte(c.logs), nil
+}
+
+func (c *runLogsClient) GetApplyLogs(ctx context.Context, applyID string) ([]byte, error) {
+	return []byte(c.logs), nil
+}
+
+func TestFormatRunStatusCommentBody_Errored(t *testing.T) {
+	run := &tfe.Run{
+		ID:     "run-123",
+		Status: tfe.RunErrored,
+		Plan:   &tfe.Plan{ID: "plan-123", Status: tfe.PlanErrored},
+		Workspace: &tfe.Workspace{
+			Name:         "service-a",
+			Organization: &tfe.Organization{Name: "zapier"},
+		},
+	}
+
+	main, _, _, _ := FormatRunStatusCommentBody(&runLogsClient{logs: erroredPlanLog}, nil, run, &runstream.TFRunMetadata{Action: "plan"})
+	assert.Equal(t, "\n<details><summary>Error output</summary>\n\n```\n"+
+		"Error: Reference to undeclared resource\n\n"+
+		"  on main.tf line 12, in resource \"random_integer\" \"pet_length\":\n"+
+		"  12:   max = random_pet.missing.length\n\n"+
+		"A managed resource \"random_pet\" \"missing\" has not been declared in the root module.\n"+
+		"```\n\n</details>\n*Click Terraform Cloud URL to see detailed plan output*\n", main)
+
+	run.Apply = &tfe.Apply{ID: "apply-123", Status: tfe.ApplyErrored}
+	main, _, _, _ = FormatRunStatusCommentBody(&runLogsClient{logs: erroredPlanLog}, nil, run, &runstream.TFRunMetadata{Action: "apply"})
+	assert.Contains(t, main, "Error: Reference to undeclared resource")
+	assert.Contains(t, main, "*Click Terraform Cloud URL to see detailed apply output*")
+
+	// errored applies without diagnostics keep the short status comment
+	main, _, _, _ = FormatRunStatusCommentBody(&runLogsClient{}, nil, run, &runstream.TFRunMetadata{Action: "apply"})
+	assert.Empty(t, main)
+
+	main, _, _, _ = FormatRunStatusCommentBody(&runLogsClient{}, nil, run, &runstream.TFRunMetadata{Action: "plan"})
+	assert.Equal(t, "\n*Click Terraform Cloud URL to see detailed plan output*\n", main)
+}
@@ -0,0 +1,52 @@
+package comment_formatter
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+// RunTimelineInfo renders the status timeline of a run once it has finished, or an empty string while it is running.
+func RunTimelineInfo(rs runstream.StreamClient, run *tfe.Run, rmd runstream.RunMetadata) string {
+	switch run.Status {
+	case tfe.RunApplied, tfe.RunPlannedAndFinished, tfe.RunErrored, tfe.RunDiscarded, tfe.RunCanceled:
+	default:
+		return ""
+	}
+	timeline, err := rs.GetRunTimeline(run.ID)
+	if err != nil {
+		log.Error().Err(err).Str("runID", run.ID).Msg("could not get run timeline")
+		return ""
+	}
+	return FormatRunTimeline(timeline, ProjectTemplates(rs, rmd))
+}
+
+// FormatRunTimeline renders the statuses of a run with the time spent in each, in a collapsed section.
+func FormatRunTimeline(timeline *runstream.TFRunTimeline, templates map[string]string) string {
+	if len(timeline.Transitions) < 2 {
+		return ""
+	}
+
+	data := RunTimelineTemplateData{RunID: timeline.RunID}
+	if d, ok := timeline.QueueWait(); ok {
+		data.QueueWait = formatDuration(d)
+	}
+	if d, ok := timeline.PlanDuration(); ok {
+		data.PlanDuration = formatDuration(d)
+	}
+	for i, tr := range timeline.Transitions {
+		row := &RunTimelineRow{Status: tr.Status, Since: tr.At.UTC().Format(time.RFC3339)}
+		if i < len(timeline.Transitions)-1 {
+			row.Duration = formatDuration(timeline.Transitions[i+1].At.Sub(tr.At))
+		}
+		data.Transitions = append(data.Transitions, row)
+	}
+	return renderTemplate(RunTimelineTemplateName, templates, data)
+}
+
+// formatDuration rounds a duration to the second, e.g. 1m3s.
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,33 @@
+package comment_formatter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+func TestFormatRunTimeline(t *testing.T) {
+	created := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	timeline := &runstream.TFRunTimeline{RunID: "run-1", Transitions: []*runstream.StatusTransition{
+		{Status: "pending", At: created},
+	}}
+	assert.Empty(t, FormatRunTimeline(timeline, nil))
+
+	timeline.Transitions = append(timeline.Transitions,
+		&runstream.StatusTransition{Status: "planning", At: created.Add(12 * time.Second)},
+		&runstream.StatusTransition{Status: "planned_and_finished", At: created.Add(75 * time.Second)},
+	)
+	assert.Equal(t, `
+<details><summary>Run timeline (queued 12s, planned in 1m3s)</summary>
+
+| Status | Since | Duration |
+| --- | --- | --- |
+| `+"`pending`"+` | 2022-12-01T10:00:00Z | 12s |
+| `+"`planning`"+` | 2022-12-01T10:00:12Z | 1m3s |
+| `+"`planned_and_finished`"+` | 2022-12-01T10:01:15Z |  |
+
+</details>
+`, FormatRunTimeline(timeline, nil))
+}
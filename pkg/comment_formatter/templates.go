@@ -0,0 +1,504 @@
+package comment_formatter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+)
+
+// TemplateDirEnvName is a directory of `<name>.tpl` files overriding the default comment templates for all projects.
+const TemplateDirEnvName = "TFBUDDY_TEMPLATE_DIR"
+
+// Names of the templates that can be overridden on the server or per project in .tfbuddy.yaml.
+const (
+	// PlanTemplateName renders the plan changes, see terraform_plan.PlanTemplateData.
+	PlanTemplateName = "plan"
+	// RunDetailsTemplateName renders the top level note of a run's discussion thread.
+	RunDetailsTemplateName = "run_details"
+	// RunSummaryTemplateName renders the resource counts of a planned or applied run.
+	RunSummaryTemplateName = "run_summary"
+	// HowToApplyTemplateName renders the instructions to apply a plan.
+	HowToApplyTemplateName = "how_to_apply"
+	// FailedPlanTemplateName renders the message for an errored plan or apply.
+	FailedPlanTemplateName = "failed_plan"
+	// ProtectedResourcesTemplateName renders the warning for a plan that destroys or replaces protected resources.
+	ProtectedResourcesTemplateName = "protected_resources"
+	// PolicyResultsTemplateName renders the failures & warnings of the Rego policies evaluated against the plan.
+	PolicyResultsTemplateName = "policy_results"
+	// CostEstimateTemplateName renders the monthly cost estimate of a run.
+	CostEstimateTemplateName = "cost_estimate"
+	// PolicyChecksTemplateName renders the results of the TFC Sentinel & OPA policies evaluated against the run.
+	PolicyChecksTemplateName = "policy_checks"
+	// PolicySoftFailedTemplateName renders the message for a run waiting for soft failed policies to be overridden, when
+	// the policy checks can't be listed.
+	PolicySoftFailedTemplateName = "policy_soft_failed"
+	// HowToConfirmTemplateName renders the instructions to confirm or discard an apply waiting for confirmation.
+	HowToConfirmTemplateName = "how_to_confirm"
+	// AutoApplyTemplateName renders the notice for a planning run that is applied automatically.
+	AutoApplyTemplateName = "auto_apply"
+	// MRSummaryTemplateName renders the consolidated MR summary note, see MRSummaryTemplateData.
+	MRSummaryTemplateName = "mr_summary"
+	// RunTimelineTemplateName renders the status timeline of a finished run, see RunTimelineTemplateData.
+	RunTimelineTemplateName = "run_timeline"
+)
+
+var TemplateNames = []string{
+	PlanTemplateName,
+	RunDetailsTemplateName,
+	RunSummaryTemplateName,
+	HowToApplyTemplateName,
+	FailedPlanTemplateName,
+	ProtectedResourcesTemplateName,
+	PolicyResultsTemplateName,
+	CostEstimateTemplateName,
+	PolicyChecksTemplateName,
+	PolicySoftFailedTemplateName,
+	HowToConfirmTemplateName,
+	AutoApplyTemplateName,
+	MRSummaryTemplateName,
+	RunTimelineTemplateName,
+}
+
+var defaultTemplates = map[string]string{
+	RunDetailsTemplateName:         DEFAULT_RUN_DETAILS_TEMPLATE,
+	RunSummaryTemplateName:         DEFAULT_RUN_SUMMARY_TEMPLATE,
+	HowToApplyTemplateName:         DEFAULT_HOW_TO_APPLY_TEMPLATE,
+	FailedPlanTemplateName:         DEFAULT_FAILED_PLAN_TEMPLATE,
+	ProtectedResourcesTemplateName: DEFAULT_PROTECTED_RESOURCES_TEMPLATE,
+	PolicyResultsTemplateName:      DEFAULT_POLICY_RESULTS_TEMPLATE,
+	CostEstimateTemplateName:       DEFAULT_COST_ESTIMATE_TEMPLATE,
+	PolicyChecksTemplateName:       DEFAULT_POLICY_CHECKS_TEMPLATE,
+	PolicySoftFailedTemplateName:   DEFAULT_POLICY_SOFT_FAILED_TEMPLATE,
+	HowToConfirmTemplateName:       DEFAULT_HOW_TO_CONFIRM_TEMPLATE,
+	AutoApplyTemplateName:          DEFAULT_AUTO_APPLY_TEMPLATE,
+	MRSummaryTemplateName:          DEFAULT_MR_SUMMARY_TEMPLATE,
+	RunTimelineTemplateName:        DEFAULT_RUN_TIMELINE_TEMPLATE,
+}
+
+// StatusTemplateData is the data available to the run status templates.
+type StatusTemplateData struct {
+	// Organization & Workspace are the TFC names of the run's workspace
+	Organization string
+	Workspace    string
+
+	// RunID, RunURL & Status describe the TFC run
+	RunID     string
+	RunURL    string
+	Status    string
+	AutoApply bool
+
+	// Action is the triggered action (i.e. plan / apply)
+	Action string
+
+	// Additions, Changes & Destructions are the resource counts of the plan, or of the apply once it has completed
+	Additions    int
+	Changes      int
+	Destructions int
+
+	// CommitSHA, ProjectNameWithNamespace & MergeRequestIID identify the MR the run was triggered for
+	CommitSHA                string
+	ProjectNameWithNamespace string
+	MergeRequestIID          int
+
+	// ProtectedResources are the addresses of the protected resources the plan destroys or replaces. ApplyBlocked is
+	// set when the apply run was discarded because of them, AllowDestroy when they were acknowledged.
+	ProtectedResources []string
+	ApplyBlocked       bool
+	AllowDestroy       bool
+
+	// PolicyDenials & PolicyWarnings are the messages of the policy rules that matched the plan
+	PolicyDenials  []string
+	PolicyWarnings []string
+
+	// PriorMonthlyCost, ProposedMonthlyCost & DeltaMonthlyCost are the formatted monthly cost estimate of the run
+	PriorMonthlyCost    string
+	ProposedMonthlyCost string
+	DeltaMonthlyCost    string
+
+	// PolicyChecks are the results of the TFC policies evaluated against the run. PolicyOverridable is set when the
+	// run waits for soft failed policies to be overridden.
+	PolicyChecks      []*tfc_api.PolicyOutcome
+	PolicyOverridable bool
+
+	// ErrorLog holds the error diagnostics of an errored plan or apply log
+	ErrorLog string
+}
+
+// MRSummaryTemplateData is the data available to the MR summary template.
+type MRSummaryTemplateData struct {
+	// CommitSHA, ProjectNameWithNamespace & MergeRequestIID identify the MR commit the summarised runs were triggered for
+	CommitSHA                string
+	ProjectNameWithNamespace string
+	MergeRequestIID          int
+
+	// Workspaces are the latest runs of each workspace, ordered by organization & workspace name
+	Workspaces []*runstream.WorkspaceRunSummary
+
+	// DeltaMonthlyCost is the formatted total monthly cost change, empty if no run has a cost estimate.
+	// CostThresholdExceeded is set when it is above the project's formatted CostThreshold.
+	DeltaMonthlyCost      string
+	CostThreshold         string
+	CostThresholdExceeded bool
+}
+
+// RunTimelineTemplateData is the data available to the run timeline template.
+type RunTimelineTemplateData struct {
+	RunID string
+	// QueueWait & PlanDuration are the formatted time the run waited before planning & the time it took to plan,
+	// empty if unknown
+	QueueWait    string
+	PlanDuration string
+	// Transitions are the statuses of the run, oldest first
+	Transitions []*RunTimelineRow
+}
+
+// RunTimelineRow is a status of a run, with the time spent in it. Duration is empty for the last status.
+type RunTimelineRow struct {
+	Status   string
+	Since    string
+	Duration string
+}
+
+func newStatusTemplateData(org, wsName, runUrl, runID, status string, autoApply bool, rmd runstream.RunMetadata) StatusTemplateData {
+	return StatusTemplateData{
+		Organization:             org,
+		Workspace:                wsName,
+		RunID:                    runID,
+		RunURL:                   runUrl,
+		Status:                   status,
+		AutoApply:                autoApply,
+		Action:                   rmd.GetAction(),
+		CommitSHA:                rmd.GetCommitSHA(),
+		ProjectNameWithNamespace: rmd.GetMRProjectNameWithNamespace(),
+		MergeRequestIID:          rmd.GetMRInternalID(),
+	}
+}
+
+var (
+	serverTemplatesOnce sync.Once
+	serverTemplates     map[string]string
+)
+
+// LoadServerTemplates reads & validates the templates in TFBUDDY_TEMPLATE_DIR. Invalid templates are logged and
+// the defaults are used instead.
+func LoadServerTemplates() map[string]string {
+	serverTemplatesOnce.Do(func() {
+		serverTemplates = readTemplateDir(os.Getenv(TemplateDirEnvName))
+	})
+	return serverTemplates
+}
+
+func readTemplateDir(dir string) map[string]string {
+	templates := map[string]string{}
+	if dir == "" {
+		return templates
+	}
+	for _, name := range TemplateNames {
+		filename := filepath.Join(dir, name+".tpl")
+		b, err := os.ReadFile(filename)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			log.Error().Err(err).Str("file", filename).Msg("could not read template, using the default")
+			continue
+		}
+		if err := ValidateTemplate(name, string(b)); err != nil {
+			log.Error().Err(err).Str("file", filename).Msg("invalid template, using the default")
+			continue
+		}
+		log.Info().Str("file", filename).Msg("loaded custom template")
+		templates[name] = string(b)
+	}
+	return templates
+}
+
+// ValidateTemplate checks that the template parses and renders against sample data.
+func ValidateTemplate(name, text string) error {
+	if name == PlanTemplateName {
+		return terraform_plan.ValidateMarkdownTemplate(text)
+	}
+	if _, ok := defaultTemplates[name]; !ok {
+		return fmt.Errorf("unknown template %q", name)
+	}
+	_, err := executeTemplate(name, text, sampleTemplateData(name))
+	return err
+}
+
+// sampleTemplateData returns the data templates are validated against.
+func sampleTemplateData(name string) interface{} {
+	switch name {
+	case MRSummaryTemplateName:
+		return MRSummaryTemplateData{
+			CommitSHA:                "abcd1234",
+			ProjectNameWithNamespace: "example-group/example-project",
+			MergeRequestIID:          1,
+			Workspaces: []*runstream.WorkspaceRunSummary{{
+				Organization: "example-org",
+				Workspace:    "example-workspace",
+				RunID:        "run-example",
+				RunURL:       "https://app.terraform.io/app/example-org/workspaces/example-workspace/runs/run-example",
+				Action:       "plan",
+				Status:       "planned_and_finished",
+			}},
+			DeltaMonthlyCost:      "+$10.00",
+			CostThreshold:         "$5.00",
+			CostThresholdExceeded: true,
+		}
+	case RunTimelineTemplateName:
+		return RunTimelineTemplateData{
+			RunID:        "run-example",
+			QueueWait:    "12s",
+			PlanDuration: "1m3s",
+			Transitions: []*RunTimelineRow{
+				{Status: "pending", Since: "2022-12-01T10:00:00Z", Duration: "12s"},
+				{Status: "planned_and_finished", Since: "2022-12-01T10:00:12Z"},
+			},
+		}
+	}
+	return StatusTemplateData{
+		Organization:       "example-org",
+		Workspace:          "example-workspace",
+		RunID:              "run-example",
+		RunURL:             "https://app.terraform.io/app/example-org/workspaces/example-workspace/runs/run-example",
+		Status:             "planned",
+		Action:             "plan",
+		ProtectedResources: []string{"aws_db_instance.example"},
+		PolicyDenials:      []string{"aws_db_instance.example must be encrypted"},
+		PolicyWarnings:     []string{"aws_db_instance.example has no backups"},
+	}
+}
+
+// TemplateStore holds the project templates referenced by the run metadata, see runstream.StreamClient.
+type TemplateStore interface {
+	GetTemplate(ref string) (string, error)
+}
+
+// storedTemplates caches the project templates read from the TemplateStore, they are stored by content so a reference
+// always resolves to the same text.
+var storedTemplates sync.Map
+
+// ProjectTemplates returns the project's custom templates of a run, keyed by template name. Templates that can't be
+// read are logged and the server or default template is used instead.
+func ProjectTemplates(ts TemplateStore, rmd runstream.RunMetadata) map[string]string {
+	refs := rmd.GetTemplateRefs()
+	if len(refs) == 0 || ts == nil {
+		return nil
+	}
+	templates := map[string]string{}
+	for name, ref := range refs {
+		if text, ok := storedTemplates.Load(ref); ok {
+			templates[name] = text.(string)
+			continue
+		}
+		text, err := ts.GetTemplate(ref)
+		if err != nil {
+			log.Error().Err(err).Str("template", name).Str("runID", rmd.GetRunID()).Msg("could not read project template, using the default")
+			continue
+		}
+		storedTemplates.Store(ref, text)
+		templates[name] = text
+	}
+	return templates
+}
+
+// customTemplate returns the project template, or the server template, if one is configured.
+func customTemplate(name string, templates map[string]string) string {
+	if text := templates[name]; text != "" {
+		return text
+	}
+	return LoadServerTemplates()[name]
+}
+
+// renderTemplate renders the named template with the project templates, falling back to the default if the custom one
+// fails.
+func renderTemplate(name string, templates map[string]string, data interface{}) string {
+	if text := customTemplate(name, templates); text != "" {
+		out, err := executeTemplate(name, text, data)
+		if err == nil {
+			return out
+		}
+		log.Warn().Err(err).Str("template", name).Msg("could not render custom template, using the default")
+	}
+
+	out, err := executeTemplate(name, defaultTemplates[name], data)
+	if err != nil {
+		log.Error().Err(err).Str("template", name).Msg("could not render template")
+	}
+	return out
+}
+
+func executeTemplate(name, text string, data interface{}) (string, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+const DEFAULT_RUN_DETAILS_TEMPLATE = `
+### Terraform Cloud
+**Workspace**: ` + "`{{ .Workspace }}`" + `<br>
+**Command**: {{ .Action }} <br>
+**Status**: ` + "`{{ .Status }}`" + `<br>
+**Run URL**: [{{ .RunURL }}]({{ .RunURL }}) <br>
+`
+
+// errorLogBlock renders the error diagnostics of a run log in a collapsed code block.
+const errorLogBlock = `
+<details><summary>Error output</summary>
+
+` + "```" + `
+{{ .ErrorLog }}
+` + "```" + `
+
+</details>`
+
+const DEFAULT_FAILED_PLAN_TEMPLATE = `
+{{- if .ErrorLog }}` + errorLogBlock + `
+{{- end }}
+*Click Terraform Cloud URL to see detailed {{ if eq .Action "apply" }}apply{{ else }}plan{{ end }} output*
+`
+
+const DEFAULT_RUN_SUMMARY_TEMPLATE = `
+  * Additions: {{ .Additions }}
+  * Changes: {{ .Changes }}
+  * Destructions: {{ .Destructions }}`
+
+const DEFAULT_HOW_TO_APPLY_TEMPLATE = `
+
+---
+* To **apply** the plan for all workspaces, comment:
+	> ` + "`tfc apply`" + `
+
+* To **apply** the plan for this workspace only, comment:
+	> ` + "`tfc apply -w {{ .Workspace }}`" + `
+
+Remember to **merge** the MR once the apply has succeeded`
+
+const DEFAULT_PROTECTED_RESOURCES_TEMPLATE = `
+{{ if .ApplyBlocked -}}
+:no_entry: **Apply blocked, the plan destroys or replaces protected resources:**
+{{- else -}}
+:warning: **The plan destroys or replaces protected resources:**
+{{- end }}
+{{- range .ProtectedResources }}
+  * ` + "`{{ . }}`" + `
+{{- end }}
+
+{{ if .ApplyBlocked -}}
+The run has been discarded. To apply anyway, comment:
+	> ` + "`tfc apply -w {{ .Workspace }} --allow-destroy`" + `
+{{- else if .AllowDestroy -}}
+The destruction was acknowledged with ` + "`--allow-destroy`" + `.
+{{- else -}}
+To apply, the destruction must be acknowledged with:
+	> ` + "`tfc apply -w {{ .Workspace }} --allow-destroy`" + `
+{{- end }}
+
+`
+
+const DEFAULT_POLICY_RESULTS_TEMPLATE = `
+{{ if .PolicyDenials -}}
+:no_entry: **The plan fails policy checks:**
+{{- else -}}
+:warning: **The plan has policy warnings:**
+{{- end }}
+{{- range .PolicyDenials }}
+  * :x: {{ . }}
+{{- end }}
+{{- range .PolicyWarnings }}
+  * :warning: {{ . }}
+{{- end }}
+{{ if .ApplyBlocked }}
+The run has been discarded, the policy failures must be fixed to apply.
+{{ else if .PolicyDenials }}
+Applying is blocked until the policy failures are fixed.
+{{ end }}
+`
+
+const DEFAULT_COST_ESTIMATE_TEMPLATE = `
+**Monthly cost estimate**:
+  * Prior: ` + "`{{ .PriorMonthlyCost }}`" + `
+  * Proposed: ` + "`{{ .ProposedMonthlyCost }}`" + `
+  * Delta: ` + "`{{ .DeltaMonthlyCost }}`" + `
+`
+
+const DEFAULT_POLICY_CHECKS_TEMPLATE = `
+**Policy checks**:
+
+| Policy | Enforcement | Result |
+| --- | --- | --- |
+{{- range .PolicyChecks }}
+| ` + "`{{ .Name }}`" + ` | {{ .EnforcementLevel }} | {{ if .Passed }}:white_check_mark: passed{{ else }}:x: failed{{ end }} |
+{{- end }}
+{{ range .PolicyChecks }}{{ if and (not .Passed) .Output }}
+<details><summary>` + "`{{ .Name }}`" + ` output</summary>
+
+` + "```" + `
+{{ .Output }}
+` + "```" + `
+
+</details>
+{{ end }}{{ end }}
+{{- if .PolicyOverridable }}
+The run waits for the failed policies to be overridden. An authorized user can override them by commenting:
+	> ` + "`tfc override-policy -w {{ .Workspace }}`" + `
+{{ end }}`
+
+const DEFAULT_POLICY_SOFT_FAILED_TEMPLATE = `The plan has soft failed policy checks, please open TFC URL to approve.`
+
+const DEFAULT_HOW_TO_CONFIRM_TEMPLATE = `
+
+---
+* To **confirm** and apply the plan, comment:
+	> ` + "`tfc confirm -w {{ .Workspace }}`" + `
+
+* To **discard** the run, comment:
+	> ` + "`tfc discard -w {{ .Workspace }}`" + `
+`
+
+const DEFAULT_AUTO_APPLY_TEMPLATE = `Auto Apply Enabled - plan will automatically Apply if it passes policy checks.`
+
+const DEFAULT_MR_SUMMARY_TEMPLATE = `
+### Terraform Cloud Summary
+**Commit**: ` + "`{{ .CommitSHA }}`" + `
+
+| Workspace | Command | Status | Add | Change | Destroy | Run |
+| --- | --- | --- | --- | --- | --- | --- |
+{{ range .Workspaces -}}
+| ` + "`{{ .Organization }}/{{ .Workspace }}`" + ` | {{ .Action }} | ` + "`{{ .Status }}`" + ` | {{ .Additions }} | {{ .Changes }} | {{ .Destructions }} | [{{ .RunID }}]({{ .RunURL }}) |
+{{ end -}}
+{{ if .DeltaMonthlyCost }}
+**Monthly cost change**: ` + "`{{ .DeltaMonthlyCost }}`" + `
+{{ if .CostThresholdExceeded }}
+:warning: The monthly cost increase exceeds the threshold of ` + "`{{ .CostThreshold }}`" + `, applying requires the approval of a cost approver.
+{{ end }}{{ end }}`
+
+const DEFAULT_RUN_TIMELINE_TEMPLATE = `
+<details><summary>Run timeline
+{{- if or .QueueWait .PlanDuration }} (
+{{- if .QueueWait }}queued {{ .QueueWait }}{{ if .PlanDuration }}, {{ end }}{{ end }}
+{{- if .PlanDuration }}planned in {{ .PlanDuration }}{{ end }})
+{{- end }}</summary>
+
+| Status | Since | Duration |
+| --- | --- | --- |
+{{ range .Transitions -}}
+| ` + "`{{ .Status }}`" + ` | {{ .Since }} | {{ .Duration }} |
+{{ end }}
+</details>
+`
@@ -0,0 +1,149 @@
+package comment_formatter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		text     string
+		wantErr  bool
+	}{
+		{
+			name:     "default run details",
+			template: RunDetailsTemplateName,
+			text:     DEFAULT_RUN_DETAILS_TEMPLATE,
+		},
+		{
+			name:     "custom how to apply",
+			template: HowToApplyTemplateName,
+			text:     "Comment `tfc apply -w {{ .Workspace }}` to apply !{{ .MergeRequestIID }}",
+		},
+		{
+			name:     "custom plan",
+			template: PlanTemplateName,
+			text:     "{{ .AdditionCount }} to add",
+		},
+		{
+			name:     "default MR summary",
+			template: MRSummaryTemplateName,
+			text:     DEFAULT_MR_SUMMARY_TEMPLATE,
+		},
+		{
+			name:     "default run timeline",
+			template: RunTimelineTemplateName,
+			text:     DEFAULT_RUN_TIMELINE_TEMPLATE,
+		},
+		{
+			name:     "run timeline with status fields",
+			template: RunTimelineTemplateName,
+			text:     "{{ .Workspace }}",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown field",
+			template: RunSummaryTemplateName,
+			text:     "{{ .Sausage }}",
+			wantErr:  true,
+		},
+		{
+			name:     "syntax error",
+			template: FailedPlanTemplateName,
+			text:     "{{ if .Status }}",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown template",
+			template: "sausage",
+			text:     "{{ .Status }}",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTemplate(tt.template, tt.text)
+			assert.Equal(t, tt.wantErr, err != nil, "ValidateTemplate() error = %v", err)
+		})
+	}
+}
+
+func Test_readTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, RunSummaryTemplateName+".tpl"), []byte("{{ .Additions }} added"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, HowToApplyTemplateName+".tpl"), []byte("{{ .Sausage }}"), 0644))
+
+	got := readTemplateDir(dir)
+	assert.Equal(t, map[string]string{RunSummaryTemplateName: "{{ .Additions }} added"}, got)
+}
+
+func TestFormatRunStatusCommentBody_Templates(t *testing.T) {
+	run := &tfe.Run{
+		ID:     "run-123",
+		Status: tfe.RunApplied,
+		Apply:  &tfe.Apply{ResourceAdditions: 2},
+		Workspace: &tfe.Workspace{
+			Name:         "service-a",
+			Organization: &tfe.Organization{Name: "zapier"},
+		},
+	}
+
+	rmd := &runstream.TFRunMetadata{Action: "apply"}
+	main, _, toplevel, resolve := FormatRunStatusCommentBody(nil, nil, run, rmd)
+	assert.Equal(t, "\n  * Additions: 2\n  * Changes: 0\n  * Destructions: 0", main)
+	assert.Equal(t, `
+### Terraform Cloud
+**Workspace**: `+"`service-a`"+`<br>
+**Command**: apply <br>
+**Status**: `+"`applied`"+`<br>
+**Run URL**: [https://app.terraform.io/app/zapier/workspaces/service-a/runs/run-123](https://app.terraform.io/app/zapier/workspaces/service-a/runs/run-123) <br>
+`, toplevel)
+	assert.True(t, resolve)
+
+	ts := templateStore{
+		"ref-summary": "{{ .Workspace }}: +{{ .Additions }}",
+		"ref-details": "{{ .Sausage }}",
+	}
+	rmd.TemplateRefs = map[string]string{
+		RunSummaryTemplateName: "ref-summary",
+		RunDetailsTemplateName: "ref-details",
+		HowToApplyTemplateName: "ref-missing",
+	}
+	main, _, toplevel, _ = FormatRunStatusCommentBody(nil, ts, run, rmd)
+	assert.Equal(t, "service-a: +2", main)
+	assert.Contains(t, toplevel, "**Workspace**: `service-a`", "falls back to the default template")
+
+	run.Status = tfe.RunPlanning
+	run.AutoApply = true
+	rmd.TemplateRefs = map[string]string{AutoApplyTemplateName: "ref-auto-apply"}
+	ts["ref-auto-apply"] = "{{ .Workspace }} applies automatically"
+	main, _, _, _ = FormatRunStatusCommentBody(nil, ts, run, rmd)
+	assert.Equal(t, "service-a applies automatically", main)
+}
+
+func TestFormatMRSummaryBody_Template(t *testing.T) {
+	summary := &runstream.TFMRSummary{CommitSHA: "abcd1234"}
+	summary.SetWorkspaceRun(&runstream.WorkspaceRunSummary{Organization: "zapier", Workspace: "service-a", Status: "applied"})
+
+	templates := map[string]string{MRSummaryTemplateName: "{{ .CommitSHA }}{{ range .Workspaces }} {{ .Workspace }}={{ .Status }}{{ end }}"}
+	assert.Equal(t, "abcd1234 service-a=applied", FormatMRSummaryBody(summary, templates))
+}
+
+// templateStore is a TemplateStore of template texts by reference.
+type templateStore map[string]string
+
+func (s templateStore) GetTemplate(ref string) (string, error) {
+	text, ok := s[ref]
+	if !ok {
+		return "", errors.New("template not found")
+	}
+	return text, nil
+}
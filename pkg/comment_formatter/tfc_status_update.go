@@ -1,21 +1,30 @@
 package comment_formatter
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/hashicorp/go-tfe"
 	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/plan_policy"
 	"github.com/zapier/tfbuddy/pkg/runstream"
 	"github.com/zapier/tfbuddy/pkg/terraform_plan"
 	"github.com/zapier/tfbuddy/pkg/tfc_api"
 )
 
-func FormatRunStatusCommentBody(tfc tfc_api.ApiClient, run *tfe.Run, rmd runstream.RunMetadata) (main, toplevel string, resolve bool) {
+// FormatRunStatusCommentBody renders the comment for a run status update. Plan output too large for a single comment
+// is returned in continued, to be posted as follow-up comments after main.
+// Custom templates of the project are read from ts.
+func FormatRunStatusCommentBody(tfc tfc_api.ApiClient, ts TemplateStore, run *tfe.Run, rmd runstream.RunMetadata) (main string, continued []string, toplevel string, resolve bool) {
 	wsName := run.Workspace.Name
 	org := run.Workspace.Organization.Name
 	runUrl := fmt.Sprintf("https://app.terraform.io/app/%s/workspaces/%s/runs/%s", org, wsName, run.ID)
 
+	templates := ProjectTemplates(ts, rmd)
+	data := newStatusTemplateData(org, wsName, runUrl, run.ID, string(run.Status), run.AutoApply, rmd)
 	extraInfo := ""
+	continuedInfo := []string{}
 	resolveDiscussion := false
 
 	switch run.Status {
@@ -28,25 +37,36 @@ func FormatRunStatusCommentBody(tfc tfc_api.ApiClient, run *tfe.Run, rmd runstre
 	case tfe.RunApplying:
 		// no extra info
 	case tfe.RunApplied:
-		extraInfo = fmt.Sprintf(successPlanSummaryFormat, run.Apply.ResourceAdditions, run.Apply.ResourceChanges, run.Apply.ResourceDestructions)
+		data.Additions, data.Changes, data.Destructions = run.Apply.ResourceAdditions, run.Apply.ResourceChanges, run.Apply.ResourceDestructions
+		extraInfo = renderTemplate(RunSummaryTemplateName, templates, data)
 
 		resolveDiscussion = true
 	case tfe.RunDiscarded:
 		// no extra info
 	case tfe.RunErrored:
-		if rmd.GetAction() == "plan" {
-			extraInfo += failedPlanSummaryFormat
+		data.ErrorLog = RunErrorLog(tfc, run)
+		if rmd.GetAction() == "plan" || data.ErrorLog != "" {
+			extraInfo += renderTemplate(FailedPlanTemplateName, templates, data)
 		}
 
 	case tfe.RunPlanning:
 		// no extra info
 		if run.AutoApply {
-			extraInfo = "Auto Apply Enabled - plan will automatically Apply if it passes policy checks."
+			extraInfo = renderTemplate(AutoApplyTemplateName, templates, data)
 		}
 	case tfe.RunPlanned:
-		extraInfo = fmt.Sprintf(successPlanSummaryFormat, run.Plan.ResourceAdditions, run.Plan.ResourceChanges, run.Plan.ResourceDestructions)
-		if !run.AutoApply {
-			extraInfo += fmt.Sprintf(howToApplyFormat, wsName)
+		data.Additions, data.Changes, data.Destructions = run.Plan.ResourceAdditions, run.Plan.ResourceChanges, run.Plan.ResourceDestructions
+		if !rmd.GetProtectedResources().IsEmpty() || len(plan_policy.Policies(rmd.GetPolicies())) > 0 {
+			b, err := tfc.GetPlanOutput(run.Plan.ID)
+			if err != nil {
+				log.Error().Err(err).Msg("could not get plan JSON")
+			} else {
+				extraInfo = protectedResourcesInfo(b, rmd, templates, data) + policyResultsInfo(b, rmd, templates, data)
+			}
+		}
+		extraInfo += renderTemplate(RunSummaryTemplateName, templates, data)
+		if awaitsConfirmation(run, rmd) {
+			extraInfo += renderTemplate(HowToConfirmTemplateName, templates, data)
 		}
 	case tfe.RunPlannedAndFinished:
 		log.Trace().Interface("plan", run.Plan).Msg("planned_and_finished")
@@ -55,23 +75,40 @@ func FormatRunStatusCommentBody(tfc tfc_api.ApiClient, run *tfe.Run, rmd runstre
 		if err != nil {
 			log.Error().Err(err).Msg("could not get plan JSON")
 		} else {
-			extraInfo += "<br>" + terraform_plan.PresentPlanChangesAsMarkdown(b, runUrl) + "</br>"
+			chunks := formatPlanChanges(b, runUrl, rmd, templates, MaxCommentLength(rmd.GetVcsProvider())-commentOverhead)
+			extraInfo += protectedResourcesInfo(b, rmd, templates, data) + policyResultsInfo(b, rmd, templates, data) + costEstimateInfo(run, rmd, templates, data)
+			extraInfo += "<br>" + chunks[0] + "</br>"
+			continuedInfo = chunks[1:]
 		}
 		log.Trace().Str("plan_id", run.Plan.ID).Str("plan_json", string(b)).Msg("")
 
 		if hasChanges(run.Plan) {
-			extraInfo += fmt.Sprintf(howToApplyFormat, wsName)
+			if len(continuedInfo) > 0 {
+				continuedInfo[len(continuedInfo)-1] += renderTemplate(HowToApplyTemplateName, templates, data)
+			} else {
+				extraInfo += renderTemplate(HowToApplyTemplateName, templates, data)
+			}
 		} else {
 			resolveDiscussion = true
 		}
 
-	case tfe.RunPolicySoftFailed:
-		// no extra info
-		extraInfo = "The plan has soft failed policy checks, please open TFC URL to approve."
+	case tfe.RunCostEstimated:
+		// speculative plans show the cost estimate with the plan once they have finished
+		if rmd.GetAction() != "plan" {
+			extraInfo = costEstimateInfo(run, rmd, templates, data)
+		}
+
+	case tfe.RunPolicySoftFailed, tfe.RunPolicyOverride, tfe.RunPostPlanAwaitingDecision:
+		data.PolicyOverridable = true
+		extraInfo = policyChecksInfo(tfc, run, rmd, templates, data)
+		if extraInfo == "" {
+			extraInfo = renderTemplate(PolicySoftFailedTemplateName, templates, data)
+		}
 
 	case tfe.RunPolicyChecked:
-		if !run.AutoApply {
-			extraInfo = "Plan requires confirmation through the Terraform Cloud console. Click Run URL link to open & confirm."
+		extraInfo = policyChecksInfo(tfc, run, rmd, templates, data)
+		if awaitsConfirmation(run, rmd) {
+			extraInfo += renderTemplate(HowToConfirmTemplateName, templates, data)
 		}
 
 	default:
@@ -79,16 +116,80 @@ func FormatRunStatusCommentBody(tfc tfc_api.ApiClient, run *tfe.Run, rmd runstre
 		return
 	}
 
-	topLevelNoteBody := fmt.Sprintf(
-		MR_RUN_DETAILS_FORMAT,
-		wsName,
-		rmd.GetAction(),
-		run.Status,
-		runUrl, runUrl,
-	)
+	topLevelNoteBody := renderTemplate(RunDetailsTemplateName, templates, data)
+
+	return extraInfo, continuedInfo, topLevelNoteBody, resolveDiscussion
+
+}
+
+// protectedResourcesInfo renders a warning if the plan destroys or replaces protected resources of the workspace.
+func protectedResourcesInfo(b []byte, rmd runstream.RunMetadata, templates map[string]string, data StatusTemplateData) string {
+	destroyed, err := terraform_plan.DestroyedProtectedResources(b, rmd.GetProtectedResources())
+	if err != nil {
+		log.Error().Err(err).Msg("could not check plan for protected resources")
+		return ""
+	}
+	if len(destroyed) == 0 {
+		return ""
+	}
+	data.ProtectedResources = destroyed
+	data.ApplyBlocked = runstream.IsProtectedApply(rmd)
+	data.AllowDestroy = rmd.GetAllowDestroy()
+	return renderTemplate(ProtectedResourcesTemplateName, templates, data)
+}
 
-	return extraInfo, topLevelNoteBody, resolveDiscussion
+// policyResultsInfo renders the failures & warnings of the policies evaluated against the plan.
+func policyResultsInfo(b []byte, rmd runstream.RunMetadata, templates map[string]string, data StatusTemplateData) string {
+	result, err := plan_policy.Evaluate(plan_policy.Policies(rmd.GetPolicies()), b)
+	if err != nil {
+		log.Error().Err(err).Msg("could not evaluate policies")
+		return fmt.Sprintf("\n:no_entry: **Policies could not be evaluated:** %v\n", err)
+	}
+	if result.IsEmpty() {
+		return ""
+	}
+	data.PolicyDenials = result.Deny
+	data.PolicyWarnings = result.Warn
+	data.ApplyBlocked = rmd.GetConfirmApply() && result.Failed()
+	return renderTemplate(PolicyResultsTemplateName, templates, data)
+}
 
+// policyChecksInfo renders the result of each TFC policy evaluated against the run.
+func policyChecksInfo(tfc tfc_api.ApiClient, run *tfe.Run, rmd runstream.RunMetadata, templates map[string]string, data StatusTemplateData) string {
+	outcomes, err := tfc.GetPolicyOutcomes(context.Background(), run.ID)
+	if err != nil {
+		log.Error().Err(err).Str("runID", run.ID).Msg("could not get policy checks")
+		return ""
+	}
+	if len(outcomes) == 0 {
+		return ""
+	}
+	data.PolicyChecks = outcomes
+	return renderTemplate(PolicyChecksTemplateName, templates, data)
+}
+
+// costEstimateInfo renders the monthly cost estimate of the run, if TFC cost estimation is enabled & has finished.
+func costEstimateInfo(run *tfe.Run, rmd runstream.RunMetadata, templates map[string]string, data StatusTemplateData) string {
+	ce := run.CostEstimate
+	if ce == nil || ce.Status != tfe.CostEstimateFinished {
+		return ""
+	}
+	data.PriorMonthlyCost = formatCost(ce.PriorMonthlyCost)
+	data.ProposedMonthlyCost = formatCost(ce.ProposedMonthlyCost)
+	data.DeltaMonthlyCost = formatCost(ce.DeltaMonthlyCost)
+	if delta, err := strconv.ParseFloat(ce.DeltaMonthlyCost, 64); err == nil {
+		data.DeltaMonthlyCost = formatCostDelta(delta)
+	}
+	return renderTemplate(CostEstimateTemplateName, templates, data)
+}
+
+// formatCost formats a cost in USD as returned by the TFC API.
+func formatCost(cost string) string {
+	f, err := strconv.ParseFloat(cost, 64)
+	if err != nil {
+		return cost
+	}
+	return fmt.Sprintf("$%.2f", f)
 }
 
 func hasChanges(plan *tfe.Plan) bool {
@@ -104,34 +205,13 @@ func hasChanges(plan *tfe.Plan) bool {
 	return false
 }
 
+// awaitsConfirmation returns true for apply runs that wait for the user to confirm them with `tfc confirm`. Applies
+// with protected resources or policies are confirmed or discarded by TF Buddy once the plan has been checked.
+func awaitsConfirmation(run *tfe.Run, rmd runstream.RunMetadata) bool {
+	return rmd.GetAction() == "apply" && !run.AutoApply && !rmd.GetConfirmApply()
+}
+
 const MR_COMMENT_FORMAT = `
 ### Terraform Cloud
 %s
 `
-const MR_RUN_DETAILS_FORMAT = `
-### Terraform Cloud
-**Workspace**: ` + "`%s`" + `<br>
-**Command**: %s <br>
-**Status**: ` + "`%s`" + `<br>
-**Run URL**: [%s](%s) <br>
-`
-
-var failedPlanSummaryFormat = `
-*Click Terraform Cloud URL to see detailed plan output*
-`
-
-var successPlanSummaryFormat = `
-  * Additions: %d
-  * Changes: %d
-  * Destructions: %d`
-
-var howToApplyFormat = `
-
----
-* To **apply** the plan for all workspaces, comment:
-	> ` + "`tfc apply`" + `
-
-* To **apply** the plan for this workspace only, comment:
-	> ` + "`tfc apply -w %s`" + `
-
-Remember to **merge** the MR once the apply has succeeded`
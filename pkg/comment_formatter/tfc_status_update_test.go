@@ -0,0 +1,204 @@
+package comment_formatter
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+)
+
+// planOutputClient returns the same plan JSON for all plans, other API calls are not implemented.
+type planOutputClient struct {
+	tfc_api.ApiClient
+	plan []byte
+}
+
+func (c *planOutputClient) GetPlanOutput(id string) ([]byte, error) {
+	return c.plan, nil
+}
+
+func TestFormatRunStatusCommentBody_ProtectedResources(t *testing.T) {
+	plan, err := os.ReadFile("../terraform_plan/testdata/TestPresentPlanChangesAsMarkdown/replace.tfplan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tfc := &planOutputClient{plan: plan}
+	protected := &terraform_plan.ProtectedResources{Addresses: []string{"random_pet.rando[*]"}}
+	newRun := func(status tfe.RunStatus, autoApply bool) *tfe.Run {
+		return &tfe.Run{
+			ID:        "run-123",
+			Status:    status,
+			AutoApply: autoApply,
+			Plan:      &tfe.Plan{ID: "plan-123", ResourceAdditions: 5, ResourceDestructions: 5},
+			Workspace: &tfe.Workspace{
+				Name:         "service-a",
+				Organization: &tfe.Organization{Name: "zapier"},
+			},
+		}
+	}
+
+	// speculative plan: warn that the apply must be acknowledged
+	main, _, _, _ := FormatRunStatusCommentBody(tfc, nil, newRun(tfe.RunPlannedAndFinished, false), &runstream.TFRunMetadata{
+		Action:             "plan",
+		ProtectedResources: protected,
+	})
+	assert.Contains(t, main, ":warning: **The plan destroys or replaces protected resources:**")
+	assert.Contains(t, main, "  * `random_pet.rando[3]`")
+	assert.Contains(t, main, "`tfc apply -w service-a --allow-destroy`")
+
+	// protected apply: the run is discarded and there are no apply instructions
+	main, _, _, _ = FormatRunStatusCommentBody(tfc, nil, newRun(tfe.RunPlanned, false), &runstream.TFRunMetadata{
+		Action:             "apply",
+		ProtectedResources: protected,
+		ConfirmApply:       true,
+	})
+	assert.Contains(t, main, ":no_entry: **Apply blocked, the plan destroys or replaces protected resources:**")
+	assert.Contains(t, main, "The run has been discarded.")
+	assert.NotContains(t, main, "To **apply** the plan")
+
+	// acknowledged apply
+	main, _, _, _ = FormatRunStatusCommentBody(tfc, nil, newRun(tfe.RunPlanned, true), &runstream.TFRunMetadata{
+		Action:             "apply",
+		ProtectedResources: protected,
+		AllowDestroy:       true,
+	})
+	assert.Contains(t, main, "The destruction was acknowledged with `--allow-destroy`.")
+
+	// no protected resources destroyed
+	main, _, _, _ = FormatRunStatusCommentBody(tfc, nil, newRun(tfe.RunPlanned, false), &runstream.TFRunMetadata{
+		Action:             "apply",
+		ProtectedResources: &terraform_plan.ProtectedResources{Types: []string{"aws_db_instance"}},
+		ConfirmApply:       true,
+	})
+	assert.Equal(t, "\n  * Additions: 5\n  * Changes: 0\n  * Destructions: 5", main)
+}
+
+func TestFormatRunStatusCommentBody_PolicyResults(t *testing.T) {
+	plan, err := os.ReadFile("../terraform_plan/testdata/TestPresentPlanChangesAsMarkdown/replace.tfplan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tfc := &planOutputClient{plan: plan}
+	policies := map[string]string{"policies/pets.rego": `
+package tfbuddy
+
+deny[msg] {
+	input.resource_changes[_].address == "random_integer.pet_length"
+	msg := "pet_length must not change"
+}
+
+warn[msg] {
+	input.resource_changes[_].type == "time_rotating"
+	msg := "time_rotating is deprecated"
+}
+`}
+	run := &tfe.Run{
+		ID:     "run-123",
+		Status: tfe.RunPlannedAndFinished,
+		Plan:   &tfe.Plan{ID: "plan-123"},
+		Workspace: &tfe.Workspace{
+			Name:         "service-a",
+			Organization: &tfe.Organization{Name: "zapier"},
+		},
+	}
+
+	main, _, _, _ := FormatRunStatusCommentBody(tfc, nil, run, &runstream.TFRunMetadata{Action: "plan", Policies: policies})
+	assert.Contains(t, main, `
+:no_entry: **The plan fails policy checks:**
+  * :x: pet_length must not change
+  * :warning: time_rotating is deprecated
+
+Applying is blocked until the policy failures are fixed.
+`)
+
+	run.Status = tfe.RunPlanned
+	main, _, _, _ = FormatRunStatusCommentBody(tfc, nil, run, &runstream.TFRunMetadata{Action: "apply", Policies: policies, ConfirmApply: true})
+	assert.Contains(t, main, "The run has been discarded, the policy failures must be fixed to apply.")
+}
+
+func TestFormatRunStatusCommentBody_CostEstimate(t *testing.T) {
+	run := &tfe.Run{
+		ID:     "run-123",
+		Status: tfe.RunCostEstimated,
+		Plan:   &tfe.Plan{ID: "plan-123"},
+		CostEstimate: &tfe.CostEstimate{
+			Status:              tfe.CostEstimateFinished,
+			PriorMonthlyCost:    "10.0",
+			ProposedMonthlyCost: "82.5",
+			DeltaMonthlyCost:    "72.5",
+		},
+		Workspace: &tfe.Workspace{
+			Name:         "service-a",
+			Organization: &tfe.Organization{Name: "zapier"},
+		},
+	}
+
+	main, _, _, _ := FormatRunStatusCommentBody(nil, nil, run, &runstream.TFRunMetadata{Action: "apply"})
+	assert.Equal(t, "\n**Monthly cost estimate**:\n  * Prior: `$10.00`\n  * Proposed: `$82.50`\n  * Delta: `+$72.50`\n", main)
+
+	// speculative plans show the cost estimate once they have finished
+	main, _, _, _ = FormatRunStatusCommentBody(nil, nil, run, &runstream.TFRunMetadata{Action: "plan"})
+	assert.Empty(t, main)
+
+	run.CostEstimate.Status = tfe.CostEstimateErrored
+	main, _, _, _ = FormatRunStatusCommentBody(nil, nil, run, &runstream.TFRunMetadata{Action: "apply"})
+	assert.Empty(t, main)
+}
+
+// policyOutcomesClient returns the same policy outcomes for all runs, other API calls are not implemented.
+type policyOutcomesClient struct {
+	tfc_api.ApiClient
+	outcomes []*tfc_api.PolicyOutcome
+}
+
+func (c *policyOutcomesClient) GetPolicyOutcomes(ctx context.Context, runID string) ([]*tfc_api.PolicyOutcome, error) {
+	return c.outcomes, nil
+}
+
+func TestFormatRunStatusCommentBody_PolicyChecks(t *testing.T) {
+	tfc := &policyOutcomesClient{outcomes: []*tfc_api.PolicyOutcome{
+		{Name: "networking/restrict-ingress.sentinel", EnforcementLevel: "soft-mandatory", Output: `FALSE - restrict-ingress.sentinel:12:1 - Rule "main"`},
+		{Name: "cost/limit-monthly-cost.sentinel", EnforcementLevel: "advisory", Passed: true},
+	}}
+	run := &tfe.Run{
+		ID:     "run-123",
+		Status: tfe.RunPolicyOverride,
+		Workspace: &tfe.Workspace{
+			Name:         "service-a",
+			Organization: &tfe.Organization{Name: "zapier"},
+		},
+	}
+
+	main, _, _, _ := FormatRunStatusCommentBody(tfc, nil, run, &runstream.TFRunMetadata{Action: "apply"})
+	assert.Equal(t, "\n**Policy checks**:\n\n"+
+		"| Policy | Enforcement | Result |\n"+
+		"| --- | --- | --- |\n"+
+		"| `networking/restrict-ingress.sentinel` | soft-mandatory | :x: failed |\n"+
+		"| `cost/limit-monthly-cost.sentinel` | advisory | :white_check_mark: passed |\n"+
+		"\n<details><summary>`networking/restrict-ingress.sentinel` output</summary>\n\n"+
+		"```\nFALSE - restrict-ingress.sentinel:12:1 - Rule \"main\"\n```\n\n</details>\n"+
+		"\nThe run waits for the failed policies to be overridden. An authorized user can override them by commenting:\n"+
+		"\t> `tfc override-policy -w service-a`\n", main)
+
+	run.Status = tfe.RunPolicyChecked
+	run.AutoApply = true
+	main, _, _, _ = FormatRunStatusCommentBody(tfc, nil, run, &runstream.TFRunMetadata{Action: "apply"})
+	assert.NotContains(t, main, "override-policy")
+	assert.NotContains(t, main, "tfc confirm")
+
+	// the run waits for the user to confirm it
+	run.AutoApply = false
+	main, _, _, _ = FormatRunStatusCommentBody(tfc, nil, run, &runstream.TFRunMetadata{Action: "apply"})
+	assert.Contains(t, main, "> `tfc confirm -w service-a`")
+	assert.Contains(t, main, "> `tfc discard -w service-a`")
+
+	// without policy checks the comment falls back to the TFC URL
+	run.Status = tfe.RunPolicySoftFailed
+	main, _, _, _ = FormatRunStatusCommentBody(&policyOutcomesClient{}, nil, run, &runstream.TFRunMetadata{Action: "plan"})
+	assert.Equal(t, "The plan has soft failed policy checks, please open TFC URL to approve.", main)
+}
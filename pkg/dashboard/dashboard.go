@@ -0,0 +1,265 @@
+package dashboard
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/api"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+//go:embed static
+var static embed.FS
+
+const (
+	// maxRecentEvents is the number of run events kept in memory for the dashboard
+	maxRecentEvents = 100
+	// maxSummaries is the number of most recently updated MRs shown on the dashboard
+	maxSummaries = 50
+	// keepAliveInterval is the interval of the comments sent to keep idle event streams open
+	keepAliveInterval = 30 * time.Second
+)
+
+// RunEvent is a run status change observed on the RUN_EVENTS stream.
+type RunEvent struct {
+	RunID           string
+	Organization    string
+	Workspace       string
+	Action          string
+	Status          string
+	RunURL          string
+	Project         string
+	MergeRequestIID int
+	MergeRequestURL string
+	ObservedAt      time.Time
+}
+
+// PollingTask is a run that TF Buddy polls for status updates.
+type PollingTask struct {
+	RunID           string
+	Organization    string
+	Workspace       string
+	Action          string
+	LastStatus      string
+	RunURL          string
+	MergeRequestURL string
+}
+
+// MRSummary is the latest run of each workspace triggered for a MR commit.
+type MRSummary struct {
+	Project         string
+	MergeRequestIID int
+	MergeRequestURL string
+	CommitSHA       string
+	Workspaces      []*runstream.WorkspaceRunSummary
+	UpdatedAt       time.Time
+}
+
+// State is the dashboard content, the locks are served by the API.
+type State struct {
+	PollingTasks []*PollingTask
+	RecentEvents []*RunEvent
+	Summaries    []*MRSummary
+}
+
+// Dashboard serves a web UI showing the runs TF Buddy is processing. Run events are pushed to the UI with
+// server-sent events.
+type Dashboard struct {
+	rs runstream.StreamClient
+
+	mu      sync.Mutex
+	recent  []*RunEvent
+	clients map[chan *RunEvent]struct{}
+}
+
+func NewDashboard(rs runstream.StreamClient) *Dashboard {
+	return &Dashboard{
+		rs:      rs,
+		recent:  []*RunEvent{},
+		clients: map[chan *RunEvent]struct{}{},
+	}
+}
+
+// Register serves the UI under /dashboard/ and its data on the authenticated API group.
+func (d *Dashboard) Register(e *echo.Echo, apiGroup *echo.Group) {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not read dashboard files")
+	}
+	e.GET("/dashboard", func(c echo.Context) error {
+		return c.Redirect(http.StatusMovedPermanently, "/dashboard/")
+	})
+	e.GET("/dashboard/*", echo.WrapHandler(http.StripPrefix("/dashboard/", http.FileServer(http.FS(files)))))
+
+	apiGroup.GET("/dashboard", d.GetState)
+	apiGroup.GET(api.EventsPath, d.StreamEvents)
+}
+
+// Observe records a run event and pushes it to the connected UIs.
+func (d *Dashboard) Observe(re runstream.RunEvent) {
+	ev := &RunEvent{
+		RunID:      re.GetRunID(),
+		Status:     re.GetNewStatus(),
+		ObservedAt: time.Now(),
+	}
+	if rmd, err := d.rs.GetRunMeta(re.GetRunID()); err == nil {
+		ev.Organization = rmd.GetOrganization()
+		ev.Workspace = rmd.GetWorkspace()
+		ev.Action = rmd.GetAction()
+		ev.RunURL = runURL(rmd.GetOrganization(), rmd.GetWorkspace(), rmd.GetRunID())
+		ev.Project = rmd.GetMRProjectNameWithNamespace()
+		ev.MergeRequestIID = rmd.GetMRInternalID()
+		ev.MergeRequestURL = mergeRequestURL(rmd.GetMRWebURL(), rmd.GetVcsProvider(), rmd.GetMRProjectNameWithNamespace(), rmd.GetMRInternalID())
+	} else {
+		log.Debug().Err(err).Str("runID", re.GetRunID()).Msg("could not get run metadata for dashboard event")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recent = append([]*RunEvent{ev}, d.recent...)
+	if len(d.recent) > maxRecentEvents {
+		d.recent = d.recent[:maxRecentEvents]
+	}
+	for client := range d.clients {
+		select {
+		case client <- ev:
+		default:
+			// the client is too slow, it will catch up on its next refresh
+		}
+	}
+}
+
+// GetState returns the polling tasks, recent run events and MR summaries.
+func (d *Dashboard) GetState(c echo.Context) error {
+	tasks, err := d.rs.ListPollingTasks()
+	if err != nil {
+		return err
+	}
+	summaries, err := d.rs.ListMRSummaries()
+	if err != nil {
+		return err
+	}
+
+	state := &State{
+		PollingTasks: []*PollingTask{},
+		Summaries:    latestMRSummaries(summaries),
+	}
+	for _, task := range tasks {
+		rmd := task.GetRunMetaData()
+		state.PollingTasks = append(state.PollingTasks, &PollingTask{
+			RunID:           task.GetRunID(),
+			Organization:    rmd.GetOrganization(),
+			Workspace:       rmd.GetWorkspace(),
+			Action:          rmd.GetAction(),
+			LastStatus:      task.GetLastStatus(),
+			RunURL:          runURL(rmd.GetOrganization(), rmd.GetWorkspace(), task.GetRunID()),
+			MergeRequestURL: mergeRequestURL(rmd.GetMRWebURL(), rmd.GetVcsProvider(), rmd.GetMRProjectNameWithNamespace(), rmd.GetMRInternalID()),
+		})
+	}
+	d.mu.Lock()
+	state.RecentEvents = append([]*RunEvent{}, d.recent...)
+	d.mu.Unlock()
+
+	return c.JSON(http.StatusOK, state)
+}
+
+// StreamEvents pushes run events to the UI as server-sent events.
+func (d *Dashboard) StreamEvents(c echo.Context) error {
+	events := make(chan *RunEvent, 16)
+	d.mu.Lock()
+	d.clients[events] = struct{}{}
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.clients, events)
+		d.mu.Unlock()
+	}()
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		case ev := <-events:
+			b, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "event: run\ndata: %s\n\n", b); err != nil {
+				return nil
+			}
+		}
+		w.Flush()
+	}
+}
+
+// latestMRSummaries returns the summary of the latest commit of each MR, most recently updated first.
+func latestMRSummaries(summaries []*runstream.TFMRSummary) []*MRSummary {
+	latest := map[string]*MRSummary{}
+	for _, summary := range summaries {
+		s := &MRSummary{
+			Project:         summary.ProjectNameWithNamespace,
+			MergeRequestIID: summary.MergeRequestIID,
+			MergeRequestURL: mergeRequestURL(summary.MergeRequestWebURL, summary.VcsProvider, summary.ProjectNameWithNamespace, summary.MergeRequestIID),
+			CommitSHA:       summary.CommitSHA,
+			Workspaces:      summary.SortedWorkspaces(),
+		}
+		for _, ws := range s.Workspaces {
+			if ws.UpdatedAt.After(s.UpdatedAt) {
+				s.UpdatedAt = ws.UpdatedAt
+			}
+		}
+		key := fmt.Sprintf("%s!%d", s.Project, s.MergeRequestIID)
+		if prev, ok := latest[key]; !ok || s.UpdatedAt.After(prev.UpdatedAt) {
+			latest[key] = s
+		}
+	}
+
+	result := make([]*MRSummary, 0, len(latest))
+	for _, s := range latest {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UpdatedAt.After(result[j].UpdatedAt)
+	})
+	if len(result) > maxSummaries {
+		result = result[:maxSummaries]
+	}
+	return result
+}
+
+func runURL(org, ws, runID string) string {
+	return fmt.Sprintf("https://app.terraform.io/app/%s/workspaces/%s/runs/%s", org, ws, runID)
+}
+
+// mergeRequestURL returns the MR link recorded with the run. Runs recorded without it only get a link on GitHub, as
+// the GitLab instance isn't known.
+func mergeRequestURL(webURL, vcsProvider, project string, mrIID int) string {
+	if webURL != "" {
+		return webURL
+	}
+	if vcsProvider == "github" {
+		return fmt.Sprintf("https://github.com/%s/pull/%d", project, mrIID)
+	}
+	return ""
+}
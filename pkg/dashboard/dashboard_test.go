@@ -0,0 +1,133 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+func TestDashboard_GetState(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	rs := mocks.NewMockStreamClient(mockCtrl)
+	d := NewDashboard(rs)
+
+	rmd := &runstream.TFRunMetadata{
+		RunID:                                "run-1",
+		Organization:                         "zapier",
+		Workspace:                            "a-ws",
+		Action:                               "plan",
+		MergeRequestProjectNameWithNamespace: "zapier/tfbuddy",
+		MergeRequestIID:                      101,
+		MergeRequestWebURL:                   "https://gitlab.example.com/zapier/tfbuddy/-/merge_requests/101",
+		VcsProvider:                          "gitlab",
+	}
+	rs.EXPECT().GetRunMeta("run-1").Return(rmd, nil)
+	d.Observe(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "planning"})
+
+	task := mocks.NewMockRunPollingTask(mockCtrl)
+	task.EXPECT().GetRunID().Return("run-1").AnyTimes()
+	task.EXPECT().GetLastStatus().Return("planning")
+	task.EXPECT().GetRunMetaData().Return(rmd)
+	rs.EXPECT().ListPollingTasks().Return([]runstream.RunPollingTask{task}, nil)
+
+	now := time.Now()
+	rs.EXPECT().ListMRSummaries().Return([]*runstream.TFMRSummary{
+		{ProjectNameWithNamespace: "zapier/tfbuddy", MergeRequestIID: 101, CommitSHA: "old", VcsProvider: "gitlab",
+			Workspaces: map[string]*runstream.WorkspaceRunSummary{"zapier/a-ws": {Workspace: "a-ws", UpdatedAt: now.Add(-time.Hour)}}},
+		{ProjectNameWithNamespace: "zapier/tfbuddy", MergeRequestIID: 101, CommitSHA: "new", VcsProvider: "gitlab",
+			MergeRequestWebURL: "https://gitlab.example.com/zapier/tfbuddy/-/merge_requests/101",
+			Workspaces:         map[string]*runstream.WorkspaceRunSummary{"zapier/a-ws": {Workspace: "a-ws", UpdatedAt: now}}},
+		{ProjectNameWithNamespace: "zapier/other", MergeRequestIID: 7, CommitSHA: "abcd", VcsProvider: "github",
+			Workspaces: map[string]*runstream.WorkspaceRunSummary{"zapier/b-ws": {Workspace: "b-ws", UpdatedAt: now.Add(-time.Minute)}}},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/dashboard", nil), rec)
+	assert.NoError(t, d.GetState(c))
+
+	state := &State{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), state))
+	if assert.Len(t, state.PollingTasks, 1) {
+		assert.Equal(t, &PollingTask{
+			RunID:           "run-1",
+			Organization:    "zapier",
+			Workspace:       "a-ws",
+			Action:          "plan",
+			LastStatus:      "planning",
+			RunURL:          "https://app.terraform.io/app/zapier/workspaces/a-ws/runs/run-1",
+			MergeRequestURL: "https://gitlab.example.com/zapier/tfbuddy/-/merge_requests/101",
+		}, state.PollingTasks[0])
+	}
+	if assert.Len(t, state.RecentEvents, 1) {
+		assert.Equal(t, "planning", state.RecentEvents[0].Status)
+		assert.Equal(t, "zapier/tfbuddy", state.RecentEvents[0].Project)
+	}
+	if assert.Len(t, state.Summaries, 2) {
+		assert.Equal(t, "new", state.Summaries[0].CommitSHA)
+		assert.Equal(t, "https://gitlab.example.com/zapier/tfbuddy/-/merge_requests/101", state.Summaries[0].MergeRequestURL)
+		assert.Equal(t, "https://github.com/zapier/other/pull/7", state.Summaries[1].MergeRequestURL)
+	}
+}
+
+// syncRecorder allows reading the response while it is written.
+type syncRecorder struct {
+	*httptest.ResponseRecorder
+	mu sync.Mutex
+}
+
+func (r *syncRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ResponseRecorder.Write(b)
+}
+
+func (r *syncRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Body.String()
+}
+
+func TestDashboard_StreamEvents(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	rs := mocks.NewMockStreamClient(mockCtrl)
+	rs.EXPECT().GetRunMeta("run-1").Return(&runstream.TFRunMetadata{RunID: "run-1", Organization: "zapier", Workspace: "a-ws"}, nil)
+	d := NewDashboard(rs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := &syncRecorder{ResponseRecorder: httptest.NewRecorder()}
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/events", nil).WithContext(ctx), rec)
+	done := make(chan error)
+	go func() {
+		done <- d.StreamEvents(c)
+	}()
+
+	// wait for the client to be connected
+	assert.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.clients) == 1
+	}, time.Second, 10*time.Millisecond)
+	d.Observe(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "applied"})
+	assert.Eventually(t, func() bool {
+		return strings.Contains(rec.String(), `"Status":"applied"`)
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+
+	assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+	assert.True(t, strings.HasPrefix(rec.String(), "event: run\ndata: {\"RunID\":\"run-1\""))
+	assert.Empty(t, d.clients)
+}
@@ -67,6 +67,17 @@ func (gr *Repository) GetMergeBase(oldest, newest string) (string, error) {
 	}
 	return "", fmt.Errorf("could not find merge base")
 }
+func (gr *Repository) CheckoutCommit(sha string) error {
+	hash, err := gr.ResolveRevision(plumbing.Revision(sha))
+	if err != nil {
+		return fmt.Errorf("could not find commit %s: %v", sha, err)
+	}
+	wt, err := gr.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Checkout(&git.CheckoutOptions{Hash: *hash})
+}
 func (gr *Repository) GetModifiedFileNamesBetweenCommits(oldest, newest string) ([]string, error) {
 
 	oldestSha, err := gr.ResolveRevision(plumbing.Revision(oldest))
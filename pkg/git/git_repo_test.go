@@ -92,3 +92,24 @@ func TestGetModifiedFileNamesBetweenCommitsNoResults(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.Equal(t, len(modifiedFiles), 0, "expected no files modified between master and test")
 }
+
+func TestCheckoutCommit(t *testing.T) {
+	gitRepo, initialCommit := mocks.InitGitTestRepo(t)
+	_, err := gitRepo.CreateCommitFileOnCurrentBranch("main2.tf", "test commit")
+	assert.Equal(t, nil, err)
+
+	client := Repository{
+		Repository: gitRepo.Repo,
+	}
+	err = client.CheckoutCommit(initialCommit)
+	assert.Equal(t, nil, err)
+	head, err := gitRepo.Repo.Head()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, initialCommit, head.Hash().String())
+	wt, err := gitRepo.Repo.Worktree()
+	assert.Equal(t, nil, err)
+	_, err = wt.Filesystem.Stat("main2.tf")
+	assert.Error(t, err, "files of later commits are removed")
+
+	assert.Error(t, client.CheckoutCommit("0123456789abcdef0123456789abcdef01234567"))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
@@ -48,7 +49,37 @@ func (c *Client) GetMergeRequestApprovals(id int, project string) (vcs.MRApprove
 	if err != nil {
 		return nil, err
 	}
-	return pr, nil
+	parts, err := splitFullName(project)
+	if err != nil {
+		return nil, err
+	}
+	// reviews are listed oldest first, a reviewer approves the PR if their latest approval or change request is an approval
+	latest := map[string]string{}
+	opts := &gogithub.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := c.client.PullRequests.ListReviews(c.ctx, parts[0], parts[1], id, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range reviews {
+			switch r.GetState() {
+			case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+				latest[r.GetUser().GetLogin()] = r.GetState()
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	approvers := []string{}
+	for login, state := range latest {
+		if state == "APPROVED" {
+			approvers = append(approvers, login)
+		}
+	}
+	sort.Strings(approvers)
+	return &PRApproved{approvalStatus: pr.IsApproved(), approvers: approvers}, nil
 }
 
 func (c *Client) CreateMergeRequestComment(prID int, fullName string, comment string) error {
@@ -176,10 +207,19 @@ func (c *Client) CloneMergeRequest(project string, mr vcs.MR, dest string) (vcs.
 	return zgit.NewRepository(gitRepo, auth, dest), nil
 }
 
+// UpdateMergeRequestDiscussionNote edits a PR comment, GitHub has no discussion threads so only the note ID is used.
 func (c *Client) UpdateMergeRequestDiscussionNote(mrIID, noteID int, project, discussionID, comment string) (vcs.MRNote, error) {
-	//TODO implement me
-	//panic("implement me")
-	return nil, nil
+	parts, err := splitFullName(project)
+	if err != nil {
+		return nil, err
+	}
+	iss, _, err := c.client.Issues.EditComment(c.ctx, parts[0], parts[1], int64(noteID), &gogithub.IssueComment{
+		Body: String(comment),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &IssueComment{iss}, nil
 }
 
 func (c *Client) ResolveMergeRequestDiscussion(s string, i int, s2 string) error {
@@ -11,6 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 	"github.com/sl1pm4t/gongs"
+	"github.com/zapier/tfbuddy/pkg/hooks_stream"
 	"github.com/zapier/tfbuddy/pkg/runstream"
 	"github.com/zapier/tfbuddy/pkg/tfc_api"
 	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
@@ -31,13 +32,14 @@ type GithubHooksHandler struct {
 	js              nats.JetStreamContext
 	ghEvents        *githubevents.EventHandler
 	triggerCreation TriggerCreationFunc
+	deliveries      hooks_stream.DeliveryRecorder
 
 	// streams
 	prStream      *gongs.GenericStream[PullRequestEventMsg, *PullRequestEventMsg]
 	commentStream *gongs.GenericStream[GithubIssueCommentEventMsg, *GithubIssueCommentEventMsg]
 }
 
-func NewGithubHooksHandler(vcs vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient, js nats.JetStreamContext) *GithubHooksHandler {
+func NewGithubHooksHandler(vcs vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient, js nats.JetStreamContext, deliveries hooks_stream.DeliveryRecorder) *GithubHooksHandler {
 	hookSecretEnv := os.Getenv("TFBUDDY_GITHUB_HOOK_SECRET_KEY")
 	prStream := gongs.NewGenericStream[PullRequestEventMsg](js, getGithubJetstreamName(), getGithubJetstreamSubject(PullRequestEventType))
 	commentStream := gongs.NewGenericStream[GithubIssueCommentEventMsg](js, getGithubJetstreamName(), getGithubJetstreamSubject(IssueCommentEvent))
@@ -50,6 +52,7 @@ func NewGithubHooksHandler(vcs vcs.GitClient, tfc tfc_api.ApiClient, rs runstrea
 		commentStream:   commentStream,
 		prStream:        prStream,
 		triggerCreation: tfc_trigger.NewTFCTrigger,
+		deliveries:      deliveries,
 	}
 
 	ghEvents := githubevents.New(hookSecretEnv)
@@ -60,7 +63,7 @@ func NewGithubHooksHandler(vcs vcs.GitClient, tfc tfc_api.ApiClient, rs runstrea
 	h.ghEvents = ghEvents
 
 	// wire up worker callbacks
-	_, err := commentStream.QueueSubscribe("github_comment_event_worker", h.processIssueCommentEvent)
+	_, err := hooks_stream.QueueSubscribe[GithubIssueCommentEventMsg](js, getGithubJetstreamSubject(IssueCommentEvent), "github_comment_event_worker", h.processIssueCommentEvent)
 	if err != nil {
 		log.Error().Err(err).Msg("github worker: could not subscribe to hook stream")
 	}
@@ -91,11 +94,22 @@ func (h *GithubHooksHandler) handleIssueCommentCreatedEvent(deliveryID string, e
 		"eventType":  eventName,
 		"repository": *event.Repo.FullName,
 	}
-	_, err := h.commentStream.Publish(&GithubIssueCommentEventMsg{payload: event})
+	msg := &GithubIssueCommentEventMsg{payload: event, deliveryID: deliveryID}
+	ack, err := h.commentStream.Publish(msg)
 	if err != nil {
 		githubWebHookFailed.With(lbls).Inc()
+		return nil
+	}
+	// GitHub redelivers hooks with the same delivery ID
+	if ack.Duplicate {
+		log.Info().Str("deliveryID", deliveryID).Msg("dropping duplicate GitHub hook")
+		githubWebHookDuplicate.With(lbls).Inc()
+		return nil
 	}
 	githubWebHookSuccess.With(lbls).Inc()
+	if err := h.deliveries.RecordDelivery(deliveryID, getGithubJetstreamSubject(IssueCommentEvent), msg.EncodeEventData()); err != nil {
+		log.Error().Err(err).Str("deliveryID", deliveryID).Msg("could not record hook delivery")
+	}
 
 	return nil
 }
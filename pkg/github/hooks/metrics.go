@@ -32,6 +32,13 @@ var (
 		},
 		append(commonLabels, "reason"),
 	)
+	githubWebHookDuplicate = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tfbuddy_github_webhook_duplicate",
+			Help: "Count of all GitHub WebHook that were dropped as duplicates",
+		},
+		commonLabels,
+	)
 )
 
 func init() {
@@ -40,4 +47,5 @@ func init() {
 	r.MustRegister(githubWebHookSuccess)
 	r.MustRegister(githubWebHookFailed)
 	r.MustRegister(githubWebHookIgnored)
+	r.MustRegister(githubWebHookDuplicate)
 }
@@ -52,9 +52,15 @@ const IssueCommentEvent = "IssueCommentEvent"
 
 type GithubIssueCommentEventMsg struct {
 	payload *github.IssueCommentEvent
+	// deliveryID is the GitHub delivery ID, it isn't part of the stream message
+	deliveryID string
 }
 
+// GetId is the de-duplication ID of the message in the hooks stream.
 func (e *GithubIssueCommentEventMsg) GetId() string {
+	if e.deliveryID != "" {
+		return e.deliveryID
+	}
 	return fmt.Sprintf("%d", *e.payload.Comment.ID)
 }
 
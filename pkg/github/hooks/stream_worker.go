@@ -52,10 +52,11 @@ func (h *GithubHooksHandler) processIssueCommentEvent(msg *GithubIssueCommentEve
 	trigger := h.triggerCreation(h.vcs, h.tfc, h.runstream,
 		&tfc_trigger.TFCTriggerConfig{
 			Branch:                   pr.GetSourceBranch(),
-			CommitSHA:                pullReq.GetBase().GetSHA(),
+			CommitSHA:                pullReq.GetHeadSHA(),
 			ProjectNameWithNamespace: event.GetRepo().GetFullName(),
 			MergeRequestIID:          *event.Issue.Number,
 			TriggerSource:            tfc_trigger.CommentTrigger,
+			AllowDestroy:             opts.AllowDestroy,
 			VcsProvider:              "github",
 		})
 
@@ -76,11 +77,43 @@ func (h *GithubHooksHandler) processIssueCommentEvent(msg *GithubIssueCommentEve
 		trigger.GetConfig().SetAction(tfc_trigger.ApplyAction)
 		trigger.GetConfig().SetWorkspace(opts.Workspace)
 
+	case "cancel":
+		log.Info().Msg("Got TFC cancel command")
+		trigger.GetConfig().SetAction(tfc_trigger.CancelAction)
+		trigger.GetConfig().SetWorkspace(opts.Workspace)
+
+	case "confirm":
+		log.Info().Msg("Got TFC confirm command")
+		if !pullReq.IsApproved() {
+			h.postPullRequestComment(event, ":no_entry: Confirm failed. Pull Request requires approval.")
+			return nil
+		}
+		if pullReq.HasConflicts() {
+			h.postPullRequestComment(event, ":no_entry: Confirm failed. Pull Request has conflicts that need to be resolved.")
+			return nil
+		}
+		trigger.GetConfig().SetAction(tfc_trigger.ConfirmAction)
+		trigger.GetConfig().SetWorkspace(opts.Workspace)
+
+	case "discard":
+		log.Info().Msg("Got TFC discard command")
+		trigger.GetConfig().SetAction(tfc_trigger.DiscardAction)
+		trigger.GetConfig().SetWorkspace(opts.Workspace)
+
 	case "lock":
 		log.Info().Msg("Got TFC lock command")
 		trigger.GetConfig().SetAction(tfc_trigger.LockAction)
 		trigger.GetConfig().SetWorkspace(opts.Workspace)
 
+	case "override-policy":
+		log.Info().Msg("Got TFC override-policy command")
+		if user := event.GetComment().GetUser().GetLogin(); !allow_list.IsPolicyOverrideAllowed(user) {
+			h.postPullRequestComment(event, fmt.Sprintf(":no_entry: Override failed. %s is not allowed to override policies.", user))
+			return nil
+		}
+		trigger.GetConfig().SetAction(tfc_trigger.OverridePolicyAction)
+		trigger.GetConfig().SetWorkspace(opts.Workspace)
+
 	case "plan":
 		log.Info().Msg("Got TFC plan command")
 		trigger.GetConfig().SetAction(tfc_trigger.PlanAction)
@@ -103,6 +136,11 @@ func (h *GithubHooksHandler) processIssueCommentEvent(msg *GithubIssueCommentEve
 		h.postPullRequestComment(event, fmt.Sprintf(":no_entry: %s", failedMsg))
 		return nil
 	}
+	// the trigger reported its refusals on the PR, only transient errors are worth processing the hook again
+	if tfError != nil && !tfc_trigger.IsTransient(tfError) {
+		log.Info().Err(tfError).Msg("hook processing failed, not processing it again")
+		return nil
+	}
 	return tfError
 
 }
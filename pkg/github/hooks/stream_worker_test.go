@@ -0,0 +1,51 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	gogithub "github.com/google/go-github/v48/github"
+	"github.com/zapier/tfbuddy/pkg/github"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+	"github.com/zapier/tfbuddy/pkg/vcs"
+)
+
+func TestProcessIssueCommentEvent_HeadCommit(t *testing.T) {
+	t.Setenv("TFBUDDY_GITHUB_REPO_ALLOW_LIST", "zapier/")
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockGitClient := mocks.NewMockGitClient(mockCtrl)
+	mockGitClient.EXPECT().GetMergeRequest(101, "zapier/tfbuddy").Return(&github.GithubPR{PullRequest: &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.String("feature"), SHA: gogithub.String("head1234")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.String("main"), SHA: gogithub.String("base1234")},
+	}}, nil)
+
+	var cfg tfc_trigger.TriggerConfig
+	h := &GithubHooksHandler{
+		vcs: mockGitClient,
+		triggerCreation: func(gl vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient, c tfc_trigger.TriggerConfig) tfc_trigger.Trigger {
+			cfg = c
+			trigger := mocks.NewMockTrigger(mockCtrl)
+			trigger.EXPECT().GetConfig().Return(c).AnyTimes()
+			trigger.EXPECT().TriggerTFCEvents().Return(&tfc_trigger.TriggeredTFCWorkspaces{Queued: []string{"service-tfbuddy"}}, nil)
+			return trigger
+		},
+	}
+
+	err := h.processIssueCommentEvent(&GithubIssueCommentEventMsg{payload: &gogithub.IssueCommentEvent{
+		Repo:    &gogithub.Repository{FullName: gogithub.String("zapier/tfbuddy")},
+		Issue:   &gogithub.Issue{Number: gogithub.Int(101)},
+		Comment: &gogithub.IssueComment{ID: gogithub.Int64(1), Body: gogithub.String("tfc plan -w service-tfbuddy")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the PR head is planned, and checked out again if the run is queued, not the target branch
+	if cfg.GetCommitSHA() != "head1234" {
+		t.Fatalf("CommitSHA = %s, want the PR head commit", cfg.GetCommitSHA())
+	}
+}
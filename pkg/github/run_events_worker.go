@@ -8,6 +8,7 @@ import (
 	"github.com/zapier/tfbuddy/pkg/comment_formatter"
 	"github.com/zapier/tfbuddy/pkg/runstream"
 	"github.com/zapier/tfbuddy/pkg/tfc_api"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
 	"github.com/zapier/tfbuddy/pkg/vcs"
 )
 
@@ -52,25 +53,50 @@ func (w *RunEventsWorker) eventStreamCallback(re runstream.RunEvent) bool {
 	}
 	run.Status = tfe.RunStatus(re.GetNewStatus())
 
-	w.postRunStatusComment(run, re.GetMetadata())
+	if err := tfc_trigger.ConfirmApply(w.tfc, run, re.GetMetadata()); err != nil {
+		log.Error().Err(err).Str("runID", run.ID).Msg("could not confirm or discard apply")
+	}
+
+	if sha, err := w.rs.GetSupersedingCommit(re.GetMetadata()); err != nil {
+		log.Error().Err(err).Str("runID", run.ID).Msg("could not get latest plan of workspace")
+	} else if sha != "" {
+		// the MR thread of the run was marked as superseded, newer runs post the updates
+		log.Debug().Str("runID", run.ID).Str("supersededBy", sha).Msg("not posting status of superseded run")
+	} else {
+		w.postRunStatusComment(run, re.GetMetadata())
+	}
 	//w.updateCommitStatusForRun(run, re.GetMetadata())
+	tfc_trigger.UpdateMRSummary(w.client, w.rs, run, re.GetMetadata())
 	return true
 }
 
 func (w *RunEventsWorker) postRunStatusComment(run *tfe.Run, rmd runstream.RunMetadata) {
 
-	commentBody, _, _ := comment_formatter.FormatRunStatusCommentBody(w.tfc, run, rmd)
+	commentBody, continuedBodies, _, _ := comment_formatter.FormatRunStatusCommentBody(w.tfc, w.rs, run, rmd)
 
 	if commentBody != "" {
-		w.client.CreateMergeRequestComment(
+		commentBody += comment_formatter.RunTimelineInfo(w.rs, run, rmd)
+		if err := w.client.CreateMergeRequestComment(
 			rmd.GetMRInternalID(),
 			rmd.GetMRProjectNameWithNamespace(),
 			fmt.Sprintf(
 				"Status: `%s`<br>%s",
 				run.Status,
 				commentBody),
-		)
+		); err != nil {
+			log.Error().Err(err).Msg("could not post run status comment")
+		}
+	}
 
+	toPost := comment_formatter.ContinuedCommentsToPost(w.rs, rmd, continuedBodies)
+	for i, body := range toPost {
+		if err := w.client.CreateMergeRequestComment(
+			rmd.GetMRInternalID(),
+			rmd.GetMRProjectNameWithNamespace(),
+			comment_formatter.FormatContinuedComment(i+2, len(continuedBodies)+1, body),
+		); err != nil {
+			log.Error().Err(err).Msg("could not post continued run status comment")
+		}
 	}
 
 }
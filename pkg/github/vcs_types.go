@@ -19,8 +19,12 @@ func (c *GithubPRIssueComment) GetDiscussionID() string {
 	return fmt.Sprintf("%d", *c.ID)
 }
 
+// GetMRNotes returns the comment itself, it is the only note of its "discussion".
 func (c *GithubPRIssueComment) GetMRNotes() []vcs.MRNote {
-	return nil
+	if c.IssueComment == nil {
+		return nil
+	}
+	return []vcs.MRNote{&IssueComment{c.IssueComment}}
 }
 
 // ----------------------------------------------------------------------------
@@ -51,6 +55,9 @@ func (gm *GithubPR) GetAuthor() vcs.MRAuthor {
 func (gm *GithubPR) GetTitle() string {
 	return gm.PullRequest.GetTitle()
 }
+func (gm *GithubPR) GetHeadSHA() string {
+	return gm.PullRequest.GetHead().GetSHA()
+}
 func (gm *GithubPR) GetTargetBranch() string {
 	return gm.PullRequest.GetBase().GetRef()
 }
@@ -95,8 +102,12 @@ var _ vcs.MRApproved = (*PRApproved)(nil)
 
 type PRApproved struct {
 	approvalStatus bool
+	approvers      []string
 }
 
 func (p *PRApproved) IsApproved() bool {
 	return p.approvalStatus
 }
+func (p *PRApproved) GetApprovers() []string {
+	return p.approvers
+}
@@ -244,6 +244,9 @@ func (gm *GitlabMR) GetAuthor() vcs.MRAuthor {
 func (gm *GitlabMR) GetTitle() string {
 	return gm.MergeRequest.Title
 }
+func (gm *GitlabMR) GetHeadSHA() string {
+	return gm.MergeRequest.SHA
+}
 func (gm *GitlabMR) GetTargetBranch() string {
 	return gm.MergeRequest.TargetBranch
 }
@@ -278,6 +281,15 @@ type GitlabMRApproval struct {
 func (gm *GitlabMRApproval) IsApproved() bool {
 	return gm.Approved
 }
+func (gm *GitlabMRApproval) GetApprovers() []string {
+	approvers := make([]string, 0, len(gm.ApprovedBy))
+	for _, a := range gm.ApprovedBy {
+		if a.User != nil {
+			approvers = append(approvers, a.User.Username)
+		}
+	}
+	return approvers
+}
 func (g *GitlabClient) GetMergeRequestApprovals(mrIID int, project string) (vcs.MRApproved, error) {
 	approvals, _, err := g.client.MergeRequestApprovals.GetConfiguration(
 		project,
@@ -361,3 +373,17 @@ func (gE *GitlabMergeCommentEvent) GetLastCommit() vcs.Commit {
 func (gE *GitlabMergeCommentEvent) GetAttributes() vcs.MRAttributes {
 	return gE
 }
+func (gE *GitlabMergeCommentEvent) GetUser() vcs.MRAuthor {
+	return &GitlabEventUser{gE.User}
+}
+
+type GitlabEventUser struct {
+	*gogitlab.EventUser
+}
+
+func (gu *GitlabEventUser) GetUsername() string {
+	if gu.EventUser == nil {
+		return ""
+	}
+	return gu.Username
+}
@@ -13,7 +13,7 @@ import (
 
 func (p *RunStatusUpdater) postRunStatusComment(run *tfe.Run, rmd runstream.RunMetadata) {
 
-	commentBody, topLevelNoteBody, resolveDiscussion := comment_formatter.FormatRunStatusCommentBody(p.tfc, run, rmd)
+	commentBody, continuedBodies, topLevelNoteBody, resolveDiscussion := comment_formatter.FormatRunStatusCommentBody(p.tfc, p.rs, run, rmd)
 
 	if _, err := p.client.UpdateMergeRequestDiscussionNote(
 		rmd.GetMRInternalID(),
@@ -26,6 +26,7 @@ func (p *RunStatusUpdater) postRunStatusComment(run *tfe.Run, rmd runstream.RunM
 	}
 
 	if commentBody != "" {
+		commentBody += comment_formatter.RunTimelineInfo(p.rs, run, rmd)
 		p.postComment(fmt.Sprintf(
 			"Status: `%s`<br>%s",
 			run.Status,
@@ -36,6 +37,16 @@ func (p *RunStatusUpdater) postRunStatusComment(run *tfe.Run, rmd runstream.RunM
 		)
 	}
 
+	toPost := comment_formatter.ContinuedCommentsToPost(p.rs, rmd, continuedBodies)
+	for i, body := range toPost {
+		p.postComment(
+			comment_formatter.FormatContinuedComment(i+2, len(continuedBodies)+1, body),
+			rmd.GetMRProjectNameWithNamespace(),
+			rmd.GetMRInternalID(),
+			rmd.GetDiscussionID(),
+		)
+	}
+
 	if resolveDiscussion {
 
 		err := p.client.ResolveMergeRequestDiscussion(
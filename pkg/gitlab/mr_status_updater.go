@@ -6,6 +6,7 @@ import (
 	"github.com/hashicorp/go-tfe"
 	"github.com/rs/zerolog/log"
 	gogitlab "github.com/xanzy/go-gitlab"
+	"github.com/zapier/tfbuddy/pkg/plan_policy"
 	"github.com/zapier/tfbuddy/pkg/runstream"
 )
 
@@ -81,6 +82,35 @@ func (p *RunStatusUpdater) updateCommitStatusForRun(run *tfe.Run, rmd runstream.
 
 }
 
+// updatePolicyStatusForRun sets the `TFC/policy/<ws>` commit status from the policies evaluated against the plan.
+func (p *RunStatusUpdater) updatePolicyStatusForRun(run *tfe.Run, rmd runstream.RunMetadata) {
+	if run.Status != tfe.RunPlannedAndFinished && run.Status != tfe.RunPlanned {
+		return
+	}
+	policies := plan_policy.Policies(rmd.GetPolicies())
+	if len(policies) == 0 {
+		return
+	}
+
+	b, err := p.tfc.GetPlanOutput(run.Plan.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("could not get plan JSON")
+		p.updateStatus(gogitlab.Failed, "policy", rmd)
+		return
+	}
+	result, err := plan_policy.Evaluate(policies, b)
+	if err != nil {
+		log.Error().Err(err).Msg("could not evaluate policies")
+		p.updateStatus(gogitlab.Failed, "policy", rmd)
+		return
+	}
+	if result.Failed() {
+		p.updateStatus(gogitlab.Failed, "policy", rmd)
+	} else {
+		p.updateStatus(gogitlab.Success, "policy", rmd)
+	}
+}
+
 func (p *RunStatusUpdater) updateStatus(state gogitlab.BuildStateValue, action string, rmd runstream.RunMetadata) {
 	status := &gogitlab.SetCommitStatusOptions{
 		Name:        statusName(rmd.GetWorkspace(), action),
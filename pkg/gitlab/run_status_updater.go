@@ -5,6 +5,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/zapier/tfbuddy/pkg/runstream"
 	"github.com/zapier/tfbuddy/pkg/tfc_api"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
 	"github.com/zapier/tfbuddy/pkg/vcs"
 )
 
@@ -47,7 +48,20 @@ func (p *RunStatusUpdater) eventStreamCallback(re runstream.RunEvent) bool {
 	}
 	run.Status = tfe.RunStatus(re.GetNewStatus())
 
-	p.postRunStatusComment(run, re.GetMetadata())
+	if err := tfc_trigger.ConfirmApply(p.tfc, run, re.GetMetadata()); err != nil {
+		log.Error().Err(err).Str("runID", run.ID).Msg("could not confirm or discard apply")
+	}
+
+	if sha, err := p.rs.GetSupersedingCommit(re.GetMetadata()); err != nil {
+		log.Error().Err(err).Str("runID", run.ID).Msg("could not get latest plan of workspace")
+	} else if sha != "" {
+		// the MR thread of the run was marked as superseded, newer runs post the updates
+		log.Debug().Str("runID", run.ID).Str("supersededBy", sha).Msg("not posting status of superseded run")
+	} else {
+		p.postRunStatusComment(run, re.GetMetadata())
+	}
 	p.updateCommitStatusForRun(run, re.GetMetadata())
+	p.updatePolicyStatusForRun(run, re.GetMetadata())
+	tfc_trigger.UpdateMRSummary(p.client, p.rs, run, re.GetMetadata())
 	return true
 }
@@ -40,6 +40,7 @@ func (w *GitlabEventWorker) processNoteEvent(event vcs.MRCommentEvent) (projectN
 			ProjectNameWithNamespace: proj,
 			MergeRequestIID:          event.GetMR().GetInternalID(),
 			TriggerSource:            tfc_trigger.CommentTrigger,
+			AllowDestroy:             opts.AllowDestroy,
 			VcsProvider:              "gitlab",
 		})
 
@@ -63,11 +64,43 @@ func (w *GitlabEventWorker) processNoteEvent(event vcs.MRCommentEvent) (projectN
 		trigger.GetConfig().SetAction(tfc_trigger.ApplyAction)
 		trigger.GetConfig().SetWorkspace(opts.Workspace)
 
+	case "cancel":
+		log.Info().Msg("Got TFC cancel command")
+		trigger.GetConfig().SetAction(tfc_trigger.CancelAction)
+		trigger.GetConfig().SetWorkspace(opts.Workspace)
+
+	case "confirm":
+		log.Info().Msg("Got TFC confirm command")
+		if !w.checkApproval(event) {
+			w.postMessageToMergeRequest(event, ":no_entry: Confirm failed. Merge Request requires approval.")
+			return proj, nil
+		}
+		if !w.checkForMergeConflicts(event) {
+			w.postMessageToMergeRequest(event, ":no_entry: Confirm failed. Merge Request has conflicts that need to be resolved.")
+			return proj, nil
+		}
+		trigger.GetConfig().SetAction(tfc_trigger.ConfirmAction)
+		trigger.GetConfig().SetWorkspace(opts.Workspace)
+
+	case "discard":
+		log.Info().Msg("Got TFC discard command")
+		trigger.GetConfig().SetAction(tfc_trigger.DiscardAction)
+		trigger.GetConfig().SetWorkspace(opts.Workspace)
+
 	case "lock":
 		log.Info().Msg("Got TFC lock command")
 		trigger.GetConfig().SetAction(tfc_trigger.LockAction)
 		trigger.GetConfig().SetWorkspace(opts.Workspace)
 
+	case "override-policy":
+		log.Info().Msg("Got TFC override-policy command")
+		if user := event.GetUser().GetUsername(); !allow_list.IsPolicyOverrideAllowed(user) {
+			w.postMessageToMergeRequest(event, fmt.Sprintf(":no_entry: Override failed. %s is not allowed to override policies.", user))
+			return proj, nil
+		}
+		trigger.GetConfig().SetAction(tfc_trigger.OverridePolicyAction)
+		trigger.GetConfig().SetWorkspace(opts.Workspace)
+
 	case "plan":
 		log.Info().Msg("Got TFC plan command")
 		trigger.GetConfig().SetAction(tfc_trigger.PlanAction)
@@ -79,6 +79,20 @@ func Test_parseCommentCommand(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "tfc apply (allow destroy)",
+			args: args{"tfc apply -w service-foo --allow-destroy"},
+			want: &comment_actions.CommentOpts{
+				Args: comment_actions.CommentArgs{
+					Agent:   "tfc",
+					Command: "apply",
+					Rest:    nil,
+				},
+				Workspace:    "service-foo",
+				AllowDestroy: true,
+			},
+			wantErr: false,
+		},
 		{
 			name:    "not tfc command",
 			args:    args{"amazing gitlab review comment"},
@@ -345,3 +359,57 @@ func TestProcessNoteEventPlanFailedMultipleWorkspaces(t *testing.T) {
 		t.Fatal("expected a project name to be returned")
 	}
 }
+
+func TestProcessNoteEventOverridePolicyDenied(t *testing.T) {
+	os.Setenv(allow_list.GitlabProjectAllowListEnv, "zapier/")
+	defer os.Unsetenv(allow_list.GitlabProjectAllowListEnv)
+	os.Setenv(allow_list.PolicyOverrideUsersEnv, "alice")
+	defer os.Unsetenv(allow_list.PolicyOverrideUsersEnv)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockGitClient := mocks.NewMockGitClient(mockCtrl)
+	mockGitClient.EXPECT().CreateMergeRequestComment(101, "zapier/service-tf-buddy", ":no_entry: Override failed. mallory is not allowed to override policies.")
+
+	mockProject := mocks.NewMockProject(mockCtrl)
+	mockProject.EXPECT().GetPathWithNamespace().Return("zapier/service-tf-buddy").Times(2)
+
+	mockLastCommit := mocks.NewMockCommit(mockCtrl)
+	mockLastCommit.EXPECT().GetSHA().Return("abvc12345")
+
+	mockAttributes := mocks.NewMockMRAttributes(mockCtrl)
+	mockAttributes.EXPECT().GetNote().Return("tfc override-policy -w service-tf-buddy")
+	mockAttributes.EXPECT().GetType().Return("SomeNote")
+
+	mockUser := mocks.NewMockMRAuthor(mockCtrl)
+	mockUser.EXPECT().GetUsername().Return("mallory")
+
+	mockMREvent := mocks.NewMockMRCommentEvent(mockCtrl)
+	mockMREvent.EXPECT().GetProject().Return(mockProject).Times(2)
+	mockMREvent.EXPECT().GetAttributes().Return(mockAttributes).Times(2)
+	mockMREvent.EXPECT().GetLastCommit().Return(mockLastCommit)
+	mockMREvent.EXPECT().GetUser().Return(mockUser)
+
+	mockSimpleMR := mocks.NewMockMR(mockCtrl)
+	mockSimpleMR.EXPECT().GetSourceBranch().Return("DTA-2009")
+	mockSimpleMR.EXPECT().GetInternalID().Return(101).Times(2)
+	mockMREvent.EXPECT().GetMR().Return(mockSimpleMR).Times(3)
+
+	mockTFCTrigger := mocks.NewMockTrigger(mockCtrl)
+
+	client := &GitlabEventWorker{
+		gl:        mockGitClient,
+		tfc:       mocks.NewMockApiClient(mockCtrl),
+		runstream: mocks.NewMockStreamClient(mockCtrl),
+		triggerCreation: func(gl vcs.GitClient, tfc tfc_api.ApiClient, runstream runstream.StreamClient, cfg tfc_trigger.TriggerConfig) tfc_trigger.Trigger {
+			return mockTFCTrigger
+		},
+	}
+
+	proj, err := client.processNoteEvent(mockMREvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj != "zapier/service-tf-buddy" {
+		t.Fatal("expected a project name to be returned")
+	}
+}
@@ -0,0 +1,55 @@
+package gitlab_hooks
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	gogitlab "github.com/xanzy/go-gitlab"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+	"github.com/zapier/tfbuddy/pkg/vcs"
+)
+
+// isPush returns true if the MR event is a push of new commits.
+func isPush(event *gogitlab.MergeEvent) bool {
+	return event.ObjectAttributes.Action == "update" &&
+		event.ObjectAttributes.OldRev != "" &&
+		event.ObjectAttributes.OldRev != event.ObjectAttributes.LastCommit.ID
+}
+
+// debounceWindow returns the debounce setting of the project's .tfbuddy.yaml on the MR's target branch, 0 if it can't
+// be read.
+func debounceWindow(gl vcs.GitClient, event *gogitlab.MergeEvent) time.Duration {
+	project := event.Project.PathWithNamespace
+	window, err := tfc_trigger.DebounceWindow(gl, project, event.ObjectAttributes.TargetBranch)
+	if err != nil {
+		log.Error().Err(err).Str("project", project).Msg("could not read debounce window, planning right away")
+		return 0
+	}
+	return window
+}
+
+// debounce returns how long to wait before planning a pushed commit, 0 if it can be planned now. superseded is true
+// if another commit was pushed to the MR since, the event of that commit will plan it. The debounce window was
+// recorded with the pending plan when the commit was pushed.
+func (w *GitlabEventWorker) debounce(msg *MergeRequestEventMsg) (wait time.Duration, superseded bool) {
+	key := msg.DebounceKey()
+	pp, err := w.deliveries.GetPendingPlan(key)
+	if err != nil {
+		log.Error().Err(err).Str("key", key).Msg("could not read pending plan, planning right away")
+		return 0, false
+	}
+	if pp == nil {
+		// replayed hooks & pushes received before debouncing was enabled
+		return 0, false
+	}
+	if pp.CommitSHA != msg.payload.ObjectAttributes.LastCommit.ID {
+		return 0, true
+	}
+	if wait := time.Until(pp.PushedAt.Add(pp.Window)); wait > 0 {
+		return wait, false
+	}
+	if err := w.deliveries.ClearPendingPlan(key, pp); err != nil {
+		log.Error().Err(err).Str("key", key).Msg("could not clear pending plan")
+	}
+	return 0, false
+}
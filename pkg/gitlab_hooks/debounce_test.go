@@ -0,0 +1,103 @@
+package gitlab_hooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	gogitlab "github.com/xanzy/go-gitlab"
+	"github.com/zapier/tfbuddy/pkg/hooks_stream"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+)
+
+// testPendingPlans keeps the pending plans of debounced pushes in memory.
+type testPendingPlans struct {
+	hooks_stream.DeliveryRecorder
+	plans map[string]*hooks_stream.PendingPlan
+}
+
+func (r *testPendingPlans) SetPendingPlan(key, commitSHA string, window time.Duration) error {
+	r.plans[key] = &hooks_stream.PendingPlan{CommitSHA: commitSHA, PushedAt: time.Now(), Window: window}
+	return nil
+}
+
+func (r *testPendingPlans) GetPendingPlan(key string) (*hooks_stream.PendingPlan, error) {
+	return r.plans[key], nil
+}
+
+func (r *testPendingPlans) ClearPendingPlan(key string, pp *hooks_stream.PendingPlan) error {
+	if r.plans[key] == pp {
+		delete(r.plans, key)
+	}
+	return nil
+}
+
+func testPush(sha string) *MergeRequestEventMsg {
+	event := &gogitlab.MergeEvent{}
+	event.Project.PathWithNamespace = "zapier/tfbuddy"
+	event.ObjectAttributes.TargetBranch = "main"
+	event.ObjectAttributes.IID = 101
+	event.ObjectAttributes.Action = "update"
+	event.ObjectAttributes.OldRev = "0000aaaa"
+	event.ObjectAttributes.LastCommit.ID = sha
+	return &MergeRequestEventMsg{payload: event}
+}
+
+func Test_debounceWindow(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	gl := mocks.NewMockGitClient(mockCtrl)
+	// the window is read from the target branch, a MR can't change how its own pushes are planned
+	gl.EXPECT().GetRepoFile("zapier/tfbuddy", tfc_trigger.ProjectConfigFilename, "main").Return([]byte("debounce: 1m\n"), nil)
+	gl.EXPECT().GetRepoFile("zapier/tfbuddy", tfc_trigger.ProjectConfigFilename, "release").Return([]byte("workspaces: []\n"), nil)
+	gl.EXPECT().GetRepoFile("zapier/tfbuddy", tfc_trigger.ProjectConfigFilename, "broken").Return([]byte("debounce: soon\n"), nil)
+
+	push := testPush("abcd1234")
+	assert.Equal(t, time.Minute, debounceWindow(gl, push.payload))
+
+	// without a debounce setting on the target branch, pushes are planned right away
+	push.payload.ObjectAttributes.TargetBranch = "release"
+	assert.Zero(t, debounceWindow(gl, push.payload))
+
+	push.payload.ObjectAttributes.TargetBranch = "broken"
+	assert.Zero(t, debounceWindow(gl, push.payload), "pushes are planned right away if the window can't be read")
+}
+
+func TestGitlabEventWorker_debounce(t *testing.T) {
+	// the window is recorded with the pending plan, the delayed deliveries don't read the project config again
+	pending := &testPendingPlans{plans: map[string]*hooks_stream.PendingPlan{}}
+	w := &GitlabEventWorker{deliveries: pending}
+
+	first, second := testPush("abcd1234"), testPush("efgh5678")
+	assert.Equal(t, "gitlab/zapier/tfbuddy!101", first.DebounceKey())
+
+	// without a pending plan, e.g. a replayed hook, the push is planned right away
+	wait, superseded := w.debounce(first)
+	assert.Zero(t, wait)
+	assert.False(t, superseded)
+
+	_ = pending.SetPendingPlan(first.DebounceKey(), "abcd1234", time.Minute)
+	wait, superseded = w.debounce(first)
+	assert.InDelta(t, time.Minute, wait, float64(time.Second))
+	assert.False(t, superseded)
+
+	_ = pending.SetPendingPlan(second.DebounceKey(), "efgh5678", time.Minute)
+	_, superseded = w.debounce(first)
+	assert.True(t, superseded, "the older push is dropped")
+
+	pending.plans[second.DebounceKey()].PushedAt = time.Now().Add(-time.Minute)
+	wait, superseded = w.debounce(second)
+	assert.Zero(t, wait)
+	assert.False(t, superseded)
+	assert.Empty(t, pending.plans, "the pending plan is cleared once planned")
+
+	// without a debounce window, pushes are planned right away
+	third := testPush("ijkl9012")
+	_ = pending.SetPendingPlan(third.DebounceKey(), "ijkl9012", 0)
+	wait, superseded = w.debounce(third)
+	assert.Zero(t, wait)
+	assert.False(t, superseded)
+	assert.Empty(t, pending.plans)
+}
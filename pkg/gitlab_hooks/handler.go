@@ -21,6 +21,9 @@ import (
 )
 
 const GitlabTokenHeader = "X-Gitlab-Token"
+
+// GitlabEventUUIDHeader identifies a hook delivery, it is used to redeliver the hook
+const GitlabEventUUIDHeader = "X-Gitlab-Event-UUID"
 const GitlabHookIgnoreReasonUnhandledEventType = "unhandled-event-type"
 
 type TriggerCreationFunc func(gl vcs.GitClient,
@@ -33,6 +36,7 @@ type GitlabHooksHandler struct {
 	gl              vcs.GitClient
 	runstream       runstream.StreamClient
 	triggerCreation TriggerCreationFunc
+	deliveries      hooks_stream.DeliveryRecorder
 
 	// hook streams and workers
 	hookSecretKey string
@@ -41,7 +45,7 @@ type GitlabHooksHandler struct {
 	hooksWorker   *GitlabEventWorker
 }
 
-func NewGitlabHooksHandler(gl vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient, js nats.JetStreamContext) *GitlabHooksHandler {
+func NewGitlabHooksHandler(gl vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient, js nats.JetStreamContext, deliveries hooks_stream.DeliveryRecorder) *GitlabHooksHandler {
 	hookSecretEnv := os.Getenv("TFBUDDY_GITLAB_HOOK_SECRET_KEY")
 	notesStream := gongs.NewGenericStream[NoteEventMsg](js, noteEventsStreamSubject(), hooks_stream.HooksStreamName)
 	mrStream := gongs.NewGenericStream[MergeRequestEventMsg](js, mrEventsStreamSubject(), hooks_stream.HooksStreamName)
@@ -51,6 +55,7 @@ func NewGitlabHooksHandler(gl vcs.GitClient, tfc tfc_api.ApiClient, rs runstream
 		gl:              gl,
 		runstream:       rs,
 		triggerCreation: tfc_trigger.NewTFCTrigger,
+		deliveries:      deliveries,
 		mrStream:        mrStream,
 		notesStream:     notesStream,
 		hookSecretKey:   hookSecretEnv,
@@ -88,6 +93,8 @@ func (h *GitlabHooksHandler) handler(c echo.Context) error {
 
 	var err error
 	var proj string
+	// duplicate is the reason a hook was dropped as a duplicate of a hook already received
+	var duplicate string
 	labels["eventType"] = string(eventType)
 	switch eventType {
 	case gogitlab.EventTypeMergeRequest:
@@ -100,11 +107,32 @@ func (h *GitlabHooksHandler) handler(c echo.Context) error {
 		msg := &MergeRequestEventMsg{
 			GitlabHookEvent: GitlabHookEvent{},
 			payload:         event,
+			deliveryID:      c.Request().Header.Get(GitlabEventUUIDHeader),
 		}
 
 		proj = event.Project.PathWithNamespace
-		_, err = h.mrStream.Publish(msg)
-		checkError(err, "could not publish merge request event to stream")
+		// GitLab retries hooks, the same MR event must not trigger the same plan twice
+		if isDuplicate, err := h.deliveries.MarkProcessed(msg.DedupKey()); err != nil {
+			log.Error().Err(err).Msg("could not check merge request event for duplicates")
+		} else if isDuplicate {
+			duplicate = "commit-action"
+			break
+		}
+		// pushes are planned once they have been quiet for the project's debounce window, the last one wins. The window
+		// is read once here, not on each delayed delivery of the event.
+		if isPush(event) {
+			checkError(h.deliveries.SetPendingPlan(msg.DebounceKey(), event.ObjectAttributes.LastCommit.ID, debounceWindow(h.gl, event)), "could not record pending plan")
+		}
+		ack, err := h.mrStream.Publish(msg)
+		if checkError(err, "could not publish merge request event to stream") {
+			checkError(h.deliveries.UnmarkProcessed(msg.DedupKey()), "could not forget merge request event")
+			break
+		}
+		if ack.Duplicate {
+			duplicate = "delivery-id"
+			break
+		}
+		h.recordDelivery(c, mrEventsStreamSubject(), msg.EncodeEventData())
 
 	case gogitlab.EventTypeNote:
 		log.Info().Msg("processing GitLab Note/Comment event")
@@ -114,9 +142,17 @@ func (h *GitlabHooksHandler) handler(c echo.Context) error {
 			break
 		}
 
+		event.deliveryID = c.Request().Header.Get(GitlabEventUUIDHeader)
 		proj = event.payload.GetProject().GetPathWithNamespace()
-		_, err = h.notesStream.Publish(event)
-		checkError(err, "could not publish note event to stream")
+		ack, err := h.notesStream.Publish(event)
+		if checkError(err, "could not publish note event to stream") {
+			break
+		}
+		if ack.Duplicate {
+			duplicate = "delivery-id"
+			break
+		}
+		h.recordDelivery(c, noteEventsStreamSubject(), event.EncodeEventData())
 
 	default:
 		log.Info().Msgf("Ignoring Gitlab Event type: %s", eventType)
@@ -127,6 +163,13 @@ func (h *GitlabHooksHandler) handler(c echo.Context) error {
 	}
 	labels["project"] = proj
 
+	if duplicate != "" {
+		log.Info().Str("project", proj).Str("reason", duplicate).Msg("dropping duplicate GitLab hook")
+		labels["reason"] = duplicate
+		gitlabWebHookDuplicate.With(labels).Inc()
+		return c.String(http.StatusOK, "OK")
+	}
+
 	if err != nil {
 		labels["reason"] = "error"
 		gitlabWebHookFailed.With(labels).Inc()
@@ -138,6 +181,15 @@ func (h *GitlabHooksHandler) handler(c echo.Context) error {
 	return c.String(http.StatusOK, "OK")
 }
 
+// recordDelivery keeps the published hook, so it can be redelivered by its event UUID.
+func (h *GitlabHooksHandler) recordDelivery(c echo.Context, subject string, data []byte) {
+	id := c.Request().Header.Get(GitlabEventUUIDHeader)
+	if id == "" {
+		return
+	}
+	checkError(h.deliveries.RecordDelivery(id, subject, data), "could not record hook delivery")
+}
+
 func getGitlabEventBody[T any](c echo.Context) (*T, error) {
 	event := new(T)
 
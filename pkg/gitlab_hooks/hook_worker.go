@@ -3,6 +3,7 @@ package gitlab_hooks
 import (
 	"github.com/nats-io/nats.go"
 	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/hooks_stream"
 	"github.com/zapier/tfbuddy/pkg/runstream"
 	"github.com/zapier/tfbuddy/pkg/tfc_api"
 	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
@@ -13,6 +14,7 @@ type GitlabEventWorker struct {
 	tfc             tfc_api.ApiClient
 	gl              vcs.GitClient
 	runstream       runstream.StreamClient
+	deliveries      hooks_stream.DeliveryRecorder
 	triggerCreation TriggerCreationFunc
 }
 
@@ -21,15 +23,16 @@ func NewGitlabEventWorker(h *GitlabHooksHandler, js nats.JetStreamContext) *Gitl
 		tfc:             h.tfc,
 		gl:              h.gl,
 		runstream:       h.runstream,
+		deliveries:      h.deliveries,
 		triggerCreation: tfc_trigger.NewTFCTrigger,
 	}
 
-	_, err := h.mrStream.QueueSubscribe("gitlab_mr_event_worker", w.processMREventStreamMsg)
+	_, err := hooks_stream.QueueSubscribe[MergeRequestEventMsg](js, mrEventsStreamSubject(), "gitlab_mr_event_worker", w.processMREventStreamMsg)
 	if err != nil {
 		log.Error().Err(err).Msg("could not subscribe to hook stream")
 	}
 
-	_, err = h.notesStream.QueueSubscribe("gitlab_note_event_worker", w.processNoteEventStreamMsg)
+	_, err = hooks_stream.QueueSubscribe[NoteEventMsg](js, noteEventsStreamSubject(), "gitlab_note_event_worker", w.processNoteEventStreamMsg)
 	if err != nil {
 		log.Error().Err(err).Msg("could not subscribe to hook stream")
 	}
@@ -38,15 +41,22 @@ func NewGitlabEventWorker(h *GitlabHooksHandler, js nats.JetStreamContext) *Gitl
 }
 
 func (w *GitlabEventWorker) processNoteEventStreamMsg(msg *NoteEventMsg) error {
-
-	w.processNoteEvent(msg)
-
-	return nil
+	_, err := w.processNoteEvent(msg)
+	return redeliveryError(err)
 }
 
 func (w *GitlabEventWorker) processMREventStreamMsg(msg *MergeRequestEventMsg) error {
+	_, err := w.processMergeRequestEvent(msg)
+	return redeliveryError(err)
+}
 
-	w.processMergeRequestEvent(msg)
-
+// redeliveryError returns the errors of hooks that must be processed again: transient trigger errors & delays. Other
+// errors, like refused applies or workspaces without changes, were reported on the MR; processing the hook again would
+// report them again, so the hook is handled.
+func redeliveryError(err error) error {
+	if err == nil || tfc_trigger.IsTransient(err) || hooks_stream.IsDelay(err) {
+		return err
+	}
+	log.Info().Err(err).Msg("hook processing failed, not processing it again")
 	return nil
 }
@@ -0,0 +1,25 @@
+package gitlab_hooks
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/hooks_stream"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+)
+
+func Test_redeliveryError(t *testing.T) {
+	transient := &tfc_trigger.TransientError{Err: errors.New("nats: timeout")}
+	delay := hooks_stream.Delay(time.Minute)
+
+	assert.NoError(t, redeliveryError(nil))
+	assert.NoError(t, redeliveryError(tfc_trigger.ErrNoChangesDetected), "reported refusals are not processed again")
+	assert.NoError(t, redeliveryError(tfc_trigger.ErrCostApprovalMissing))
+	assert.Equal(t, transient, redeliveryError(transient))
+	wrapped := fmt.Errorf("could not trigger: %w", transient)
+	assert.Equal(t, wrapped, redeliveryError(wrapped))
+	assert.Equal(t, delay, redeliveryError(delay))
+}
@@ -33,6 +33,13 @@ var (
 		},
 		commonLabels,
 	)
+	gitlabWebHookDuplicate = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tfbuddy_gitlab_webhook_duplicate",
+			Help: "Count of all GitLab WebHook that were dropped as duplicates",
+		},
+		commonLabels,
+	)
 
 	gitlabHookReadFromStream = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "tfbuddy_gitlab_hook_stream_msg_read",
@@ -46,6 +53,7 @@ func init() {
 	r.MustRegister(gitlabWebHookSuccess)
 	r.MustRegister(gitlabWebHookFailed)
 	r.MustRegister(gitlabWebHookIgnored)
+	r.MustRegister(gitlabWebHookDuplicate)
 
 	r.MustRegister(gitlabHookReadFromStream)
 }
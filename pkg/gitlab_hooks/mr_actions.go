@@ -5,6 +5,7 @@ import (
 	"github.com/rs/zerolog/log"
 	gogitlab "github.com/xanzy/go-gitlab"
 	"github.com/zapier/tfbuddy/pkg/allow_list"
+	"github.com/zapier/tfbuddy/pkg/hooks_stream"
 	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
 )
 
@@ -40,7 +41,17 @@ func (w *GitlabEventWorker) processMergeRequestEvent(msg *MergeRequestEventMsg)
 		return projectName, err
 
 	case "update":
-		if event.ObjectAttributes.OldRev != "" && event.ObjectAttributes.OldRev != event.ObjectAttributes.LastCommit.ID {
+		if isPush(event) {
+			wait, superseded := w.debounce(msg)
+			if superseded {
+				log.Debug().Str("project", projectName).Int("mrIID", event.ObjectAttributes.IID).Msg("push superseded by a newer push, not planning it")
+				labels["reason"] = "debounced"
+				gitlabWebHookIgnored.With(labels).Inc()
+				return projectName, nil
+			}
+			if wait > 0 {
+				return projectName, hooks_stream.Delay(wait)
+			}
 			_, err := trigger.TriggerTFCEvents()
 			return projectName, err
 		}
@@ -32,10 +32,17 @@ type NoteEventMsg struct {
 	GitlabHookEvent
 
 	payload *gitlab.GitlabMergeCommentEvent
+	// deliveryID is the GitLab event UUID, it isn't part of the stream message
+	deliveryID string
 }
 
+// GetId is the de-duplication ID of the message in the hooks stream.
 func (e *NoteEventMsg) GetId() string {
-	return e.payload.GetDiscussionID()
+	if e.deliveryID != "" {
+		return e.deliveryID
+	}
+	// a discussion has many notes, each of them can be a command
+	return fmt.Sprintf("gitlab/%s!%d#note_%d", e.payload.Project.PathWithNamespace, e.payload.MergeRequest.IID, e.payload.ObjectAttributes.ID)
 }
 
 func (e *NoteEventMsg) DecodeEventData(b []byte) error {
@@ -69,6 +76,10 @@ func (e *NoteEventMsg) GetLastCommit() vcs.Commit {
 	return e.payload.GetLastCommit()
 }
 
+func (e *NoteEventMsg) GetUser() vcs.MRAuthor {
+	return e.payload.GetUser()
+}
+
 // ----------------------------------------------
 
 func mrEventsStreamSubject() string {
@@ -79,10 +90,31 @@ type MergeRequestEventMsg struct {
 	GitlabHookEvent
 
 	payload *gogitlab.MergeEvent
+	// deliveryID is the GitLab event UUID, it isn't part of the stream message
+	deliveryID string
 }
 
+// GetId is the de-duplication ID of the message in the hooks stream.
 func (e *MergeRequestEventMsg) GetId() string {
-	return fmt.Sprintf("%d", e.payload.ObjectAttributes.ID)
+	if e.deliveryID != "" {
+		return e.deliveryID
+	}
+	return e.DedupKey()
+}
+
+// DedupKey identifies the MR event by project, MR, commit SHA & action, the same MR event is only processed once.
+func (e *MergeRequestEventMsg) DedupKey() string {
+	return fmt.Sprintf("gitlab/%s!%d@%s:%s",
+		e.payload.Project.PathWithNamespace,
+		e.payload.ObjectAttributes.IID,
+		e.payload.ObjectAttributes.LastCommit.ID,
+		e.payload.ObjectAttributes.Action,
+	)
+}
+
+// DebounceKey identifies the MR of the event, the pushes to a MR are debounced together.
+func (e *MergeRequestEventMsg) DebounceKey() string {
+	return fmt.Sprintf("gitlab/%s!%d", e.payload.Project.PathWithNamespace, e.payload.ObjectAttributes.IID)
 }
 
 func (e *MergeRequestEventMsg) DecodeEventData(b []byte) error {
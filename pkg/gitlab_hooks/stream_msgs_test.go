@@ -0,0 +1,38 @@
+package gitlab_hooks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gogitlab "github.com/xanzy/go-gitlab"
+	"github.com/zapier/tfbuddy/pkg/gitlab"
+)
+
+func TestMergeRequestEventMsg_GetId(t *testing.T) {
+	event := &gogitlab.MergeEvent{}
+	event.Project.PathWithNamespace = "zapier/tfbuddy"
+	event.ObjectAttributes.IID = 101
+	event.ObjectAttributes.LastCommit.ID = "abcd1234"
+	event.ObjectAttributes.Action = "update"
+
+	msg := &MergeRequestEventMsg{payload: event}
+	assert.Equal(t, "gitlab/zapier/tfbuddy!101@abcd1234:update", msg.DedupKey())
+	assert.Equal(t, msg.DedupKey(), msg.GetId(), "without an event UUID, MR events are de-duplicated by their key")
+
+	msg.deliveryID = "5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1"
+	assert.Equal(t, "5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1", msg.GetId())
+}
+
+func TestNoteEventMsg_GetId(t *testing.T) {
+	event := &gitlab.GitlabMergeCommentEvent{MergeCommentEvent: &gogitlab.MergeCommentEvent{}}
+	event.Project.PathWithNamespace = "zapier/tfbuddy"
+	event.MergeRequest.IID = 101
+	event.ObjectAttributes.ID = 1234
+	event.ObjectAttributes.DiscussionID = "6a9c1750b37d513a43987b574953fceb50b03ce7"
+
+	msg := &NoteEventMsg{payload: event}
+	assert.Equal(t, "gitlab/zapier/tfbuddy!101#note_1234", msg.GetId(), "without an event UUID, notes are de-duplicated by their ID")
+
+	msg.deliveryID = "5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1"
+	assert.Equal(t, "5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1", msg.GetId())
+}
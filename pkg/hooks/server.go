@@ -7,8 +7,14 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/nats-io/nats.go"
 	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/api"
+	"github.com/zapier/tfbuddy/pkg/comment_formatter"
+	"github.com/zapier/tfbuddy/pkg/dashboard"
 	"github.com/zapier/tfbuddy/pkg/github"
 	"github.com/zapier/tfbuddy/pkg/hooks_stream"
+	"github.com/zapier/tfbuddy/pkg/notifier"
+	"github.com/zapier/tfbuddy/pkg/plan_policy"
+	"github.com/zapier/tfbuddy/pkg/slack"
 	"github.com/ziflex/lecho/v3"
 
 	ghHooks "github.com/zapier/tfbuddy/pkg/github/hooks"
@@ -18,6 +24,7 @@ import (
 	"github.com/zapier/tfbuddy/pkg/runstream"
 	"github.com/zapier/tfbuddy/pkg/tfc_api"
 	"github.com/zapier/tfbuddy/pkg/tfc_hooks"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
 )
 
 func StartServer() {
@@ -48,10 +55,34 @@ func StartServer() {
 	health.AddLivenessCheck("runstream-streams", rs.HealthCheck)
 	health.AddLivenessCheck("hook-stream", hs.HealthCheck)
 
+	// validate custom comment templates & policies on startup rather than on the first run status update
+	comment_formatter.LoadServerTemplates()
+	plan_policy.LoadServerPolicies()
+
 	// setup API clients
 	gl := gitlab.NewGitlabClient()
 	tfc := tfc_api.NewTFCClient()
 
+	// read only JSON API & dashboard
+	if apiGroup := api.NewHandler(tfc, rs, hs).Register(e); apiGroup != nil {
+		d := dashboard.NewDashboard(rs)
+		d.Register(e, apiGroup)
+		closeObserver, err := runstream.ObserveTFRunEvents(nc, "", d.Observe)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not observe run events for the dashboard")
+		}
+		defer closeObserver()
+	}
+
+	// outbound notifications to Slack & webhooks
+	if n := notifier.NewNotifier(rs, tfc); n.Enabled() {
+		closeNotifier, err := n.Start()
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not start notifier")
+		}
+		defer closeNotifier()
+	}
+
 	hooksGroup := e.Group("/hooks")
 	hooksGroup.Use(middleware.BodyDump(func(c echo.Context, reqBody, resBody []byte) {
 		log.Trace().RawJSON("body", reqBody).Msg("Received hook request")
@@ -63,16 +94,40 @@ func StartServer() {
 	// Github
 	//
 	gh := github.NewGithubClient()
-	githubHooksHandler := ghHooks.NewGithubHooksHandler(gh, tfc, rs, js)
+	githubHooksHandler := ghHooks.NewGithubHooksHandler(gh, tfc, rs, js, hs)
 	hooksGroup.POST("/github/events", githubHooksHandler.Handler)
 
 	//
 	// Gitlab
 	//
-	gitlabGroupHandler := gitlab_hooks.NewGitlabHooksHandler(gl, tfc, rs, js)
+	gitlabGroupHandler := gitlab_hooks.NewGitlabHooksHandler(gl, tfc, rs, js, hs)
 	hooksGroup.POST("/gitlab/group", gitlabGroupHandler.GroupHandler())
 	hooksGroup.POST("/gitlab/project", gitlabGroupHandler.ProjectHandler())
 
+	//
+	// Slack
+	//
+	if slack.Enabled() {
+		slackHandler := slack.NewCommandHandler(gl, gh, tfc, rs)
+		hooksGroup.POST("/slack/command", slackHandler.Handler)
+		closeSlackUpdater, err := slack.NewRunUpdater(rs).Start()
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not subscribe to run events for Slack")
+		}
+		defer closeSlackUpdater()
+	}
+
+	//
+	// TFC run queue
+	//
+	if runstream.LoadRunLimits().Enabled() {
+		closeDispatcher, err := tfc_trigger.NewRunDispatcher(gl, gh, tfc, rs).Start()
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not subscribe to run events for the TFC run queue")
+		}
+		defer closeDispatcher()
+	}
+
 	//
 	// Terraform Cloud
 	//
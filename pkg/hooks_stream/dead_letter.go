@@ -0,0 +1,284 @@
+package hooks_stream
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"github.com/sl1pm4t/gongs"
+)
+
+const HooksDeadLetterStreamName = "HOOKS_DEAD_LETTER"
+
+const HookFailuresKvBucket = "HOOKS_FAILURES"
+
+// MaxDeliveriesEnvName is the number of times the processing of a hook fails before it is moved to the dead-letter
+// stream.
+const MaxDeliveriesEnvName = "TFBUDDY_HOOKS_MAX_DELIVERIES"
+
+const defaultMaxDeliveries = 3
+
+// redeliveryDelay is the delay before a failed hook is processed again, multiplied by the number of failures
+var redeliveryDelay = 10 * time.Second
+
+// Headers of dead-letter messages
+const (
+	OriginalSubjectHeader = "Tfbuddy-Original-Subject"
+	ErrorHeader           = "Tfbuddy-Error"
+	DeliveriesHeader      = "Tfbuddy-Deliveries"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead-letter message not found")
+
+var hooksDeadLettered = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "tfbuddy_hooks_dead_lettered",
+	Help: "Count of hook messages moved to the dead-letter stream after failing processing",
+}, []string{"subject"})
+
+func init() {
+	r := prometheus.DefaultRegisterer
+	r.MustRegister(hooksDeadLettered)
+}
+
+// DeadLetter is a hook message whose processing failed on every delivery.
+type DeadLetter struct {
+	Sequence uint64
+	// Subject is the HOOKS stream subject the hook was published to
+	Subject    string
+	Error      string
+	Deliveries int
+	Time       time.Time
+	Data       []byte `json:"-"`
+}
+
+// QueueSubscribe processes the hooks published to a subject of the HOOKS stream, like gongs.GenericStream
+// QueueSubscribe. Hooks whose processing returns an error are redelivered with an increasing delay, until their
+// processing failed TFBUDDY_HOOKS_MAX_DELIVERIES times, then moved to the dead-letter stream to be inspected & replayed
+// with `tfbuddy hooks replay`. Hooks that can't be decoded are moved to the dead-letter stream right away. Hooks whose
+// processing returns a Delay error are processed again after the delay, delays aren't counted as failures: the failures
+// of each hook are counted in the HOOKS_FAILURES KV store.
+func QueueSubscribe[T any, I gongs.MsgEvent[T]](js nats.JetStreamContext, subject, queue string, fn gongs.MsgHandlerFunc[T]) (*nats.Subscription, error) {
+	maxDeliveries := maxDeliveries()
+	failuresKV, err := configureHookFailuresKVStore(js)
+	if err != nil {
+		return nil, err
+	}
+	return js.QueueSubscribe(subject, queue,
+		func(msg *nats.Msg) {
+			deliveries := 1
+			var seq uint64
+			if md, err := msg.Metadata(); err == nil {
+				deliveries = int(md.NumDelivered)
+				seq = md.Sequence.Stream
+			}
+			log := log.With().Str("subject", msg.Subject).Int("deliveries", deliveries).Logger()
+
+			evt := I(new(T))
+			err := evt.DecodeEventData(msg.Data)
+			failures := 1
+			if err == nil {
+				err = fn((*T)(evt))
+				if err == nil {
+					if err := msg.Ack(); err != nil {
+						log.Error().Err(err).Msg("could not Ack NATS msg")
+					}
+					clearHookFailures(failuresKV, seq)
+					return
+				}
+				var delay *delayError
+				if errors.As(err, &delay) {
+					log.Debug().Dur("delay", delay.delay).Msg("delaying hook processing")
+					if err := msg.NakWithDelay(delay.delay); err != nil {
+						log.Error().Err(err).Msg("could not Nak NATS msg")
+					}
+					return
+				}
+				failures = recordHookFailure(failuresKV, seq, deliveries)
+				if failures < maxDeliveries {
+					log.Warn().Err(err).Int("failures", failures).Msg("could not process hook, it will be redelivered")
+					if err := msg.NakWithDelay(redeliveryDelay * time.Duration(failures)); err != nil {
+						log.Error().Err(err).Msg("could not Nak NATS msg")
+					}
+					return
+				}
+			}
+
+			log.Error().Err(err).Int("failures", failures).Msg("could not process hook, moving it to the dead-letter stream")
+			if dlErr := publishDeadLetter(js, msg, err, failures); dlErr != nil {
+				log.Error().Err(dlErr).Msg("could not publish hook to the dead-letter stream")
+				if err := msg.Nak(); err != nil {
+					log.Error().Err(err).Msg("could not Nak NATS msg")
+				}
+				return
+			}
+			hooksDeadLettered.WithLabelValues(msg.Subject).Inc()
+			if err := msg.Term(); err != nil {
+				log.Error().Err(err).Msg("could not Terminate NATS msg")
+			}
+			clearHookFailures(failuresKV, seq)
+		},
+		nats.ManualAck(),
+	)
+}
+
+// recordHookFailure counts a failed processing of the hook with the HOOKS stream sequence, and returns its number of
+// failures. Without the count, e.g. if the KV store is unavailable, the number of deliveries is returned.
+func recordHookFailure(kv nats.KeyValue, seq uint64, deliveries int) int {
+	if seq == 0 {
+		return deliveries
+	}
+	key := strconv.FormatUint(seq, 10)
+	failures := 0
+	entry, err := kv.Get(key)
+	if err == nil {
+		failures, err = strconv.Atoi(string(entry.Value()))
+	}
+	if err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		log.Error().Err(err).Uint64("seq", seq).Msg("could not read hook failures, counting deliveries")
+		return deliveries
+	}
+	failures++
+	if _, err := kv.Put(key, []byte(strconv.Itoa(failures))); err != nil {
+		log.Error().Err(err).Uint64("seq", seq).Msg("could not record hook failure, counting deliveries")
+		return deliveries
+	}
+	return failures
+}
+
+// clearHookFailures forgets the failures of a hook once it is processed or dead-lettered.
+func clearHookFailures(kv nats.KeyValue, seq uint64) {
+	if seq == 0 {
+		return
+	}
+	if err := kv.Delete(strconv.FormatUint(seq, 10)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		log.Error().Err(err).Uint64("seq", seq).Msg("could not clear hook failures")
+	}
+}
+
+func configureHookFailuresKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      HookFailuresKvBucket,
+		Description: "KV store for the number of failed processings of each hook, by HOOKS stream sequence",
+		TTL:         time.Hour,
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+
+	return js.CreateKeyValue(cfg)
+}
+
+func maxDeliveries() int {
+	if n, err := strconv.Atoi(os.Getenv(MaxDeliveriesEnvName)); err == nil && n > 0 {
+		return n
+	}
+	return defaultMaxDeliveries
+}
+
+func deadLetterSubject(subject string) string {
+	return fmt.Sprintf("%s.%s", HooksDeadLetterStreamName, strings.TrimPrefix(subject, HooksStreamName+"."))
+}
+
+func publishDeadLetter(js nats.JetStreamContext, msg *nats.Msg, procErr error, failures int) error {
+	dl := nats.NewMsg(deadLetterSubject(msg.Subject))
+	dl.Data = msg.Data
+	dl.Header.Set(OriginalSubjectHeader, msg.Subject)
+	dl.Header.Set(ErrorHeader, procErr.Error())
+	dl.Header.Set(DeliveriesHeader, strconv.Itoa(failures))
+	_, err := js.PublishMsg(dl)
+	return err
+}
+
+// ListDeadLetters returns the hooks in the dead-letter stream, oldest first.
+func (s *HooksStream) ListDeadLetters() ([]*DeadLetter, error) {
+	info, err := s.js.StreamInfo(HooksDeadLetterStreamName)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters := []*DeadLetter{}
+	if info.State.Msgs == 0 {
+		return deadLetters, nil
+	}
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq; seq++ {
+		dl, err := s.GetDeadLetter(seq)
+		if err == ErrDeadLetterNotFound {
+			// replayed or deleted
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, dl)
+	}
+	return deadLetters, nil
+}
+
+// GetDeadLetter reads a hook from the dead-letter stream by its sequence number.
+func (s *HooksStream) GetDeadLetter(seq uint64) (*DeadLetter, error) {
+	msg, err := s.js.GetMsg(HooksDeadLetterStreamName, seq)
+	if err == nats.ErrMsgNotFound {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	deliveries, _ := strconv.Atoi(msg.Header.Get(DeliveriesHeader))
+	return &DeadLetter{
+		Sequence:   msg.Sequence,
+		Subject:    msg.Header.Get(OriginalSubjectHeader),
+		Error:      msg.Header.Get(ErrorHeader),
+		Deliveries: deliveries,
+		Time:       msg.Time,
+		Data:       msg.Data,
+	}, nil
+}
+
+// ReplayDeadLetter publishes a hook from the dead-letter stream to its original subject, so it is processed again,
+// and removes it from the dead-letter stream.
+func (s *HooksStream) ReplayDeadLetter(seq uint64) (*DeadLetter, error) {
+	dl, err := s.GetDeadLetter(seq)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.js.Publish(dl.Subject, dl.Data); err != nil {
+		return nil, err
+	}
+	if err := s.js.DeleteMsg(HooksDeadLetterStreamName, seq); err != nil {
+		return dl, fmt.Errorf("hook was replayed but could not be removed from the dead-letter stream: %w", err)
+	}
+	return dl, nil
+}
+
+func configureHooksDeadLetterStream(js nats.JetStreamContext) {
+	sCfg := &nats.StreamConfig{
+		Name:        HooksDeadLetterStreamName,
+		Description: "Gitlab/Github Hooks that failed processing",
+		Subjects:    []string{fmt.Sprintf("%s.>", HooksDeadLetterStreamName)},
+		Retention:   nats.LimitsPolicy,
+		MaxMsgs:     10240,
+		MaxAge:      time.Hour * 24 * 7,
+		Replicas:    1,
+	}
+
+	if _, err := js.StreamInfo(sCfg.Name); err == nats.ErrStreamNotFound {
+		_, err = js.AddStream(sCfg)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not create hooks dead-letter stream")
+		}
+	} else if err != nil {
+		log.Fatal().Err(err).Msg("error reading hooks dead-letter stream info")
+	} else if _, err := js.UpdateStream(sCfg); err != nil {
+		log.Fatal().Err(err).Msg("error updating hooks dead-letter stream")
+	}
+}
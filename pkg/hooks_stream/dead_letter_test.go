@@ -0,0 +1,214 @@
+package hooks_stream
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+const TEST_PORT = 8371
+
+type testHookMsg struct {
+	data string
+}
+
+func (m *testHookMsg) GetId() string {
+	return m.data
+}
+
+func (m *testHookMsg) DecodeEventData(b []byte) error {
+	if string(b) == "garbage" {
+		return errors.New("could not decode")
+	}
+	m.data = string(b)
+	return nil
+}
+
+func (m *testHookMsg) EncodeEventData() []byte {
+	return []byte(m.data)
+}
+
+func testHooksStream(t *testing.T) (*HooksStream, func()) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = TEST_PORT
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	s := natstest.RunServer(&opts)
+
+	nc, err := nats.Connect(fmt.Sprintf("nats://127.0.0.1:%d", TEST_PORT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewHooksStream(nc), func() {
+		nc.Close()
+		s.Shutdown()
+	}
+}
+
+func TestQueueSubscribe_DeadLetter(t *testing.T) {
+	t.Setenv(MaxDeliveriesEnvName, "2")
+	redeliveryDelay = 10 * time.Millisecond
+	hs, cleanup := testHooksStream(t)
+	defer cleanup()
+
+	mu := sync.Mutex{}
+	processed := map[string]int{}
+	sub, err := QueueSubscribe[testHookMsg](hs.js, "HOOKS.test.events", "test_worker", func(msg *testHookMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed[msg.data]++
+		if msg.data == "fails" && processed[msg.data] < 3 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	for _, data := range []string{"ok", "fails", "garbage"} {
+		if _, err := hs.js.Publish("HOOKS.test.events", []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var deadLetters []*DeadLetter
+	assert.Eventually(t, func() bool {
+		deadLetters, err = hs.ListDeadLetters()
+		return err == nil && len(deadLetters) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, map[string]int{"ok": 1, "fails": 2}, processed)
+	mu.Unlock()
+	if assert.Len(t, deadLetters, 2) {
+		fails, garbage := deadLetters[0], deadLetters[1]
+		if string(fails.Data) != "fails" {
+			fails, garbage = garbage, fails
+		}
+		assert.Equal(t, "HOOKS.test.events", fails.Subject)
+		assert.Equal(t, "boom", fails.Error)
+		assert.Equal(t, 2, fails.Deliveries)
+		assert.Equal(t, "could not decode", garbage.Error)
+		assert.Equal(t, 1, garbage.Deliveries)
+
+		_, err = hs.ReplayDeadLetter(fails.Sequence)
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return processed["fails"] == 3
+		}, 5*time.Second, 10*time.Millisecond)
+
+		deadLetters, err = hs.ListDeadLetters()
+		assert.NoError(t, err)
+		assert.Len(t, deadLetters, 1, "replayed hooks are removed from the dead-letter stream")
+		_, err = hs.ReplayDeadLetter(fails.Sequence)
+		assert.Equal(t, ErrDeadLetterNotFound, err)
+	}
+}
+
+func TestQueueSubscribe_DelaysAreNotFailures(t *testing.T) {
+	t.Setenv(MaxDeliveriesEnvName, "2")
+	redeliveryDelay = 10 * time.Millisecond
+	hs, cleanup := testHooksStream(t)
+	defer cleanup()
+
+	mu := sync.Mutex{}
+	processed := 0
+	sub, err := QueueSubscribe[testHookMsg](hs.js, "HOOKS.test.events", "test_worker", func(msg *testHookMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed++
+		if processed <= 3 {
+			return Delay(10 * time.Millisecond)
+		}
+		return errors.New("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	if _, err := hs.js.Publish("HOOKS.test.events", []byte("debounced")); err != nil {
+		t.Fatal(err)
+	}
+
+	var deadLetters []*DeadLetter
+	assert.Eventually(t, func() bool {
+		deadLetters, err = hs.ListDeadLetters()
+		return err == nil && len(deadLetters) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, 5, processed, "the hook fails twice after its delays")
+	mu.Unlock()
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, 2, deadLetters[0].Deliveries)
+	}
+}
+
+func TestHooksStream_Redeliver(t *testing.T) {
+	hs, cleanup := testHooksStream(t)
+	defer cleanup()
+
+	_, err := hs.Redeliver("5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1")
+	assert.Equal(t, ErrDeliveryNotFound, err)
+
+	assert.NoError(t, hs.RecordDelivery("5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1", "HOOKS.test.events", []byte(`{"id":1}`)))
+	sub, err := hs.js.SubscribeSync("HOOKS.test.events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	d, err := hs.Redeliver("5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1")
+	assert.NoError(t, err)
+	assert.Equal(t, "HOOKS.test.events", d.Subject)
+	msg, err := sub.NextMsg(time.Second)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"id":1}`, string(msg.Data))
+	}
+}
+
+func TestHooksStream_MarkProcessed(t *testing.T) {
+	hs, cleanup := testHooksStream(t)
+	defer cleanup()
+
+	key := "gitlab/zapier/tfbuddy!101@abcd1234:update"
+	duplicate, err := hs.MarkProcessed(key)
+	assert.NoError(t, err)
+	assert.False(t, duplicate)
+
+	duplicate, err = hs.MarkProcessed(key)
+	assert.NoError(t, err)
+	assert.True(t, duplicate)
+
+	duplicate, err = hs.MarkProcessed("gitlab/zapier/tfbuddy!101@efgh5678:update")
+	assert.NoError(t, err)
+	assert.False(t, duplicate, "a new commit is not a duplicate")
+
+	assert.NoError(t, hs.UnmarkProcessed(key))
+	duplicate, err = hs.MarkProcessed(key)
+	assert.NoError(t, err)
+	assert.False(t, duplicate)
+}
+
+func TestHooksStream_DuplicateDeliveryID(t *testing.T) {
+	hs, cleanup := testHooksStream(t)
+	defer cleanup()
+
+	ack, err := hs.js.Publish("HOOKS.test.events", []byte("hook"), nats.MsgId("5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1"))
+	assert.NoError(t, err)
+	assert.False(t, ack.Duplicate)
+	ack, err = hs.js.Publish("HOOKS.test.events", []byte("hook"), nats.MsgId("5b3ad0c4-4a4e-4bb2-9a33-e5f2f5c7b0a1"))
+	assert.NoError(t, err)
+	assert.True(t, ack.Duplicate)
+}
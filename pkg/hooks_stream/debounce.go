@@ -0,0 +1,104 @@
+package hooks_stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const PendingPlansKvBucket = "HOOKS_PENDING_PLANS"
+
+// PendingPlan is the last commit pushed to a MR whose plan is debounced, it is planned once no other commit was pushed
+// for the debounce window.
+type PendingPlan struct {
+	CommitSHA string
+	PushedAt  time.Time
+	// Window is the debounce window of the project when the commit was pushed, 0 plans the commit right away
+	Window time.Duration
+	// Revision is the KV revision of the pending plan, so it is only cleared if no other commit was pushed since
+	Revision uint64 `json:"-"`
+}
+
+// delayError asks QueueSubscribe to process a hook again after a delay, without counting it as a failure.
+type delayError struct {
+	delay time.Duration
+}
+
+func (e *delayError) Error() string {
+	return fmt.Sprintf("hook processing delayed by %s", e.delay)
+}
+
+// Delay is returned by hook processing functions to process the hook again after a delay, e.g. once pushes to a MR
+// have been quiet for the debounce window.
+func Delay(d time.Duration) error {
+	return &delayError{delay: d}
+}
+
+// IsDelay returns true if the hook processing error is a Delay.
+func IsDelay(err error) bool {
+	var delay *delayError
+	return errors.As(err, &delay)
+}
+
+// SetPendingPlan records the last commit pushed to a MR, keyed by project & MR, with the current time and the debounce
+// window of the project.
+func (s *HooksStream) SetPendingPlan(key, commitSHA string, window time.Duration) error {
+	b, err := json.Marshal(&PendingPlan{
+		CommitSHA: commitSHA,
+		PushedAt:  time.Now(),
+		Window:    window,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.pendingKV.Put(processedKey(key), b)
+	return err
+}
+
+// GetPendingPlan reads the last commit pushed to a MR, it returns nil if no plan is pending.
+func (s *HooksStream) GetPendingPlan(key string) (*PendingPlan, error) {
+	entry, err := s.pendingKV.Get(processedKey(key))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pp := &PendingPlan{}
+	if err := json.Unmarshal(entry.Value(), pp); err != nil {
+		return nil, err
+	}
+	pp.Revision = entry.Revision()
+	return pp, nil
+}
+
+// ClearPendingPlan removes a pending plan once it was triggered, unless another commit was pushed since it was read.
+func (s *HooksStream) ClearPendingPlan(key string, pp *PendingPlan) error {
+	err := s.pendingKV.Delete(processedKey(key), nats.LastRevision(pp.Revision))
+	if errors.Is(err, nats.ErrKeyExists) || errors.Is(err, nats.ErrKeyNotFound) {
+		// a commit was pushed since, or the pending plan expired
+		return nil
+	}
+	return err
+}
+
+func configurePendingPlansKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      PendingPlansKvBucket,
+		Description: "KV store for the last commits pushed to MRs whose plan is debounced",
+		TTL:         time.Hour,
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+
+	return js.CreateKeyValue(cfg)
+}
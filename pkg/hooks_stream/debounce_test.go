@@ -0,0 +1,79 @@
+package hooks_stream
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooksStream_PendingPlan(t *testing.T) {
+	hs, cleanup := testHooksStream(t)
+	defer cleanup()
+
+	key := "gitlab/zapier/tfbuddy!101"
+	pp, err := hs.GetPendingPlan(key)
+	assert.NoError(t, err)
+	assert.Nil(t, pp)
+
+	assert.NoError(t, hs.SetPendingPlan(key, "abcd1234", 30*time.Second))
+	stale, err := hs.GetPendingPlan(key)
+	if assert.NoError(t, err) && assert.NotNil(t, stale) {
+		assert.Equal(t, "abcd1234", stale.CommitSHA)
+		assert.Equal(t, 30*time.Second, stale.Window)
+	}
+
+	// a new push replaces the pending plan, the stale one can't clear it
+	assert.NoError(t, hs.SetPendingPlan(key, "efgh5678", 30*time.Second))
+	assert.NoError(t, hs.ClearPendingPlan(key, stale))
+	pp, err = hs.GetPendingPlan(key)
+	if assert.NoError(t, err) && assert.NotNil(t, pp) {
+		assert.Equal(t, "efgh5678", pp.CommitSHA)
+	}
+
+	assert.NoError(t, hs.ClearPendingPlan(key, pp))
+	pp, err = hs.GetPendingPlan(key)
+	assert.NoError(t, err)
+	assert.Nil(t, pp)
+}
+
+func TestQueueSubscribe_Delay(t *testing.T) {
+	t.Setenv(MaxDeliveriesEnvName, "1")
+	hs, cleanup := testHooksStream(t)
+	defer cleanup()
+
+	mu := sync.Mutex{}
+	processed := []time.Time{}
+	sub, err := QueueSubscribe[testHookMsg](hs.js, "HOOKS.test.events", "test_worker", func(msg *testHookMsg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, time.Now())
+		if len(processed) == 1 {
+			return Delay(200 * time.Millisecond)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	if _, err := hs.js.Publish("HOOKS.test.events", []byte("push")); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(processed) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.GreaterOrEqual(t, processed[1].Sub(processed[0]), 200*time.Millisecond)
+	mu.Unlock()
+
+	// delayed hooks aren't failures, even past the max deliveries
+	dls, err := hs.ListDeadLetters()
+	assert.NoError(t, err)
+	assert.Empty(t, dls)
+}
@@ -0,0 +1,53 @@
+package hooks_stream
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const ProcessedHooksKvBucket = "HOOKS_PROCESSED"
+
+// MarkProcessed records the de-duplication key of a hook accepted for processing, e.g. the project, MR, commit SHA &
+// action of a MR event. duplicate is true if the key was already recorded in the last hour, the hook should then be
+// dropped.
+func (s *HooksStream) MarkProcessed(key string) (duplicate bool, err error) {
+	_, err = s.processedKV.Create(processedKey(key), []byte(time.Now().UTC().Format(time.RFC3339)))
+	if errors.Is(err, nats.ErrKeyExists) {
+		return true, nil
+	}
+	return false, err
+}
+
+// UnmarkProcessed forgets the de-duplication key of a hook that could not be published, so its retry is accepted.
+func (s *HooksStream) UnmarkProcessed(key string) error {
+	return s.processedKV.Delete(processedKey(key))
+}
+
+// processedKey hashes a de-duplication key, project paths & branch names can contain characters that aren't valid in
+// KV keys.
+func processedKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+func configureProcessedHooksKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      ProcessedHooksKvBucket,
+		Description: "KV store for the de-duplication keys of hooks accepted for processing",
+		TTL:         time.Hour,
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+
+	return js.CreateKeyValue(cfg)
+}
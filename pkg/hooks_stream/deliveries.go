@@ -0,0 +1,95 @@
+package hooks_stream
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const HookDeliveriesKvBucket = "HOOK_DELIVERIES"
+
+var ErrDeliveryNotFound = errors.New("hook delivery not found")
+
+// DeliveryRecorder keeps the hooks published to the HOOKS stream, so they can be redelivered, the de-duplication keys
+// of the hooks accepted for processing and the pending plans of debounced MR pushes.
+type DeliveryRecorder interface {
+	RecordDelivery(id, subject string, data []byte) error
+	MarkProcessed(key string) (duplicate bool, err error)
+	UnmarkProcessed(key string) error
+	SetPendingPlan(key, commitSHA string, window time.Duration) error
+	GetPendingPlan(key string) (*PendingPlan, error)
+	ClearPendingPlan(key string, pp *PendingPlan) error
+}
+
+// Delivery is a hook received from GitLab or GitHub, as published to the HOOKS stream. Deliveries are kept for a day so
+// their processing can be re-run.
+type Delivery struct {
+	// ID is the GitLab event UUID or the GitHub delivery ID
+	ID         string
+	Subject    string
+	Data       []byte
+	ReceivedAt time.Time
+}
+
+// RecordDelivery keeps a hook published to the HOOKS stream, so it can be redelivered by its ID.
+func (s *HooksStream) RecordDelivery(id, subject string, data []byte) error {
+	b, err := json.Marshal(&Delivery{
+		ID:         id,
+		Subject:    subject,
+		Data:       data,
+		ReceivedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.deliveriesKV.Put(id, b)
+	return err
+}
+
+// GetDelivery reads a recorded hook by its ID.
+func (s *HooksStream) GetDelivery(id string) (*Delivery, error) {
+	entry, err := s.deliveriesKV.Get(id)
+	if err == nats.ErrKeyNotFound || err == nats.ErrInvalidKey {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := &Delivery{}
+	if err := json.Unmarshal(entry.Value(), d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Redeliver publishes a recorded hook to the HOOKS stream again, so it is processed again.
+func (s *HooksStream) Redeliver(id string) (*Delivery, error) {
+	d, err := s.GetDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.js.Publish(d.Subject, d.Data); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func configureHookDeliveriesKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      HookDeliveriesKvBucket,
+		Description: "KV store for the hooks received from GitLab & GitHub",
+		TTL:         time.Hour * 24,
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+
+	return js.CreateKeyValue(cfg)
+}
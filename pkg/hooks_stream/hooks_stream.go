@@ -11,8 +11,11 @@ import (
 const HooksStreamName = "HOOKS"
 
 type HooksStream struct {
-	nc *nats.Conn
-	js nats.JetStreamContext
+	nc           *nats.Conn
+	js           nats.JetStreamContext
+	deliveriesKV nats.KeyValue
+	processedKV  nats.KeyValue
+	pendingKV    nats.KeyValue
 }
 
 func NewHooksStream(nc *nats.Conn) *HooksStream {
@@ -22,10 +25,26 @@ func NewHooksStream(nc *nats.Conn) *HooksStream {
 	}
 
 	configureHooksStream(js)
+	configureHooksDeadLetterStream(js)
+	deliveriesKV, err := configureHookDeliveriesKVStore(js)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not create hook deliveries KV store")
+	}
+	processedKV, err := configureProcessedHooksKVStore(js)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not create processed hooks KV store")
+	}
+	pendingKV, err := configurePendingPlansKVStore(js)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not create pending plans KV store")
+	}
 
 	s := &HooksStream{
 		nc,
 		js,
+		deliveriesKV,
+		processedKV,
+		pendingKV,
 	}
 
 	return s
@@ -40,7 +59,9 @@ func configureHooksStream(js nats.JetStreamContext) {
 		Retention:   nats.WorkQueuePolicy,
 		MaxMsgs:     10240,
 		MaxAge:      time.Hour * 1,
-		Replicas:    1,
+		// hooks published with the same GitLab event UUID or GitHub delivery ID are dropped
+		Duplicates: time.Hour * 1,
+		Replicas:   1,
 	}
 
 	strInfo, err := js.StreamInfo(sCfg.Name)
@@ -90,3 +111,47 @@ func (s *HooksStream) HealthCheck() error {
 
 	return nil
 }
+
+// StreamState is the processing state of the hooks stream.
+type StreamState struct {
+	// Messages is the number of hooks waiting to be processed
+	Messages  uint64
+	Bytes     uint64
+	FirstSeq  uint64
+	LastSeq   uint64
+	Consumers []*ConsumerState
+}
+
+// ConsumerState is the processing state of a hooks stream consumer.
+type ConsumerState struct {
+	Name           string
+	NumPending     uint64
+	NumAckPending  int
+	NumRedelivered int
+	NumWaiting     int
+}
+
+// State returns the processing state of the hooks stream and its consumers.
+func (s *HooksStream) State() (*StreamState, error) {
+	info, err := s.js.StreamInfo(HooksStreamName)
+	if err != nil {
+		return nil, err
+	}
+	state := &StreamState{
+		Messages:  info.State.Msgs,
+		Bytes:     info.State.Bytes,
+		FirstSeq:  info.State.FirstSeq,
+		LastSeq:   info.State.LastSeq,
+		Consumers: []*ConsumerState{},
+	}
+	for ci := range s.js.Consumers(HooksStreamName) {
+		state.Consumers = append(state.Consumers, &ConsumerState{
+			Name:           ci.Name,
+			NumPending:     ci.NumPending,
+			NumAckPending:  ci.NumAckPending,
+			NumRedelivered: ci.NumRedelivered,
+			NumWaiting:     ci.NumWaiting,
+		})
+	}
+	return state, nil
+}
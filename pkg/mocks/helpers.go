@@ -117,6 +117,8 @@ type TestMetaData struct {
 	TargetBranch  string
 	SourceBranch  string
 	TFBuddyConfig []byte
+	// TargetTFBuddyConfig is the .tfbuddy.yaml of the target branch, the guard settings are read from
+	TargetTFBuddyConfig []byte
 }
 type TestSuite struct {
 	MockGitClient     *MockGitClient
@@ -131,6 +133,8 @@ type TestSuite struct {
 }
 type TestOverrides struct {
 	ProjectConfig *tfc_trigger.ProjectConfig
+	// TargetProjectConfig is the project config of the target branch, defaults to ProjectConfig
+	TargetProjectConfig *tfc_trigger.ProjectConfig
 }
 type RegexMatcher struct {
 	regex *regexp.Regexp
@@ -157,6 +161,7 @@ func (ts *TestSuite) InitTestSuite() {
 
 	ts.MockGitMR.EXPECT().GetInternalID().Return(ts.MetaData.MRIID).AnyTimes()
 	ts.MockGitMR.EXPECT().GetTargetBranch().Return(ts.MetaData.TargetBranch).AnyTimes()
+	ts.MockGitMR.EXPECT().GetWebURL().Return(fmt.Sprintf("https://gitlab.com/%s/-/merge_requests/%d", ts.MetaData.ProjectNameNS, ts.MetaData.MRIID)).AnyTimes()
 	ts.MockGitMR.EXPECT().GetSourceBranch().Return(ts.MetaData.SourceBranch).AnyTimes()
 	ts.MockGitMR.EXPECT().GetTitle().Return("MR Title").AnyTimes()
 
@@ -167,6 +172,7 @@ func (ts *TestSuite) InitTestSuite() {
 	ts.MockGitClient.EXPECT().GetMergeRequest(ts.MetaData.MRIID, ts.MetaData.ProjectNameNS).Return(ts.MockGitMR, nil).AnyTimes()
 	ts.MockGitClient.EXPECT().GetMergeRequestModifiedFiles(ts.MetaData.MRIID, ts.MetaData.ProjectNameNS).Return([]string{"main.tf"}, nil).AnyTimes()
 	ts.MockGitClient.EXPECT().GetRepoFile(ts.MetaData.ProjectNameNS, ".tfbuddy.yaml", ts.MetaData.SourceBranch).Return(ts.MetaData.TFBuddyConfig, nil).AnyTimes()
+	ts.MockGitClient.EXPECT().GetRepoFile(ts.MetaData.ProjectNameNS, ".tfbuddy.yaml", ts.MetaData.TargetBranch).Return(ts.MetaData.TargetTFBuddyConfig, nil).AnyTimes()
 	ts.MockGitClient.EXPECT().CloneMergeRequest(ts.MetaData.ProjectNameNS, gomock.Any(), gomock.Any()).Return(ts.MockGitRepo, nil).AnyTimes()
 	ts.MockGitClient.EXPECT().CreateMergeRequestDiscussion(ts.MetaData.MRIID, ts.MetaData.ProjectNameNS, &RegexMatcher{regex: regexp.MustCompile("Starting TFC apply for Workspace: `([A-z\\-]){1,}/([A-z\\-]){1,}`.")}).Return(ts.MockGitDisc, nil).AnyTimes()
 
@@ -181,12 +187,17 @@ func (ts *TestSuite) InitTestSuite() {
 	ts.MockTriggerConfig.EXPECT().GetMergeRequestDiscussionID().Return("1010").AnyTimes()
 	ts.MockTriggerConfig.EXPECT().GetMergeRequestRootNoteID().Return(int64(202)).AnyTimes()
 	ts.MockTriggerConfig.EXPECT().GetVcsProvider().Return("vcs").AnyTimes()
+	ts.MockTriggerConfig.EXPECT().GetAllowDestroy().Return(false).AnyTimes()
+	ts.MockTriggerConfig.EXPECT().GetSlackChannel().Return("").AnyTimes()
+	ts.MockTriggerConfig.EXPECT().GetSlackThreadTS().Return("").AnyTimes()
+	ts.MockTriggerConfig.EXPECT().GetRunSlotID().Return("").AnyTimes()
 
 	ts.MockApiClient.EXPECT().GetWorkspaceByName(gomock.Any(), gomock.Any(), gomock.Any()).Return(&tfe.Workspace{ID: "service-tfbuddy"}, nil).AnyTimes()
 	ts.MockApiClient.EXPECT().GetTagsByQuery(gomock.Any(), gomock.Any(), "tfbuddylock").AnyTimes()
 	ts.MockApiClient.EXPECT().AddTags(gomock.Any(), gomock.Any(), "tfbuddylock", "101").AnyTimes()
 
 	ts.MockStreamClient.EXPECT().AddRunMeta(gomock.Any()).AnyTimes()
+	ts.MockStreamClient.EXPECT().SetLatestRun(gomock.Any()).Return(nil, nil).AnyTimes()
 
 }
 
@@ -212,6 +223,13 @@ func CreateTestSuite(mockCtrl *gomock.Controller, overrides TestOverrides, t *te
 	if err != nil {
 		t.Fatal(err)
 	}
+	targetData := data
+	if overrides.TargetProjectConfig != nil {
+		targetData, err = yaml.Marshal(overrides.TargetProjectConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
 
 	commonSha := "commonsha1234"
 	mockGitClient := NewMockGitClient(mockCtrl)
@@ -242,6 +260,8 @@ func CreateTestSuite(mockCtrl *gomock.Controller, overrides TestOverrides, t *te
 			TargetBranch:  targetBranch,
 			TFBuddyConfig: data,
 			SourceBranch:  srcBranch,
+
+			TargetTFBuddyConfig: targetData,
 		},
 	}
 }
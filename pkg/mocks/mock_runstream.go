@@ -10,6 +10,7 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	runstream "github.com/zapier/tfbuddy/pkg/runstream"
+	terraform_plan "github.com/zapier/tfbuddy/pkg/terraform_plan"
 )
 
 // MockStreamClient is a mock of StreamClient interface.
@@ -35,6 +36,21 @@ func (m *MockStreamClient) EXPECT() *MockStreamClientMockRecorder {
 	return m.recorder
 }
 
+// AcquireRunSlot mocks base method.
+func (m *MockStreamClient) AcquireRunSlot(qr *runstream.QueuedRun) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcquireRunSlot", qr)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcquireRunSlot indicates an expected call of AcquireRunSlot.
+func (mr *MockStreamClientMockRecorder) AcquireRunSlot(qr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcquireRunSlot", reflect.TypeOf((*MockStreamClient)(nil).AcquireRunSlot), qr)
+}
+
 // AddRunMeta mocks base method.
 func (m *MockStreamClient) AddRunMeta(rmd runstream.RunMetadata) error {
 	m.ctrl.T.Helper()
@@ -49,6 +65,50 @@ func (mr *MockStreamClientMockRecorder) AddRunMeta(rmd interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRunMeta", reflect.TypeOf((*MockStreamClient)(nil).AddRunMeta), rmd)
 }
 
+// AssignRunSlot mocks base method.
+func (m *MockStreamClient) AssignRunSlot(id, runID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AssignRunSlot", id, runID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AssignRunSlot indicates an expected call of AssignRunSlot.
+func (mr *MockStreamClientMockRecorder) AssignRunSlot(id, runID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssignRunSlot", reflect.TypeOf((*MockStreamClient)(nil).AssignRunSlot), id, runID)
+}
+
+// DispatchQueuedRuns mocks base method.
+func (m *MockStreamClient) DispatchQueuedRuns() ([]*runstream.QueuedRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DispatchQueuedRuns")
+	ret0, _ := ret[0].([]*runstream.QueuedRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DispatchQueuedRuns indicates an expected call of DispatchQueuedRuns.
+func (mr *MockStreamClientMockRecorder) DispatchQueuedRuns() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DispatchQueuedRuns", reflect.TypeOf((*MockStreamClient)(nil).DispatchQueuedRuns))
+}
+
+// GetMRSummary mocks base method.
+func (m *MockStreamClient) GetMRSummary(project string, mrIID int, commitSHA string) (*runstream.TFMRSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMRSummary", project, mrIID, commitSHA)
+	ret0, _ := ret[0].(*runstream.TFMRSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMRSummary indicates an expected call of GetMRSummary.
+func (mr *MockStreamClientMockRecorder) GetMRSummary(project, mrIID, commitSHA interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMRSummary", reflect.TypeOf((*MockStreamClient)(nil).GetMRSummary), project, mrIID, commitSHA)
+}
+
 // GetRunMeta mocks base method.
 func (m *MockStreamClient) GetRunMeta(runID string) (runstream.RunMetadata, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +124,51 @@ func (mr *MockStreamClientMockRecorder) GetRunMeta(runID interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRunMeta", reflect.TypeOf((*MockStreamClient)(nil).GetRunMeta), runID)
 }
 
+// GetRunTimeline mocks base method.
+func (m *MockStreamClient) GetRunTimeline(runID string) (*runstream.TFRunTimeline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRunTimeline", runID)
+	ret0, _ := ret[0].(*runstream.TFRunTimeline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRunTimeline indicates an expected call of GetRunTimeline.
+func (mr *MockStreamClientMockRecorder) GetRunTimeline(runID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRunTimeline", reflect.TypeOf((*MockStreamClient)(nil).GetRunTimeline), runID)
+}
+
+// GetSupersedingCommit mocks base method.
+func (m *MockStreamClient) GetSupersedingCommit(rmd runstream.RunMetadata) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSupersedingCommit", rmd)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSupersedingCommit indicates an expected call of GetSupersedingCommit.
+func (mr *MockStreamClientMockRecorder) GetSupersedingCommit(rmd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSupersedingCommit", reflect.TypeOf((*MockStreamClient)(nil).GetSupersedingCommit), rmd)
+}
+
+// GetTemplate mocks base method.
+func (m *MockStreamClient) GetTemplate(ref string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTemplate", ref)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTemplate indicates an expected call of GetTemplate.
+func (mr *MockStreamClientMockRecorder) GetTemplate(ref interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplate", reflect.TypeOf((*MockStreamClient)(nil).GetTemplate), ref)
+}
+
 // HealthCheck mocks base method.
 func (m *MockStreamClient) HealthCheck() error {
 	m.ctrl.T.Helper()
@@ -78,6 +183,141 @@ func (mr *MockStreamClientMockRecorder) HealthCheck() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HealthCheck", reflect.TypeOf((*MockStreamClient)(nil).HealthCheck))
 }
 
+// ListCommitRuns mocks base method.
+func (m *MockStreamClient) ListCommitRuns(commitSHA string) ([]*runstream.RunIndexEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCommitRuns", commitSHA)
+	ret0, _ := ret[0].([]*runstream.RunIndexEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCommitRuns indicates an expected call of ListCommitRuns.
+func (mr *MockStreamClientMockRecorder) ListCommitRuns(commitSHA interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCommitRuns", reflect.TypeOf((*MockStreamClient)(nil).ListCommitRuns), commitSHA)
+}
+
+// ListMRRunMeta mocks base method.
+func (m *MockStreamClient) ListMRRunMeta(project string, mrIID int) ([]runstream.RunMetadata, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMRRunMeta", project, mrIID)
+	ret0, _ := ret[0].([]runstream.RunMetadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMRRunMeta indicates an expected call of ListMRRunMeta.
+func (mr *MockStreamClientMockRecorder) ListMRRunMeta(project, mrIID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMRRunMeta", reflect.TypeOf((*MockStreamClient)(nil).ListMRRunMeta), project, mrIID)
+}
+
+// ListMRRuns mocks base method.
+func (m *MockStreamClient) ListMRRuns(project string, mrIID int) ([]*runstream.RunIndexEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMRRuns", project, mrIID)
+	ret0, _ := ret[0].([]*runstream.RunIndexEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMRRuns indicates an expected call of ListMRRuns.
+func (mr *MockStreamClientMockRecorder) ListMRRuns(project, mrIID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMRRuns", reflect.TypeOf((*MockStreamClient)(nil).ListMRRuns), project, mrIID)
+}
+
+// ListMRSummaries mocks base method.
+func (m *MockStreamClient) ListMRSummaries() ([]*runstream.TFMRSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMRSummaries")
+	ret0, _ := ret[0].([]*runstream.TFMRSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMRSummaries indicates an expected call of ListMRSummaries.
+func (mr *MockStreamClientMockRecorder) ListMRSummaries() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMRSummaries", reflect.TypeOf((*MockStreamClient)(nil).ListMRSummaries))
+}
+
+// ListPollingTasks mocks base method.
+func (m *MockStreamClient) ListPollingTasks() ([]runstream.RunPollingTask, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPollingTasks")
+	ret0, _ := ret[0].([]runstream.RunPollingTask)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPollingTasks indicates an expected call of ListPollingTasks.
+func (mr *MockStreamClientMockRecorder) ListPollingTasks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPollingTasks", reflect.TypeOf((*MockStreamClient)(nil).ListPollingTasks))
+}
+
+// ListProjectRuns mocks base method.
+func (m *MockStreamClient) ListProjectRuns(project string) ([]*runstream.RunIndexEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListProjectRuns", project)
+	ret0, _ := ret[0].([]*runstream.RunIndexEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProjectRuns indicates an expected call of ListProjectRuns.
+func (mr *MockStreamClientMockRecorder) ListProjectRuns(project interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProjectRuns", reflect.TypeOf((*MockStreamClient)(nil).ListProjectRuns), project)
+}
+
+// ListRuns mocks base method.
+func (m *MockStreamClient) ListRuns() ([]*runstream.RunIndexEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRuns")
+	ret0, _ := ret[0].([]*runstream.RunIndexEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRuns indicates an expected call of ListRuns.
+func (mr *MockStreamClientMockRecorder) ListRuns() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRuns", reflect.TypeOf((*MockStreamClient)(nil).ListRuns))
+}
+
+// ListWorkspaceRuns mocks base method.
+func (m *MockStreamClient) ListWorkspaceRuns(org, workspace string) ([]*runstream.RunIndexEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWorkspaceRuns", org, workspace)
+	ret0, _ := ret[0].([]*runstream.RunIndexEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWorkspaceRuns indicates an expected call of ListWorkspaceRuns.
+func (mr *MockStreamClientMockRecorder) ListWorkspaceRuns(org, workspace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWorkspaceRuns", reflect.TypeOf((*MockStreamClient)(nil).ListWorkspaceRuns), org, workspace)
+}
+
+// MarkContinuedCommentsPosted mocks base method.
+func (m *MockStreamClient) MarkContinuedCommentsPosted(runID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkContinuedCommentsPosted", runID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkContinuedCommentsPosted indicates an expected call of MarkContinuedCommentsPosted.
+func (mr *MockStreamClientMockRecorder) MarkContinuedCommentsPosted(runID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkContinuedCommentsPosted", reflect.TypeOf((*MockStreamClient)(nil).MarkContinuedCommentsPosted), runID)
+}
+
 // NewTFRunPollingTask mocks base method.
 func (m *MockStreamClient) NewTFRunPollingTask(meta runstream.RunMetadata, delay time.Duration) runstream.RunPollingTask {
 	m.ctrl.T.Helper()
@@ -92,6 +332,20 @@ func (mr *MockStreamClientMockRecorder) NewTFRunPollingTask(meta, delay interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewTFRunPollingTask", reflect.TypeOf((*MockStreamClient)(nil).NewTFRunPollingTask), meta, delay)
 }
 
+// PublishNotificationEvent mocks base method.
+func (m *MockStreamClient) PublishNotificationEvent(ev *runstream.NotificationEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishNotificationEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishNotificationEvent indicates an expected call of PublishNotificationEvent.
+func (mr *MockStreamClientMockRecorder) PublishNotificationEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishNotificationEvent", reflect.TypeOf((*MockStreamClient)(nil).PublishNotificationEvent), ev)
+}
+
 // PublishTFRunEvent mocks base method.
 func (m *MockStreamClient) PublishTFRunEvent(re runstream.RunEvent) error {
 	m.ctrl.T.Helper()
@@ -106,6 +360,80 @@ func (mr *MockStreamClientMockRecorder) PublishTFRunEvent(re interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishTFRunEvent", reflect.TypeOf((*MockStreamClient)(nil).PublishTFRunEvent), re)
 }
 
+// PutTemplate mocks base method.
+func (m *MockStreamClient) PutTemplate(text string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutTemplate", text)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutTemplate indicates an expected call of PutTemplate.
+func (mr *MockStreamClientMockRecorder) PutTemplate(text interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutTemplate", reflect.TypeOf((*MockStreamClient)(nil).PutTemplate), text)
+}
+
+// ReleaseRunSlot mocks base method.
+func (m *MockStreamClient) ReleaseRunSlot(id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseRunSlot", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseRunSlot indicates an expected call of ReleaseRunSlot.
+func (mr *MockStreamClientMockRecorder) ReleaseRunSlot(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseRunSlot", reflect.TypeOf((*MockStreamClient)(nil).ReleaseRunSlot), id)
+}
+
+// SetLatestRun mocks base method.
+func (m *MockStreamClient) SetLatestRun(rmd runstream.RunMetadata) (*runstream.LatestRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLatestRun", rmd)
+	ret0, _ := ret[0].(*runstream.LatestRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetLatestRun indicates an expected call of SetLatestRun.
+func (mr *MockStreamClientMockRecorder) SetLatestRun(rmd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLatestRun", reflect.TypeOf((*MockStreamClient)(nil).SetLatestRun), rmd)
+}
+
+// SubscribeAllTFRunEvents mocks base method.
+func (m *MockStreamClient) SubscribeAllTFRunEvents(queue string, cb func(runstream.RunEvent) bool) (func(), error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeAllTFRunEvents", queue, cb)
+	ret0, _ := ret[0].(func())
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeAllTFRunEvents indicates an expected call of SubscribeAllTFRunEvents.
+func (mr *MockStreamClientMockRecorder) SubscribeAllTFRunEvents(queue, cb interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeAllTFRunEvents", reflect.TypeOf((*MockStreamClient)(nil).SubscribeAllTFRunEvents), queue, cb)
+}
+
+// SubscribeNotificationEvents mocks base method.
+func (m *MockStreamClient) SubscribeNotificationEvents(cb func(*runstream.NotificationEvent) bool) (func(), error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeNotificationEvents", cb)
+	ret0, _ := ret[0].(func())
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeNotificationEvents indicates an expected call of SubscribeNotificationEvents.
+func (mr *MockStreamClientMockRecorder) SubscribeNotificationEvents(cb interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeNotificationEvents", reflect.TypeOf((*MockStreamClient)(nil).SubscribeNotificationEvents), cb)
+}
+
 // SubscribeTFRunEvents mocks base method.
 func (m *MockStreamClient) SubscribeTFRunEvents(queue string, cb func(runstream.RunEvent) bool) (func(), error) {
 	m.ctrl.T.Helper()
@@ -136,6 +464,20 @@ func (mr *MockStreamClientMockRecorder) SubscribeTFRunPollingTasks(cb interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeTFRunPollingTasks", reflect.TypeOf((*MockStreamClient)(nil).SubscribeTFRunPollingTasks), cb)
 }
 
+// UpdateMRSummary mocks base method.
+func (m *MockStreamClient) UpdateMRSummary(summary *runstream.TFMRSummary) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMRSummary", summary)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateMRSummary indicates an expected call of UpdateMRSummary.
+func (mr *MockStreamClientMockRecorder) UpdateMRSummary(summary interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMRSummary", reflect.TypeOf((*MockStreamClient)(nil).UpdateMRSummary), summary)
+}
+
 // MockRunEvent is a mock of RunEvent interface.
 type MockRunEvent struct {
 	ctrl     *gomock.Controller
@@ -201,6 +543,20 @@ func (mr *MockRunEventMockRecorder) GetRunID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRunID", reflect.TypeOf((*MockRunEvent)(nil).GetRunID))
 }
 
+// GetStatusTimestamps mocks base method.
+func (m *MockRunEvent) GetStatusTimestamps() map[string]time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatusTimestamps")
+	ret0, _ := ret[0].(map[string]time.Time)
+	return ret0
+}
+
+// GetStatusTimestamps indicates an expected call of GetStatusTimestamps.
+func (mr *MockRunEventMockRecorder) GetStatusTimestamps() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatusTimestamps", reflect.TypeOf((*MockRunEvent)(nil).GetStatusTimestamps))
+}
+
 // SetMetadata mocks base method.
 func (m *MockRunEvent) SetMetadata(arg0 runstream.RunMetadata) {
 	m.ctrl.T.Helper()
@@ -250,6 +606,20 @@ func (mr *MockRunMetadataMockRecorder) GetAction() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAction", reflect.TypeOf((*MockRunMetadata)(nil).GetAction))
 }
 
+// GetAllowDestroy mocks base method.
+func (m *MockRunMetadata) GetAllowDestroy() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllowDestroy")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// GetAllowDestroy indicates an expected call of GetAllowDestroy.
+func (mr *MockRunMetadataMockRecorder) GetAllowDestroy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllowDestroy", reflect.TypeOf((*MockRunMetadata)(nil).GetAllowDestroy))
+}
+
 // GetCommitSHA mocks base method.
 func (m *MockRunMetadata) GetCommitSHA() string {
 	m.ctrl.T.Helper()
@@ -264,6 +634,34 @@ func (mr *MockRunMetadataMockRecorder) GetCommitSHA() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommitSHA", reflect.TypeOf((*MockRunMetadata)(nil).GetCommitSHA))
 }
 
+// GetConfirmApply mocks base method.
+func (m *MockRunMetadata) GetConfirmApply() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConfirmApply")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// GetConfirmApply indicates an expected call of GetConfirmApply.
+func (mr *MockRunMetadataMockRecorder) GetConfirmApply() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConfirmApply", reflect.TypeOf((*MockRunMetadata)(nil).GetConfirmApply))
+}
+
+// GetCostThreshold mocks base method.
+func (m *MockRunMetadata) GetCostThreshold() float64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCostThreshold")
+	ret0, _ := ret[0].(float64)
+	return ret0
+}
+
+// GetCostThreshold indicates an expected call of GetCostThreshold.
+func (mr *MockRunMetadataMockRecorder) GetCostThreshold() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCostThreshold", reflect.TypeOf((*MockRunMetadata)(nil).GetCostThreshold))
+}
+
 // GetDiscussionID mocks base method.
 func (m *MockRunMetadata) GetDiscussionID() string {
 	m.ctrl.T.Helper()
@@ -306,6 +704,34 @@ func (mr *MockRunMetadataMockRecorder) GetMRProjectNameWithNamespace() *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMRProjectNameWithNamespace", reflect.TypeOf((*MockRunMetadata)(nil).GetMRProjectNameWithNamespace))
 }
 
+// GetMRWebURL mocks base method.
+func (m *MockRunMetadata) GetMRWebURL() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMRWebURL")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetMRWebURL indicates an expected call of GetMRWebURL.
+func (mr *MockRunMetadataMockRecorder) GetMRWebURL() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMRWebURL", reflect.TypeOf((*MockRunMetadata)(nil).GetMRWebURL))
+}
+
+// GetNotifications mocks base method.
+func (m *MockRunMetadata) GetNotifications() []*runstream.NotificationRule {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotifications")
+	ret0, _ := ret[0].([]*runstream.NotificationRule)
+	return ret0
+}
+
+// GetNotifications indicates an expected call of GetNotifications.
+func (mr *MockRunMetadataMockRecorder) GetNotifications() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotifications", reflect.TypeOf((*MockRunMetadata)(nil).GetNotifications))
+}
+
 // GetOrganization mocks base method.
 func (m *MockRunMetadata) GetOrganization() string {
 	m.ctrl.T.Helper()
@@ -320,6 +746,48 @@ func (mr *MockRunMetadataMockRecorder) GetOrganization() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrganization", reflect.TypeOf((*MockRunMetadata)(nil).GetOrganization))
 }
 
+// GetPlanFormat mocks base method.
+func (m *MockRunMetadata) GetPlanFormat() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlanFormat")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetPlanFormat indicates an expected call of GetPlanFormat.
+func (mr *MockRunMetadataMockRecorder) GetPlanFormat() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlanFormat", reflect.TypeOf((*MockRunMetadata)(nil).GetPlanFormat))
+}
+
+// GetPolicies mocks base method.
+func (m *MockRunMetadata) GetPolicies() map[string]string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPolicies")
+	ret0, _ := ret[0].(map[string]string)
+	return ret0
+}
+
+// GetPolicies indicates an expected call of GetPolicies.
+func (mr *MockRunMetadataMockRecorder) GetPolicies() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPolicies", reflect.TypeOf((*MockRunMetadata)(nil).GetPolicies))
+}
+
+// GetProtectedResources mocks base method.
+func (m *MockRunMetadata) GetProtectedResources() *terraform_plan.ProtectedResources {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProtectedResources")
+	ret0, _ := ret[0].(*terraform_plan.ProtectedResources)
+	return ret0
+}
+
+// GetProtectedResources indicates an expected call of GetProtectedResources.
+func (mr *MockRunMetadataMockRecorder) GetProtectedResources() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProtectedResources", reflect.TypeOf((*MockRunMetadata)(nil).GetProtectedResources))
+}
+
 // GetRootNoteID mocks base method.
 func (m *MockRunMetadata) GetRootNoteID() int64 {
 	m.ctrl.T.Helper()
@@ -348,6 +816,48 @@ func (mr *MockRunMetadataMockRecorder) GetRunID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRunID", reflect.TypeOf((*MockRunMetadata)(nil).GetRunID))
 }
 
+// GetSlackChannel mocks base method.
+func (m *MockRunMetadata) GetSlackChannel() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSlackChannel")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetSlackChannel indicates an expected call of GetSlackChannel.
+func (mr *MockRunMetadataMockRecorder) GetSlackChannel() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSlackChannel", reflect.TypeOf((*MockRunMetadata)(nil).GetSlackChannel))
+}
+
+// GetSlackThreadTS mocks base method.
+func (m *MockRunMetadata) GetSlackThreadTS() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSlackThreadTS")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetSlackThreadTS indicates an expected call of GetSlackThreadTS.
+func (mr *MockRunMetadataMockRecorder) GetSlackThreadTS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSlackThreadTS", reflect.TypeOf((*MockRunMetadata)(nil).GetSlackThreadTS))
+}
+
+// GetTemplateRefs mocks base method.
+func (m *MockRunMetadata) GetTemplateRefs() map[string]string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTemplateRefs")
+	ret0, _ := ret[0].(map[string]string)
+	return ret0
+}
+
+// GetTemplateRefs indicates an expected call of GetTemplateRefs.
+func (mr *MockRunMetadataMockRecorder) GetTemplateRefs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTemplateRefs", reflect.TypeOf((*MockRunMetadata)(nil).GetTemplateRefs))
+}
+
 // GetVcsProvider mocks base method.
 func (m *MockRunMetadata) GetVcsProvider() string {
 	m.ctrl.T.Helper()
@@ -50,6 +50,34 @@ func (mr *MockApiClientMockRecorder) AddTags(ctx, workspace, prefix, value inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTags", reflect.TypeOf((*MockApiClient)(nil).AddTags), ctx, workspace, prefix, value)
 }
 
+// ApplyRun mocks base method.
+func (m *MockApiClient) ApplyRun(ctx context.Context, runID, comment string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyRun", ctx, runID, comment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ApplyRun indicates an expected call of ApplyRun.
+func (mr *MockApiClientMockRecorder) ApplyRun(ctx, runID, comment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyRun", reflect.TypeOf((*MockApiClient)(nil).ApplyRun), ctx, runID, comment)
+}
+
+// CancelRun mocks base method.
+func (m *MockApiClient) CancelRun(ctx context.Context, runID, comment string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelRun", ctx, runID, comment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelRun indicates an expected call of CancelRun.
+func (mr *MockApiClientMockRecorder) CancelRun(ctx, runID, comment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelRun", reflect.TypeOf((*MockApiClient)(nil).CancelRun), ctx, runID, comment)
+}
+
 // CreateRunFromSource mocks base method.
 func (m *MockApiClient) CreateRunFromSource(opts *tfc_api.ApiRunOptions) (*tfe.Run, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +93,64 @@ func (mr *MockApiClientMockRecorder) CreateRunFromSource(opts interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRunFromSource", reflect.TypeOf((*MockApiClient)(nil).CreateRunFromSource), opts)
 }
 
+// DiscardRun mocks base method.
+func (m *MockApiClient) DiscardRun(ctx context.Context, runID, comment string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiscardRun", ctx, runID, comment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DiscardRun indicates an expected call of DiscardRun.
+func (mr *MockApiClientMockRecorder) DiscardRun(ctx, runID, comment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiscardRun", reflect.TypeOf((*MockApiClient)(nil).DiscardRun), ctx, runID, comment)
+}
+
+// ForceCancelRun mocks base method.
+func (m *MockApiClient) ForceCancelRun(ctx context.Context, runID, comment string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceCancelRun", ctx, runID, comment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForceCancelRun indicates an expected call of ForceCancelRun.
+func (mr *MockApiClientMockRecorder) ForceCancelRun(ctx, runID, comment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceCancelRun", reflect.TypeOf((*MockApiClient)(nil).ForceCancelRun), ctx, runID, comment)
+}
+
+// GetApplyLogs mocks base method.
+func (m *MockApiClient) GetApplyLogs(ctx context.Context, applyID string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApplyLogs", ctx, applyID)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApplyLogs indicates an expected call of GetApplyLogs.
+func (mr *MockApiClientMockRecorder) GetApplyLogs(ctx, applyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApplyLogs", reflect.TypeOf((*MockApiClient)(nil).GetApplyLogs), ctx, applyID)
+}
+
+// GetPlanLogs mocks base method.
+func (m *MockApiClient) GetPlanLogs(ctx context.Context, planID string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlanLogs", ctx, planID)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlanLogs indicates an expected call of GetPlanLogs.
+func (mr *MockApiClientMockRecorder) GetPlanLogs(ctx, planID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlanLogs", reflect.TypeOf((*MockApiClient)(nil).GetPlanLogs), ctx, planID)
+}
+
 // GetPlanOutput mocks base method.
 func (m *MockApiClient) GetPlanOutput(id string) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -80,6 +166,21 @@ func (mr *MockApiClientMockRecorder) GetPlanOutput(id interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlanOutput", reflect.TypeOf((*MockApiClient)(nil).GetPlanOutput), id)
 }
 
+// GetPolicyOutcomes mocks base method.
+func (m *MockApiClient) GetPolicyOutcomes(ctx context.Context, runID string) ([]*tfc_api.PolicyOutcome, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPolicyOutcomes", ctx, runID)
+	ret0, _ := ret[0].([]*tfc_api.PolicyOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPolicyOutcomes indicates an expected call of GetPolicyOutcomes.
+func (mr *MockApiClientMockRecorder) GetPolicyOutcomes(ctx, runID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPolicyOutcomes", reflect.TypeOf((*MockApiClient)(nil).GetPolicyOutcomes), ctx, runID)
+}
+
 // GetRun mocks base method.
 func (m *MockApiClient) GetRun(id string) (*tfe.Run, error) {
 	m.ctrl.T.Helper()
@@ -140,6 +241,36 @@ func (mr *MockApiClientMockRecorder) GetWorkspaceByName(ctx, org, name interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceByName", reflect.TypeOf((*MockApiClient)(nil).GetWorkspaceByName), ctx, org, name)
 }
 
+// ListWorkspaceRuns mocks base method.
+func (m *MockApiClient) ListWorkspaceRuns(ctx context.Context, workspaceID string) ([]*tfe.Run, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWorkspaceRuns", ctx, workspaceID)
+	ret0, _ := ret[0].([]*tfe.Run)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWorkspaceRuns indicates an expected call of ListWorkspaceRuns.
+func (mr *MockApiClientMockRecorder) ListWorkspaceRuns(ctx, workspaceID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWorkspaceRuns", reflect.TypeOf((*MockApiClient)(nil).ListWorkspaceRuns), ctx, workspaceID)
+}
+
+// ListWorkspaces mocks base method.
+func (m *MockApiClient) ListWorkspaces(ctx context.Context, org string) ([]*tfe.Workspace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWorkspaces", ctx, org)
+	ret0, _ := ret[0].([]*tfe.Workspace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWorkspaces indicates an expected call of ListWorkspaces.
+func (mr *MockApiClientMockRecorder) ListWorkspaces(ctx, org interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWorkspaces", reflect.TypeOf((*MockApiClient)(nil).ListWorkspaces), ctx, org)
+}
+
 // LockUnlockWorkspace mocks base method.
 func (m *MockApiClient) LockUnlockWorkspace(ctx context.Context, workspace, reason, tag string, lock bool) error {
 	m.ctrl.T.Helper()
@@ -154,6 +285,20 @@ func (mr *MockApiClientMockRecorder) LockUnlockWorkspace(ctx, workspace, reason,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockUnlockWorkspace", reflect.TypeOf((*MockApiClient)(nil).LockUnlockWorkspace), ctx, workspace, reason, tag, lock)
 }
 
+// OverridePolicies mocks base method.
+func (m *MockApiClient) OverridePolicies(ctx context.Context, runID, comment string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OverridePolicies", ctx, runID, comment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// OverridePolicies indicates an expected call of OverridePolicies.
+func (mr *MockApiClientMockRecorder) OverridePolicies(ctx, runID, comment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OverridePolicies", reflect.TypeOf((*MockApiClient)(nil).OverridePolicies), ctx, runID, comment)
+}
+
 // RemoveTagsByQuery mocks base method.
 func (m *MockApiClient) RemoveTagsByQuery(ctx context.Context, workspace, query string) error {
 	m.ctrl.T.Helper()
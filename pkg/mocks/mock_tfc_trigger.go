@@ -114,6 +114,20 @@ func (mr *MockTriggerConfigMockRecorder) GetAction() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAction", reflect.TypeOf((*MockTriggerConfig)(nil).GetAction))
 }
 
+// GetAllowDestroy mocks base method.
+func (m *MockTriggerConfig) GetAllowDestroy() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllowDestroy")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// GetAllowDestroy indicates an expected call of GetAllowDestroy.
+func (mr *MockTriggerConfigMockRecorder) GetAllowDestroy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllowDestroy", reflect.TypeOf((*MockTriggerConfig)(nil).GetAllowDestroy))
+}
+
 // GetBranch mocks base method.
 func (m *MockTriggerConfig) GetBranch() string {
 	m.ctrl.T.Helper()
@@ -198,6 +212,48 @@ func (mr *MockTriggerConfigMockRecorder) GetProjectNameWithNamespace() *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProjectNameWithNamespace", reflect.TypeOf((*MockTriggerConfig)(nil).GetProjectNameWithNamespace))
 }
 
+// GetRunSlotID mocks base method.
+func (m *MockTriggerConfig) GetRunSlotID() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRunSlotID")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetRunSlotID indicates an expected call of GetRunSlotID.
+func (mr *MockTriggerConfigMockRecorder) GetRunSlotID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRunSlotID", reflect.TypeOf((*MockTriggerConfig)(nil).GetRunSlotID))
+}
+
+// GetSlackChannel mocks base method.
+func (m *MockTriggerConfig) GetSlackChannel() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSlackChannel")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetSlackChannel indicates an expected call of GetSlackChannel.
+func (mr *MockTriggerConfigMockRecorder) GetSlackChannel() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSlackChannel", reflect.TypeOf((*MockTriggerConfig)(nil).GetSlackChannel))
+}
+
+// GetSlackThreadTS mocks base method.
+func (m *MockTriggerConfig) GetSlackThreadTS() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSlackThreadTS")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetSlackThreadTS indicates an expected call of GetSlackThreadTS.
+func (mr *MockTriggerConfigMockRecorder) GetSlackThreadTS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSlackThreadTS", reflect.TypeOf((*MockTriggerConfig)(nil).GetSlackThreadTS))
+}
+
 // GetTriggerSource mocks base method.
 func (m *MockTriggerConfig) GetTriggerSource() tfc_trigger.TriggerSource {
 	m.ctrl.T.Helper()
@@ -109,18 +109,18 @@ func (mr *MockGitClientMockRecorder) GetMergeRequest(arg0, arg1 interface{}) *go
 }
 
 // GetMergeRequestApprovals mocks base method.
-func (m *MockGitClient) GetMergeRequestApprovals(arg0 int, arg1 string) (vcs.MRApproved, error) {
+func (m *MockGitClient) GetMergeRequestApprovals(id int, project string) (vcs.MRApproved, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetMergeRequestApprovals", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetMergeRequestApprovals", id, project)
 	ret0, _ := ret[0].(vcs.MRApproved)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetMergeRequestApprovals indicates an expected call of GetMergeRequestApprovals.
-func (mr *MockGitClientMockRecorder) GetMergeRequestApprovals(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockGitClientMockRecorder) GetMergeRequestApprovals(id, project interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMergeRequestApprovals", reflect.TypeOf((*MockGitClient)(nil).GetMergeRequestApprovals), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMergeRequestApprovals", reflect.TypeOf((*MockGitClient)(nil).GetMergeRequestApprovals), id, project)
 }
 
 // GetMergeRequestModifiedFiles mocks base method.
@@ -235,6 +235,20 @@ func (m *MockGitRepo) EXPECT() *MockGitRepoMockRecorder {
 	return m.recorder
 }
 
+// CheckoutCommit mocks base method.
+func (m *MockGitRepo) CheckoutCommit(sha string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckoutCommit", sha)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckoutCommit indicates an expected call of CheckoutCommit.
+func (mr *MockGitRepoMockRecorder) CheckoutCommit(sha interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckoutCommit", reflect.TypeOf((*MockGitRepo)(nil).CheckoutCommit), sha)
+}
+
 // FetchUpstreamBranch mocks base method.
 func (m *MockGitRepo) FetchUpstreamBranch(arg0 string) error {
 	m.ctrl.T.Helper()
@@ -316,6 +330,20 @@ func (m *MockMRApproved) EXPECT() *MockMRApprovedMockRecorder {
 	return m.recorder
 }
 
+// GetApprovers mocks base method.
+func (m *MockMRApproved) GetApprovers() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApprovers")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetApprovers indicates an expected call of GetApprovers.
+func (mr *MockMRApprovedMockRecorder) GetApprovers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApprovers", reflect.TypeOf((*MockMRApproved)(nil).GetApprovers))
+}
+
 // IsApproved mocks base method.
 func (m *MockMRApproved) IsApproved() bool {
 	m.ctrl.T.Helper()
@@ -492,6 +520,20 @@ func (mr *MockDetailedMRMockRecorder) GetAuthor() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthor", reflect.TypeOf((*MockDetailedMR)(nil).GetAuthor))
 }
 
+// GetHeadSHA mocks base method.
+func (m *MockDetailedMR) GetHeadSHA() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHeadSHA")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetHeadSHA indicates an expected call of GetHeadSHA.
+func (mr *MockDetailedMRMockRecorder) GetHeadSHA() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHeadSHA", reflect.TypeOf((*MockDetailedMR)(nil).GetHeadSHA))
+}
+
 // GetInternalID mocks base method.
 func (m *MockDetailedMR) GetInternalID() int {
 	m.ctrl.T.Helper()
@@ -1054,6 +1096,20 @@ func (mr *MockMRCommentEventMockRecorder) GetProject() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProject", reflect.TypeOf((*MockMRCommentEvent)(nil).GetProject))
 }
 
+// GetUser mocks base method.
+func (m *MockMRCommentEvent) GetUser() vcs.MRAuthor {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser")
+	ret0, _ := ret[0].(vcs.MRAuthor)
+	return ret0
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockMRCommentEventMockRecorder) GetUser() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockMRCommentEvent)(nil).GetUser))
+}
+
 // MockMRAttributes is a mock of MRAttributes interface.
 type MockMRAttributes struct {
 	ctrl     *gomock.Controller
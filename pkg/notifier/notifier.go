@@ -0,0 +1,373 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+)
+
+const (
+	// TargetEnvPrefix prefixes the environment variables holding the URL of each notification target, e.g.
+	// TFBUDDY_NOTIFICATION_TARGET_PLATFORM_SLACK is the URL of the `platform-slack` target.
+	TargetEnvPrefix = "TFBUDDY_NOTIFICATION_TARGET_"
+	// SecretEnvName is the key used to sign the payloads sent to webhook targets. Webhook targets are ignored if it
+	// isn't set.
+	SecretEnvName = "TFBUDDY_NOTIFICATION_SECRET"
+	// SignatureHeader holds the hex encoded HMAC-SHA256 of the timestamp header and the payload, prefixed with
+	// `sha256=`.
+	SignatureHeader = "X-TFBuddy-Signature"
+	// TimestampHeader holds the Unix time the payload was signed at, so receivers can refuse replayed payloads.
+	TimestampHeader = "X-TFBuddy-Timestamp"
+
+	// slackHost is the host of Slack incoming webhooks, which are sent a Slack message instead of the payload
+	slackHost = "hooks.slack.com"
+	// runEventsQueue is the durable consumer group of the notifiers, each run event is notified by a single TF Buddy
+	// pod
+	runEventsQueue = "notifier"
+	// queueSize is the number of events waiting to be notified, events received while the queue is full are
+	// redelivered later
+	queueSize = 256
+	// workers is the number of events notified concurrently
+	workers = 4
+)
+
+var (
+	commonLabels = []string{
+		"event",
+		"target",
+	}
+	notificationsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tfbuddy_notifications_sent",
+		Help: "Count of all notifications sent to notification targets",
+	}, commonLabels)
+	notificationsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tfbuddy_notifications_failed",
+		Help: "Count of all notifications that could not be sent to notification targets",
+	}, commonLabels)
+)
+
+func init() {
+	r := prometheus.DefaultRegisterer
+	r.MustRegister(notificationsSent)
+	r.MustRegister(notificationsFailed)
+}
+
+// Notification is the payload sent to webhook targets.
+type Notification struct {
+	Event           string
+	Organization    string
+	Workspace       string
+	Action          string `json:",omitempty"`
+	RunID           string `json:",omitempty"`
+	RunURL          string `json:",omitempty"`
+	Status          string `json:",omitempty"`
+	Project         string
+	MergeRequestIID int
+	VcsProvider     string
+	Message         string
+	At              time.Time
+}
+
+// Notifier sends run events & lock contention to the Slack incoming webhooks and HTTP endpoints configured for a
+// project or workspace in .tfbuddy.yaml. The targets and their URLs are configured on the server, so projects can't
+// send events to arbitrary URLs.
+type Notifier struct {
+	rs      runstream.StreamClient
+	tfc     tfc_api.ApiClient
+	targets map[string]string
+	secret  []byte
+	client  *http.Client
+	// jobs are the events waiting to be notified by the workers
+	jobs chan func()
+}
+
+func NewNotifier(rs runstream.StreamClient, tfc tfc_api.ApiClient) *Notifier {
+	secret := os.Getenv(SecretEnvName)
+	return &Notifier{
+		rs:      rs,
+		tfc:     tfc,
+		targets: loadTargets(os.Environ(), secret != ""),
+		secret:  []byte(secret),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		jobs:    make(chan func(), queueSize),
+	}
+}
+
+// loadTargets reads the notification targets from the environment, target names are lower case with `-` instead of
+// `_`. Webhook targets are only loaded if their payloads are signed, Slack targets are always loaded.
+func loadTargets(environ []string, signed bool) map[string]string {
+	targets := map[string]string{}
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, TargetEnvPrefix) || value == "" {
+			continue
+		}
+		name := strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(key, TargetEnvPrefix)), "_", "-")
+		if !signed && !isSlack(value) {
+			log.Warn().Str("target", name).Msgf("%s is not set, ignoring webhook notification target", SecretEnvName)
+			continue
+		}
+		targets[name] = value
+	}
+	return targets
+}
+
+// isSlack returns true if the target is a Slack incoming webhook.
+func isSlack(targetURL string) bool {
+	u, err := url.Parse(targetURL)
+	return err == nil && u.Host == slackHost
+}
+
+// Enabled returns true if at least one notification target is configured.
+func (n *Notifier) Enabled() bool {
+	return len(n.targets) > 0
+}
+
+// Start starts the workers sending notifications, and subscribes them to the run events and notification events.
+// The returned closer unsubscribes and stops the workers once their current notification is sent.
+func (n *Notifier) Start() (closer func(), err error) {
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.work(stop)
+		}()
+	}
+
+	closeRunEvents, err := n.rs.SubscribeAllTFRunEvents(runEventsQueue, n.HandleRunEvent)
+	if err != nil {
+		close(stop)
+		return nil, err
+	}
+	closeEvents, err := n.rs.SubscribeNotificationEvents(n.HandleNotificationEvent)
+	if err != nil {
+		closeRunEvents()
+		close(stop)
+		return nil, err
+	}
+	return func() {
+		closeRunEvents()
+		closeEvents()
+		close(stop)
+		wg.Wait()
+	}, nil
+}
+
+// work runs the queued notification jobs until stop is closed.
+func (n *Notifier) work(stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case job := <-n.jobs:
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Error().Interface("panic", r).Msg("notification job panicked")
+					}
+				}()
+				job()
+			}()
+		}
+	}
+}
+
+// enqueue queues a notification job for the workers. It returns false if the queue is full, so the event is
+// redelivered later.
+func (n *Notifier) enqueue(job func()) bool {
+	select {
+	case n.jobs <- job:
+		return true
+	default:
+		log.Warn().Msg("notification queue is full, the event will be redelivered")
+		return false
+	}
+}
+
+// HandleRunEvent queues the notifications of a run status change.
+func (n *Notifier) HandleRunEvent(re runstream.RunEvent) bool {
+	if re.GetMetadata() == nil || len(re.GetMetadata().GetNotifications()) == 0 {
+		return true
+	}
+	return n.enqueue(func() {
+		n.notifyRunEvent(re)
+	})
+}
+
+// notifyRunEvent notifies the apply started, apply failed & destroy planned events of a run status change.
+func (n *Notifier) notifyRunEvent(re runstream.RunEvent) {
+	rmd := re.GetMetadata()
+
+	event := n.runEvent(re.GetNewStatus(), rmd)
+	if event == "" {
+		return
+	}
+	runURL := fmt.Sprintf("https://app.terraform.io/app/%s/workspaces/%s/runs/%s", rmd.GetOrganization(), rmd.GetWorkspace(), rmd.GetRunID())
+	n.notify(rmd.GetNotifications(), &Notification{
+		Event:           event,
+		Organization:    rmd.GetOrganization(),
+		Workspace:       rmd.GetWorkspace(),
+		Action:          rmd.GetAction(),
+		RunID:           rmd.GetRunID(),
+		RunURL:          runURL,
+		Status:          re.GetNewStatus(),
+		Project:         rmd.GetMRProjectNameWithNamespace(),
+		MergeRequestIID: rmd.GetMRInternalID(),
+		VcsProvider:     rmd.GetVcsProvider(),
+		Message:         runMessage(event, rmd, runURL),
+		At:              time.Now(),
+	})
+}
+
+// runEvent returns the notification event of a run status, or an empty string if the status isn't notified.
+func (n *Notifier) runEvent(status string, rmd runstream.RunMetadata) string {
+	switch tfe.RunStatus(status) {
+	case tfe.RunApplying:
+		return runstream.NotifyApplyStarted
+	case tfe.RunErrored:
+		if rmd.GetAction() == "apply" {
+			return runstream.NotifyApplyFailed
+		}
+	case tfe.RunPlanned, tfe.RunPlannedAndFinished:
+		if !matches(rmd.GetNotifications(), runstream.NotifyDestroyPlanned) {
+			// only read the plan if it's notified
+			return ""
+		}
+		run, err := n.tfc.GetRun(rmd.GetRunID())
+		if err != nil {
+			log.Error().Err(err).Str("runID", rmd.GetRunID()).Msg("could not get run")
+			return ""
+		}
+		if run.Plan != nil && run.Plan.ResourceDestructions > 0 {
+			return runstream.NotifyDestroyPlanned
+		}
+	}
+	return ""
+}
+
+// matches returns true if any of the rules sends the event.
+func matches(rules []*runstream.NotificationRule, event string) bool {
+	for _, rule := range rules {
+		if rule.Matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func runMessage(event string, rmd runstream.RunMetadata, runURL string) string {
+	ws := fmt.Sprintf("`%s/%s`", rmd.GetOrganization(), rmd.GetWorkspace())
+	mr := fmt.Sprintf("%s!%d", rmd.GetMRProjectNameWithNamespace(), rmd.GetMRInternalID())
+	switch event {
+	case runstream.NotifyApplyStarted:
+		return fmt.Sprintf("Apply started for %s from %s: %s", ws, mr, runURL)
+	case runstream.NotifyApplyFailed:
+		return fmt.Sprintf("Apply failed for %s from %s: %s", ws, mr, runURL)
+	default:
+		return fmt.Sprintf("Plan for %s from %s destroys resources: %s", ws, mr, runURL)
+	}
+}
+
+// HandleNotificationEvent queues the notification of an event published by TF Buddy, like lock contention.
+func (n *Notifier) HandleNotificationEvent(ev *runstream.NotificationEvent) bool {
+	at := time.Now()
+	return n.enqueue(func() {
+		n.notify(ev.Notifications, &Notification{
+			Event:           ev.Event,
+			Organization:    ev.Organization,
+			Workspace:       ev.Workspace,
+			Project:         ev.MergeRequestProjectNameWithNamespace,
+			MergeRequestIID: ev.MergeRequestIID,
+			VcsProvider:     ev.VcsProvider,
+			Message:         ev.Message,
+			At:              at,
+		})
+	})
+}
+
+// notify sends the notification to the targets of the rules matching its event. Failed notifications are logged and
+// counted, they aren't retried.
+func (n *Notifier) notify(rules []*runstream.NotificationRule, notification *Notification) {
+	for _, rule := range rules {
+		if !rule.Matches(notification.Event) {
+			continue
+		}
+		log := log.With().Str("target", rule.Target).Str("event", notification.Event).Logger()
+		targetURL, ok := n.targets[rule.Target]
+		if !ok {
+			log.Warn().Msg("unknown notification target")
+			continue
+		}
+		labels := prometheus.Labels{
+			"event":  notification.Event,
+			"target": rule.Target,
+		}
+		if err := n.send(targetURL, notification); err != nil {
+			log.Error().Err(err).Msg("could not send notification")
+			notificationsFailed.With(labels).Inc()
+			continue
+		}
+		notificationsSent.With(labels).Inc()
+	}
+}
+
+// send posts the notification to a target. Slack incoming webhooks get the message, other targets get the signed
+// JSON payload.
+func (n *Notifier) send(targetURL string, notification *Notification) error {
+	var payload interface{} = notification
+	slack := isSlack(targetURL)
+	if slack {
+		payload = map[string]string{"text": notification.Message}
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, targetURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if !slack {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(n.secret, timestamp, b))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp, a `.` and the payload. Receivers compare it with the
+// signature header, and refuse payloads with an old timestamp.
+func Sign(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,173 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type sentRequest struct {
+	url       string
+	timestamp string
+	signature string
+	body      []byte
+}
+
+func testNotifier(t *testing.T, rs runstream.StreamClient, tfc *mocks.MockApiClient) (*Notifier, *[]sentRequest) {
+	sent := &[]sentRequest{}
+	n := &Notifier{
+		rs:  rs,
+		tfc: tfc,
+		targets: map[string]string{
+			"platform-slack": "https://hooks.slack.com/services/T0/B0/XXX",
+			"audit":          "https://audit.example.com/tfbuddy",
+		},
+		secret: []byte("s3cr3t"),
+		jobs:   make(chan func(), 1),
+		client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			*sent = append(*sent, sentRequest{
+				url:       req.URL.String(),
+				timestamp: req.Header.Get(TimestampHeader),
+				signature: req.Header.Get(SignatureHeader),
+				body:      b,
+			})
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})},
+	}
+	return n, sent
+}
+
+func TestLoadTargets(t *testing.T) {
+	environ := []string{
+		"TFBUDDY_NOTIFICATION_TARGET_PLATFORM_SLACK=https://hooks.slack.com/services/T0/B0/XXX",
+		"TFBUDDY_NOTIFICATION_TARGET_AUDIT=https://audit.example.com/tfbuddy",
+		"TFBUDDY_NOTIFICATION_TARGET_EMPTY=",
+		"TFBUDDY_NOTIFICATION_SECRET=s3cr3t",
+	}
+	assert.Equal(t, map[string]string{
+		"platform-slack": "https://hooks.slack.com/services/T0/B0/XXX",
+		"audit":          "https://audit.example.com/tfbuddy",
+	}, loadTargets(environ, true))
+	assert.Equal(t, map[string]string{
+		"platform-slack": "https://hooks.slack.com/services/T0/B0/XXX",
+	}, loadTargets(environ, false), "webhook payloads are never sent unsigned")
+}
+
+func TestNotifier_notifyRunEvent(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	rs := mocks.NewMockStreamClient(mockCtrl)
+	tfc := mocks.NewMockApiClient(mockCtrl)
+	n, sent := testNotifier(t, rs, tfc)
+
+	rmd := &runstream.TFRunMetadata{
+		RunID:                                "run-1",
+		Organization:                         "zapier",
+		Workspace:                            "a-ws",
+		Action:                               "apply",
+		MergeRequestProjectNameWithNamespace: "zapier/tfbuddy",
+		MergeRequestIID:                      101,
+		VcsProvider:                          "gitlab",
+		Notifications: []*runstream.NotificationRule{
+			{Target: "platform-slack", Events: []string{"apply_started", "destroy_planned"}},
+			{Target: "audit", Events: []string{"apply_started"}},
+			{Target: "unknown", Events: []string{"apply_started"}},
+		},
+	}
+	tfc.EXPECT().GetRun("run-1").Return(&tfe.Run{ID: "run-1", Plan: &tfe.Plan{ResourceDestructions: 2}}, nil)
+
+	n.notifyRunEvent(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "applying", Metadata: rmd})
+	if assert.Len(t, *sent, 2) {
+		slack := (*sent)[0]
+		assert.Equal(t, "https://hooks.slack.com/services/T0/B0/XXX", slack.url)
+		assert.Empty(t, slack.signature, "Slack messages are not signed")
+		assert.Empty(t, slack.timestamp)
+		assert.JSONEq(t, `{"text":"Apply started for `+"`zapier/a-ws`"+` from zapier/tfbuddy!101: https://app.terraform.io/app/zapier/workspaces/a-ws/runs/run-1"}`, string(slack.body))
+
+		audit := (*sent)[1]
+		assert.Equal(t, "https://audit.example.com/tfbuddy", audit.url)
+		assert.NotEmpty(t, audit.timestamp)
+		assert.Equal(t, "sha256="+Sign([]byte("s3cr3t"), audit.timestamp, audit.body), audit.signature)
+		assert.NotEqual(t, "sha256="+Sign([]byte("s3cr3t"), "0", audit.body), audit.signature, "the timestamp is signed")
+		notification := &Notification{}
+		assert.NoError(t, json.Unmarshal(audit.body, notification))
+		assert.Equal(t, "apply_started", notification.Event)
+		assert.Equal(t, "run-1", notification.RunID)
+		assert.Equal(t, "applying", notification.Status)
+		assert.Equal(t, 101, notification.MergeRequestIID)
+	}
+
+	// no rule sends apply_failed
+	n.notifyRunEvent(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "errored", Metadata: rmd})
+	assert.Len(t, *sent, 2)
+
+	n.notifyRunEvent(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "planned", Metadata: rmd})
+	if assert.Len(t, *sent, 3) {
+		assert.Contains(t, string((*sent)[2].body), "destroys resources")
+	}
+}
+
+func TestNotifier_HandleNotificationEvent(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	n, sent := testNotifier(t, mocks.NewMockStreamClient(mockCtrl), mocks.NewMockApiClient(mockCtrl))
+
+	assert.True(t, n.HandleNotificationEvent(&runstream.NotificationEvent{
+		Event:                                "lock_contention",
+		Organization:                         "zapier",
+		Workspace:                            "a-ws",
+		MergeRequestProjectNameWithNamespace: "zapier/tfbuddy",
+		MergeRequestIID:                      101,
+		Message:                              "Apply of `zapier/a-ws` from zapier/tfbuddy!101 was refused, the workspace is locked by MR !7.",
+		Notifications: []*runstream.NotificationRule{
+			{Target: "audit", Events: []string{"lock_contention"}},
+		},
+	}))
+	assert.Empty(t, *sent, "notifications are sent by the workers")
+	(<-n.jobs)()
+	if assert.Len(t, *sent, 1) {
+		notification := &Notification{}
+		assert.NoError(t, json.Unmarshal((*sent)[0].body, notification))
+		assert.Equal(t, "lock_contention", notification.Event)
+		assert.Equal(t, "a-ws", notification.Workspace)
+		assert.Empty(t, notification.RunID)
+	}
+}
+
+func TestNotifier_HandleRunEvent(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	n, sent := testNotifier(t, mocks.NewMockStreamClient(mockCtrl), mocks.NewMockApiClient(mockCtrl))
+
+	rmd := &runstream.TFRunMetadata{
+		RunID:         "run-1",
+		Action:        "apply",
+		Notifications: []*runstream.NotificationRule{{Target: "audit", Events: []string{"apply_started"}}},
+	}
+	assert.True(t, n.HandleRunEvent(&runstream.TFRunEvent{RunID: "run-2", NewStatus: "applying", Metadata: &runstream.TFRunMetadata{}}),
+		"events of runs without notifications are consumed")
+	assert.True(t, n.HandleRunEvent(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "applying", Metadata: rmd}))
+	assert.False(t, n.HandleRunEvent(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "errored", Metadata: rmd}),
+		"events are redelivered while the queue is full")
+	assert.Empty(t, *sent)
+
+	(<-n.jobs)()
+	assert.Len(t, *sent, 1)
+}
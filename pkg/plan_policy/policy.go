@@ -0,0 +1,174 @@
+package plan_policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/rs/zerolog/log"
+)
+
+// PolicyDirEnvName is a directory of `.rego` policies evaluated against the plans of all projects.
+const PolicyDirEnvName = "TFBUDDY_POLICY_DIR"
+
+// PolicyPackage is the Rego package policies must declare. Its `deny` rules are hard failures that block applies,
+// its `warn` rules are only reported.
+const PolicyPackage = "tfbuddy"
+
+const evalTimeout = 10 * time.Second
+
+// Result holds the messages of the policy rules that matched a plan.
+type Result struct {
+	Deny []string
+	Warn []string
+}
+
+// Failed returns true if a deny rule matched the plan.
+func (r *Result) Failed() bool {
+	return r != nil && len(r.Deny) > 0
+}
+
+// IsEmpty returns true if no rule matched the plan.
+func (r *Result) IsEmpty() bool {
+	return r == nil || len(r.Deny) == 0 && len(r.Warn) == 0
+}
+
+// Evaluate runs the policies, keyed by file name, against the plan JSON.
+func Evaluate(policies map[string]string, plan []byte) (*Result, error) {
+	if len(policies) == 0 {
+		return &Result{}, nil
+	}
+
+	var input interface{}
+	if err := json.Unmarshal(plan, &input); err != nil {
+		return nil, fmt.Errorf("could not parse plan JSON: %w", err)
+	}
+
+	options := []func(*rego.Rego){
+		rego.Query("data." + PolicyPackage),
+		rego.Input(input),
+	}
+	for _, name := range sortedNames(policies) {
+		options = append(options, rego.Module(name, policies[name]))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), evalTimeout)
+	defer cancel()
+	rs, err := rego.New(options...).Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not evaluate policies: %w", err)
+	}
+
+	result := &Result{}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return result, nil
+	}
+	rules, _ := rs[0].Expressions[0].Value.(map[string]interface{})
+	result.Deny = messages(rules["deny"])
+	result.Warn = messages(rules["warn"])
+	return result, nil
+}
+
+// ValidatePolicy checks that the policy parses and declares the tfbuddy package.
+func ValidatePolicy(name, src string) error {
+	module, err := ast.ParseModule(name, src)
+	if err != nil {
+		return err
+	}
+	if module == nil {
+		return fmt.Errorf("%s is empty", name)
+	}
+	if pkg := strings.TrimPrefix(module.Package.Path.String(), "data."); pkg != PolicyPackage {
+		return fmt.Errorf("%s must declare `package %s`, not %s", name, PolicyPackage, pkg)
+	}
+	return nil
+}
+
+var (
+	serverPoliciesOnce sync.Once
+	serverPolicies     map[string]string
+)
+
+// LoadServerPolicies reads & validates the policies in TFBUDDY_POLICY_DIR. Invalid policies are logged and skipped.
+func LoadServerPolicies() map[string]string {
+	serverPoliciesOnce.Do(func() {
+		serverPolicies = readPolicyDir(os.Getenv(PolicyDirEnvName))
+	})
+	return serverPolicies
+}
+
+// Policies returns the server policies together with the project policies.
+func Policies(project map[string]string) map[string]string {
+	policies := map[string]string{}
+	for name, src := range LoadServerPolicies() {
+		policies["server/"+name] = src
+	}
+	for name, src := range project {
+		policies[name] = src
+	}
+	return policies
+}
+
+func readPolicyDir(dir string) map[string]string {
+	policies := map[string]string{}
+	if dir == "" {
+		return policies
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.rego"))
+	if err != nil {
+		log.Error().Err(err).Str("dir", dir).Msg("could not list policies")
+		return policies
+	}
+	for _, filename := range files {
+		b, err := os.ReadFile(filename)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			log.Error().Err(err).Str("file", filename).Msg("could not read policy, skipping")
+			continue
+		}
+		name := filepath.Base(filename)
+		if err := ValidatePolicy(name, string(b)); err != nil {
+			log.Error().Err(err).Str("file", filename).Msg("invalid policy, skipping")
+			continue
+		}
+		log.Info().Str("file", filename).Msg("loaded policy")
+		policies[name] = string(b)
+	}
+	return policies
+}
+
+// messages converts the value of a set rule to sorted strings.
+func messages(v interface{}) []string {
+	values, _ := v.([]interface{})
+	msgs := make([]string, 0, len(values))
+	for _, m := range values {
+		if s, ok := m.(string); ok {
+			msgs = append(msgs, s)
+		} else {
+			b, _ := json.Marshal(m)
+			msgs = append(msgs, string(b))
+		}
+	}
+	sort.Strings(msgs)
+	return msgs
+}
+
+func sortedNames(policies map[string]string) []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
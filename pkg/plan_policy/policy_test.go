@@ -0,0 +1,92 @@
+package plan_policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const denyDeletes = `
+package tfbuddy
+
+deny[msg] {
+	rc := input.resource_changes[_]
+	rc.change.actions[_] == "delete"
+	rc.type == "random_integer"
+	msg := sprintf("%s must not be deleted", [rc.address])
+}
+
+warn[msg] {
+	rc := input.resource_changes[_]
+	rc.change.actions[_] == "create"
+	rc.type == "time_rotating"
+	msg := sprintf("%s is created", [rc.address])
+}
+`
+
+func TestEvaluate(t *testing.T) {
+	plan, err := os.ReadFile("../terraform_plan/testdata/TestPresentPlanChangesAsMarkdown/replace.tfplan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		policies map[string]string
+		want     *Result
+		wantErr  bool
+	}{
+		{
+			name:     "no policies",
+			policies: map[string]string{},
+			want:     &Result{},
+		},
+		{
+			name:     "deny and warn",
+			policies: map[string]string{"deletes.rego": denyDeletes},
+			want: &Result{
+				Deny: []string{"random_integer.pet_length must not be deleted"},
+				Warn: []string{"time_rotating.moar_pets is created"},
+			},
+		},
+		{
+			name:     "no match",
+			policies: map[string]string{"allow.rego": "package tfbuddy\n\ndeny[msg] {\n\tinput.format_version == \"0.0\"\n\tmsg := \"old\"\n}\n"},
+			want:     &Result{Deny: []string{}, Warn: []string{}},
+		},
+		{
+			name:     "syntax error",
+			policies: map[string]string{"broken.rego": "package tfbuddy\n\ndeny[msg] {"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Evaluate(tt.policies, plan)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	assert.NoError(t, ValidatePolicy("deletes.rego", denyDeletes))
+	assert.Error(t, ValidatePolicy("other.rego", "package other\n\ndeny[msg] { msg := \"x\" }"))
+	assert.Error(t, ValidatePolicy("broken.rego", "package tfbuddy\n\ndeny[msg] {"))
+}
+
+func Test_readPolicyDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "deletes.rego"), []byte(denyDeletes), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "other.rego"), []byte("package other\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# policies"), 0644))
+
+	got := readPolicyDir(dir)
+	assert.Equal(t, map[string]string{"deletes.rego": denyDeletes}, got)
+}
@@ -1,6 +1,10 @@
 package runstream
 
-import "time"
+import (
+	"time"
+
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
+)
 
 //go:generate mockgen -source interfaces.go -destination=../mocks/mock_runstream.go -package=mocks github.com/zapier/tfbuddy/pkg/runstream
 
@@ -9,14 +13,38 @@ type StreamClient interface {
 	PublishTFRunEvent(re RunEvent) error
 	AddRunMeta(rmd RunMetadata) error
 	GetRunMeta(runID string) (RunMetadata, error)
+	MarkContinuedCommentsPosted(runID string) (bool, error)
+	PutTemplate(text string) (ref string, err error)
+	GetTemplate(ref string) (string, error)
+	ListMRRunMeta(project string, mrIID int) ([]RunMetadata, error)
+	ListRuns() ([]*RunIndexEntry, error)
+	ListProjectRuns(project string) ([]*RunIndexEntry, error)
+	ListMRRuns(project string, mrIID int) ([]*RunIndexEntry, error)
+	ListCommitRuns(commitSHA string) ([]*RunIndexEntry, error)
+	ListWorkspaceRuns(org, workspace string) ([]*RunIndexEntry, error)
 	NewTFRunPollingTask(meta RunMetadata, delay time.Duration) RunPollingTask
 	SubscribeTFRunPollingTasks(cb func(task RunPollingTask) bool) (closer func(), err error)
+	ListPollingTasks() ([]RunPollingTask, error)
 	SubscribeTFRunEvents(queue string, cb func(run RunEvent) bool) (closer func(), err error)
+	SubscribeAllTFRunEvents(queue string, cb func(run RunEvent) bool) (closer func(), err error)
+	GetRunTimeline(runID string) (*TFRunTimeline, error)
+	PublishNotificationEvent(ev *NotificationEvent) error
+	SubscribeNotificationEvents(cb func(ev *NotificationEvent) bool) (closer func(), err error)
+	GetMRSummary(project string, mrIID int, commitSHA string) (*TFMRSummary, error)
+	UpdateMRSummary(summary *TFMRSummary) error
+	ListMRSummaries() ([]*TFMRSummary, error)
+	SetLatestRun(rmd RunMetadata) (*LatestRun, error)
+	GetSupersedingCommit(rmd RunMetadata) (string, error)
+	AcquireRunSlot(qr *QueuedRun) (position int, err error)
+	AssignRunSlot(id, runID string) error
+	ReleaseRunSlot(id string) error
+	DispatchQueuedRuns() (dispatched []*QueuedRun, err error)
 }
 
 type RunEvent interface {
 	GetRunID() string
 	GetNewStatus() string
+	GetStatusTimestamps() map[string]time.Time
 	GetMetadata() RunMetadata
 	SetMetadata(RunMetadata)
 }
@@ -24,6 +52,7 @@ type RunEvent interface {
 type RunMetadata interface {
 	GetAction() string
 	GetMRInternalID() int
+	GetMRWebURL() string
 	GetRootNoteID() int64
 	GetMRProjectNameWithNamespace() string
 	GetDiscussionID() string
@@ -32,6 +61,16 @@ type RunMetadata interface {
 	GetCommitSHA() string
 	GetOrganization() string
 	GetVcsProvider() string
+	GetPlanFormat() string
+	GetTemplateRefs() map[string]string
+	GetProtectedResources() *terraform_plan.ProtectedResources
+	GetAllowDestroy() bool
+	GetPolicies() map[string]string
+	GetConfirmApply() bool
+	GetCostThreshold() float64
+	GetNotifications() []*NotificationRule
+	GetSlackChannel() string
+	GetSlackThreadTS() string
 }
 
 type RunPollingTask interface {
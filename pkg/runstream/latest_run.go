@@ -0,0 +1,111 @@
+package runstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog/log"
+)
+
+const LatestRunsKvBucket = "LATEST_RUNS"
+
+// maxLatestRunUpdateAttempts is the number of times the latest plan is recorded again when another worker recorded one
+// first
+const maxLatestRunUpdateAttempts = 5
+
+// LatestRun is the latest plan TF Buddy created for a workspace of a MR. Plans of older commits are superseded by it.
+type LatestRun struct {
+	RunID     string
+	CommitSHA string
+	CreatedAt time.Time
+	// Revision is the KV revision of the latest run, so it is only replaced if no other plan was recorded since
+	Revision uint64 `json:"-"`
+}
+
+// SetLatestRun records the run as the latest plan of its workspace for its MR. The previous latest plan is returned, or
+// nil if there was none.
+func (s *Stream) SetLatestRun(rmd RunMetadata) (previous *LatestRun, err error) {
+	key := latestRunKey(rmd.GetMRProjectNameWithNamespace(), rmd.GetMRInternalID(), rmd.GetOrganization(), rmd.GetWorkspace())
+	b, err := json.Marshal(&LatestRun{
+		RunID:     rmd.GetRunID(),
+		CommitSHA: rmd.GetCommitSHA(),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; attempt < maxLatestRunUpdateAttempts; attempt++ {
+		previous, err = s.getLatestRun(key)
+		if err != nil {
+			return nil, err
+		}
+		if previous == nil {
+			_, err = s.latestKV.Create(key, b)
+		} else {
+			_, err = s.latestKV.Update(key, b, previous.Revision)
+		}
+		if err != nil {
+			// another plan was recorded first, it is the one this run supersedes
+			log.Debug().Err(err).Str("runID", rmd.GetRunID()).Msg("could not record latest run, retrying")
+			continue
+		}
+		return previous, nil
+	}
+	return nil, fmt.Errorf("could not record latest run: %w", err)
+}
+
+// GetSupersedingCommit returns the commit SHA of the latest plan of the run's workspace for its MR, if the run is a
+// plan of an older commit, or an empty string otherwise. Updates of superseded runs aren't posted.
+func (s *Stream) GetSupersedingCommit(rmd RunMetadata) (string, error) {
+	if rmd.GetAction() != "plan" || rmd.GetMRProjectNameWithNamespace() == "" {
+		return "", nil
+	}
+	latest, err := s.getLatestRun(latestRunKey(rmd.GetMRProjectNameWithNamespace(), rmd.GetMRInternalID(), rmd.GetOrganization(), rmd.GetWorkspace()))
+	if err != nil {
+		return "", err
+	}
+	if latest == nil || latest.RunID == rmd.GetRunID() || latest.CommitSHA == rmd.GetCommitSHA() {
+		return "", nil
+	}
+	return latest.CommitSHA, nil
+}
+
+func (s *Stream) getLatestRun(key string) (*LatestRun, error) {
+	entry, err := s.latestKV.Get(key)
+	if err == nats.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	latest := &LatestRun{}
+	if err := json.Unmarshal(entry.Value(), latest); err != nil {
+		return nil, err
+	}
+	latest.Revision = entry.Revision()
+	return latest, nil
+}
+
+func latestRunKey(project string, mrIID int, org, workspace string) string {
+	return fmt.Sprintf("%s.%d.%s.%s", kvSafeKey(project), mrIID, kvSafeKey(org), kvSafeKey(workspace))
+}
+
+func configureLatestRunsKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      LatestRunsKvBucket,
+		Description: "KV store for the latest plan of each workspace of a MR",
+		TTL:         time.Hour * 720,
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+
+	return js.CreateKeyValue(cfg)
+}
@@ -0,0 +1,97 @@
+package runstream
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream_SupersedePlans(t *testing.T) {
+	s, cleanup := testRunIndexStream(t)
+	defer cleanup()
+
+	plan := func(runID, sha string) *TFRunMetadata {
+		return &TFRunMetadata{
+			RunID:                                runID,
+			Organization:                         "zapier",
+			Workspace:                            "service-tfbuddy",
+			Action:                               "plan",
+			CommitSHA:                            sha,
+			MergeRequestProjectNameWithNamespace: "zapier/tfbuddy",
+			MergeRequestIID:                      101,
+		}
+	}
+	run1, run2, run3 := plan("run-1", "aaaa1111"), plan("run-2", "bbbb2222"), plan("run-3", "bbbb2222")
+
+	previous, err := s.SetLatestRun(run1)
+	assert.NoError(t, err)
+	assert.Nil(t, previous)
+	sha, err := s.GetSupersedingCommit(run1)
+	assert.NoError(t, err)
+	assert.Empty(t, sha)
+
+	previous, err = s.SetLatestRun(run2)
+	assert.NoError(t, err)
+	if assert.NotNil(t, previous) {
+		assert.Equal(t, "run-1", previous.RunID)
+		assert.Equal(t, "aaaa1111", previous.CommitSHA)
+	}
+	sha, err = s.GetSupersedingCommit(run1)
+	assert.NoError(t, err)
+	assert.Equal(t, "bbbb2222", sha)
+
+	// a new plan of the same commit doesn't supersede the previous one
+	_, err = s.SetLatestRun(run3)
+	assert.NoError(t, err)
+	sha, err = s.GetSupersedingCommit(run2)
+	assert.NoError(t, err)
+	assert.Empty(t, sha)
+
+	// applies aren't superseded
+	apply := plan("run-4", "aaaa1111")
+	apply.Action = "apply"
+	sha, err = s.GetSupersedingCommit(apply)
+	assert.NoError(t, err)
+	assert.Empty(t, sha)
+}
+
+func TestStream_SetLatestRunConcurrently(t *testing.T) {
+	s, cleanup := testRunIndexStream(t)
+	defer cleanup()
+
+	// each plan recorded concurrently supersedes a different plan
+	previous := make(chan string, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			latest, err := s.SetLatestRun(&TFRunMetadata{
+				RunID:                                fmt.Sprintf("run-%d", i),
+				Organization:                         "zapier",
+				Workspace:                            "service-tfbuddy",
+				Action:                               "plan",
+				CommitSHA:                            fmt.Sprintf("sha-%d", i),
+				MergeRequestProjectNameWithNamespace: "zapier/tfbuddy",
+				MergeRequestIID:                      101,
+			})
+			assert.NoError(t, err)
+			if latest == nil {
+				previous <- ""
+			} else {
+				previous <- latest.RunID
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(previous)
+
+	seen := map[string]bool{}
+	for runID := range previous {
+		assert.False(t, seen[runID], "%q superseded twice", runID)
+		seen[runID] = true
+	}
+	assert.Len(t, seen, 4)
+}
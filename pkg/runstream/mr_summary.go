@@ -0,0 +1,231 @@
+package runstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const MRSummaryKvBucket = "MR_SUMMARIES"
+
+// TFMRSummary is the consolidated status of every workspace run triggered for a single Merge Request commit.
+type TFMRSummary struct {
+	// ProjectNameWithNamespace is the fully qualified project name (e.g. group/subgroup/project)
+	ProjectNameWithNamespace string
+	// MergeRequestIID is the Merge Request IID the summary belongs to
+	MergeRequestIID int
+	// MergeRequestWebURL is the link to the Merge Request on the VCS
+	MergeRequestWebURL string
+	// VcsProvider is the VCS hosting the Merge Request
+	VcsProvider string
+	// CommitSHA is the git commit the summarised runs were triggered for
+	CommitSHA string
+	// DiscussionID is the MR discussion thread holding the summary note
+	DiscussionID string
+	// NoteID is the summary note that is updated on every run event
+	NoteID int64
+	// ClaimedAt is when a worker claimed the summary to create its note, the claim is taken over once it is stale
+	ClaimedAt time.Time
+	// Workspaces holds the latest run status for each workspace, keyed by "organization/workspace"
+	Workspaces map[string]*WorkspaceRunSummary
+	// CostThreshold is the total monthly cost increase above which applies need approval from a cost approver
+	CostThreshold float64
+
+	// Revision is the NATS KV entry revision
+	Revision uint64 `json:"-"`
+}
+
+// WorkspaceRunSummary is a single row of the MR summary.
+type WorkspaceRunSummary struct {
+	Organization string
+	Workspace    string
+	RunID        string
+	RunURL       string
+	Action       string
+	Status       string
+	Additions    int
+	Changes      int
+	Destructions int
+	// DeltaMonthlyCost is the change of the monthly cost estimate in USD, empty if the run has no cost estimate
+	DeltaMonthlyCost string
+	UpdatedAt        time.Time
+}
+
+// SetWorkspaceRun adds or replaces the summary row for a workspace, the row is copied.
+func (s *TFMRSummary) SetWorkspaceRun(row *WorkspaceRunSummary) {
+	if s.Workspaces == nil {
+		s.Workspaces = map[string]*WorkspaceRunSummary{}
+	}
+	ws := *row
+	ws.UpdatedAt = time.Now()
+	key := fmt.Sprintf("%s/%s", ws.Organization, ws.Workspace)
+	// the cost estimate is only fetched once, keep it for the later status updates of the run
+	if prev, ok := s.Workspaces[key]; ok && prev.RunID == ws.RunID && ws.DeltaMonthlyCost == "" {
+		ws.DeltaMonthlyCost = prev.DeltaMonthlyCost
+	}
+	s.Workspaces[key] = &ws
+}
+
+// NoteClaimed returns true if another worker is creating the summary note. Claims older than the TTL are stale, e.g.
+// the worker crashed, and can be taken over.
+func (s *TFMRSummary) NoteClaimed(ttl time.Duration) bool {
+	return s.NoteID == 0 && !s.ClaimedAt.IsZero() && time.Since(s.ClaimedAt) < ttl
+}
+
+// DeltaMonthlyCost returns the total change of the monthly cost estimates of all workspaces. ok is false if no run has
+// a cost estimate.
+func (s *TFMRSummary) DeltaMonthlyCost() (delta float64, ok bool) {
+	for _, ws := range s.Workspaces {
+		if ws.DeltaMonthlyCost == "" {
+			continue
+		}
+		d, err := strconv.ParseFloat(ws.DeltaMonthlyCost, 64)
+		if err != nil {
+			continue
+		}
+		delta += d
+		ok = true
+	}
+	return delta, ok
+}
+
+// CostThresholdExceeded returns true if the total monthly cost increase is above the project's cost threshold.
+func (s *TFMRSummary) CostThresholdExceeded() bool {
+	delta, ok := s.DeltaMonthlyCost()
+	return ok && s.CostThreshold > 0 && delta > s.CostThreshold
+}
+
+// SortedWorkspaces returns the summary rows ordered by organization & workspace name.
+func (s *TFMRSummary) SortedWorkspaces() []*WorkspaceRunSummary {
+	keys := make([]string, 0, len(s.Workspaces))
+	for k := range s.Workspaces {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rows := make([]*WorkspaceRunSummary, 0, len(keys))
+	for _, k := range keys {
+		rows = append(rows, s.Workspaces[k])
+	}
+	return rows
+}
+
+// GetMRSummary reads the summary for a MR commit. If no summary exists yet, an empty one is returned.
+func (s *Stream) GetMRSummary(project string, mrIID int, commitSHA string) (*TFMRSummary, error) {
+	entry, err := s.summaryKV.Get(mrSummaryKVKey(project, mrIID, commitSHA))
+	if err == nats.ErrKeyNotFound {
+		return &TFMRSummary{
+			ProjectNameWithNamespace: project,
+			MergeRequestIID:          mrIID,
+			CommitSHA:                commitSHA,
+			Workspaces:               map[string]*WorkspaceRunSummary{},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := decodeTFMRSummary(entry.Value())
+	if err != nil {
+		return nil, err
+	}
+	summary.Revision = entry.Revision()
+	return summary, nil
+}
+
+// UpdateMRSummary writes the summary back to the KV store. The write fails if the summary has been modified
+// since it was read, so callers should re-read and retry.
+func (s *Stream) UpdateMRSummary(summary *TFMRSummary) error {
+	b, err := encodeTFMRSummary(summary)
+	if err != nil {
+		return err
+	}
+
+	key := mrSummaryKVKey(summary.ProjectNameWithNamespace, summary.MergeRequestIID, summary.CommitSHA)
+	var rev uint64
+	if summary.Revision == 0 {
+		rev, err = s.summaryKV.Create(key, b)
+	} else {
+		rev, err = s.summaryKV.Update(key, b, summary.Revision)
+	}
+	if err != nil {
+		return err
+	}
+	summary.Revision = rev
+	return nil
+}
+
+// ListMRSummaries returns the summaries of all MR commits.
+func (s *Stream) ListMRSummaries() ([]*TFMRSummary, error) {
+	w, err := s.summaryKV.WatchAll(nats.IgnoreDeletes())
+	if err != nil {
+		return nil, err
+	}
+	defer w.Stop()
+
+	var summaries []*TFMRSummary
+	for entry := range w.Updates() {
+		// a nil entry marks the end of the initial values
+		if entry == nil {
+			break
+		}
+		summary, err := decodeTFMRSummary(entry.Value())
+		if err != nil {
+			return nil, err
+		}
+		summary.Revision = entry.Revision()
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func mrSummaryKVKey(project string, mrIID int, commitSHA string) string {
+	return fmt.Sprintf("%s.%d.%s", kvSafeKey(project), mrIID, commitSHA)
+}
+
+// kvSafeKey escapes a value so it can be used as a single token of a NATS KV key. Letters, digits, "-", "_" and "/"
+// are kept, every other byte is written as "=XX" in hex, so distinct values never map to the same key.
+func kvSafeKey(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "=%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func encodeTFMRSummary(summary *TFMRSummary) ([]byte, error) {
+	return json.Marshal(summary)
+}
+
+func decodeTFMRSummary(b []byte) (*TFMRSummary, error) {
+	summary := &TFMRSummary{}
+	err := json.Unmarshal(b, summary)
+	return summary, err
+}
+
+func configureMRSummaryKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      MRSummaryKvBucket,
+		Description: "KV store for Merge Request run summaries",
+		TTL:         time.Hour * 720,
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+	return js.CreateKeyValue(cfg)
+}
@@ -0,0 +1,108 @@
+package runstream
+
+import (
+	"fmt"
+	"testing"
+
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream_MRSummary(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = TEST_PORT
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	s := RunServerWithOptions(&opts)
+	defer s.Shutdown()
+
+	url := fmt.Sprintf("nats://127.0.0.1:%d", TEST_PORT)
+	nc := testConnect(t, url)
+	defer nc.Close()
+	js := testGetJetstreamContext(t, nc)
+
+	kv, err := configureMRSummaryKVStore(js)
+	if err != nil {
+		t.Fatalf("configureMRSummaryKVStore() failure: %v", err)
+	}
+	stream := &Stream{js: js, summaryKV: kv}
+
+	summary, err := stream.GetMRSummary("zapier/tfbuddy", 101, "abcd1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint64(0), summary.Revision)
+	assert.Empty(t, summary.Workspaces)
+
+	summary.SetWorkspaceRun(&WorkspaceRunSummary{Organization: "zapier", Workspace: "b-ws", Status: "pending"})
+	summary.SetWorkspaceRun(&WorkspaceRunSummary{Organization: "zapier", Workspace: "a-ws", Status: "planning"})
+	if err := stream.UpdateMRSummary(summary); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := stream.GetMRSummary("zapier/tfbuddy", 101, "abcd1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := stale.SortedWorkspaces()
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "a-ws", rows[0].Workspace)
+	assert.Equal(t, "b-ws", rows[1].Workspace)
+
+	summary.NoteID = 301
+	if err := stream.UpdateMRSummary(summary); err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, stream.UpdateMRSummary(stale), "expected stale summary update to fail")
+
+	summaries, err := stream.ListMRSummaries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, summaries, 1) {
+		assert.Equal(t, int64(301), summaries[0].NoteID)
+	}
+}
+
+func TestTFMRSummary_DeltaMonthlyCost(t *testing.T) {
+	summary := &TFMRSummary{CostThreshold: 100}
+	_, ok := summary.DeltaMonthlyCost()
+	assert.False(t, ok)
+
+	summary.SetWorkspaceRun(&WorkspaceRunSummary{Organization: "zapier", Workspace: "a-ws", RunID: "run-a", DeltaMonthlyCost: "72.5"})
+	summary.SetWorkspaceRun(&WorkspaceRunSummary{Organization: "zapier", Workspace: "b-ws", RunID: "run-b", DeltaMonthlyCost: "-10.25"})
+	summary.SetWorkspaceRun(&WorkspaceRunSummary{Organization: "zapier", Workspace: "c-ws", RunID: "run-c"})
+	delta, ok := summary.DeltaMonthlyCost()
+	assert.True(t, ok)
+	assert.Equal(t, 62.25, delta)
+	assert.False(t, summary.CostThresholdExceeded())
+
+	// later status updates of the same run keep the cost estimate, a new run replaces it
+	summary.SetWorkspaceRun(&WorkspaceRunSummary{Organization: "zapier", Workspace: "a-ws", RunID: "run-a", Status: "applying"})
+	summary.SetWorkspaceRun(&WorkspaceRunSummary{Organization: "zapier", Workspace: "b-ws", RunID: "run-b2", DeltaMonthlyCost: "50"})
+	delta, _ = summary.DeltaMonthlyCost()
+	assert.Equal(t, 122.5, delta)
+	assert.True(t, summary.CostThresholdExceeded())
+}
+
+func TestTFMRSummary_SetWorkspaceRunCopies(t *testing.T) {
+	row := &WorkspaceRunSummary{Organization: "zapier", Workspace: "a-ws", RunID: "run-a", DeltaMonthlyCost: "10"}
+	summary := &TFMRSummary{}
+	summary.SetWorkspaceRun(row)
+	summary.SetWorkspaceRun(&WorkspaceRunSummary{Organization: "zapier", Workspace: "a-ws", RunID: "run-a"})
+
+	assert.True(t, row.UpdatedAt.IsZero(), "expected the caller's row to be left as is")
+	assert.Equal(t, "10", summary.Workspaces["zapier/a-ws"].DeltaMonthlyCost)
+}
+
+func Test_kvSafeKey(t *testing.T) {
+	assert.Equal(t, "zapier/tfbuddy", kvSafeKey("zapier/tfbuddy"))
+	assert.Equal(t, "zapier/tf=2Ebuddy=20v2=3D", kvSafeKey("zapier/tf.buddy v2="))
+
+	keys := map[string]string{}
+	for _, s := range []string{"zapier/tfbuddy", "zapier_tfbuddy", "zapier tfbuddy", "zapier.tfbuddy", "zapier=2Etfbuddy"} {
+		key := kvSafeKey(s)
+		assert.Empty(t, keys[key], "%q and %q share the key %q", keys[key], s, key)
+		keys[key] = s
+	}
+}
@@ -0,0 +1,119 @@
+package runstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog/log"
+)
+
+const NotificationsStreamName = "NOTIFICATIONS"
+
+// notificationsQueue is the consumer group of the notifiers, each event is sent once across all TF Buddy pods
+const notificationsQueue = "notifier"
+
+// Events that can be sent to notification targets.
+const (
+	NotifyApplyStarted   = "apply_started"
+	NotifyApplyFailed    = "apply_failed"
+	NotifyDestroyPlanned = "destroy_planned"
+	NotifyLockContention = "lock_contention"
+)
+
+var NotificationEvents = []string{
+	NotifyApplyStarted,
+	NotifyApplyFailed,
+	NotifyDestroyPlanned,
+	NotifyLockContention,
+}
+
+// NotificationRule sends the listed events of a workspace to a notification target configured on the TF Buddy server.
+type NotificationRule struct {
+	Target string   `yaml:"target"`
+	Events []string `yaml:"events"`
+}
+
+// Matches returns true if the rule sends the event.
+func (r *NotificationRule) Matches(event string) bool {
+	for _, e := range r.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationEvent is an event to notify that isn't a run status change, e.g. an apply refused because another MR
+// holds the workspace lock.
+type NotificationEvent struct {
+	Event                                string
+	Organization                         string
+	Workspace                            string
+	MergeRequestProjectNameWithNamespace string
+	MergeRequestIID                      int
+	VcsProvider                          string
+	Message                              string
+	Notifications                        []*NotificationRule
+}
+
+func (s *Stream) PublishNotificationEvent(ev *NotificationEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = s.js.Publish(fmt.Sprintf("%s.%s", NotificationsStreamName, ev.Event), b)
+	return err
+}
+
+func (s *Stream) SubscribeNotificationEvents(cb func(ev *NotificationEvent) bool) (closer func(), err error) {
+	sub, err := s.js.QueueSubscribe(
+		fmt.Sprintf("%s.*", NotificationsStreamName),
+		notificationsQueue,
+		func(msg *nats.Msg) {
+			ev := &NotificationEvent{}
+			if err := json.Unmarshal(msg.Data, ev); err != nil {
+				log.Error().Err(err).Msg("could not decode notification event")
+				if err := msg.Term(); err != nil {
+					log.Error().Err(err).Msg("could not Terminate NATS msg")
+				}
+				return
+			}
+
+			if cb(ev) {
+				if err := msg.Ack(); err != nil {
+					log.Error().Err(err).Msg("could not Ack NATS msg")
+				}
+			} else {
+				if err := msg.Nak(); err != nil {
+					log.Error().Err(err).Msg("could not Nak NATS msg")
+				}
+			}
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	closer = func() {
+		if err := sub.Unsubscribe(); err != nil {
+			log.Error().Err(err).Msg("could not unsubscribe from NATS queue")
+		}
+	}
+	return closer, nil
+}
+
+func configureNotificationsStream(js nats.JetStreamContext) {
+	sCfg := &nats.StreamConfig{
+		Name:        NotificationsStreamName,
+		Description: "TF Buddy events sent to notification targets",
+		Subjects:    []string{fmt.Sprintf("%s.*", NotificationsStreamName)},
+		Retention:   nats.WorkQueuePolicy,
+		MaxMsgs:     10240,
+		MaxAge:      time.Hour * 6,
+		Replicas:    1,
+	}
+
+	addOrUpdateStream(js, sCfg)
+}
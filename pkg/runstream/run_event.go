@@ -19,7 +19,9 @@ type TFRunEvent struct {
 	Organization string
 	Workspace    string
 	NewStatus    string
-	Metadata     RunMetadata
+	// StatusTimestamps are the times TFC reports the run entered its statuses, keyed by status (optional)
+	StatusTimestamps map[string]time.Time
+	Metadata         RunMetadata
 }
 
 func (e *TFRunEvent) GetRunID() string {
@@ -28,6 +30,9 @@ func (e *TFRunEvent) GetRunID() string {
 func (e *TFRunEvent) GetNewStatus() string {
 	return e.NewStatus
 }
+func (e *TFRunEvent) GetStatusTimestamps() map[string]time.Time {
+	return e.StatusTimestamps
+}
 func (e *TFRunEvent) GetMetadata() RunMetadata {
 	return e.Metadata
 }
@@ -41,6 +46,10 @@ func (s *Stream) PublishTFRunEvent(re RunEvent) error {
 		return err
 	}
 
+	if err := s.recordRunStatus(rmd, re.GetNewStatus(), re.GetStatusTimestamps()); err != nil {
+		log.Error().Err(err).Str("runID", re.GetRunID()).Msg("could not record run status")
+	}
+
 	b, err := encodeTFRunEvent(re)
 	if err != nil {
 		return err
@@ -50,10 +59,22 @@ func (s *Stream) PublishTFRunEvent(re RunEvent) error {
 	return err
 }
 
+// SubscribeTFRunEvents calls cb with the run events of runs triggered from a VCS provider, enriched with the run
+// metadata. Each event is consumed by a single subscriber of the provider's durable queue.
 func (s *Stream) SubscribeTFRunEvents(vcsProvider string, cb func(run RunEvent) bool) (closer func(), err error) {
+	return s.subscribeTFRunEvents(fmt.Sprintf("%s.%s", RunEventsStreamName, vcsProvider), vcsProvider, cb)
+}
+
+// SubscribeAllTFRunEvents calls cb with the run events of all VCS providers, enriched with the run metadata. Each
+// event is consumed by a single subscriber of the durable queue, independently of the other queues.
+func (s *Stream) SubscribeAllTFRunEvents(queue string, cb func(run RunEvent) bool) (closer func(), err error) {
+	return s.subscribeTFRunEvents(fmt.Sprintf("%s.*", RunEventsStreamName), queue, cb)
+}
+
+func (s *Stream) subscribeTFRunEvents(subject, queue string, cb func(run RunEvent) bool) (closer func(), err error) {
 	sub, err := s.js.QueueSubscribe(
-		fmt.Sprintf("%s.%s", RunEventsStreamName, vcsProvider),
-		vcsProvider,
+		subject,
+		queue,
 		func(msg *nats.Msg) {
 			re, err := decodeTFRunEvent(msg.Data)
 			if err != nil {
@@ -113,15 +134,41 @@ func (s *Stream) SubscribeTFRunEvents(vcsProvider string, cb func(run RunEvent)
 	return closer, nil
 }
 
+// ObserveTFRunEvents calls cb with the run events published to the RUN_EVENTS stream, without consuming them. Only
+// the events published while subscribed are observed, and they aren't enriched with the run metadata. With a queue,
+// each event is only observed by one of the subscribers of the queue, otherwise every subscriber observes it.
+func ObserveTFRunEvents(nc *nats.Conn, queue string, cb func(re RunEvent)) (closer func(), err error) {
+	sub, err := nc.QueueSubscribe(fmt.Sprintf("%s.*", RunEventsStreamName), queue, func(msg *nats.Msg) {
+		re, err := decodeTFRunEvent(msg.Data)
+		if err != nil {
+			log.Error().Err(err).Msg("could not decode Run")
+			return
+		}
+		cb(re)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	closer = func() {
+		if err := sub.Unsubscribe(); err != nil {
+			log.Error().Err(err).Msg("could not unsubscribe from NATS subject")
+		}
+	}
+	return closer, nil
+}
+
 func configureTFRunEventsStream(js nats.JetStreamContext) {
 	sCfg := &nats.StreamConfig{
 		Name:        RunEventsStreamName,
 		Description: "Terraform Cloud Run Notifications",
 		Subjects:    []string{fmt.Sprintf("%s.*", RunEventsStreamName)},
-		Retention:   nats.WorkQueuePolicy,
-		MaxMsgs:     10240,
-		MaxAge:      time.Hour * 6,
-		Replicas:    1,
+		// run events are consumed by the VCS provider queues and by the notifier queue, they are kept until each
+		// queue consumed them
+		Retention: nats.InterestPolicy,
+		MaxMsgs:   10240,
+		MaxAge:    time.Hour * 6,
+		Replicas:  1,
 	}
 
 	addOrUpdateStream(js, sCfg)
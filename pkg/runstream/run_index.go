@@ -0,0 +1,158 @@
+package runstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const RunIndexKvBucket = "RUN_INDEX"
+
+// RunIndexEntry references a run in the secondary indexes of the RUN_METADATA KV store.
+type RunIndexEntry struct {
+	RunID                                string
+	Organization                         string
+	Workspace                            string
+	Action                               string
+	CommitSHA                            string
+	MergeRequestProjectNameWithNamespace string
+	MergeRequestIID                      int
+	CreatedAt                            time.Time
+}
+
+// indexRun adds the run to the indexes by MR, by commit and by workspace.
+func (s *Stream) indexRun(rmd RunMetadata) error {
+	entry := &RunIndexEntry{
+		RunID:                                rmd.GetRunID(),
+		Organization:                         rmd.GetOrganization(),
+		Workspace:                            rmd.GetWorkspace(),
+		Action:                               rmd.GetAction(),
+		CommitSHA:                            rmd.GetCommitSHA(),
+		MergeRequestProjectNameWithNamespace: rmd.GetMRProjectNameWithNamespace(),
+		MergeRequestIID:                      rmd.GetMRInternalID(),
+		CreatedAt:                            time.Now(),
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{workspaceRunIndexKey(entry.Organization, entry.Workspace, entry.RunID)}
+	if entry.MergeRequestProjectNameWithNamespace != "" {
+		keys = append(keys, mrRunIndexKey(entry.MergeRequestProjectNameWithNamespace, entry.MergeRequestIID, entry.RunID))
+	}
+	if entry.CommitSHA != "" {
+		keys = append(keys, commitRunIndexKey(entry.CommitSHA, entry.RunID))
+	}
+	for _, key := range keys {
+		if _, err := s.indexKV.Put(key, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListMRRuns returns the runs created for the MR, newest first.
+func (s *Stream) ListMRRuns(project string, mrIID int) ([]*RunIndexEntry, error) {
+	return s.listRunIndex(mrRunIndexKey(project, mrIID, "*"))
+}
+
+// ListProjectRuns returns the runs created for all MRs of the project, newest first.
+func (s *Stream) ListProjectRuns(project string) ([]*RunIndexEntry, error) {
+	return s.listRunIndex(fmt.Sprintf("mr.%s.*.*", kvSafeKey(project)))
+}
+
+// ListCommitRuns returns the runs created for the commit, newest first.
+func (s *Stream) ListCommitRuns(commitSHA string) ([]*RunIndexEntry, error) {
+	return s.listRunIndex(commitRunIndexKey(commitSHA, "*"))
+}
+
+// ListWorkspaceRuns returns the runs TF Buddy created for the workspace, newest first.
+func (s *Stream) ListWorkspaceRuns(org, workspace string) ([]*RunIndexEntry, error) {
+	return s.listRunIndex(workspaceRunIndexKey(org, workspace, "*"))
+}
+
+// ListRuns returns all runs TF Buddy created, newest first.
+func (s *Stream) ListRuns() ([]*RunIndexEntry, error) {
+	return s.listRunIndex("ws.>")
+}
+
+// ListMRRunMeta returns the metadata of all runs created for the MR, newest first.
+func (s *Stream) ListMRRunMeta(project string, mrIID int) ([]RunMetadata, error) {
+	entries, err := s.ListMRRuns(project, mrIID)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]RunMetadata, 0, len(entries))
+	for _, entry := range entries {
+		rmd, err := s.GetRunMeta(entry.RunID)
+		if err == nats.ErrKeyNotFound {
+			// the run metadata has expired
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, rmd)
+	}
+	return runs, nil
+}
+
+// listRunIndex reads the index entries matching the key filter.
+func (s *Stream) listRunIndex(filter string) ([]*RunIndexEntry, error) {
+	w, err := s.indexKV.Watch(filter, nats.IgnoreDeletes())
+	if err != nil {
+		return nil, err
+	}
+	defer w.Stop()
+
+	var entries []*RunIndexEntry
+	for kve := range w.Updates() {
+		// a nil entry marks the end of the initial values
+		if kve == nil {
+			break
+		}
+		entry := &RunIndexEntry{}
+		if err := json.Unmarshal(kve.Value(), entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+func mrRunIndexKey(project string, mrIID int, runID string) string {
+	return fmt.Sprintf("mr.%s.%d.%s", kvSafeKey(project), mrIID, runID)
+}
+
+func commitRunIndexKey(commitSHA, runID string) string {
+	return fmt.Sprintf("commit.%s.%s", commitSHA, runID)
+}
+
+func workspaceRunIndexKey(org, workspace, runID string) string {
+	return fmt.Sprintf("ws.%s.%s.%s", kvSafeKey(org), kvSafeKey(workspace), runID)
+}
+
+func configureRunIndexKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      RunIndexKvBucket,
+		Description: "KV store indexing Run Metadata by MR, commit and workspace",
+		TTL:         time.Hour * 720,
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+
+	return js.CreateKeyValue(cfg)
+}
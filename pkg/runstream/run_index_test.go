@@ -0,0 +1,136 @@
+package runstream
+
+import (
+	"fmt"
+	"testing"
+
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRunIndexStream(t *testing.T) (*Stream, func()) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = TEST_PORT
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	s := RunServerWithOptions(&opts)
+
+	url := fmt.Sprintf("nats://127.0.0.1:%d", TEST_PORT)
+	nc := testConnect(t, url)
+	js := testGetJetstreamContext(t, nc)
+
+	metadataKV, err := configureTFRunMetadataKVStore(js)
+	if err != nil {
+		t.Fatalf("configureTFRunMetadataKVStore() failure: %v", err)
+	}
+	indexKV, err := configureRunIndexKVStore(js)
+	if err != nil {
+		t.Fatalf("configureRunIndexKVStore() failure: %v", err)
+	}
+	timelineKV, err := configureRunTimelineKVStore(js)
+	if err != nil {
+		t.Fatalf("configureRunTimelineKVStore() failure: %v", err)
+	}
+	latestKV, err := configureLatestRunsKVStore(js)
+	if err != nil {
+		t.Fatalf("configureLatestRunsKVStore() failure: %v", err)
+	}
+	queueKV, err := configureRunQueueKVStore(js)
+	if err != nil {
+		t.Fatalf("configureRunQueueKVStore() failure: %v", err)
+	}
+	return &Stream{js: js, metadataKV: metadataKV, indexKV: indexKV, timelineKV: timelineKV, latestKV: latestKV, queueKV: queueKV, limits: &RunLimits{}}, func() {
+		nc.Close()
+		s.Shutdown()
+	}
+}
+
+func runIDs(entries []*RunIndexEntry) []string {
+	ids := []string{}
+	for _, entry := range entries {
+		ids = append(ids, entry.RunID)
+	}
+	return ids
+}
+
+func TestStream_RunIndex(t *testing.T) {
+	stream, closer := testRunIndexStream(t)
+	defer closer()
+
+	runs, err := stream.ListMRRunMeta("zapier/tfbuddy", 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, runs)
+
+	for _, rmd := range []*TFRunMetadata{
+		{RunID: "run-1", Organization: "zapier", Workspace: "a-ws", CommitSHA: "abcd1234", MergeRequestProjectNameWithNamespace: "zapier/tfbuddy", MergeRequestIID: 101},
+		{RunID: "run-2", Organization: "zapier", Workspace: "a-ws", CommitSHA: "efgh5678", MergeRequestProjectNameWithNamespace: "zapier/tfbuddy", MergeRequestIID: 102},
+		{RunID: "run-3", Organization: "zapier", Workspace: "b-ws", CommitSHA: "abcd1234", MergeRequestProjectNameWithNamespace: "zapier/other", MergeRequestIID: 101},
+		{RunID: "run-4", Organization: "zapier", Workspace: "b-ws", CommitSHA: "ijkl9012", MergeRequestProjectNameWithNamespace: "zapier/tfbuddy", MergeRequestIID: 101},
+	} {
+		if err := stream.AddRunMeta(rmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mrRuns, err := stream.ListMRRuns("zapier/tfbuddy", 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"run-4", "run-1"}, runIDs(mrRuns))
+
+	projectRuns, err := stream.ListProjectRuns("zapier/tfbuddy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"run-4", "run-2", "run-1"}, runIDs(projectRuns))
+
+	allRuns, err := stream.ListRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"run-4", "run-3", "run-2", "run-1"}, runIDs(allRuns))
+
+	commitRuns, err := stream.ListCommitRuns("abcd1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"run-3", "run-1"}, runIDs(commitRuns))
+
+	wsRuns, err := stream.ListWorkspaceRuns("zapier", "a-ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"run-2", "run-1"}, runIDs(wsRuns))
+
+	runs, err = stream.ListMRRunMeta("zapier/tfbuddy", 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, runs, 2) {
+		assert.Equal(t, "run-4", runs[0].GetRunID())
+		assert.Equal(t, "b-ws", runs[0].GetWorkspace())
+	}
+}
+
+func TestStream_MarkContinuedCommentsPosted(t *testing.T) {
+	stream, closer := testRunIndexStream(t)
+	defer closer()
+
+	if err := stream.AddRunMeta(&TFRunMetadata{RunID: "run-1", Organization: "zapier", Workspace: "a-ws", MergeRequestProjectNameWithNamespace: "zapier/tfbuddy", MergeRequestIID: 101}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := stream.MarkContinuedCommentsPosted("run-1")
+	assert.NoError(t, err)
+	assert.True(t, first)
+
+	// repeated status events don't post the continued comments again
+	first, err = stream.MarkContinuedCommentsPosted("run-1")
+	assert.NoError(t, err)
+	assert.False(t, first)
+
+	_, err = stream.MarkContinuedCommentsPosted("run-unknown")
+	assert.Error(t, err)
+}
@@ -2,8 +2,12 @@ package runstream
 
 import (
 	"encoding/json"
-	"github.com/nats-io/nats.go"
+	"errors"
 	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
 )
 
 // ensure type complies with interface
@@ -21,7 +25,7 @@ type TFRunMetadata struct {
 	// options include:
 	// "merge_request" - for runs started via MR push or comment
 	// "merge" - for runs started when MR is merged (apply after merge repos) (NOT IMPLEMENTED)
-	// "slack" - for runs started via ChatOps (NOT IMPLEMENTED)
+	// "slack" - for runs started via ChatOps
 	Source string
 
 	// Action is the triggered action (i.e. plan / apply)
@@ -35,6 +39,8 @@ type TFRunMetadata struct {
 
 	// MergeRequestIID is the Gitlab Merge Request IID for which the Run has been triggered
 	MergeRequestIID int
+	// MergeRequestWebURL is the link to the Merge Request on the VCS
+	MergeRequestWebURL string
 
 	// DiscussionID is the MergeRequest discussion thread where status updates should be written (optional)
 	DiscussionID string
@@ -42,6 +48,37 @@ type TFRunMetadata struct {
 	RootNoteID int64
 
 	VcsProvider string
+
+	// PlanFormat is how the plan output is rendered in MR comments (i.e. markdown / diff)
+	PlanFormat string
+
+	// TemplateRefs reference the project's custom comment templates stored with PutTemplate, keyed by template name
+	// (optional)
+	TemplateRefs map[string]string
+
+	// ProtectedResources may only be destroyed or replaced by an apply with AllowDestroy (optional)
+	ProtectedResources *terraform_plan.ProtectedResources
+	// AllowDestroy is set when the apply was triggered with `tfc apply --allow-destroy`
+	AllowDestroy bool
+
+	// Policies are the project's Rego policies, keyed by file path (optional)
+	Policies map[string]string
+	// ConfirmApply is set for apply runs that wait for TF Buddy to check the plan before they are confirmed
+	ConfirmApply bool
+
+	// CostThreshold is the increase of the MR's total monthly cost estimate above which applies need approval from a
+	// cost approver (optional)
+	CostThreshold float64
+	// Notifications are the events of the workspace sent to notification targets (optional)
+	Notifications []*NotificationRule
+
+	// SlackChannel & SlackThreadTS are the Slack thread run updates are posted to, for runs triggered from Slack
+	SlackChannel  string
+	SlackThreadTS string
+
+	// ContinuedCommentsPosted is set once the continued comments of the plan were posted, so they are not posted again
+	// for repeated status events
+	ContinuedCommentsPosted bool
 }
 
 func (r *TFRunMetadata) GetAction() string {
@@ -50,6 +87,9 @@ func (r *TFRunMetadata) GetAction() string {
 func (r *TFRunMetadata) GetMRInternalID() int {
 	return r.MergeRequestIID
 }
+func (r *TFRunMetadata) GetMRWebURL() string {
+	return r.MergeRequestWebURL
+}
 func (r *TFRunMetadata) GetRootNoteID() int64 {
 	return r.RootNoteID
 }
@@ -74,13 +114,55 @@ func (r *TFRunMetadata) GetOrganization() string {
 func (r *TFRunMetadata) GetVcsProvider() string {
 	return r.VcsProvider
 }
+func (r *TFRunMetadata) GetPlanFormat() string {
+	return r.PlanFormat
+}
+func (r *TFRunMetadata) GetTemplateRefs() map[string]string {
+	return r.TemplateRefs
+}
+func (r *TFRunMetadata) GetProtectedResources() *terraform_plan.ProtectedResources {
+	return r.ProtectedResources
+}
+func (r *TFRunMetadata) GetAllowDestroy() bool {
+	return r.AllowDestroy
+}
+func (r *TFRunMetadata) GetPolicies() map[string]string {
+	return r.Policies
+}
+func (r *TFRunMetadata) GetConfirmApply() bool {
+	return r.ConfirmApply
+}
+func (r *TFRunMetadata) GetCostThreshold() float64 {
+	return r.CostThreshold
+}
+func (r *TFRunMetadata) GetNotifications() []*NotificationRule {
+	return r.Notifications
+}
+func (r *TFRunMetadata) GetSlackChannel() string {
+	return r.SlackChannel
+}
+func (r *TFRunMetadata) GetSlackThreadTS() string {
+	return r.SlackThreadTS
+}
+
+// IsProtectedApply returns true for apply runs that are discarded if they destroy protected resources.
+func IsProtectedApply(rmd RunMetadata) bool {
+	return rmd.GetAction() == "apply" && !rmd.GetProtectedResources().IsEmpty() && !rmd.GetAllowDestroy()
+}
+
 func (s *Stream) AddRunMeta(rmd RunMetadata) error {
 	b, err := encodeTFRunMetadata(rmd)
 	if err != nil {
 		return err
 	}
-	_, err = s.metadataKV.Create(rmd.GetRunID(), b)
-	return err
+	if _, err = s.metadataKV.Create(rmd.GetRunID(), b); err != nil {
+		return err
+	}
+	// runs are pending once created, the later statuses are recorded as the run events are published
+	if err := s.recordRunStatus(rmd, "pending", nil); err != nil {
+		log.Error().Err(err).Str("runID", rmd.GetRunID()).Msg("could not record run status")
+	}
+	return s.indexRun(rmd)
 }
 
 func (s *Stream) GetRunMeta(runID string) (RunMetadata, error) {
@@ -91,6 +173,35 @@ func (s *Stream) GetRunMeta(runID string) (RunMetadata, error) {
 	return decodeTFRunMetadata(entry.Value())
 }
 
+// MarkContinuedCommentsPosted records that the continued comments of a run's plan are posted. It returns false if they
+// already were, e.g. for a repeated status event.
+func (s *Stream) MarkContinuedCommentsPosted(runID string) (bool, error) {
+	for {
+		entry, err := s.metadataKV.Get(runID)
+		if err != nil {
+			return false, err
+		}
+		rmd := &TFRunMetadata{}
+		if err := json.Unmarshal(entry.Value(), rmd); err != nil {
+			return false, err
+		}
+		if rmd.ContinuedCommentsPosted {
+			return false, nil
+		}
+		rmd.ContinuedCommentsPosted = true
+		b, err := encodeTFRunMetadata(rmd)
+		if err != nil {
+			return false, err
+		}
+		_, err = s.metadataKV.Update(runID, b, entry.Revision())
+		if errors.Is(err, nats.ErrKeyExists) {
+			// updated by another worker meanwhile, check again
+			continue
+		}
+		return err == nil, err
+	}
+}
+
 func encodeTFRunMetadata(run RunMetadata) ([]byte, error) {
 	return json.Marshal(run)
 }
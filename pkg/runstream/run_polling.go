@@ -153,6 +153,34 @@ func (s *Stream) startPollingTaskDispatcher() {
 	}()
 }
 
+// ListPollingTasks returns the polling tasks of the runs that are still in progress.
+func (s *Stream) ListPollingTasks() ([]RunPollingTask, error) {
+	keys, err := s.pollingKV.Keys()
+	if err == nats.ErrNoKeysFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tasks []RunPollingTask
+	for _, key := range keys {
+		entry, err := s.pollingKV.Get(key)
+		if err == nats.ErrKeyNotFound {
+			// the task has completed in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		task, err := s.decodeTFRunPollingTaskKVEntry(entry)
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
 const pollingQueueName = "polling"
 
 func (s *Stream) SubscribeTFRunPollingTasks(cb func(task RunPollingTask) bool) (closer func(), err error) {
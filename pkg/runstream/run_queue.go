@@ -0,0 +1,406 @@
+package runstream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog/log"
+)
+
+const RunQueueKvBucket = "TFC_RUN_QUEUE"
+
+// runSlotsKey is the KV entry holding the run slots, it is updated with compare-and-swap so every TF Buddy pod sees the
+// same capacity
+const runSlotsKey = "slots"
+
+// queuedRunKeyPrefix prefixes the KV entries of the queued runs, each queued run is a separate entry so queueing a run
+// doesn't compete with the slot updates
+const queuedRunKeyPrefix = "queued."
+
+// maxRunQueueUpdateAttempts is the number of times a slots update is retried when another pod updated them first
+const maxRunQueueUpdateAttempts = 10
+
+const (
+	// RunLimitEnvName is the maximum number of TFC runs TF Buddy runs at once, across all organizations.
+	RunLimitEnvName = "TFBUDDY_TFC_RUN_LIMIT"
+	// OrgRunLimitsEnvName are the maximum numbers of TFC runs per organization, e.g. `zapier=10,zapier-test=2`.
+	OrgRunLimitsEnvName = "TFBUDDY_TFC_ORG_RUN_LIMITS"
+	// AgentPoolRunLimitsEnvName are the maximum numbers of TFC runs per agent pool ID, e.g. `apool-123abc=5`.
+	AgentPoolRunLimitsEnvName = "TFBUDDY_TFC_AGENT_POOL_RUN_LIMITS"
+)
+
+const (
+	// runSlotTTL frees the slots of runs whose final status was never received
+	runSlotTTL = time.Hour * 6
+	// dispatchTTL frees the slots reserved for queued runs that were dispatched but never created
+	dispatchTTL = time.Minute * 10
+)
+
+// RunSlot is a TFC run counted against the concurrency limits.
+type RunSlot struct {
+	// ID is the ID of the queued run the slot was reserved for
+	ID string
+	// RunID is the TFC run ID, once the run was created
+	RunID        string
+	Organization string
+	AgentPool    string
+	StartedAt    time.Time
+}
+
+// QueuedRun is a trigger waiting for capacity under the concurrency limits.
+type QueuedRun struct {
+	ID           string
+	Organization string
+	Workspace    string
+	// AgentPool is the agent pool ID of the workspace, empty for workspaces that don't run on agents
+	AgentPool string
+	// Trigger is the encoded trigger config, the run is triggered again with it once dispatched
+	Trigger  json.RawMessage
+	QueuedAt time.Time
+}
+
+// RunQueue is the runs counted against the concurrency limits and the runs waiting for capacity, oldest first.
+type RunQueue struct {
+	Slots  []*RunSlot
+	Queued []*QueuedRun
+}
+
+// RunLimits are the maximum numbers of TFC runs TF Buddy runs at once, 0 is unlimited.
+type RunLimits struct {
+	Global     int
+	Orgs       map[string]int
+	AgentPools map[string]int
+}
+
+// LoadRunLimits reads the concurrency limits from the environment.
+func LoadRunLimits() *RunLimits {
+	global, _ := strconv.Atoi(os.Getenv(RunLimitEnvName))
+	return &RunLimits{
+		Global:     global,
+		Orgs:       parseRunLimits(os.Getenv(OrgRunLimitsEnvName)),
+		AgentPools: parseRunLimits(os.Getenv(AgentPoolRunLimitsEnvName)),
+	}
+}
+
+func parseRunLimits(s string) map[string]int {
+	limits := map[string]int{}
+	for _, p := range strings.Split(s, ",") {
+		name, limit, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			log.Warn().Str("limit", p).Msg("ignoring invalid TFC run limit")
+			continue
+		}
+		limits[name] = n
+	}
+	return limits
+}
+
+// Enabled returns true if any concurrency limit is configured.
+func (l *RunLimits) Enabled() bool {
+	return l.Global > 0 || len(l.Orgs) > 0 || len(l.AgentPools) > 0
+}
+
+// fits returns true if one more run of the org & agent pool stays within the limits.
+func (l *RunLimits) fits(slots []*RunSlot, org, pool string) bool {
+	total, inOrg, inPool := 0, 0, 0
+	for _, slot := range slots {
+		total++
+		if slot.Organization == org {
+			inOrg++
+		}
+		if pool != "" && slot.AgentPool == pool {
+			inPool++
+		}
+	}
+	if l.Global > 0 && total >= l.Global {
+		return false
+	}
+	if limit := l.Orgs[org]; limit > 0 && inOrg >= limit {
+		return false
+	}
+	if limit := l.AgentPools[pool]; pool != "" && limit > 0 && inPool >= limit {
+		return false
+	}
+	return true
+}
+
+// competes returns true if two runs are counted against a common limit, a run only overtakes the queued runs it
+// doesn't compete with.
+func (l *RunLimits) competes(a, b *QueuedRun) bool {
+	if l.Global > 0 {
+		return true
+	}
+	if a.Organization == b.Organization && l.Orgs[a.Organization] > 0 {
+		return true
+	}
+	return a.AgentPool != "" && a.AgentPool == b.AgentPool && l.AgentPools[a.AgentPool] > 0
+}
+
+// AcquireRunSlot reserves a slot for a run under the concurrency limits. position is 0 if the run can be created
+// right away, the slot is then reserved with the queued run ID until AssignRunSlot records the TFC run ID. Otherwise
+// the run is queued and position is its place in the queue of the runs it competes with, starting at 1. Queued runs
+// are dispatched by DispatchQueuedRuns.
+func (s *Stream) AcquireRunSlot(qr *QueuedRun) (position int, err error) {
+	if !s.limits.Enabled() {
+		return 0, nil
+	}
+	queued, err := s.listQueuedRuns()
+	if err != nil {
+		return 0, err
+	}
+	position = 1
+	for _, ahead := range queued {
+		if s.limits.competes(ahead, qr) {
+			position++
+		}
+	}
+	if position == 1 {
+		reserved := false
+		err = s.updateRunSlots(func(slots []*RunSlot) []*RunSlot {
+			reserved = s.limits.fits(slots, qr.Organization, qr.AgentPool)
+			if reserved {
+				slots = append(slots, newRunSlot(qr))
+			}
+			return slots
+		})
+		if err != nil || reserved {
+			return 0, err
+		}
+	}
+
+	qr.QueuedAt = time.Now()
+	b, err := json.Marshal(qr)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.queueKV.Create(queuedRunKeyPrefix+qr.ID, b); err != nil {
+		return 0, err
+	}
+	return position, nil
+}
+
+// AssignRunSlot records the TFC run ID of a reserved slot, the slot is freed once the run finishes.
+func (s *Stream) AssignRunSlot(id, runID string) error {
+	if !s.limits.Enabled() {
+		return nil
+	}
+	return s.updateRunSlots(func(slots []*RunSlot) []*RunSlot {
+		for _, slot := range slots {
+			if slot.ID == id {
+				slot.RunID = runID
+			}
+		}
+		return slots
+	})
+}
+
+// ReleaseRunSlot frees the slot of a finished run, by TFC run ID, or by queued run ID for runs that could not be
+// created. The freed capacity goes to the queued runs on the next DispatchQueuedRuns.
+func (s *Stream) ReleaseRunSlot(id string) error {
+	if !s.limits.Enabled() {
+		return nil
+	}
+	return s.updateRunSlots(func(slots []*RunSlot) []*RunSlot {
+		active := []*RunSlot{}
+		for _, slot := range slots {
+			if slot.RunID != id && slot.ID != id {
+				active = append(active, slot)
+			}
+		}
+		return active
+	})
+}
+
+// DispatchQueuedRuns removes the queued runs that fit within the limits from the queue, in order, and reserves their
+// slots. The caller must trigger the dispatched runs, and release the slots of the runs it could not create.
+func (s *Stream) DispatchQueuedRuns() (dispatched []*QueuedRun, err error) {
+	if !s.limits.Enabled() {
+		return nil, nil
+	}
+	queued, err := s.listQueuedRuns()
+	if err != nil || len(queued) == 0 {
+		return nil, err
+	}
+	err = s.updateRunSlots(func(slots []*RunSlot) []*RunSlot {
+		dispatched = nil
+		ahead := []*QueuedRun{}
+		for _, qr := range queued {
+			if hasRunSlot(slots, qr.ID) {
+				// dispatched by another pod, which is removing it from the queue
+				continue
+			}
+			if s.limits.fits(slots, qr.Organization, qr.AgentPool) && !blockedByEarlier(s.limits, ahead, qr) {
+				slots = append(slots, newRunSlot(qr))
+				dispatched = append(dispatched, qr)
+				continue
+			}
+			ahead = append(ahead, qr)
+		}
+		return slots
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, qr := range dispatched {
+		if err := s.queueKV.Delete(queuedRunKeyPrefix + qr.ID); err != nil {
+			log.Error().Err(err).Str("id", qr.ID).Msg("could not remove dispatched run from the run queue")
+		}
+	}
+	return dispatched, nil
+}
+
+// GetRunQueue returns the runs counted against the concurrency limits and the queued runs.
+func (s *Stream) GetRunQueue() (*RunQueue, error) {
+	slots, _, err := s.getRunSlots()
+	if err != nil {
+		return nil, err
+	}
+	queued, err := s.listQueuedRuns()
+	if err != nil {
+		return nil, err
+	}
+	return &RunQueue{Slots: slots, Queued: queued}, nil
+}
+
+// blockedByEarlier returns true if a run competes with a run still queued ahead of it, runs are dispatched in order.
+func blockedByEarlier(limits *RunLimits, ahead []*QueuedRun, qr *QueuedRun) bool {
+	for _, queued := range ahead {
+		if limits.competes(queued, qr) {
+			return true
+		}
+	}
+	return false
+}
+
+func newRunSlot(qr *QueuedRun) *RunSlot {
+	return &RunSlot{
+		ID:           qr.ID,
+		Organization: qr.Organization,
+		AgentPool:    qr.AgentPool,
+		StartedAt:    time.Now(),
+	}
+}
+
+func hasRunSlot(slots []*RunSlot, id string) bool {
+	for _, slot := range slots {
+		if slot.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// updateRunSlots applies fn to the run slots with compare-and-swap, retrying if another pod updated them meanwhile.
+// Expired slots are freed first.
+func (s *Stream) updateRunSlots(fn func(slots []*RunSlot) []*RunSlot) error {
+	var err error
+	for attempt := 0; attempt < maxRunQueueUpdateAttempts; attempt++ {
+		var slots []*RunSlot
+		var revision uint64
+		slots, revision, err = s.getRunSlots()
+		if err != nil {
+			return err
+		}
+		b, encErr := json.Marshal(fn(expireRunSlots(slots, time.Now())))
+		if encErr != nil {
+			return encErr
+		}
+		if revision == 0 {
+			_, err = s.queueKV.Create(runSlotsKey, b)
+		} else {
+			_, err = s.queueKV.Update(runSlotsKey, b, revision)
+		}
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, nats.ErrKeyExists) {
+			return err
+		}
+		// updated by another pod, retry with its slots
+	}
+	return fmt.Errorf("could not update TFC run slots: %w", err)
+}
+
+func expireRunSlots(slots []*RunSlot, now time.Time) []*RunSlot {
+	active := []*RunSlot{}
+	for _, slot := range slots {
+		ttl := runSlotTTL
+		if slot.RunID == "" {
+			ttl = dispatchTTL
+		}
+		if now.Sub(slot.StartedAt) > ttl {
+			log.Warn().Str("runID", slot.RunID).Str("id", slot.ID).Msg("freeing expired TFC run slot")
+			continue
+		}
+		active = append(active, slot)
+	}
+	return active
+}
+
+func (s *Stream) getRunSlots() ([]*RunSlot, uint64, error) {
+	entry, err := s.queueKV.Get(runSlotsKey)
+	if err == nats.ErrKeyNotFound {
+		return []*RunSlot{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	slots := []*RunSlot{}
+	if err := json.Unmarshal(entry.Value(), &slots); err != nil {
+		return nil, 0, err
+	}
+	return slots, entry.Revision(), nil
+}
+
+// listQueuedRuns returns the queued runs, in the order they were queued.
+func (s *Stream) listQueuedRuns() ([]*QueuedRun, error) {
+	w, err := s.queueKV.Watch(queuedRunKeyPrefix+"*", nats.IgnoreDeletes())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := w.Stop(); err != nil {
+			log.Error().Err(err).Msg("could not stop run queue watcher")
+		}
+	}()
+	// the current entries are received in the order they were created, followed by nil
+	queued := []*QueuedRun{}
+	for entry := range w.Updates() {
+		if entry == nil {
+			break
+		}
+		qr := &QueuedRun{}
+		if err := json.Unmarshal(entry.Value(), qr); err != nil {
+			return nil, err
+		}
+		queued = append(queued, qr)
+	}
+	return queued, nil
+}
+
+func configureRunQueueKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      RunQueueKvBucket,
+		Description: "KV store for the TFC runs counted against the concurrency limits and the queued runs",
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+
+	return js.CreateKeyValue(cfg)
+}
@@ -0,0 +1,95 @@
+package runstream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRunLimits(t *testing.T) {
+	t.Setenv(RunLimitEnvName, "")
+	t.Setenv(OrgRunLimitsEnvName, "zapier=10, zapier-test=2,broken")
+	t.Setenv(AgentPoolRunLimitsEnvName, "apool-123abc=5")
+
+	limits := LoadRunLimits()
+	assert.True(t, limits.Enabled())
+	assert.Equal(t, 0, limits.Global)
+	assert.Equal(t, map[string]int{"zapier": 10, "zapier-test": 2}, limits.Orgs)
+	assert.Equal(t, map[string]int{"apool-123abc": 5}, limits.AgentPools)
+
+	t.Setenv(OrgRunLimitsEnvName, "")
+	t.Setenv(AgentPoolRunLimitsEnvName, "")
+	assert.False(t, LoadRunLimits().Enabled())
+}
+
+func TestStream_RunQueue(t *testing.T) {
+	s, cleanup := testRunIndexStream(t)
+	defer cleanup()
+	s.limits = &RunLimits{
+		Orgs:       map[string]int{"zapier": 1},
+		AgentPools: map[string]int{"apool-1": 1},
+	}
+
+	acquire := func(id, org, pool string) int {
+		position, err := s.AcquireRunSlot(&QueuedRun{ID: id, Organization: org, Workspace: "ws-" + id, AgentPool: pool})
+		assert.NoError(t, err)
+		return position
+	}
+	ids := func(runs []*QueuedRun) []string {
+		result := []string{}
+		for _, qr := range runs {
+			result = append(result, qr.ID)
+		}
+		return result
+	}
+
+	assert.Equal(t, 0, acquire("a", "zapier", ""))
+	assert.NoError(t, s.AssignRunSlot("a", "run-a"))
+	assert.Equal(t, 1, acquire("b", "zapier", ""))
+	assert.Equal(t, 0, acquire("c", "other", ""), "runs of other orgs aren't limited")
+	assert.Equal(t, 2, acquire("d", "zapier", ""))
+	assert.Equal(t, 0, acquire("e", "other", "apool-1"))
+	assert.Equal(t, 1, acquire("f", "other", "apool-1"), "runs on the same agent pool are limited")
+
+	assert.NoError(t, s.ReleaseRunSlot("run-a"))
+	dispatched, err := s.DispatchQueuedRuns()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(dispatched))
+
+	q, err := s.GetRunQueue()
+	assert.NoError(t, err)
+	assert.Len(t, q.Slots, 3)
+	assert.Equal(t, []string{"d", "f"}, ids(q.Queued))
+	// each queued run is a separate entry, dispatched runs are removed
+	_, err = s.queueKV.Get(queuedRunKeyPrefix + "d")
+	assert.NoError(t, err)
+	_, err = s.queueKV.Get(queuedRunKeyPrefix + "b")
+	assert.ErrorIs(t, err, nats.ErrKeyNotFound)
+
+	dispatched, err = s.DispatchQueuedRuns()
+	assert.NoError(t, err)
+	assert.Empty(t, dispatched, "nothing is dispatched without capacity")
+
+	// b could not be created, its slot goes to the next run of the org
+	assert.NoError(t, s.ReleaseRunSlot("b"))
+	dispatched, err = s.DispatchQueuedRuns()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d"}, ids(dispatched))
+}
+
+func Test_expireRunSlots(t *testing.T) {
+	now := time.Now()
+	slots := expireRunSlots([]*RunSlot{
+		{ID: "running", RunID: "run-1", StartedAt: now.Add(-time.Hour)},
+		{ID: "lost", RunID: "run-2", StartedAt: now.Add(-runSlotTTL - time.Minute)},
+		{ID: "dispatching", StartedAt: now.Add(-time.Minute)},
+		{ID: "never-created", StartedAt: now.Add(-dispatchTTL - time.Minute)},
+	}, now)
+
+	if assert.Len(t, slots, 2) {
+		assert.Equal(t, "running", slots[0].ID)
+		assert.Equal(t, "dispatching", slots[1].ID)
+	}
+}
@@ -0,0 +1,208 @@
+package runstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+const RunTimelineKvBucket = "RUN_TIMELINE"
+
+// maxTimelineUpdateAttempts is the number of times a timeline update is retried when another worker updated it first
+const maxTimelineUpdateAttempts = 5
+
+var (
+	timelineLabels = []string{
+		"organization",
+		"workspace",
+		"action",
+	}
+	runQueueWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tfbuddy_run_queue_wait_seconds",
+		Help:    "Time runs waited in the TFC queue, from their creation until they started planning",
+		Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600},
+	}, timelineLabels)
+	runPlanDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tfbuddy_run_plan_duration_seconds",
+		Help:    "Time runs spent planning",
+		Buckets: []float64{5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	}, timelineLabels)
+)
+
+func init() {
+	r := prometheus.DefaultRegisterer
+	r.MustRegister(runQueueWaitSeconds)
+	r.MustRegister(runPlanDurationSeconds)
+}
+
+// StatusTransition is a run status with the time the run entered it.
+type StatusTransition struct {
+	Status string
+	At     time.Time
+}
+
+// TFRunTimeline is the history of the statuses of a run, ordered by the time the run entered them.
+type TFRunTimeline struct {
+	RunID       string
+	Transitions []*StatusTransition
+
+	// Revision is the NATS KV entry revision
+	Revision uint64 `json:"-"`
+}
+
+// LastStatus returns the latest status of the run, or an empty string if no status was recorded yet.
+func (t *TFRunTimeline) LastStatus() string {
+	if len(t.Transitions) == 0 {
+		return ""
+	}
+	return t.Transitions[len(t.Transitions)-1].Status
+}
+
+// QueueWait returns the time the run waited from its creation until it started planning. ok is false if the run
+// hasn't started planning yet.
+func (t *TFRunTimeline) QueueWait() (d time.Duration, ok bool) {
+	pending, planning := t.indexOf("pending"), t.indexOf("planning")
+	if pending < 0 || planning < 0 || planning < pending {
+		return 0, false
+	}
+	return t.Transitions[planning].At.Sub(t.Transitions[pending].At), true
+}
+
+// PlanDuration returns the time the run spent planning. ok is false if the run hasn't finished planning yet.
+func (t *TFRunTimeline) PlanDuration() (d time.Duration, ok bool) {
+	planning := t.indexOf("planning")
+	if planning < 0 || planning == len(t.Transitions)-1 {
+		return 0, false
+	}
+	return t.Transitions[planning+1].At.Sub(t.Transitions[planning].At), true
+}
+
+// merge adds the statuses to the timeline, with the time the run entered them, and sorts the transitions by time. It
+// returns false if the timeline is unchanged.
+func (t *TFRunTimeline) merge(status string, timestamps map[string]time.Time) bool {
+	changed := false
+	recorded := map[string]*StatusTransition{}
+	for _, tr := range t.Transitions {
+		recorded[tr.Status] = tr
+	}
+	for st, at := range timestamps {
+		if tr, ok := recorded[st]; ok {
+			if !tr.At.Equal(at) {
+				tr.At = at
+				changed = true
+			}
+			continue
+		}
+		recorded[st] = &StatusTransition{Status: st, At: at}
+		t.Transitions = append(t.Transitions, recorded[st])
+		changed = true
+	}
+	if _, ok := recorded[status]; !ok {
+		t.Transitions = append(t.Transitions, &StatusTransition{Status: status, At: time.Now()})
+		changed = true
+	}
+	sort.SliceStable(t.Transitions, func(i, j int) bool {
+		return t.Transitions[i].At.Before(t.Transitions[j].At)
+	})
+	return changed
+}
+
+// indexOf returns the index of the first transition to status, or -1.
+func (t *TFRunTimeline) indexOf(status string) int {
+	for i, tr := range t.Transitions {
+		if tr.Status == status {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetRunTimeline reads the status timeline of a run. If no status was recorded yet, an empty timeline is returned.
+func (s *Stream) GetRunTimeline(runID string) (*TFRunTimeline, error) {
+	entry, err := s.timelineKV.Get(runID)
+	if err == nats.ErrKeyNotFound {
+		return &TFRunTimeline{RunID: runID, Transitions: []*StatusTransition{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	timeline := &TFRunTimeline{}
+	if err := json.Unmarshal(entry.Value(), timeline); err != nil {
+		return nil, err
+	}
+	timeline.Revision = entry.Revision()
+	return timeline, nil
+}
+
+// recordRunStatus adds the statuses TFC reports for a run to its timeline, at the time TFC reports the run entered
+// them, and keeps the transitions ordered by that time. The run notifications & polling tasks report the same statuses
+// out of order, so a status is only recorded once; a time reported by TFC replaces the one recorded for it. status is
+// recorded at the current time if TFC reports no time for it. The queue wait and plan duration metrics are observed
+// once the transitions they measure are recorded.
+func (s *Stream) recordRunStatus(rmd RunMetadata, status string, timestamps map[string]time.Time) error {
+	var err error
+	for attempt := 0; attempt < maxTimelineUpdateAttempts; attempt++ {
+		var timeline *TFRunTimeline
+		timeline, err = s.GetRunTimeline(rmd.GetRunID())
+		if err != nil {
+			return err
+		}
+		_, hadQueueWait := timeline.QueueWait()
+		_, hadPlanDuration := timeline.PlanDuration()
+		if !timeline.merge(status, timestamps) {
+			return nil
+		}
+
+		b, encErr := json.Marshal(timeline)
+		if encErr != nil {
+			return encErr
+		}
+		if timeline.Revision == 0 {
+			_, err = s.timelineKV.Create(rmd.GetRunID(), b)
+		} else {
+			_, err = s.timelineKV.Update(rmd.GetRunID(), b, timeline.Revision)
+		}
+		if err != nil {
+			// another worker recorded a status first, read the timeline again
+			log.Debug().Err(err).Str("runID", rmd.GetRunID()).Msg("could not update run timeline, retrying")
+			continue
+		}
+
+		labels := prometheus.Labels{
+			"organization": rmd.GetOrganization(),
+			"workspace":    rmd.GetWorkspace(),
+			"action":       rmd.GetAction(),
+		}
+		if d, ok := timeline.QueueWait(); ok && !hadQueueWait {
+			runQueueWaitSeconds.With(labels).Observe(d.Seconds())
+		}
+		if d, ok := timeline.PlanDuration(); ok && !hadPlanDuration {
+			runPlanDurationSeconds.With(labels).Observe(d.Seconds())
+		}
+		return nil
+	}
+	return fmt.Errorf("could not update run timeline: %w", err)
+}
+
+func configureRunTimelineKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      RunTimelineKvBucket,
+		Description: "KV store for the status timeline of runs",
+		TTL:         time.Hour * 720,
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+
+	return js.CreateKeyValue(cfg)
+}
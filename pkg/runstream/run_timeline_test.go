@@ -0,0 +1,73 @@
+package runstream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream_RunTimeline(t *testing.T) {
+	stream, closer := testRunIndexStream(t)
+	defer closer()
+
+	timeline, err := stream.GetRunTimeline("run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, timeline.Transitions)
+
+	rmd := &TFRunMetadata{RunID: "run-1", Organization: "zapier", Workspace: "a-ws", Action: "plan"}
+	if err := stream.AddRunMeta(rmd); err != nil {
+		t.Fatal(err)
+	}
+	timeline, err = stream.GetRunTimeline("run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, timeline.Transitions, 1) {
+		assert.Equal(t, "pending", timeline.Transitions[0].Status)
+	}
+	_, ok := timeline.QueueWait()
+	assert.False(t, ok)
+
+	// TFC reports the run was created before TF Buddy recorded its metadata
+	created := timeline.Transitions[0].At.Add(-5 * time.Second)
+	for _, re := range []*TFRunEvent{
+		{NewStatus: "planning", StatusTimestamps: map[string]time.Time{
+			"pending":  created,
+			"planning": created.Add(10 * time.Second),
+		}},
+		// the polling task reports the status the notification already reported
+		{NewStatus: "planning", StatusTimestamps: map[string]time.Time{"planning": created.Add(10 * time.Second)}},
+		{NewStatus: "planned_and_finished", StatusTimestamps: map[string]time.Time{
+			"planning":             created.Add(10 * time.Second),
+			"planned_and_finished": created.Add(70 * time.Second),
+		}},
+		// a delayed notification is ordered by the time TFC reports
+		{NewStatus: "plan_queued", StatusTimestamps: map[string]time.Time{"plan_queued": created.Add(2 * time.Second)}},
+	} {
+		if err := stream.recordRunStatus(rmd, re.NewStatus, re.StatusTimestamps); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeline, err = stream.GetRunTimeline("run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{}
+	for _, tr := range timeline.Transitions {
+		statuses = append(statuses, tr.Status)
+	}
+	assert.Equal(t, []string{"pending", "plan_queued", "planning", "planned_and_finished"}, statuses)
+	assert.True(t, created.Equal(timeline.Transitions[0].At))
+	assert.Equal(t, "planned_and_finished", timeline.LastStatus())
+
+	wait, ok := timeline.QueueWait()
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, wait)
+	plan, ok := timeline.PlanDuration()
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, plan)
+}
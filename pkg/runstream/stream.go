@@ -15,19 +15,41 @@ type Stream struct {
 	js         nats.JetStreamContext
 	metadataKV nats.KeyValue
 	pollingKV  nats.KeyValue
+	summaryKV  nats.KeyValue
+	indexKV    nats.KeyValue
+	timelineKV nats.KeyValue
+	latestKV   nats.KeyValue
+	queueKV    nats.KeyValue
+	limits     *RunLimits
+
+	templatesKV nats.KeyValue
 }
 
 func NewStream(js nats.JetStreamContext) StreamClient {
 
 	configureTFRunEventsStream(js)
 	configureTFRunPollingTaskStream(js)
+	configureNotificationsStream(js)
 	kv, _ := configureTFRunMetadataKVStore(js)
 	pollingKV, _ := configureRunPollingKVStore(js)
+	summaryKV, _ := configureMRSummaryKVStore(js)
+	indexKV, _ := configureRunIndexKVStore(js)
+	timelineKV, _ := configureRunTimelineKVStore(js)
+	latestKV, _ := configureLatestRunsKVStore(js)
+	queueKV, _ := configureRunQueueKVStore(js)
+	templatesKV, _ := configureTemplatesKVStore(js)
 
 	s := &Stream{
 		js,
 		kv,
 		pollingKV,
+		summaryKV,
+		indexKV,
+		timelineKV,
+		latestKV,
+		queueKV,
+		LoadRunLimits(),
+		templatesKV,
 	}
 
 	s.startPollingTaskDispatcher()
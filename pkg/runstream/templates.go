@@ -0,0 +1,50 @@
+package runstream
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const TemplatesKvBucket = "COMMENT_TEMPLATES"
+
+// PutTemplate stores the text of a project's comment template and returns its reference. Templates are stored by
+// content, so the run metadata only holds references and identical templates are stored once.
+func (s *Stream) PutTemplate(text string) (ref string, err error) {
+	sum := sha256.Sum256([]byte(text))
+	ref = hex.EncodeToString(sum[:])
+	// put even if the template exists, to extend its TTL for the new run
+	if _, err := s.templatesKV.Put(ref, []byte(text)); err != nil {
+		return "", err
+	}
+	return ref, nil
+}
+
+// GetTemplate returns the text of a stored comment template.
+func (s *Stream) GetTemplate(ref string) (string, error) {
+	entry, err := s.templatesKV.Get(ref)
+	if err != nil {
+		return "", err
+	}
+	return string(entry.Value()), nil
+}
+
+func configureTemplatesKVStore(js nats.JetStreamContext) (nats.KeyValue, error) {
+	cfg := &nats.KeyValueConfig{
+		Bucket:      TemplatesKvBucket,
+		Description: "KV store for the comment templates of projects, referenced by the run metadata",
+		TTL:         time.Hour * 720,
+		Storage:     nats.FileStorage,
+		Replicas:    1,
+	}
+
+	for store := range js.KeyValueStores() {
+		if store.Bucket() == cfg.Bucket {
+			return js.KeyValue(cfg.Bucket)
+		}
+	}
+
+	return js.CreateKeyValue(cfg)
+}
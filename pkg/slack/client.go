@@ -0,0 +1,71 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultAPIURL = "https://slack.com/api"
+
+// Client posts messages with the Slack Web API.
+type Client struct {
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+func NewClient(token string) *Client {
+	return &Client{
+		apiURL: defaultAPIURL,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type postMessageRequest struct {
+	Channel  string `json:"channel"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+	Text     string `json:"text"`
+}
+
+type postMessageResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	TS    string `json:"ts"`
+}
+
+// PostMessage posts a message to a channel, or to a thread if threadTS is set. The timestamp of the message is
+// returned, it identifies the thread of replies to the message.
+func (c *Client) PostMessage(channel, threadTS, text string) (ts string, err error) {
+	b, err := json.Marshal(&postMessageRequest{Channel: channel, ThreadTS: threadTS, Text: text})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.apiURL+"/chat.postMessage", bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	msg := &postMessageResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(msg); err != nil {
+		return "", err
+	}
+	// the Web API reports errors in the body of 200 responses
+	if !msg.OK {
+		return "", fmt.Errorf("could not post Slack message: %s", msg.Error)
+	}
+	return msg.TS, nil
+}
@@ -0,0 +1,317 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/allow_list"
+	"github.com/zapier/tfbuddy/pkg/comment_actions"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+	"github.com/zapier/tfbuddy/pkg/vcs"
+)
+
+const (
+	// SigningSecretEnvName is the signing secret of the Slack app, used to verify slash command requests.
+	SigningSecretEnvName = "TFBUDDY_SLACK_SIGNING_SECRET"
+	// BotTokenEnvName is the bot token of the Slack app, used to post run updates.
+	BotTokenEnvName = "TFBUDDY_SLACK_BOT_TOKEN"
+
+	// maxRequestAge is how old a signed request can be, older requests are refused to prevent replays
+	maxRequestAge = 5 * time.Minute
+
+	usage = "Usage: `/tfc plan|apply <project>!<MR> [-w <workspace>] [--allow-destroy]`, " +
+		"e.g. `/tfc plan zapier/tfbuddy!101 -w service-tfbuddy`. GitHub pull requests are written `<owner>/<repo>#<PR>`."
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid Slack request signature")
+	ErrUsage            = errors.New("could not parse Slack command")
+)
+
+type TriggerCreationFunc func(gl vcs.GitClient,
+	tfc tfc_api.ApiClient,
+	runstream runstream.StreamClient,
+	cfg tfc_trigger.TriggerConfig) tfc_trigger.Trigger
+
+// Command is a parsed `/tfc` slash command.
+type Command struct {
+	VcsProvider              string
+	ProjectNameWithNamespace string
+	MergeRequestIID          int
+	Opts                     *comment_actions.CommentOpts
+	UserID                   string
+	UserName                 string
+	ChannelID                string
+}
+
+// CommandHandler runs `/tfc plan` & `/tfc apply` slash commands against merge requests, with the same allow lists &
+// approval checks as MR comments. Only the Slack users & channels of the Slack allow lists may run commands. The outcome is posted in a thread of the channel the command was sent from.
+type CommandHandler struct {
+	vcs             map[string]vcs.GitClient
+	tfc             tfc_api.ApiClient
+	rs              runstream.StreamClient
+	slack           *Client
+	signingSecret   []byte
+	triggerCreation TriggerCreationFunc
+}
+
+func NewCommandHandler(gl, gh vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient) *CommandHandler {
+	return &CommandHandler{
+		vcs: map[string]vcs.GitClient{
+			"gitlab": gl,
+			"github": gh,
+		},
+		tfc:             tfc,
+		rs:              rs,
+		slack:           NewClient(os.Getenv(BotTokenEnvName)),
+		signingSecret:   []byte(os.Getenv(SigningSecretEnvName)),
+		triggerCreation: tfc_trigger.NewTFCTrigger,
+	}
+}
+
+// Enabled returns true if the Slack app is configured.
+func Enabled() bool {
+	return os.Getenv(SigningSecretEnvName) != "" && os.Getenv(BotTokenEnvName) != ""
+}
+
+type commandResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// Handler answers a slash command request. Slack expects an answer within 3 seconds, so the command is run in the
+// background and only usage errors are answered directly.
+func (h *CommandHandler) Handler(c echo.Context) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "could not read request")
+	}
+	if err := VerifySignature(h.signingSecret, c.Request().Header, body, time.Now()); err != nil {
+		log.Warn().Err(err).Msg("refused Slack command")
+		return c.String(http.StatusUnauthorized, err.Error())
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "could not parse request")
+	}
+
+	cmd, err := ParseCommand(form.Get("text"))
+	if err != nil {
+		return c.JSON(http.StatusOK, &commandResponse{ResponseType: "ephemeral", Text: usage})
+	}
+	cmd.UserID = form.Get("user_id")
+	cmd.UserName = form.Get("user_name")
+	cmd.ChannelID = form.Get("channel_id")
+
+	if !allow_list.IsSlackCommandAllowed(cmd.UserID, cmd.ChannelID) {
+		return c.JSON(http.StatusOK, &commandResponse{
+			ResponseType: "ephemeral",
+			Text:         ":no_entry: You are not allowed to run TF Buddy commands from this channel.",
+		})
+	}
+	if !isProjectAllowed(cmd) {
+		return c.JSON(http.StatusOK, &commandResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf(":no_entry: TF Buddy is not enabled for %s.", cmd.ProjectNameWithNamespace),
+		})
+	}
+
+	go h.processInBackground(cmd)
+	return c.JSON(http.StatusOK, &commandResponse{
+		ResponseType: "ephemeral",
+		Text:         fmt.Sprintf("Running `%s` for %s, updates will be posted in a thread.", cmd.Opts.Args.Command, cmd.mergeRequestRef()),
+	})
+}
+
+// VerifySignature checks the signature Slack computes over the request timestamp & body with the app signing secret.
+func VerifySignature(secret []byte, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sig := header.Get("X-Slack-Signature")
+	if ts == "" || sig == "" {
+		return ErrInvalidSignature
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if math.Abs(now.Sub(time.Unix(sec, 0)).Seconds()) > maxRequestAge.Seconds() {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(Sign(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Sign returns the Slack v0 signature of a request.
+func Sign(secret []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseCommand parses the text of a slash command, e.g. `plan zapier/tfbuddy!101 -w service-tfbuddy`. GitLab merge
+// requests are referenced as `<project>!<MR>` & GitHub pull requests as `<owner>/<repo>#<PR>`, the other words are
+// parsed like a `tfc` MR comment.
+func ParseCommand(text string) (*Command, error) {
+	cmd := &Command{}
+	words := []string{"tfc"}
+	for _, word := range strings.Fields(text) {
+		if cmd.ProjectNameWithNamespace == "" {
+			if provider, project, iid, ok := parseMergeRequestRef(word); ok {
+				cmd.VcsProvider, cmd.ProjectNameWithNamespace, cmd.MergeRequestIID = provider, project, iid
+				continue
+			}
+		}
+		words = append(words, word)
+	}
+	if cmd.ProjectNameWithNamespace == "" {
+		return nil, ErrUsage
+	}
+
+	opts, err := comment_actions.ParseCommentCommand(strings.Join(words, " "))
+	if err != nil {
+		return nil, ErrUsage
+	}
+	switch opts.Args.Command {
+	case "plan", "apply":
+	default:
+		return nil, ErrUsage
+	}
+	cmd.Opts = opts
+	return cmd, nil
+}
+
+func parseMergeRequestRef(word string) (provider, project string, iid int, ok bool) {
+	for _, ref := range []struct{ sep, provider string }{{"!", "gitlab"}, {"#", "github"}} {
+		i := strings.LastIndex(word, ref.sep)
+		if i <= 0 {
+			continue
+		}
+		iid, err := strconv.Atoi(word[i+1:])
+		if err != nil || iid <= 0 {
+			continue
+		}
+		return ref.provider, word[:i], iid, true
+	}
+	return "", "", 0, false
+}
+
+func isProjectAllowed(cmd *Command) bool {
+	if cmd.VcsProvider == "github" {
+		return allow_list.IsGithubRepoAllowed(cmd.ProjectNameWithNamespace)
+	}
+	return allow_list.IsGitlabProjectAllowed(cmd.ProjectNameWithNamespace)
+}
+
+func (cmd *Command) mergeRequestRef() string {
+	if cmd.VcsProvider == "github" {
+		return fmt.Sprintf("%s#%d", cmd.ProjectNameWithNamespace, cmd.MergeRequestIID)
+	}
+	return fmt.Sprintf("%s!%d", cmd.ProjectNameWithNamespace, cmd.MergeRequestIID)
+}
+
+// processInBackground processes a command, logging its error. A panic is recovered, so it doesn't crash the server.
+func (h *CommandHandler) processInBackground(cmd *Command) {
+	log := log.With().Str("project", cmd.ProjectNameWithNamespace).Int("mrIID", cmd.MergeRequestIID).Logger()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Interface("panic", r).Msg("panic while processing Slack command")
+		}
+	}()
+	if err := h.process(cmd); err != nil {
+		log.Error().Err(err).Msg("could not process Slack command")
+	}
+}
+
+// process starts a thread for the command, then triggers the TFC runs with the thread as the Slack destination of
+// their status updates.
+func (h *CommandHandler) process(cmd *Command) error {
+	command := cmd.Opts.Args.Command
+	threadTS, err := h.slack.PostMessage(cmd.ChannelID, "",
+		fmt.Sprintf("%s requested `tfc %s` for %s", cmd.UserName, command, cmd.mergeRequestRef()))
+	if err != nil {
+		return err
+	}
+	reply := func(msg string) {
+		if _, err := h.slack.PostMessage(cmd.ChannelID, threadTS, msg); err != nil {
+			log.Error().Err(err).Msg("could not post Slack message")
+		}
+	}
+
+	gc := h.vcs[cmd.VcsProvider]
+	mr, err := gc.GetMergeRequest(cmd.MergeRequestIID, cmd.ProjectNameWithNamespace)
+	if err != nil {
+		reply(fmt.Sprintf(":fire: Error: could not get merge request: %v", err))
+		return err
+	}
+
+	action := tfc_trigger.PlanAction
+	if command == "apply" {
+		action = tfc_trigger.ApplyAction
+		approvals, err := gc.GetMergeRequestApprovals(cmd.MergeRequestIID, cmd.ProjectNameWithNamespace)
+		if err != nil {
+			reply(fmt.Sprintf(":fire: Error: could not get merge request approvals: %v", err))
+			return err
+		}
+		if !approvals.IsApproved() {
+			reply(":no_entry: Apply failed. Merge Request requires approval.")
+			return nil
+		}
+		if mr.HasConflicts() {
+			reply(":no_entry: Apply failed. Merge Request has conflicts that need to be resolved.")
+			return nil
+		}
+	}
+
+	trigger := h.triggerCreation(gc, h.tfc, h.rs,
+		&tfc_trigger.TFCTriggerConfig{
+			Action:                   action,
+			AllowDestroy:             cmd.Opts.AllowDestroy,
+			Branch:                   mr.GetSourceBranch(),
+			CommitSHA:                mr.GetHeadSHA(),
+			ProjectNameWithNamespace: cmd.ProjectNameWithNamespace,
+			MergeRequestIID:          cmd.MergeRequestIID,
+			TriggerSource:            tfc_trigger.SlackTrigger,
+			VcsProvider:              cmd.VcsProvider,
+			Workspace:                cmd.Opts.Workspace,
+			SlackChannel:             cmd.ChannelID,
+			SlackThreadTS:            threadTS,
+		})
+	executedWorkspaces, err := trigger.TriggerTFCEvents()
+	if err != nil {
+		reply(fmt.Sprintf(":fire: Error: %v", err))
+		return err
+	}
+	msg := ""
+	if len(executedWorkspaces.Executed) > 0 {
+		msg += fmt.Sprintf("Started %s for: `%s`\n", command, strings.Join(executedWorkspaces.Executed, "`, `"))
+	}
+	if len(executedWorkspaces.Queued) > 0 {
+		msg += fmt.Sprintf(":hourglass: Queued %s for: `%s`\n", command, strings.Join(executedWorkspaces.Queued, "`, `"))
+	}
+	for _, failedWS := range executedWorkspaces.Errored {
+		msg += fmt.Sprintf(":no_entry: %s could not be run because: %s\n", failedWS.Name, failedWS.Error)
+	}
+	if msg == "" {
+		msg = "No workspaces were run."
+	}
+	reply(strings.TrimSpace(msg))
+	return nil
+}
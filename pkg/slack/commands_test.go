@@ -0,0 +1,260 @@
+package slack
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/allow_list"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+	"github.com/zapier/tfbuddy/pkg/vcs"
+)
+
+// testSlackAPI records the messages posted to the Slack Web API.
+type testSlackAPI struct {
+	mu       sync.Mutex
+	messages []*postMessageRequest
+}
+
+func (a *testSlackAPI) client(t *testing.T) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat.postMessage", r.URL.Path)
+		assert.Equal(t, "Bearer xoxb-test", r.Header.Get("Authorization"))
+		msg := &postMessageRequest{}
+		if err := json.NewDecoder(r.Body).Decode(msg); err != nil {
+			t.Error(err)
+		}
+		a.mu.Lock()
+		a.messages = append(a.messages, msg)
+		ts := "1700000000." + strconv.Itoa(len(a.messages))
+		a.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(&postMessageResponse{OK: true, TS: ts})
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient("xoxb-test")
+	c.apiURL = srv.URL
+	return c
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("signing-secret")
+	body := []byte("command=%2Ftfc&text=plan+zapier%2Ftfbuddy%21101")
+	now := time.Unix(1700000000, 0)
+	header := func(ts, sig string) http.Header {
+		h := http.Header{}
+		h.Set("X-Slack-Request-Timestamp", ts)
+		h.Set("X-Slack-Signature", sig)
+		return h
+	}
+
+	assert.NoError(t, VerifySignature(secret, header("1700000000", Sign(secret, "1700000000", body)), body, now))
+	assert.Equal(t, ErrInvalidSignature, VerifySignature(secret, header("1700000000", Sign([]byte("other"), "1700000000", body)), body, now))
+	assert.Equal(t, ErrInvalidSignature, VerifySignature(secret, header("1699999000", Sign(secret, "1699999000", body)), body, now), "stale requests are refused")
+	assert.Equal(t, ErrInvalidSignature, VerifySignature(secret, http.Header{}, body, now))
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantErr      bool
+		provider     string
+		project      string
+		iid          int
+		command      string
+		workspace    string
+		allowDestroy bool
+	}{
+		{name: "gitlab plan", text: "plan zapier/tfbuddy!101", provider: "gitlab", project: "zapier/tfbuddy", iid: 101, command: "plan"},
+		{name: "github apply with workspace", text: "apply Zapier/TFBuddy#7 -w service-tfbuddy --allow-destroy", provider: "github", project: "Zapier/TFBuddy", iid: 7, command: "apply", workspace: "service-tfbuddy", allowDestroy: true},
+		{name: "missing merge request", text: "plan -w service-tfbuddy", wantErr: true},
+		{name: "unsupported command", text: "unlock zapier/tfbuddy!101", wantErr: true},
+		{name: "empty", text: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := ParseCommand(tt.text)
+			if tt.wantErr {
+				assert.Equal(t, ErrUsage, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.provider, cmd.VcsProvider)
+				assert.Equal(t, tt.project, cmd.ProjectNameWithNamespace)
+				assert.Equal(t, tt.iid, cmd.MergeRequestIID)
+				assert.Equal(t, tt.command, cmd.Opts.Args.Command)
+				assert.Equal(t, tt.workspace, cmd.Opts.Workspace)
+				assert.Equal(t, tt.allowDestroy, cmd.Opts.AllowDestroy)
+			}
+		})
+	}
+}
+
+func TestCommandHandler_Handler(t *testing.T) {
+	t.Setenv(allow_list.GitlabProjectAllowListEnv, "zapier/")
+	t.Setenv(allow_list.SlackUserAllowListEnv, "U123")
+	t.Setenv(allow_list.SlackChannelAllowListEnv, "C123")
+	h := &CommandHandler{signingSecret: []byte("signing-secret")}
+	e := echo.New()
+	userID, channelID := "U123", "C123"
+	request := func(text string, signed bool) (int, string) {
+		body := url.Values{"text": {text}, "user_id": {userID}, "user_name": {"jane"}, "channel_id": {channelID}}.Encode()
+		req := httptest.NewRequest(http.MethodPost, "/hooks/slack/command", strings.NewReader(body))
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Slack-Request-Timestamp", ts)
+		if signed {
+			req.Header.Set("X-Slack-Signature", Sign(h.signingSecret, ts, []byte(body)))
+		}
+		rec := httptest.NewRecorder()
+		assert.NoError(t, h.Handler(e.NewContext(req, rec)))
+		return rec.Code, rec.Body.String()
+	}
+
+	code, _ := request("plan zapier/tfbuddy!101", false)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, body := request("destroy zapier/tfbuddy!101", true)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "Usage:")
+
+	code, body = request("plan other/project!101", true)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "TF Buddy is not enabled for other/project")
+
+	userID = "U456"
+	code, body = request("plan zapier/tfbuddy!101", true)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "You are not allowed to run TF Buddy commands")
+
+	userID, channelID = "U123", "C456"
+	_, body = request("plan zapier/tfbuddy!101", true)
+	assert.Contains(t, body, "You are not allowed to run TF Buddy commands")
+}
+
+func TestCommandHandler_processInBackground(t *testing.T) {
+	h := &CommandHandler{
+		vcs:   map[string]vcs.GitClient{},
+		slack: (&testSlackAPI{}).client(t),
+	}
+	cmd, err := ParseCommand("plan zapier/tfbuddy#7")
+	assert.NoError(t, err)
+	// the GitHub client is missing, processing the command panics
+	assert.NotPanics(t, func() { h.processInBackground(cmd) })
+}
+
+func TestCommandHandler_Process(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	gl := mocks.NewMockGitClient(mockCtrl)
+	mr := mocks.NewMockDetailedMR(mockCtrl)
+	approvals := mocks.NewMockMRApproved(mockCtrl)
+	trigger := mocks.NewMockTrigger(mockCtrl)
+
+	gl.EXPECT().GetMergeRequest(101, "zapier/tfbuddy").Return(mr, nil)
+	gl.EXPECT().GetMergeRequestApprovals(101, "zapier/tfbuddy").Return(approvals, nil)
+	approvals.EXPECT().IsApproved().Return(true)
+	mr.EXPECT().HasConflicts().Return(false)
+	mr.EXPECT().GetSourceBranch().Return("test-branch")
+	mr.EXPECT().GetHeadSHA().Return("abcd12345")
+	trigger.EXPECT().TriggerTFCEvents().Return(&tfc_trigger.TriggeredTFCWorkspaces{
+		Executed: []string{"service-tfbuddy"},
+	}, nil)
+
+	api := &testSlackAPI{}
+	var cfg tfc_trigger.TriggerConfig
+	h := &CommandHandler{
+		vcs:   map[string]vcs.GitClient{"gitlab": gl},
+		slack: api.client(t),
+		triggerCreation: func(gl vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient, c tfc_trigger.TriggerConfig) tfc_trigger.Trigger {
+			cfg = c
+			return trigger
+		},
+	}
+
+	cmd, err := ParseCommand("apply zapier/tfbuddy!101 -w service-tfbuddy")
+	assert.NoError(t, err)
+	cmd.UserName, cmd.ChannelID = "jane", "C123"
+	assert.NoError(t, h.process(cmd))
+
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, tfc_trigger.ApplyAction, cfg.GetAction())
+		assert.Equal(t, tfc_trigger.SlackTrigger, cfg.GetTriggerSource())
+		assert.Equal(t, "abcd12345", cfg.GetCommitSHA())
+		assert.Equal(t, "service-tfbuddy", cfg.GetWorkspace())
+		assert.Equal(t, "C123", cfg.GetSlackChannel())
+		assert.Equal(t, "1700000000.1", cfg.GetSlackThreadTS())
+	}
+	if assert.Len(t, api.messages, 2) {
+		assert.Equal(t, "jane requested `tfc apply` for zapier/tfbuddy!101", api.messages[0].Text)
+		assert.Empty(t, api.messages[0].ThreadTS)
+		assert.Equal(t, "1700000000.1", api.messages[1].ThreadTS)
+		assert.Equal(t, "Started apply for: `service-tfbuddy`", api.messages[1].Text)
+	}
+}
+
+func TestCommandHandler_ProcessRequiresApproval(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	gh := mocks.NewMockGitClient(mockCtrl)
+	mr := mocks.NewMockDetailedMR(mockCtrl)
+	approvals := mocks.NewMockMRApproved(mockCtrl)
+
+	gh.EXPECT().GetMergeRequest(7, "zapier/tfbuddy").Return(mr, nil)
+	gh.EXPECT().GetMergeRequestApprovals(7, "zapier/tfbuddy").Return(approvals, nil)
+	approvals.EXPECT().IsApproved().Return(false)
+
+	api := &testSlackAPI{}
+	h := &CommandHandler{
+		vcs:   map[string]vcs.GitClient{"github": gh},
+		slack: api.client(t),
+		triggerCreation: func(gl vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient, c tfc_trigger.TriggerConfig) tfc_trigger.Trigger {
+			t.Fatal("no run should be triggered")
+			return nil
+		},
+	}
+
+	cmd, err := ParseCommand("apply zapier/tfbuddy#7")
+	assert.NoError(t, err)
+	cmd.UserName, cmd.ChannelID = "jane", "C123"
+	assert.NoError(t, h.process(cmd))
+	if assert.Len(t, api.messages, 2) {
+		assert.Equal(t, ":no_entry: Apply failed. Merge Request requires approval.", api.messages[1].Text)
+	}
+}
+
+func TestRunUpdater_HandleRunEvent(t *testing.T) {
+	run1 := &runstream.TFRunEvent{RunID: "run-1", NewStatus: "planned"}
+	run1.SetMetadata(&runstream.TFRunMetadata{
+		RunID:         "run-1",
+		Organization:  "zapier",
+		Workspace:     "service-tfbuddy",
+		SlackChannel:  "C123",
+		SlackThreadTS: "1700000000.1",
+	})
+	run2 := &runstream.TFRunEvent{RunID: "run-2", NewStatus: "planned"}
+	run2.SetMetadata(&runstream.TFRunMetadata{RunID: "run-2"})
+
+	api := &testSlackAPI{}
+	u := &RunUpdater{slack: api.client(t)}
+	assert.True(t, u.HandleRunEvent(run1))
+	// runs triggered from MRs aren't posted to Slack
+	assert.True(t, u.HandleRunEvent(run2))
+
+	if assert.Len(t, api.messages, 1) {
+		assert.Equal(t, "C123", api.messages[0].Channel)
+		assert.Equal(t, "1700000000.1", api.messages[0].ThreadTS)
+		assert.Equal(t, "`zapier/service-tfbuddy` <https://app.terraform.io/app/zapier/workspaces/service-tfbuddy/runs/run-1|run-1>: `planned`", api.messages[0].Text)
+	}
+}
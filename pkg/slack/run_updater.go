@@ -0,0 +1,49 @@
+package slack
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+// runEventsQueue is the durable consumer group of the Slack run updaters, each run event is posted by a single TF Buddy
+// pod
+const runEventsQueue = "slack"
+
+// RunUpdater posts the status changes of runs triggered from Slack to the thread of their command. MR comments are
+// still updated by the VCS run event workers.
+type RunUpdater struct {
+	rs    runstream.StreamClient
+	slack *Client
+}
+
+func NewRunUpdater(rs runstream.StreamClient) *RunUpdater {
+	return &RunUpdater{
+		rs:    rs,
+		slack: NewClient(os.Getenv(BotTokenEnvName)),
+	}
+}
+
+// Start subscribes to the run events, the returned closer unsubscribes. The run events are consumed from a durable
+// queue, so the updates of runs changing status while no TF Buddy pod is subscribed are still posted.
+func (u *RunUpdater) Start() (closer func(), err error) {
+	return u.rs.SubscribeAllTFRunEvents(runEventsQueue, u.HandleRunEvent)
+}
+
+// HandleRunEvent posts the run status change to the Slack thread the run was triggered from. Failed posts aren't
+// retried, so a Slack error doesn't hold up the run events.
+func (u *RunUpdater) HandleRunEvent(re runstream.RunEvent) bool {
+	rmd := re.GetMetadata()
+	if rmd == nil || rmd.GetSlackChannel() == "" || rmd.GetSlackThreadTS() == "" {
+		return true
+	}
+
+	runURL := fmt.Sprintf("https://app.terraform.io/app/%s/workspaces/%s/runs/%s", rmd.GetOrganization(), rmd.GetWorkspace(), rmd.GetRunID())
+	msg := fmt.Sprintf("`%s/%s` <%s|%s>: `%s`", rmd.GetOrganization(), rmd.GetWorkspace(), runURL, rmd.GetRunID(), re.GetNewStatus())
+	if _, err := u.slack.PostMessage(rmd.GetSlackChannel(), rmd.GetSlackThreadTS(), msg); err != nil {
+		log.Error().Err(err).Str("runID", rmd.GetRunID()).Msg("could not post run update to Slack")
+	}
+	return true
+}
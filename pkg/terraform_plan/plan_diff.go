@@ -0,0 +1,261 @@
+package terraform_plan
+
+import (
+	"fmt"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const (
+	// PlanFormatMarkdown renders the plan as lists of changed resources & attributes.
+	PlanFormatMarkdown = "markdown"
+	// PlanFormatDiff renders the plan in the style of the `terraform plan` CLI output.
+	PlanFormatDiff = "diff"
+)
+
+// PresentPlanChangesAsDiff renders the plan like the `terraform plan` CLI output, inside a diff code block so that
+// additions and removals are highlighted in the MR comment.
+func PresentPlanChangesAsDiff(b []byte, tfcUrl string) string {
+	plan, err := parseJSONPlan(b)
+	if err != nil {
+		return ""
+	}
+
+	r := &diffRenderer{sb: &strings.Builder{}}
+	for _, chg := range plan.ResourceDrift {
+		r.resourceDrift(chg)
+	}
+	for _, chg := range plan.ResourceChanges {
+		r.resourceChange(chg)
+	}
+	for _, d := range plan.DeferredChanges {
+		if d.ResourceChange != nil {
+			r.comment("%s was deferred (%s): %s", d.ResourceChange.Address, formatActions(d.ResourceChange.Change), d.Reason)
+			r.sb.WriteString("\n")
+		}
+	}
+	r.outputChanges(plan.OutputChanges)
+
+	out := &strings.Builder{}
+	if r.sb.Len() == 0 {
+		out.WriteString("\nNo changes. Your infrastructure matches the configuration.\n")
+	} else {
+		fence := CodeFence(r.sb.String())
+		out.WriteString("\n" + fence + "diff\n")
+		out.WriteString(r.sb.String())
+		out.WriteString(fence + "\n")
+	}
+
+	out.WriteString("\n<b>Plan: </b> ")
+	if r.importCount > 0 {
+		out.WriteString(fmt.Sprintf("%d to import, ", r.importCount))
+	}
+	out.WriteString(fmt.Sprintf("%d to add, %d to change, %d to destroy.\n", r.addCount, r.changeCount, r.destroyCount))
+	out.WriteString(fmt.Sprintf("\nSee [Terraform Cloud Output](%s) for more info.\n", tfcUrl))
+	return out.String()
+}
+
+// CodeFence returns a markdown code fence longer than any run of backticks in content, so that the content can't close
+// the code block early.
+func CodeFence(content string) string {
+	longest, run := 0, 0
+	for _, c := range content {
+		if c != '`' {
+			run = 0
+			continue
+		}
+		run++
+		if run > longest {
+			longest = run
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+type diffRenderer struct {
+	sb           *strings.Builder
+	addCount     int
+	changeCount  int
+	destroyCount int
+	importCount  int
+}
+
+func (r *diffRenderer) resourceChange(chg *tfjson.ResourceChange) {
+	actions := chg.Change.Actions
+	moved := chg.PreviousAddress != "" && chg.PreviousAddress != chg.Address
+	importing := chg.Change.Importing != nil
+	if importing {
+		r.importCount += 1
+	}
+
+	block := fmt.Sprintf("resource %q %q {", chg.Type, chg.Name)
+	if chg.Mode == tfjson.DataResourceMode {
+		block = fmt.Sprintf("data %q %q {", chg.Type, chg.Name)
+	}
+
+	switch {
+	case actions.NoOp():
+		if moved {
+			r.comment("%s has moved to %s", chg.PreviousAddress, chg.Address)
+		}
+		if importing {
+			r.comment("%s will be imported", chg.Address)
+		}
+		if moved || importing {
+			r.sb.WriteString("\n")
+		}
+
+	case actions.Read():
+		r.comment("%s will be read during apply", chg.Address)
+		r.line("<=", 0, block)
+		r.attributes("+", 1, chg.Change.After, chg.Change.AfterUnknown, chg.Change.AfterSensitive)
+		r.line("", 0, "}")
+		r.sb.WriteString("\n")
+
+	case actions.Create():
+		r.addCount += 1
+		r.comment("%s will be created", chg.Address)
+		r.line("+", 0, block)
+		r.attributes("+", 1, chg.Change.After, chg.Change.AfterUnknown, chg.Change.AfterSensitive)
+		r.line("", 0, "}")
+		r.sb.WriteString("\n")
+
+	case actions.Update():
+		r.changeCount += 1
+		r.comment("%s will be updated in-place", chg.Address)
+		r.resourceUpdate(chg, "~", block, moved, importing)
+
+	case actions.Delete():
+		r.destroyCount += 1
+		r.comment("%s will be destroyed", chg.Address)
+		if moved {
+			r.comment("(because %s was moved to %s)", chg.PreviousAddress, chg.Address)
+		}
+		r.line("-", 0, block)
+		r.attributes("-", 1, chg.Change.Before, nil, chg.Change.BeforeSensitive)
+		r.line("", 0, "}")
+		r.sb.WriteString("\n")
+
+	case actions.Replace():
+		r.addCount += 1
+		r.destroyCount += 1
+		symbol := "-/+"
+		if actions.CreateBeforeDestroy() {
+			symbol = "+/-"
+		}
+		r.comment("%s must be replaced", chg.Address)
+		r.resourceUpdate(chg, symbol, block, moved, importing)
+
+	case actions.Forget():
+		r.comment("%s will no longer be managed by Terraform", chg.Address)
+		r.sb.WriteString("\n")
+	}
+}
+
+// resourceDrift writes the changes Terraform detected to a resource made outside of Terraform since the last run.
+func (r *diffRenderer) resourceDrift(chg *tfjson.ResourceChange) {
+	if chg.Change == nil || chg.Change.Actions.NoOp() {
+		return
+	}
+	if chg.Change.Actions.Delete() {
+		r.comment("%s has been deleted outside of Terraform", chg.Address)
+		r.sb.WriteString("\n")
+		return
+	}
+	r.comment("%s has changed outside of Terraform", chg.Address)
+	r.resourceUpdate(chg, "~", fmt.Sprintf("resource %q %q {", chg.Type, chg.Name), false, false)
+}
+
+// resourceUpdate writes the block of a resource updated in place or replaced, listing only the changed attributes.
+func (r *diffRenderer) resourceUpdate(chg *tfjson.ResourceChange, symbol, block string, moved, importing bool) {
+	if moved {
+		r.comment("(moved from %s)", chg.PreviousAddress)
+	}
+	if importing {
+		r.comment("(imported from %q)", chg.Change.Importing.ID)
+	}
+	r.line(symbol, 0, block)
+	for _, attr := range processChanges(chg) {
+		attrSymbol := "~"
+		text := fmt.Sprintf("%s = %s -> %s", attr.Field, attr.Before, attr.After)
+		if attr.Before == "null" {
+			attrSymbol = "+"
+			text = fmt.Sprintf("%s = %s", attr.Field, attr.After)
+		} else if attr.After == "null" {
+			attrSymbol = "-"
+		}
+		if attr.ForcesReplacement {
+			text += " # forces replacement"
+		}
+		r.line(attrSymbol, 1, text)
+	}
+	r.line("", 0, "}")
+	r.sb.WriteString("\n")
+}
+
+func (r *diffRenderer) outputChanges(outputs map[string]*tfjson.Change) {
+	changes := processOutputChanges(outputs)
+	if len(changes) == 0 {
+		return
+	}
+
+	r.line("", 0, "Changes to Outputs:")
+	for _, oc := range changes {
+		switch oc.Action {
+		case "create":
+			r.line("+", 1, fmt.Sprintf("%s = %s", oc.Name, oc.After))
+		case "delete":
+			r.line("-", 1, fmt.Sprintf("%s = %s", oc.Name, oc.Before))
+		default:
+			r.line("~", 1, fmt.Sprintf("%s = %s -> %s", oc.Name, oc.Before, oc.After))
+		}
+	}
+}
+
+// attributes writes the attributes of a created, read or destroyed resource, descending into nested objects & lists.
+func (r *diffRenderer) attributes(symbol string, indent int, value, unknown, sensitive interface{}) {
+	values, _ := value.(map[string]interface{})
+	for _, k := range sortedKeys(values, nil, unknown) {
+		v := values[k]
+		if v == nil && !isTrue(child(unknown, k)) {
+			continue
+		}
+		r.value(symbol, indent, k+" = ", v, child(unknown, k), child(sensitive, k))
+	}
+}
+
+func (r *diffRenderer) value(symbol string, indent int, prefix string, v, unknown, sensitive interface{}) {
+	switch {
+	case isTrue(unknown):
+		r.line(symbol, indent, prefix+knownAfterApply)
+	case isTrue(sensitive):
+		r.line(symbol, indent, prefix+sensitiveValue)
+	default:
+		switch val := v.(type) {
+		case map[string]interface{}:
+			r.line(symbol, indent, prefix+"{")
+			r.attributes(symbol, indent+1, val, unknown, sensitive)
+			r.line(symbol, indent, "}")
+		case []interface{}:
+			r.line(symbol, indent, prefix+"[")
+			for i, elem := range val {
+				r.value(symbol, indent+1, "", elem, child(unknown, i), child(sensitive, i))
+			}
+			r.line(symbol, indent, "]")
+		default:
+			r.line(symbol, indent, prefix+formatValue(v, nil))
+		}
+	}
+}
+
+func (r *diffRenderer) comment(format string, args ...interface{}) {
+	r.line("", 0, "# "+fmt.Sprintf(format, args...))
+}
+
+func (r *diffRenderer) line(symbol string, indent int, text string) {
+	r.sb.WriteString(fmt.Sprintf("%-3s %s%s\n", symbol, strings.Repeat("    ", indent), text))
+}
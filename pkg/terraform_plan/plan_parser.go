@@ -3,7 +3,11 @@ package terraform_plan
 import (
 	"bytes"
 	_ "embed"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"text/template"
 
 	tfjson "github.com/hashicorp/terraform-json"
@@ -13,6 +17,35 @@ import (
 //go:embed templates/plan_output.tpl
 var planTemplate []byte
 
+var templateFuncs = template.FuncMap{
+	// escape makes attribute values safe to render inside HTML tags of the MR comment
+	"escape":  strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace,
+	"section": newCollapsedSection,
+}
+
+// collapsedSection is a list of resource addresses rendered inside a <details> element.
+type collapsedSection struct {
+	Name      string
+	Count     int
+	Addresses []string
+}
+
+func newCollapsedSection(name string, count int, resources ...interface{}) collapsedSection {
+	section := collapsedSection{Name: name, Count: count}
+	for _, res := range resources {
+		switch r := res.(type) {
+		case []string:
+			section.Addresses = append(section.Addresses, r...)
+		case map[string][]*ResourceChange:
+			for address := range r {
+				section.Addresses = append(section.Addresses, address)
+			}
+		}
+	}
+	sort.Strings(section.Addresses)
+	return section
+}
+
 func parseJSONPlan(b []byte) (*tfjson.Plan, error) {
 	plan := &tfjson.Plan{}
 	err := plan.UnmarshalJSON(b)
@@ -23,7 +56,24 @@ func parseJSONPlan(b []byte) (*tfjson.Plan, error) {
 	return plan, nil
 }
 
+// MarkdownOptions controls how much detail is rendered, so that large plans can be reduced to fit in a MR comment.
+type MarkdownOptions struct {
+	// Collapsed renders only the resource addresses, with each section wrapped in a <details> element.
+	Collapsed bool
+	// SummaryOnly renders the resource counts and the link to the full plan output.
+	SummaryOnly bool
+	// Template replaces the default plan template. The default is used if it cannot be rendered.
+	Template string
+	// Diff is the plan rendered with PresentPlanChangesAsDiff, it is available to the custom template and replaces the
+	// default template when the plan format is diff.
+	Diff string
+}
+
 func PresentPlanChangesAsMarkdown(b []byte, tfcUrl string) string {
+	return PresentPlanChangesAsMarkdownWithOptions(b, tfcUrl, MarkdownOptions{})
+}
+
+func PresentPlanChangesAsMarkdownWithOptions(b []byte, tfcUrl string, opts MarkdownOptions) string {
 	plan, err := parseJSONPlan(b)
 	if err != nil {
 		return ""
@@ -32,13 +82,30 @@ func PresentPlanChangesAsMarkdown(b []byte, tfcUrl string) string {
 	tplData := PlanTemplateData{
 		Changes:      map[string][]*ResourceChange{},
 		Replacements: map[string][]*ResourceChange{},
+		Drift:        map[string][]*ResourceChange{},
 		TfcUrl:       tfcUrl,
+		Collapsed:    opts.Collapsed,
+		SummaryOnly:  opts.SummaryOnly,
+		Diff:         opts.Diff,
 	}
 	for _, chg := range plan.ResourceChanges {
+		if chg.PreviousAddress != "" && chg.PreviousAddress != chg.Address {
+			tplData.MoveCount += 1
+			tplData.Moves = append(tplData.Moves, &ResourceMove{From: chg.PreviousAddress, To: chg.Address})
+		}
+		if chg.Change.Importing != nil {
+			tplData.ImportCount += 1
+			tplData.Imports = append(tplData.Imports, &ResourceImport{Address: chg.Address, ID: chg.Change.Importing.ID})
+		}
+
 		switch {
 		case chg.Change.Actions.NoOp():
 			continue
 
+		case chg.Change.Actions.Forget():
+			tplData.ForgetCount += 1
+			tplData.Forgets = append(tplData.Forgets, chg.Address)
+
 		case chg.Change.Actions.Create():
 			tplData.AdditionCount += 1
 			tplData.Additions = append(tplData.Additions, chg.Address)
@@ -57,57 +124,332 @@ func PresentPlanChangesAsMarkdown(b []byte, tfcUrl string) string {
 		}
 	}
 
-	t := template.Must(template.New("plan").Parse(string(planTemplate)))
+	for _, chg := range plan.ResourceDrift {
+		if chg.Change == nil || chg.Change.Actions.NoOp() {
+			continue
+		}
+		tplData.DriftCount += 1
+		if chg.Change.Actions.Delete() {
+			tplData.DriftDeletions = append(tplData.DriftDeletions, chg.Address)
+			continue
+		}
+		tplData.Drift[chg.Address] = processChanges(chg)
+	}
+	tplData.Outputs = processOutputChanges(plan.OutputChanges)
+	tplData.OutputCount = len(tplData.Outputs)
+
+	for _, d := range plan.DeferredChanges {
+		if d.ResourceChange == nil {
+			continue
+		}
+		tplData.DeferredCount += 1
+		tplData.Deferred = append(tplData.Deferred, &DeferredResource{
+			Address: d.ResourceChange.Address,
+			Actions: formatActions(d.ResourceChange.Change),
+			Reason:  d.Reason,
+		})
+	}
+
+	if opts.Template != "" {
+		out, err := renderPlanTemplate(opts.Template, tplData)
+		if err == nil {
+			return out
+		}
+		log.Warn().Err(err).Msg("could not render custom plan template, using the default template")
+	}
+	if opts.Diff != "" && !opts.SummaryOnly {
+		return opts.Diff
+	}
+
+	out, err := renderPlanTemplate(string(planTemplate), tplData)
+	if err != nil {
+		log.Error().Err(err).Msg("could not render plan template")
+	}
+	return out
+}
+
+// ValidateMarkdownTemplate checks that a custom plan template parses and renders in every mode against sample data.
+func ValidateMarkdownTemplate(text string) error {
+	data := PlanTemplateData{
+		AdditionCount: 1,
+		Additions:     []string{"aws_s3_bucket.example"},
+		ChangeCount:   1,
+		Changes: map[string][]*ResourceChange{
+			"aws_instance.example": {{Field: "instance_type", Before: `"t3.micro"`, After: `"t3.small"`}},
+		},
+		Replacements:   map[string][]*ResourceChange{},
+		Moves:          []*ResourceMove{{From: "aws_iam_role.old", To: "aws_iam_role.new"}},
+		Imports:        []*ResourceImport{{Address: "aws_iam_role.new", ID: "new"}},
+		Deferred:       []*DeferredResource{},
+		Outputs:        []*OutputChange{{Name: "example", Action: "update", Before: "1", After: "2"}},
+		Drift:          map[string][]*ResourceChange{},
+		DriftDeletions: []string{},
+		TfcUrl:         "https://app.terraform.io",
+	}
+	for _, opts := range []MarkdownOptions{{}, {Collapsed: true}, {SummaryOnly: true}, {Diff: "\n```diff\n+ aws_s3_bucket.example\n```\n"}} {
+		data.Collapsed = opts.Collapsed
+		data.SummaryOnly = opts.SummaryOnly
+		data.Diff = opts.Diff
+		if _, err := renderPlanTemplate(text, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderPlanTemplate(text string, data PlanTemplateData) (string, error) {
+	t, err := template.New("plan").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
 
 	outputBuffer := &bytes.Buffer{}
-	t.Execute(outputBuffer, tplData)
-	return outputBuffer.String()
+	if err := t.Execute(outputBuffer, data); err != nil {
+		return "", err
+	}
+	return outputBuffer.String(), nil
 }
 
+const (
+	sensitiveValue  = "(sensitive value)"
+	knownAfterApply = "(known after apply)"
+)
+
+// processChanges returns the attribute level changes for a resource, comparing the before and after values of the
+// change and descending into nested objects & lists.
 func processChanges(chg *tfjson.ResourceChange) []*ResourceChange {
-	beforeMap := chg.Change.Before.(map[string]interface{})
-	afterMap := chg.Change.After.(map[string]interface{})
-	afterUnknownMap := chg.Change.AfterUnknown.(map[string]interface{})
-
-	afterSensitiveMap := map[string]interface{}{}
-	if chg.Change.AfterSensitive != nil {
-		afterSensitiveMap = chg.Change.AfterSensitive.(map[string]interface{})
-	}
-	changes := []*ResourceChange{}
-	for k, bv := range beforeMap {
-		rc := &ResourceChange{
-			Field:  k,
-			Before: fmt.Sprintf("%v", bv),
-		}
-		if av, ok := afterMap[k]; ok && fmt.Sprintf("%v", bv) != fmt.Sprintf("%v", bv) {
-			rc.After = fmt.Sprintf("%v", av)
-
-		} else if _, ok := afterSensitiveMap[k]; ok {
-			rc.After = "*sensitive value*"
-
-		} else if _, ok := afterUnknownMap[k]; ok {
-			rc.After = "*known after apply*"
-
-		} else {
-			switch {
-			case isReplace(chg):
-				if rc.After == "" {
-					rc.After = rc.Before
-				}
-				fallthrough
-			case chg.Change.Actions[0] == tfjson.ActionCreate:
-				changes = append(changes, rc)
-
-			case chg.Change.Actions[0] == tfjson.ActionUpdate:
-				if rc.Before != rc.After {
-					changes = append(changes, rc)
-				}
+	d := &attributeDiff{
+		replacePaths: chg.Change.ReplacePaths,
+		changes:      []*ResourceChange{},
+	}
+	d.diff(
+		nil,
+		chg.Change.Before,
+		chg.Change.After,
+		chg.Change.AfterUnknown,
+		chg.Change.BeforeSensitive,
+		chg.Change.AfterSensitive,
+	)
+	return d.changes
+}
+
+type attributeDiff struct {
+	replacePaths []interface{}
+	changes      []*ResourceChange
+}
+
+func (d *attributeDiff) diff(path []interface{}, before, after, unknown, beforeSensitive, afterSensitive interface{}) {
+	if isTrue(unknown) {
+		d.add(path, formatValue(before, beforeSensitive), knownAfterApply)
+		return
+	}
+	if isTrue(beforeSensitive) || isTrue(afterSensitive) {
+		if !reflect.DeepEqual(before, after) {
+			d.add(path, formatValue(before, beforeSensitive), formatValue(after, afterSensitive))
+		}
+		return
+	}
+
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if (beforeIsMap || before == nil) && (afterIsMap || after == nil) && (beforeIsMap || afterIsMap) {
+		for _, k := range sortedKeys(beforeMap, afterMap, unknown) {
+			d.diff(
+				append(path, k),
+				beforeMap[k],
+				afterMap[k],
+				child(unknown, k),
+				child(beforeSensitive, k),
+				child(afterSensitive, k),
+			)
+		}
+		return
+	}
+
+	beforeList, beforeIsList := before.([]interface{})
+	afterList, afterIsList := after.([]interface{})
+	if (beforeIsList || before == nil) && (afterIsList || after == nil) && (beforeIsList || afterIsList) {
+		length := len(beforeList)
+		if len(afterList) > length {
+			length = len(afterList)
+		}
+		if l := len(listValue(unknown)); l > length {
+			length = l
+		}
+		for i := 0; i < length; i++ {
+			d.diff(
+				append(path, float64(i)),
+				index(beforeList, i),
+				index(afterList, i),
+				child(unknown, i),
+				child(beforeSensitive, i),
+				child(afterSensitive, i),
+			)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		d.add(path, formatValue(before, nil), formatValue(after, nil))
+	}
+}
+
+func (d *attributeDiff) add(path []interface{}, before, after string) {
+	if len(path) == 0 {
+		return
+	}
+	d.changes = append(d.changes, &ResourceChange{
+		Field:             formatPath(path),
+		Before:            before,
+		After:             after,
+		ForcesReplacement: forcesReplacement(path, d.replacePaths),
+	})
+}
+
+// forcesReplacement checks if the attribute path is, or is nested within, one of the plan's replace_paths.
+func forcesReplacement(path []interface{}, replacePaths []interface{}) bool {
+	for _, rp := range replacePaths {
+		rPath, ok := rp.([]interface{})
+		if !ok || len(rPath) == 0 || len(rPath) > len(path) {
+			continue
+		}
+		matched := true
+		for i, step := range rPath {
+			if fmt.Sprintf("%v", step) != fmt.Sprintf("%v", path[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPath(path []interface{}) string {
+	sb := &strings.Builder{}
+	for _, step := range path {
+		switch v := step.(type) {
+		case float64:
+			sb.WriteString(fmt.Sprintf("[%d]", int(v)))
+		default:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
 			}
+			sb.WriteString(fmt.Sprintf("%v", v))
 		}
 	}
+	return sb.String()
+}
+
+func formatValue(v interface{}, sensitive interface{}) string {
+	if isTrue(sensitive) {
+		return sensitiveValue
+	}
+	if v == nil {
+		return "null"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// processOutputChanges returns the changed outputs sorted by name, masking sensitive values.
+func processOutputChanges(outputs map[string]*tfjson.Change) []*OutputChange {
+	changes := []*OutputChange{}
+	for name, chg := range outputs {
+		if chg == nil || chg.Actions.NoOp() {
+			continue
+		}
+		oc := &OutputChange{
+			Name:   name,
+			Action: "update",
+			Before: formatValue(chg.Before, chg.BeforeSensitive),
+			After:  formatValue(chg.After, chg.AfterSensitive),
+		}
+		if isTrue(chg.AfterUnknown) {
+			oc.After = knownAfterApply
+		}
+		if chg.Actions.Create() {
+			oc.Action = "create"
+		} else if chg.Actions.Delete() {
+			oc.Action = "delete"
+		}
+		changes = append(changes, oc)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
 	return changes
 }
 
+func formatActions(chg *tfjson.Change) string {
+	if chg == nil {
+		return ""
+	}
+	actions := make([]string, 0, len(chg.Actions))
+	for _, a := range chg.Actions {
+		actions = append(actions, string(a))
+	}
+	return strings.Join(actions, ", ")
+}
+
+func isTrue(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
+
+// child returns the nested value of a before/after sensitive or unknown structure.
+func child(v interface{}, key interface{}) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		if k, ok := key.(string); ok {
+			return c[k]
+		}
+	case []interface{}:
+		if i, ok := key.(int); ok {
+			return index(c, i)
+		}
+	}
+	return nil
+}
+
+func index(l []interface{}, i int) interface{} {
+	if i < len(l) {
+		return l[i]
+	}
+	return nil
+}
+
+func listValue(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func sortedKeys(before, after map[string]interface{}, unknown interface{}) []string {
+	keySet := map[string]bool{}
+	for k := range before {
+		keySet[k] = true
+	}
+	for k := range after {
+		keySet[k] = true
+	}
+	if u, ok := unknown.(map[string]interface{}); ok {
+		for k := range u {
+			keySet[k] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for k := range keySet {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func isReplace(chg *tfjson.ResourceChange) bool {
 	if len(chg.Change.Actions) < 2 {
 		return false
@@ -130,7 +472,51 @@ type PlanTemplateData struct {
 	Destructions     []string
 	ReplacementCount int
 	Replacements     map[string][]*ResourceChange
+	MoveCount        int
+	Moves            []*ResourceMove
+	ImportCount      int
+	Imports          []*ResourceImport
+	ForgetCount      int
+	Forgets          []string
+	DeferredCount    int
+	Deferred         []*DeferredResource
+	OutputCount      int
+	Outputs          []*OutputChange
+	DriftCount       int
+	Drift            map[string][]*ResourceChange
+	DriftDeletions   []string
 	TfcUrl           string
+	Collapsed        bool
+	SummaryOnly      bool
+	// Diff is the plan rendered as a diff code block when the project's plan format is diff, empty otherwise
+	Diff string
+}
+
+// ResourceMove is a resource whose address changed, e.g. via a `moved` block.
+type ResourceMove struct {
+	From string
+	To   string
+}
+
+// ResourceImport is a resource imported into the state by the plan.
+type ResourceImport struct {
+	Address string
+	ID      string
+}
+
+// OutputChange is a root module output value changed by the plan. Sensitive values are masked.
+type OutputChange struct {
+	Name   string
+	Action string
+	Before string
+	After  string
+}
+
+// DeferredResource is a resource change that Terraform could not plan yet and deferred to a later run.
+type DeferredResource struct {
+	Address string
+	Actions string
+	Reason  string
 }
 
 type ResourceChange struct {
@@ -39,6 +39,12 @@ func Test_parseJSONPlan(t *testing.T) {
 			want:    nil,
 			wantErr: false,
 		},
+		{
+			name:    "update-nested",
+			tfplan:  "testdata/TestPresentPlanChangesAsMarkdown/update-nested.tfplan.json",
+			want:    nil,
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -56,6 +62,8 @@ func Test_parseJSONPlan(t *testing.T) {
 func TestPresentPlanChangesAsMarkdown(t *testing.T) {
 	tests := []struct {
 		name string
+		plan string
+		opts MarkdownOptions
 	}{
 		{
 			name: "basic",
@@ -66,11 +74,38 @@ func TestPresentPlanChangesAsMarkdown(t *testing.T) {
 		{
 			name: "replace",
 		},
+		{
+			name: "update-nested",
+		},
+		{
+			name: "refactor",
+			plan: "TestPresentPlanChangesAsDiff/refactor",
+		},
+		{
+			name: "refactor-summary",
+			plan: "TestPresentPlanChangesAsDiff/refactor",
+			opts: MarkdownOptions{SummaryOnly: true},
+		},
+		{
+			name: "update-nested-collapsed",
+			plan: "TestPresentPlanChangesAsMarkdown/update-nested",
+			opts: MarkdownOptions{Collapsed: true},
+		},
+		{
+			name: "update-nested-summary",
+			plan: "TestPresentPlanChangesAsMarkdown/update-nested",
+			opts: MarkdownOptions{SummaryOnly: true},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			plan := testLoadTestData(t, ".tfplan.json")
-			got := PresentPlanChangesAsMarkdown(plan, "http://app.terraform.io/x/y/z")
+			var plan []byte
+			if tt.plan != "" {
+				plan = testLoadFile(t, fmt.Sprintf("testdata/%s.tfplan.json", tt.plan))
+			} else {
+				plan = testLoadTestData(t, ".tfplan.json")
+			}
+			got := PresentPlanChangesAsMarkdownWithOptions(plan, "http://app.terraform.io/x/y/z", tt.opts)
 			if updateGolden {
 				testWriteTestData(t, ".md", []byte(got))
 			}
@@ -104,3 +139,92 @@ func testWriteTestData(t *testing.T, suffix string, b []byte) {
 		t.Fatalf("could not write testdata file (%s): %v", filename, err)
 	}
 }
+
+func TestPresentPlanChangesAsDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		plan string
+	}{
+		{
+			name: "refactor",
+		},
+		{
+			name: "replace",
+			plan: "testdata/TestPresentPlanChangesAsMarkdown/replace.tfplan.json",
+		},
+		{
+			name: "update-nested",
+			plan: "testdata/TestPresentPlanChangesAsMarkdown/update-nested.tfplan.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var plan []byte
+			if tt.plan != "" {
+				plan = testLoadFile(t, tt.plan)
+			} else {
+				plan = testLoadTestData(t, ".tfplan.json")
+			}
+			got := PresentPlanChangesAsDiff(plan, "http://app.terraform.io/x/y/z")
+			if updateGolden {
+				testWriteTestData(t, ".md", []byte(got))
+			}
+			want := string(testLoadTestData(t, ".md"))
+			assert.Equal(t, want, got, "")
+		})
+	}
+}
+
+func TestCodeFence(t *testing.T) {
+	assert.Equal(t, "```", CodeFence("+ name = \"app\""))
+	assert.Equal(t, "```", CodeFence("+ cmd = \"echo `id`\""))
+	assert.Equal(t, "````", CodeFence("+ readme = \"```bash\""))
+	assert.Equal(t, "``````", CodeFence("+ readme = \"`````\""))
+}
+
+func TestValidateMarkdownTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tpl     string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			tpl:  string(planTemplate),
+		},
+		{
+			name: "custom",
+			tpl:  "{{ .AdditionCount }} to add, see {{ .TfcUrl }}",
+		},
+		{
+			name:    "syntax-error",
+			tpl:     "{{ .AdditionCount ",
+			wantErr: true,
+		},
+		{
+			name:    "unknown-field",
+			tpl:     "{{ .Sausage }}",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMarkdownTemplate(tt.tpl)
+			assert.Equal(t, tt.wantErr, err != nil, "ValidateMarkdownTemplate() error = %v", err)
+		})
+	}
+}
+
+func TestPresentPlanChangesAsMarkdownWithOptions_Template(t *testing.T) {
+	plan := testLoadFile(t, "testdata/TestPresentPlanChangesAsMarkdown/basic.tfplan.json")
+
+	got := PresentPlanChangesAsMarkdownWithOptions(plan, "http://app.terraform.io/x/y/z", MarkdownOptions{
+		Template: "{{ range .Additions }}+ {{ . }}{{ end }}",
+	})
+	assert.Equal(t, "+ random_pet.will_it_be_cats", got)
+
+	got = PresentPlanChangesAsMarkdownWithOptions(plan, "http://app.terraform.io/x/y/z", MarkdownOptions{
+		Template: "{{ .Sausage }}",
+	})
+	assert.Equal(t, PresentPlanChangesAsMarkdown(plan, "http://app.terraform.io/x/y/z"), got, "falls back to the default template")
+}
@@ -0,0 +1,76 @@
+package terraform_plan
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// ProtectedResources are resources that may only be destroyed or replaced after the change has been explicitly
+// acknowledged with `tfc apply --allow-destroy`.
+type ProtectedResources struct {
+	// Types are resource types (e.g. aws_db_instance)
+	Types []string `yaml:"types" json:",omitempty"`
+	// Addresses are resource address patterns, where `*` matches any characters (e.g. module.db.*)
+	Addresses []string `yaml:"addresses" json:",omitempty"`
+}
+
+// IsEmpty returns true if no resources are protected.
+func (p *ProtectedResources) IsEmpty() bool {
+	return p == nil || len(p.Types) == 0 && len(p.Addresses) == 0
+}
+
+// Matches returns true if the resource is protected by type or address.
+func (p *ProtectedResources) Matches(chg *tfjson.ResourceChange) bool {
+	if p.IsEmpty() || chg.Mode == tfjson.DataResourceMode {
+		return false
+	}
+	for _, t := range p.Types {
+		if t == chg.Type {
+			return true
+		}
+	}
+	for _, pattern := range p.Addresses {
+		if matchAddress(pattern, chg.Address) {
+			return true
+		}
+	}
+	return false
+}
+
+// DestroyedProtectedResources returns the sorted addresses of the protected resources that the plan destroys or
+// replaces.
+func DestroyedProtectedResources(b []byte, p *ProtectedResources) ([]string, error) {
+	if p.IsEmpty() {
+		return nil, nil
+	}
+	plan, err := parseJSONPlan(b)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{}
+	for _, chg := range plan.ResourceChanges {
+		if chg.Change == nil {
+			continue
+		}
+		if !chg.Change.Actions.Delete() && !chg.Change.Actions.Replace() {
+			continue
+		}
+		if p.Matches(chg) {
+			addresses = append(addresses, chg.Address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// matchAddress matches a resource address against a pattern where `*` matches any characters. All other characters,
+// including the brackets of instance keys, match literally.
+func matchAddress(pattern, address string) bool {
+	expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `.*`)
+	matched, _ := regexp.MatchString("^"+expr+"$", address)
+	return matched
+}
@@ -0,0 +1,54 @@
+package terraform_plan
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestroyedProtectedResources(t *testing.T) {
+	plan, err := os.ReadFile("testdata/TestPresentPlanChangesAsMarkdown/replace.tfplan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		protected *ProtectedResources
+		want      []string
+	}{
+		{
+			name:      "none",
+			protected: nil,
+			want:      nil,
+		},
+		{
+			name:      "type",
+			protected: &ProtectedResources{Types: []string{"random_integer"}},
+			want:      []string{"random_integer.pet_length"},
+		},
+		{
+			name:      "address pattern",
+			protected: &ProtectedResources{Addresses: []string{"random_pet.rando[*]"}},
+			want:      []string{"random_pet.rando[0]", "random_pet.rando[1]", "random_pet.rando[2]", "random_pet.rando[3]"},
+		},
+		{
+			name:      "exact address",
+			protected: &ProtectedResources{Addresses: []string{"random_pet.rando[1]"}},
+			want:      []string{"random_pet.rando[1]"},
+		},
+		{
+			name:      "created resource",
+			protected: &ProtectedResources{Types: []string{"time_rotating"}, Addresses: []string{"random_pet.other*"}},
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DestroyedProtectedResources(plan, tt.protected)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,9 +1,141 @@
 {{ define "resource-change-content" }}
 {{- range $key, $value := . }}
 <li><code>{{ $key }}</code>
+{{- if $value }}
+<ul>
+{{- range $value }}
+<li><code>{{ .Field }}</code>: <code>{{ .Before | escape }}</code> &rarr; <code>{{ .After | escape }}</code>{{ if .ForcesReplacement }} :warning: <b>forces replacement</b>{{ end }}</li>
+{{- end }}
+</ul>
+{{- end }}
 </li>
 {{- end}}
 {{ end }}
+{{- define "collapsed-section" }}
+{{- if .Count }}
+<details><summary>Show {{ .Name }}</summary>
+<ul>
+{{- range .Addresses }}
+<li><code>{{ . }}</code></li>
+{{- end }}
+</ul>
+</details>
+{{- end }}
+{{- end }}
+{{- define "refactor-sections" }}
+{{- if .MoveCount }}
+
+:truck: <b>Moves:</b> {{.MoveCount}}
+<ul>
+{{- range .Moves }}
+<li><code>{{ .From }}</code> &rarr; <code>{{ .To }}</code></li>
+{{- end }}
+</ul>
+{{- end }}
+{{- if .ImportCount }}
+
+:inbox_tray: <b>Imports:</b> {{.ImportCount}}
+<ul>
+{{- range .Imports }}
+<li><code>{{ .Address }}</code> from <code>{{ .ID | escape }}</code></li>
+{{- end }}
+</ul>
+{{- end }}
+{{- if .ForgetCount }}
+
+:outbox_tray: <b>Removed from state (not destroyed):</b> {{.ForgetCount}}
+<ul>
+{{- range .Forgets }}
+<li><code>{{ . }}</code></li>
+{{- end }}
+</ul>
+{{- end }}
+{{- if .DeferredCount }}
+
+:hourglass: <b>Deferred:</b> {{.DeferredCount}}
+<ul>
+{{- range .Deferred }}
+<li><code>{{ .Address }}</code> ({{ .Actions }}): {{ .Reason }}</li>
+{{- end }}
+</ul>
+{{- end }}
+{{- end }}
+{{- define "output-drift-sections" }}
+{{- if .OutputCount }}
+
+:bar_chart: <b>Output changes:</b> {{.OutputCount}}
+<ul>
+{{- range .Outputs }}
+<li><code>{{ .Name }}</code>: <code>{{ .Before | escape }}</code> &rarr; <code>{{ .After | escape }}</code></li>
+{{- end }}
+</ul>
+{{- end }}
+{{- if .DriftCount }}
+
+:warning: <b>Changed outside of Terraform:</b> {{.DriftCount}}
+{{- if .Collapsed }}
+{{- template "collapsed-section" (section "drift" .DriftCount .Drift .DriftDeletions) }}
+{{- else }}
+<ul>
+{{ template "resource-change-content" .Drift }}
+{{- range .DriftDeletions }}
+<li><code>{{ . }}</code> has been deleted</li>
+{{- end }}
+</ul>
+{{- end }}
+{{- end }}
+{{- end }}
+{{- define "plan-footer" }}
+</br>
+<b>Plan: </b> {{.AdditionCount}} to add, {{.ChangeCount}} to change, {{.ReplacementCount}} to replace and {{.DestructionCount}} to destroy.
+</br>
+
+See [Terraform Cloud Output]({{.TfcUrl}}) for more info.
+{{ end }}
+{{- if .SummaryOnly }}
+
+:seedling: <b>Additions:</b> {{.AdditionCount}}<br>
+:cyclone: <b>Changes:</b> {{.ChangeCount}}<br>
+:recycle: <b>Replacements:</b> {{.ReplacementCount}}<br>
+:boom: <b>Destructions:</b> {{.DestructionCount}}<br>
+{{- if .MoveCount }}
+:truck: <b>Moves:</b> {{.MoveCount}}<br>
+{{- end }}
+{{- if .ImportCount }}
+:inbox_tray: <b>Imports:</b> {{.ImportCount}}<br>
+{{- end }}
+{{- if .ForgetCount }}
+:outbox_tray: <b>Removed from state (not destroyed):</b> {{.ForgetCount}}<br>
+{{- end }}
+{{- if .DeferredCount }}
+:hourglass: <b>Deferred:</b> {{.DeferredCount}}<br>
+{{- end }}
+{{- if .OutputCount }}
+:bar_chart: <b>Output changes:</b> {{.OutputCount}}<br>
+{{- end }}
+{{- if .DriftCount }}
+:warning: <b>Changed outside of Terraform:</b> {{.DriftCount}}<br>
+{{- end }}
+
+The plan is too large to be displayed in a comment.
+{{- template "plan-footer" . }}
+{{- else if .Collapsed }}
+
+:seedling: <b>Additions:</b> {{.AdditionCount}}
+{{- template "collapsed-section" (section "additions" .AdditionCount .Additions) }}
+
+:cyclone: <b>Changes:</b> {{.ChangeCount}}
+{{- template "collapsed-section" (section "changes" .ChangeCount .Changes) }}
+
+:recycle: <b>Replacements:</b> {{.ReplacementCount}}
+{{- template "collapsed-section" (section "replacements" .ReplacementCount .Replacements) }}
+
+:boom: <b>Destructions:</b> {{.DestructionCount}}
+{{- template "collapsed-section" (section "destructions" .DestructionCount .Destructions) }}
+{{- template "refactor-sections" . }}
+{{- template "output-drift-sections" . }}
+{{- template "plan-footer" . }}
+{{- else }}
 
 :seedling: <b>Additions:</b> {{.AdditionCount}}
 <ul>
@@ -28,9 +160,7 @@
 <li><code>{{ . }}</code></li>
 {{- end}}
 </ul>
-</br>
-<b>Plan: </b> {{.AdditionCount}} to add, {{.ChangeCount}} to change, {{.ReplacementCount}} to replace and {{.DestructionCount}} to destroy.
-</br>
-
-See [Terraform Cloud Output]({{.TfcUrl}}) for more info.
-
+{{- template "refactor-sections" . }}
+{{- template "output-drift-sections" . }}
+{{- template "plan-footer" . }}
+{{- end }}
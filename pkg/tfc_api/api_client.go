@@ -3,6 +3,7 @@ package tfc_api
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/hashicorp/go-tfe"
@@ -12,10 +13,20 @@ import (
 //go:generate mockgen -source api_client.go -destination=../mocks/mock_tfc_api.go -package=mocks github.com/zapier/tfbuddy/pkg/tfc_api
 type ApiClient interface {
 	GetPlanOutput(id string) ([]byte, error)
+	GetPlanLogs(ctx context.Context, planID string) ([]byte, error)
+	GetApplyLogs(ctx context.Context, applyID string) ([]byte, error)
 	GetRun(id string) (*tfe.Run, error)
 	GetWorkspaceByName(ctx context.Context, org, name string) (*tfe.Workspace, error)
 	GetWorkspaceById(ctx context.Context, id string) (*tfe.Workspace, error)
+	ListWorkspaces(ctx context.Context, org string) ([]*tfe.Workspace, error)
 	CreateRunFromSource(opts *ApiRunOptions) (*tfe.Run, error)
+	ApplyRun(ctx context.Context, runID string, comment string) error
+	DiscardRun(ctx context.Context, runID string, comment string) error
+	CancelRun(ctx context.Context, runID string, comment string) error
+	ForceCancelRun(ctx context.Context, runID string, comment string) error
+	ListWorkspaceRuns(ctx context.Context, workspaceID string) ([]*tfe.Run, error)
+	GetPolicyOutcomes(ctx context.Context, runID string) ([]*PolicyOutcome, error)
+	OverridePolicies(ctx context.Context, runID string, comment string) error
 	LockUnlockWorkspace(ctx context.Context, workspace string, reason string, tag string, lock bool) error
 	AddTags(ctx context.Context, workspace string, prefix string, value string) error
 	RemoveTagsByQuery(ctx context.Context, workspace string, query string) error
@@ -50,7 +61,7 @@ func (t *TFCClient) GetRun(id string) (*tfe.Run, error) {
 		context.Background(),
 		id,
 		&tfe.RunReadOptions{
-			Include: []tfe.RunIncludeOpt{tfe.RunPlan, tfe.RunWorkspace, tfe.RunConfigVer, tfe.RunApply},
+			Include: []tfe.RunIncludeOpt{tfe.RunPlan, tfe.RunWorkspace, tfe.RunConfigVer, tfe.RunApply, tfe.RunCostEstimate},
 		},
 	)
 	if err != nil {
@@ -72,6 +83,53 @@ func (t *TFCClient) GetPlanOutput(id string) ([]byte, error) {
 	return b, nil
 }
 
+// GetPlanLogs returns the raw log output of a plan.
+func (t *TFCClient) GetPlanLogs(ctx context.Context, planID string) ([]byte, error) {
+	logs, err := t.Client.Plans.Logs(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(logs)
+}
+
+// GetApplyLogs returns the raw log output of an apply.
+func (t *TFCClient) GetApplyLogs(ctx context.Context, applyID string) ([]byte, error) {
+	logs, err := t.Client.Applies.Logs(ctx, applyID)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(logs)
+}
+
+// ApplyRun confirms a run that is waiting for confirmation, so that its plan is applied.
+func (t *TFCClient) ApplyRun(ctx context.Context, runID string, comment string) error {
+	return t.Client.Runs.Apply(ctx, runID, tfe.RunApplyOptions{Comment: tfe.String(comment)})
+}
+
+// DiscardRun discards a run that is waiting for confirmation, its plan will not be applied.
+func (t *TFCClient) DiscardRun(ctx context.Context, runID string, comment string) error {
+	return t.Client.Runs.Discard(ctx, runID, tfe.RunDiscardOptions{Comment: tfe.String(comment)})
+}
+
+// CancelRun interrupts a run that is planning or applying, or removes it from the queue.
+func (t *TFCClient) CancelRun(ctx context.Context, runID string, comment string) error {
+	return t.Client.Runs.Cancel(ctx, runID, tfe.RunCancelOptions{Comment: tfe.String(comment)})
+}
+
+// ForceCancelRun ends a run that did not stop after being canceled, once TFC allows force-canceling it.
+func (t *TFCClient) ForceCancelRun(ctx context.Context, runID string, comment string) error {
+	return t.Client.Runs.ForceCancel(ctx, runID, tfe.RunForceCancelOptions{Comment: tfe.String(comment)})
+}
+
+// ListWorkspaceRuns returns the most recent runs of a workspace, newest first.
+func (t *TFCClient) ListWorkspaceRuns(ctx context.Context, workspaceID string) ([]*tfe.Run, error) {
+	runs, err := t.Client.Runs.List(ctx, workspaceID, &tfe.RunListOptions{ListOptions: tfe.ListOptions{PageSize: 50}})
+	if err != nil {
+		return nil, err
+	}
+	return runs.Items, nil
+}
+
 func (t *TFCClient) GetWorkspaceById(ctx context.Context, id string) (*tfe.Workspace, error) {
 	return t.Client.Workspaces.ReadByID(ctx, id)
 }
@@ -85,6 +143,23 @@ func (t *TFCClient) GetWorkspaceByName(ctx context.Context, org, name string) (*
 	)
 }
 
+// ListWorkspaces returns all workspaces of an organization, with their lock state and tag names.
+func (t *TFCClient) ListWorkspaces(ctx context.Context, org string) ([]*tfe.Workspace, error) {
+	var workspaces []*tfe.Workspace
+	opts := &tfe.WorkspaceListOptions{ListOptions: tfe.ListOptions{PageSize: 100}}
+	for {
+		page, err := t.Client.Workspaces.List(ctx, org, opts)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, page.Items...)
+		if page.Pagination == nil || page.NextPage == 0 {
+			return workspaces, nil
+		}
+		opts.PageNumber = page.NextPage
+	}
+}
+
 func (t *TFCClient) LockUnlockWorkspace(ctx context.Context, workspaceID string, reason string, tag string, lock bool) error {
 
 	LockOptions := tfe.WorkspaceLockOptions{Reason: &reason}
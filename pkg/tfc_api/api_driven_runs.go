@@ -12,6 +12,8 @@ import (
 type ApiRunOptions struct {
 	// IsApply = true if this run is will auto apply
 	IsApply bool
+	// RequireConfirmation disables auto apply, the apply waits until the run is confirmed with ApplyRun
+	RequireConfirmation bool
 	// Path is the path to directory where repo source has been cloned
 	Path string
 	// Message is the Terraform Cloud run title.
@@ -43,7 +45,7 @@ func (c *TFCClient) CreateRunFromSource(opts *ApiRunOptions) (*tfe.Run, error) {
 		Message:              tfe.String(opts.Message),
 		ConfigurationVersion: cv,
 		Workspace:            ws,
-		AutoApply:            tfe.Bool(opts.IsApply),
+		AutoApply:            tfe.Bool(opts.IsApply && !opts.RequireConfirmation),
 	})
 	run.Workspace = ws
 	// TFC API is weird, it doesn't return the correct value for Speculative, so we override here.
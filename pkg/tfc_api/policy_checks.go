@@ -0,0 +1,163 @@
+package tfc_api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNoOverridablePolicies is returned when a run has no failed policy checks that can be overridden.
+var ErrNoOverridablePolicies = errors.New("the run has no failed policy checks that can be overridden")
+
+// PolicyOutcome is the result of a single Sentinel or OPA policy evaluated against a run.
+type PolicyOutcome struct {
+	// Name is the policy name, prefixed by its policy set
+	Name string
+	// EnforcementLevel is e.g. advisory, soft-mandatory or hard-mandatory (mandatory for OPA)
+	EnforcementLevel string
+	Passed           bool
+	// Output is the failure output of the policy
+	Output string
+}
+
+// GetPolicyOutcomes returns the results of the Sentinel policy checks & OPA policy evaluations of a run.
+func (t *TFCClient) GetPolicyOutcomes(ctx context.Context, runID string) ([]*PolicyOutcome, error) {
+	outcomes := []*PolicyOutcome{}
+
+	checks, err := t.Client.PolicyChecks.List(ctx, runID, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, pc := range checks.Items {
+		logs, err := t.Client.PolicyChecks.Logs(ctx, pc.ID)
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(logs)
+		if err != nil {
+			return nil, err
+		}
+		outcomes = append(outcomes, parseSentinelLog(string(b))...)
+	}
+
+	stages, err := t.Client.TaskStages.List(ctx, runID, nil)
+	if err != nil {
+		// task stages are not available on all TFC plans & TFE versions
+		log.Debug().Err(err).Str("runID", runID).Msg("could not list task stages")
+		return outcomes, nil
+	}
+	for _, stage := range stages.Items {
+		for _, pe := range stage.PolicyEvaluations {
+			sets, err := t.Client.PolicySetOutcomes.List(ctx, pe.ID, nil)
+			if err != nil {
+				return nil, err
+			}
+			for _, set := range sets.Items {
+				for _, o := range set.Outcomes {
+					outcomes = append(outcomes, &PolicyOutcome{
+						Name:             set.PolicySetName + "/" + o.PolicyName,
+						EnforcementLevel: string(o.EnforcementLevel),
+						Passed:           o.Status == "passed",
+						Output:           o.Description,
+					})
+				}
+			}
+		}
+	}
+	return outcomes, nil
+}
+
+// OverridePolicies overrides the soft failed Sentinel policy checks & OPA task stages of a run, so that it can be
+// applied.
+func (t *TFCClient) OverridePolicies(ctx context.Context, runID string, comment string) error {
+	overridden := 0
+
+	checks, err := t.Client.PolicyChecks.List(ctx, runID, nil)
+	if err != nil {
+		return err
+	}
+	for _, pc := range checks.Items {
+		if pc.Status != tfe.PolicySoftFailed || pc.Actions == nil || !pc.Actions.IsOverridable {
+			continue
+		}
+		if _, err := t.Client.PolicyChecks.Override(ctx, pc.ID); err != nil {
+			return err
+		}
+		overridden++
+	}
+
+	stages, err := t.Client.TaskStages.List(ctx, runID, nil)
+	if err != nil {
+		log.Debug().Err(err).Str("runID", runID).Msg("could not list task stages")
+	} else {
+		for _, stage := range stages.Items {
+			if stage.Actions == nil || stage.Actions.IsOverridable == nil || !*stage.Actions.IsOverridable {
+				continue
+			}
+			if _, err := t.Client.TaskStages.Override(ctx, stage.ID, tfe.TaskStageOverrideOptions{Comment: tfe.String(comment)}); err != nil {
+				return err
+			}
+			overridden++
+		}
+	}
+
+	if overridden == 0 {
+		return ErrNoOverridablePolicies
+	}
+	return nil
+}
+
+var (
+	sentinelPolicyRegex = regexp.MustCompile(`^## Policy \d+: (.+) \(([a-z-]+)\)$`)
+	sentinelResultRegex = regexp.MustCompile(`^Result: (true|false)$`)
+)
+
+// parseSentinelLog extracts the result of each policy from the log of a Sentinel policy check, e.g.
+//
+//	## Policy 1: networking/restrict-ingress.sentinel (soft-mandatory)
+//
+//	Result: false
+//
+//	FALSE - restrict-ingress.sentinel:12:1 - Rule "main"
+func parseSentinelLog(logs string) []*PolicyOutcome {
+	outcomes := []*PolicyOutcome{}
+	var current *PolicyOutcome
+	output := []string{}
+	flush := func() {
+		if current != nil {
+			current.Output = strings.TrimSpace(strings.Join(output, "\n"))
+			outcomes = append(outcomes, current)
+		}
+		output = []string{}
+	}
+
+	for _, line := range strings.Split(logs, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := sentinelPolicyRegex.FindStringSubmatch(line); m != nil {
+			flush()
+			current = &PolicyOutcome{Name: m[1], EnforcementLevel: m[2]}
+			continue
+		}
+		if current == nil || len(output) == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := sentinelResultRegex.FindStringSubmatch(line); m != nil && len(output) == 0 {
+			current.Passed = m[1] == "true"
+			continue
+		}
+		output = append(output, line)
+	}
+	flush()
+
+	for _, o := range outcomes {
+		if o.Passed {
+			o.Output = ""
+		}
+	}
+	return outcomes
+}
@@ -0,0 +1,47 @@
+package tfc_api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sentinelLog = `Sentinel Result: false
+
+This result means that Sentinel policies returned false and the protected
+behavior is not allowed by Sentinel policies.
+
+2 policies evaluated.
+
+## Policy 1: networking/restrict-ingress.sentinel (soft-mandatory)
+
+Result: false
+
+FALSE - restrict-ingress.sentinel:12:1 - Rule "main"
+  Description:
+    Security groups must not allow ingress from 0.0.0.0/0
+
+## Policy 2: cost/limit-monthly-cost.sentinel (advisory)
+
+Result: true
+
+TRUE - limit-monthly-cost.sentinel:8:1 - Rule "main"
+`
+
+func Test_parseSentinelLog(t *testing.T) {
+	want := []*PolicyOutcome{
+		{
+			Name:             "networking/restrict-ingress.sentinel",
+			EnforcementLevel: "soft-mandatory",
+			Passed:           false,
+			Output:           "FALSE - restrict-ingress.sentinel:12:1 - Rule \"main\"\n  Description:\n    Security groups must not allow ingress from 0.0.0.0/0",
+		},
+		{
+			Name:             "cost/limit-monthly-cost.sentinel",
+			EnforcementLevel: "advisory",
+			Passed:           true,
+		},
+	}
+	assert.Equal(t, want, parseSentinelLog(sentinelLog))
+	assert.Empty(t, parseSentinelLog("Sentinel Result: true\n"))
+}
@@ -127,11 +127,16 @@ func (h *NotificationHandler) processNotification(n *NotificationPayload) {
 		"organization": n.OrganizationName,
 		"workspace":    n.WorkspaceName,
 	}
+	timestamps := runStatusTimestamps(run)
+	if _, ok := timestamps[string(n.Notifications[0].RunStatus)]; !ok && !n.Notifications[0].RunUpdatedAt.IsZero() {
+		timestamps[string(n.Notifications[0].RunStatus)] = n.Notifications[0].RunUpdatedAt
+	}
 	err = h.stream.PublishTFRunEvent(&runstream.TFRunEvent{
-		Organization: n.OrganizationName,
-		Workspace:    n.WorkspaceName,
-		RunID:        n.RunId,
-		NewStatus:    string(n.Notifications[0].RunStatus),
+		Organization:     n.OrganizationName,
+		Workspace:        n.WorkspaceName,
+		RunID:            n.RunId,
+		NewStatus:        string(n.Notifications[0].RunStatus),
+		StatusTimestamps: timestamps,
 	})
 	if err != nil {
 		tfcNotificationPublishFailed.With(labels).Inc()
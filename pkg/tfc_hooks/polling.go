@@ -25,10 +25,11 @@ func (p *NotificationHandler) pollingStreamCallback(task runstream.RunPollingTas
 	if string(run.Status) != task.GetLastStatus() {
 		// Publish new RunEvent
 		err = p.stream.PublishTFRunEvent(&runstream.TFRunEvent{
-			Organization: run.Workspace.Organization.Name,
-			Workspace:    run.Workspace.Name,
-			RunID:        run.ID,
-			NewStatus:    string(run.Status),
+			Organization:     run.Workspace.Organization.Name,
+			Workspace:        run.Workspace.Name,
+			RunID:            run.ID,
+			NewStatus:        string(run.Status),
+			StatusTimestamps: runStatusTimestamps(run),
 		})
 	}
 
@@ -61,6 +62,8 @@ func isRunning(run *tfe.Run) bool {
 		fallthrough
 	case "cost_estimating":
 		fallthrough
+	case "cost_estimated":
+		fallthrough
 	case "plan_queued":
 		fallthrough
 	case "policy_checking":
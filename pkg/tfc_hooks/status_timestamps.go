@@ -0,0 +1,47 @@
+package tfc_hooks
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-tfe"
+)
+
+// runStatusTimestamps returns the times TFC reports the run entered its statuses, keyed by status. The run entered
+// pending when it was created.
+func runStatusTimestamps(run *tfe.Run) map[string]time.Time {
+	timestamps := map[string]time.Time{}
+	if run == nil {
+		return timestamps
+	}
+	add := func(status tfe.RunStatus, at time.Time) {
+		if !at.IsZero() {
+			timestamps[string(status)] = at
+		}
+	}
+	add(tfe.RunPending, run.CreatedAt)
+	if ts := run.StatusTimestamps; ts != nil {
+		add(tfe.RunApplied, ts.AppliedAt)
+		add(tfe.RunApplying, ts.ApplyingAt)
+		add(tfe.RunApplyQueued, ts.ApplyQueuedAt)
+		add(tfe.RunCanceled, ts.CanceledAt)
+		add(tfe.RunConfirmed, ts.ConfirmedAt)
+		add(tfe.RunCostEstimated, ts.CostEstimatedAt)
+		add(tfe.RunCostEstimating, ts.CostEstimatingAt)
+		add(tfe.RunDiscarded, ts.DiscardedAt)
+		add(tfe.RunErrored, ts.ErroredAt)
+		add(tfe.RunFetching, ts.FetchingAt)
+		add(tfe.RunFetchingCompleted, ts.FetchedAt)
+		add(tfe.RunPlannedAndFinished, ts.PlannedAndFinishedAt)
+		add(tfe.RunPlanned, ts.PlannedAt)
+		add(tfe.RunPlanning, ts.PlanningAt)
+		add(tfe.RunPlanQueued, ts.PlanQueuedAt)
+		add(tfe.RunPolicyChecked, ts.PolicyCheckedAt)
+		add(tfe.RunPolicySoftFailed, ts.PolicySoftFailedAt)
+		add(tfe.RunPostPlanCompleted, ts.PostPlanCompletedAt)
+		add(tfe.RunPostPlanRunning, ts.PostPlanRunningAt)
+		add(tfe.RunPrePlanCompleted, ts.PrePlanCompletedAt)
+		add(tfe.RunPrePlanRunning, ts.PrePlanRunningAt)
+		add(tfe.RunQueuing, ts.QueuingAt)
+	}
+	return timestamps
+}
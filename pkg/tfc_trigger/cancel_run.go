@@ -0,0 +1,81 @@
+package tfc_trigger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// cancelRuns cancels the workspace's runs of the MR that are in progress. Runs that were already canceled but did not
+// stop are force-canceled, once TFC allows it, by commenting the cancel command again. The lock tag acquired by canceled applies is released. The MR comments
+// are updated by the canceled status event of each run.
+func (t *TFCTrigger) cancelRuns(org, wsName string) error {
+	runs, err := t.runstream.ListMRRunMeta(t.cfg.GetProjectNameWithNamespace(), t.cfg.GetMergeRequestIID())
+	if err != nil {
+		return t.handleError(transientStreamError(err), "could not read the runs of the MR")
+	}
+
+	comment := fmt.Sprintf("TF Buddy: canceled from MR !%d", t.cfg.GetMergeRequestIID())
+	var canceled []string
+	releaseLock := false
+	for _, rmd := range runs {
+		if rmd.GetOrganization() != org || rmd.GetWorkspace() != wsName {
+			continue
+		}
+		run, err := t.tfc.GetRun(rmd.GetRunID())
+		if err != nil {
+			return t.handleError(transientTFCError(err), fmt.Sprintf("could not get run %s", rmd.GetRunID()))
+		}
+		if run.Actions == nil {
+			continue
+		}
+
+		forced := false
+		switch {
+		case run.Actions.IsForceCancelable:
+			err = t.tfc.ForceCancelRun(context.Background(), run.ID, comment)
+			forced = true
+		case run.Actions.IsCancelable:
+			err = t.tfc.CancelRun(context.Background(), run.ID, comment)
+		default:
+			continue
+		}
+		if err != nil {
+			return t.handleError(transientTFCError(err), fmt.Sprintf("could not cancel run %s", run.ID))
+		}
+		log.Info().Str("runID", run.ID).Bool("forced", forced).Msg("canceled run")
+		if forced {
+			canceled = append(canceled, fmt.Sprintf("`%s` (forced)", run.ID))
+		} else {
+			canceled = append(canceled, fmt.Sprintf("`%s`", run.ID))
+		}
+		releaseLock = releaseLock || rmd.GetAction() == ApplyAction.String()
+	}
+	if len(canceled) == 0 {
+		return t.handleError(ErrNoActiveRun, fmt.Sprintf("could not cancel the runs of workspace %s/%s", org, wsName))
+	}
+
+	if releaseLock {
+		ws, err := t.tfc.GetWorkspaceByName(context.Background(), org, wsName)
+		if err != nil {
+			return t.handleError(transientTFCError(err), "could not get Workspace from TFC API")
+		}
+		tag := fmt.Sprintf("%s-%d", tfPrefix, t.cfg.GetMergeRequestIID())
+		if err := t.tfc.RemoveTagsByQuery(context.Background(), ws.ID, tag); err != nil {
+			return t.handleError(transientTFCError(err), "Error removing locking tag from workspace")
+		}
+	}
+
+	_, err = t.gl.CreateMergeRequestDiscussion(t.cfg.GetMergeRequestIID(),
+		t.cfg.GetProjectNameWithNamespace(),
+		fmt.Sprintf("Canceled runs %s for Workspace `%s/%s`. TF Buddy doesn't force-cancel runs on its own: if a run "+
+			"doesn't stop, comment `tfc cancel -w %s` again once Terraform Cloud allows force-canceling it.",
+			strings.Join(canceled, ", "), org, wsName, wsName),
+	)
+	if err != nil {
+		return t.handleError(err, "Error posting successful run cancellation")
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package tfc_trigger_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-tfe"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+)
+
+func TestTFCEvents_CancelRuns(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{}, t)
+
+	testSuite.MockStreamClient.EXPECT().ListMRRuns(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return([]*runstream.RunIndexEntry{
+		{RunID: "run-apply", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+		{RunID: "run-applied", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+	}, nil)
+	testSuite.MockStreamClient.EXPECT().ListMRRunMeta(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return([]runstream.RunMetadata{
+		&runstream.TFRunMetadata{RunID: "run-plan", Action: "plan", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+		&runstream.TFRunMetadata{RunID: "run-applied", Action: "apply", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+		&runstream.TFRunMetadata{RunID: "run-apply", Action: "apply", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+		&runstream.TFRunMetadata{RunID: "run-other-ws", Action: "apply", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: "other-ws"},
+	}, nil)
+	testSuite.MockApiClient.EXPECT().GetRun("run-plan").Return(&tfe.Run{ID: "run-plan", Status: tfe.RunPlanning, Actions: &tfe.RunActions{IsCancelable: true}}, nil)
+	testSuite.MockApiClient.EXPECT().GetRun("run-applied").Return(&tfe.Run{ID: "run-applied", Status: tfe.RunApplied, Actions: &tfe.RunActions{}}, nil)
+	testSuite.MockApiClient.EXPECT().GetRun("run-apply").Return(&tfe.Run{ID: "run-apply", Status: tfe.RunApplying, Actions: &tfe.RunActions{IsForceCancelable: true}}, nil)
+	testSuite.MockApiClient.EXPECT().CancelRun(gomock.Any(), "run-plan", "TF Buddy: canceled from MR !101").Return(nil)
+	testSuite.MockApiClient.EXPECT().ForceCancelRun(gomock.Any(), "run-apply", "TF Buddy: canceled from MR !101").Return(nil)
+	testSuite.MockApiClient.EXPECT().RemoveTagsByQuery(gomock.Any(), "service-tfbuddy", "tfbuddylock-101").Return(nil)
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS,
+		"Canceled runs `run-plan`, `run-apply` (forced) for Workspace `zapier-test/service-tfbuddy`. TF Buddy doesn't "+
+			"force-cancel runs on its own: if a run doesn't stop, comment `tfc cancel -w service-tfbuddy` again once "+
+			"Terraform Cloud allows force-canceling it.").Return(testSuite.MockGitDisc, nil)
+	testSuite.InitTestSuite()
+
+	trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+		Action:                   tfc_trigger.CancelAction,
+		Branch:                   "test-branch",
+		CommitSHA:                "abcd12233",
+		ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:          testSuite.MetaData.MRIID,
+		TriggerSource:            tfc_trigger.CommentTrigger,
+	})
+	triggeredWS, err := trigger.TriggerTFCEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggeredWS.Executed) != 1 {
+		t.Fatal("expected the runs to be canceled", triggeredWS.Errored)
+	}
+}
+
+func TestTFCEvents_CancelRunsTransientError(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(testSuite *mocks.TestSuite)
+	}{
+		{
+			name: "MR runs",
+			expect: func(testSuite *mocks.TestSuite) {
+				testSuite.MockStreamClient.EXPECT().ListMRRuns(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return(nil, errors.New("nats: timeout"))
+			},
+		},
+		{
+			name: "run metadata",
+			expect: func(testSuite *mocks.TestSuite) {
+				testSuite.MockStreamClient.EXPECT().ListMRRuns(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return([]*runstream.RunIndexEntry{
+					{RunID: "run-plan", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+				}, nil)
+				testSuite.MockStreamClient.EXPECT().ListMRRunMeta(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return(nil, errors.New("nats: timeout"))
+			},
+		},
+		{
+			name: "TFC run",
+			expect: func(testSuite *mocks.TestSuite) {
+				testSuite.MockStreamClient.EXPECT().ListMRRuns(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return([]*runstream.RunIndexEntry{
+					{RunID: "run-plan", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+				}, nil)
+				testSuite.MockStreamClient.EXPECT().ListMRRunMeta(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return([]runstream.RunMetadata{
+					&runstream.TFRunMetadata{RunID: "run-plan", Action: "plan", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+				}, nil)
+				testSuite.MockApiClient.EXPECT().GetRun("run-plan").Return(nil, errors.New("503 Service Unavailable"))
+			},
+		},
+		{
+			name: "cancel",
+			expect: func(testSuite *mocks.TestSuite) {
+				testSuite.MockStreamClient.EXPECT().ListMRRuns(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return([]*runstream.RunIndexEntry{
+					{RunID: "run-plan", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+				}, nil)
+				testSuite.MockStreamClient.EXPECT().ListMRRunMeta(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return([]runstream.RunMetadata{
+					&runstream.TFRunMetadata{RunID: "run-plan", Action: "plan", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+				}, nil)
+				testSuite.MockApiClient.EXPECT().GetRun("run-plan").Return(&tfe.Run{ID: "run-plan", Status: tfe.RunPlanning, Actions: &tfe.RunActions{IsCancelable: true}}, nil)
+				testSuite.MockApiClient.EXPECT().CancelRun(gomock.Any(), "run-plan", gomock.Any()).Return(errors.New("503 Service Unavailable"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCancelRunsTransientError(t, tt.expect)
+		})
+	}
+}
+
+func testCancelRunsTransientError(t *testing.T, expect func(testSuite *mocks.TestSuite)) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{}, t)
+
+	// the hook is processed again, so the error isn't reported on the MR
+	expect(testSuite)
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestComment(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	testSuite.InitTestSuite()
+
+	trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+		Action:                   tfc_trigger.CancelAction,
+		Branch:                   "test-branch",
+		CommitSHA:                "abcd12233",
+		ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:          testSuite.MetaData.MRIID,
+		TriggerSource:            tfc_trigger.CommentTrigger,
+	})
+	_, err := trigger.TriggerTFCEvents()
+	if !tfc_trigger.IsTransient(err) {
+		t.Fatalf("expected a transient error, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package tfc_trigger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/plan_policy"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+)
+
+// ConfirmApply is called for each status update of a run. Apply runs of workspaces with protected resources or
+// policies are created without auto apply: once such a run waits for confirmation, its plan is checked and the run is
+// discarded if it destroys or replaces protected resources or fails a policy, or confirmed otherwise.
+func ConfirmApply(tfc tfc_api.ApiClient, run *tfe.Run, rmd runstream.RunMetadata) error {
+	if !rmd.GetConfirmApply() || run.AutoApply || run.Actions == nil || !run.Actions.IsConfirmable {
+		return nil
+	}
+
+	ctx := context.Background()
+	reasons, err := applyBlockedReasons(tfc, run, rmd)
+	if err != nil {
+		// don't leave the run waiting for a confirmation that will never come
+		log.Error().Err(err).Str("runID", run.ID).Msg("could not check plan, discarding apply")
+		return tfc.DiscardRun(ctx, run.ID, fmt.Sprintf("TF Buddy: could not check the plan: %v", err))
+	}
+	if len(reasons) > 0 {
+		log.Info().Str("runID", run.ID).Strs("reasons", reasons).Msg("discarding apply")
+		return tfc.DiscardRun(ctx, run.ID, "TF Buddy: "+strings.Join(reasons, "; "))
+	}
+	log.Info().Str("runID", run.ID).Msg("confirming apply, the plan passed all checks")
+	return tfc.ApplyRun(ctx, run.ID, "TF Buddy: no protected resources are destroyed or replaced and all policies passed")
+}
+
+// applyBlockedReasons checks the run's plan for destroyed protected resources & policy failures.
+func applyBlockedReasons(tfc tfc_api.ApiClient, run *tfe.Run, rmd runstream.RunMetadata) ([]string, error) {
+	b, err := tfc.GetPlanOutput(run.Plan.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get plan JSON: %w", err)
+	}
+
+	reasons := []string{}
+	if runstream.IsProtectedApply(rmd) {
+		destroyed, err := terraform_plan.DestroyedProtectedResources(b, rmd.GetProtectedResources())
+		if err != nil {
+			return nil, fmt.Errorf("could not check plan for protected resources: %w", err)
+		}
+		if len(destroyed) > 0 {
+			reasons = append(reasons, fmt.Sprintf("the plan destroys or replaces protected resources: %s", strings.Join(destroyed, ", ")))
+		}
+	}
+
+	result, err := plan_policy.Evaluate(plan_policy.Policies(rmd.GetPolicies()), b)
+	if err != nil {
+		return nil, err
+	}
+	if result.Failed() {
+		reasons = append(reasons, fmt.Sprintf("the plan fails policies: %s", strings.Join(result.Deny, ", ")))
+	}
+	return reasons, nil
+}
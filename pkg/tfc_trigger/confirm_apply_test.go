@@ -0,0 +1,182 @@
+package tfc_trigger_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-tfe"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+)
+
+const denyReplace = `
+package tfbuddy
+
+deny[msg] {
+	rc := input.resource_changes[_]
+	rc.address == "random_pet.rando[0]"
+	rc.change.actions[_] == "delete"
+	msg := sprintf("%s must not be replaced", [rc.address])
+}
+`
+
+func TestConfirmApply(t *testing.T) {
+	plan, err := os.ReadFile("../terraform_plan/testdata/TestPresentPlanChangesAsMarkdown/replace.tfplan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		rmd       *runstream.TFRunMetadata
+		run       *tfe.Run
+		expectAPI func(tfc *mocks.MockApiClient)
+	}{
+		{
+			name: "discard",
+			rmd: &runstream.TFRunMetadata{
+				Action:             "apply",
+				ProtectedResources: &terraform_plan.ProtectedResources{Types: []string{"random_integer"}},
+				ConfirmApply:       true,
+			},
+			run: &tfe.Run{ID: "run-1", Plan: &tfe.Plan{ID: "plan-1"}, Actions: &tfe.RunActions{IsConfirmable: true}},
+			expectAPI: func(tfc *mocks.MockApiClient) {
+				tfc.EXPECT().GetPlanOutput("plan-1").Return(plan, nil)
+				tfc.EXPECT().DiscardRun(gomock.Any(), "run-1", gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "confirm",
+			rmd: &runstream.TFRunMetadata{
+				Action:             "apply",
+				ProtectedResources: &terraform_plan.ProtectedResources{Types: []string{"aws_db_instance"}},
+				ConfirmApply:       true,
+			},
+			run: &tfe.Run{ID: "run-1", Plan: &tfe.Plan{ID: "plan-1"}, Actions: &tfe.RunActions{IsConfirmable: true}},
+			expectAPI: func(tfc *mocks.MockApiClient) {
+				tfc.EXPECT().GetPlanOutput("plan-1").Return(plan, nil)
+				tfc.EXPECT().ApplyRun(gomock.Any(), "run-1", gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "policy failure",
+			rmd: &runstream.TFRunMetadata{
+				Action:       "apply",
+				Policies:     map[string]string{"policies/replace.rego": denyReplace},
+				ConfirmApply: true,
+			},
+			run: &tfe.Run{ID: "run-1", Plan: &tfe.Plan{ID: "plan-1"}, Actions: &tfe.RunActions{IsConfirmable: true}},
+			expectAPI: func(tfc *mocks.MockApiClient) {
+				tfc.EXPECT().GetPlanOutput("plan-1").Return(plan, nil)
+				tfc.EXPECT().DiscardRun(gomock.Any(), "run-1", "TF Buddy: the plan fails policies: random_pet.rando[0] must not be replaced").Return(nil)
+			},
+		},
+		{
+			name: "allow destroy",
+			rmd: &runstream.TFRunMetadata{
+				Action:             "apply",
+				ProtectedResources: &terraform_plan.ProtectedResources{Types: []string{"random_integer"}},
+				AllowDestroy:       true,
+			},
+			run:       &tfe.Run{ID: "run-1", AutoApply: true, Plan: &tfe.Plan{ID: "plan-1"}, Actions: &tfe.RunActions{}},
+			expectAPI: func(tfc *mocks.MockApiClient) {},
+		},
+		{
+			name: "not confirmable",
+			rmd: &runstream.TFRunMetadata{
+				Action:             "apply",
+				ProtectedResources: &terraform_plan.ProtectedResources{Types: []string{"random_integer"}},
+				ConfirmApply:       true,
+			},
+			run:       &tfe.Run{ID: "run-1", Plan: &tfe.Plan{ID: "plan-1"}, Actions: &tfe.RunActions{}},
+			expectAPI: func(tfc *mocks.MockApiClient) {},
+		},
+		{
+			name:      "plan",
+			rmd:       &runstream.TFRunMetadata{Action: "plan"},
+			run:       &tfe.Run{ID: "run-1", Plan: &tfe.Plan{ID: "plan-1"}, Actions: &tfe.RunActions{IsConfirmable: true}},
+			expectAPI: func(tfc *mocks.MockApiClient) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			tfc := mocks.NewMockApiClient(mockCtrl)
+			tt.expectAPI(tfc)
+
+			if err := tfc_trigger.ConfirmApply(tfc, tt.run, tt.rmd); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTFCEvents_ApplyProtectedOnTargetBranch(t *testing.T) {
+	plan, err := os.ReadFile("../terraform_plan/testdata/TestPresentPlanChangesAsMarkdown/replace.tfplan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unprotected := &tfc_trigger.ProjectConfig{
+		Workspaces: []*tfc_trigger.TFCWorkspace{{
+			Name:         mocks.TF_WORKSPACE_NAME,
+			Organization: mocks.TF_ORGANIZATION_NAME,
+			Mode:         "apply-before-merge",
+		}}}
+	protected := &tfc_trigger.ProjectConfig{
+		ProtectedResources: &terraform_plan.ProtectedResources{Types: []string{"random_integer"}},
+		Workspaces: []*tfc_trigger.TFCWorkspace{{
+			Name:         mocks.TF_WORKSPACE_NAME,
+			Organization: mocks.TF_ORGANIZATION_NAME,
+			Mode:         "apply-before-merge",
+		}}}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	// the MR branch removes the protected resources of the target branch
+	testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{ProjectConfig: unprotected, TargetProjectConfig: protected}, t)
+	testSuite.MockGitRepo.EXPECT().GetModifiedFileNamesBetweenCommits(testSuite.MetaData.CommonSHA, "main").Return([]string{}, nil)
+	testSuite.MockApiClient.EXPECT().CreateRunFromSource(gomock.Any()).DoAndReturn(func(opts *tfc_api.ApiRunOptions) (*tfe.Run, error) {
+		if !opts.RequireConfirmation {
+			t.Error("expected the apply to wait for the protected resources to be checked")
+		}
+		return &tfe.Run{
+			ID:                   "run-1",
+			Workspace:            &tfe.Workspace{Name: mocks.TF_WORKSPACE_NAME, Organization: &tfe.Organization{Name: mocks.TF_ORGANIZATION_NAME}},
+			ConfigurationVersion: &tfe.ConfigurationVersion{Speculative: false},
+		}, nil
+	})
+	var rmd runstream.RunMetadata
+	testSuite.MockStreamClient.EXPECT().AddRunMeta(gomock.Any()).DoAndReturn(func(meta runstream.RunMetadata) error {
+		rmd = meta
+		return nil
+	})
+	testSuite.InitTestSuite()
+
+	trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+		Action:                   tfc_trigger.ApplyAction,
+		Branch:                   testSuite.MetaData.SourceBranch,
+		CommitSHA:                "abcd12233",
+		ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:          testSuite.MetaData.MRIID,
+		TriggerSource:            tfc_trigger.CommentTrigger,
+	})
+	if _, err := trigger.TriggerTFCEvents(); err != nil {
+		t.Fatal(err)
+	}
+	if rmd == nil {
+		t.Fatal("expected run metadata to be published")
+	}
+
+	// the plan replaces a protected resource, so the apply is discarded
+	testSuite.MockApiClient.EXPECT().GetPlanOutput("plan-1").Return(plan, nil)
+	testSuite.MockApiClient.EXPECT().DiscardRun(gomock.Any(), "run-1", gomock.Any()).Return(nil)
+	run := &tfe.Run{ID: "run-1", Plan: &tfe.Plan{ID: "plan-1"}, Actions: &tfe.RunActions{IsConfirmable: true}}
+	if err := tfc_trigger.ConfirmApply(testSuite.MockApiClient, run, rmd); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,68 @@
+package tfc_trigger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+// confirmableStatuses are the statuses of apply runs waiting for confirmation.
+var confirmableStatuses = map[tfe.RunStatus]bool{
+	tfe.RunPlanned:       true,
+	tfe.RunCostEstimated: true,
+	tfe.RunPolicyChecked: true,
+}
+
+// confirmOrDiscardRun confirms or discards the workspace's apply run of the MR that is waiting for confirmation.
+func (t *TFCTrigger) confirmOrDiscardRun(org, wsName string, confirm bool) error {
+	run, rmd, err := t.findConfirmableRun(org, wsName)
+	if err != nil {
+		return t.handleError(err, fmt.Sprintf("could not find a run waiting for confirmation for workspace %s/%s", org, wsName))
+	}
+
+	comment := fmt.Sprintf("TF Buddy: %sed from MR !%d", t.cfg.GetAction(), t.cfg.GetMergeRequestIID())
+	if confirm {
+		if rmd.GetConfirmApply() {
+			return t.handleError(ErrRunCheckedByTFBuddy, fmt.Sprintf("refusing to confirm run %s", run.ID))
+		}
+		err = t.tfc.ApplyRun(context.Background(), run.ID, comment)
+	} else {
+		err = t.tfc.DiscardRun(context.Background(), run.ID, comment)
+	}
+	if err != nil {
+		return t.handleError(err, fmt.Sprintf("could not %s run %s", t.cfg.GetAction(), run.ID))
+	}
+
+	_, err = t.gl.CreateMergeRequestDiscussion(t.cfg.GetMergeRequestIID(),
+		t.cfg.GetProjectNameWithNamespace(),
+		fmt.Sprintf("Successfully %sed run `%s` for Workspace `%s/%s`.", t.cfg.GetAction(), run.ID, org, wsName),
+	)
+	if err != nil {
+		return t.handleError(err, fmt.Sprintf("Error posting successful run %s", t.cfg.GetAction()))
+	}
+	return nil
+}
+
+// findConfirmableRun looks up the MR's apply runs of the workspace in the run metadata and returns the one waiting for
+// confirmation.
+func (t *TFCTrigger) findConfirmableRun(org, wsName string) (*tfe.Run, runstream.RunMetadata, error) {
+	runs, err := t.runstream.ListMRRunMeta(t.cfg.GetProjectNameWithNamespace(), t.cfg.GetMergeRequestIID())
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, rmd := range runs {
+		if rmd.GetOrganization() != org || rmd.GetWorkspace() != wsName || rmd.GetAction() != ApplyAction.String() {
+			continue
+		}
+		run, err := t.tfc.GetRun(rmd.GetRunID())
+		if err != nil {
+			return nil, nil, err
+		}
+		if confirmableStatuses[run.Status] {
+			return run, rmd, nil
+		}
+	}
+	return nil, nil, ErrNoConfirmableRun
+}
@@ -0,0 +1,107 @@
+package tfc_trigger_test
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-tfe"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+)
+
+func TestTFCEvents_ConfirmOrDiscardRun(t *testing.T) {
+	runs := func(confirmApply bool) []runstream.RunMetadata {
+		return []runstream.RunMetadata{
+			&runstream.TFRunMetadata{RunID: "run-plan", Action: "plan", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+			&runstream.TFRunMetadata{RunID: "run-other-ws", Action: "apply", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: "other-ws"},
+			&runstream.TFRunMetadata{RunID: "run-applied", Action: "apply", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+			&runstream.TFRunMetadata{RunID: "run-planned", Action: "apply", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME, ConfirmApply: confirmApply},
+		}
+	}
+	tests := []struct {
+		name     string
+		action   tfc_trigger.TriggerAction
+		runs     []runstream.RunMetadata
+		expect   func(ts *mocks.TestSuite)
+		executed bool
+	}{
+		{
+			name:   "confirm",
+			action: tfc_trigger.ConfirmAction,
+			runs:   runs(false),
+			expect: func(ts *mocks.TestSuite) {
+				ts.MockApiClient.EXPECT().ApplyRun(gomock.Any(), "run-planned", "TF Buddy: confirmed from MR !101").Return(nil)
+				ts.MockGitClient.EXPECT().CreateMergeRequestDiscussion(ts.MetaData.MRIID, ts.MetaData.ProjectNameNS,
+					"Successfully confirmed run `run-planned` for Workspace `zapier-test/service-tfbuddy`.").Return(ts.MockGitDisc, nil)
+			},
+			executed: true,
+		},
+		{
+			name:   "discard",
+			action: tfc_trigger.DiscardAction,
+			runs:   runs(false),
+			expect: func(ts *mocks.TestSuite) {
+				ts.MockApiClient.EXPECT().DiscardRun(gomock.Any(), "run-planned", "TF Buddy: discarded from MR !101").Return(nil)
+				ts.MockGitClient.EXPECT().CreateMergeRequestDiscussion(ts.MetaData.MRIID, ts.MetaData.ProjectNameNS,
+					"Successfully discarded run `run-planned` for Workspace `zapier-test/service-tfbuddy`.").Return(ts.MockGitDisc, nil)
+			},
+			executed: true,
+		},
+		{
+			name:   "confirm run checked by TF Buddy",
+			action: tfc_trigger.ConfirmAction,
+			runs:   runs(true),
+			expect: func(ts *mocks.TestSuite) {
+				ts.MockGitClient.EXPECT().CreateMergeRequestComment(ts.MetaData.MRIID, ts.MetaData.ProjectNameNS,
+					"Error: refusing to confirm run run-planned: the run is confirmed or discarded by TF Buddy once its plan has been checked").Return(nil)
+			},
+			executed: false,
+		},
+		{
+			name:   "no run waiting for confirmation",
+			action: tfc_trigger.ConfirmAction,
+			runs:   runs(false)[:3],
+			expect: func(ts *mocks.TestSuite) {
+				ts.MockGitClient.EXPECT().CreateMergeRequestComment(ts.MetaData.MRIID, ts.MetaData.ProjectNameNS,
+					"Error: could not find a run waiting for confirmation for workspace zapier-test/service-tfbuddy: no run of this MR is waiting for confirmation").Return(nil)
+			},
+			executed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{}, t)
+
+			testSuite.MockStreamClient.EXPECT().ListMRRuns(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return([]*runstream.RunIndexEntry{
+				{RunID: "run-planned", Organization: mocks.TF_ORGANIZATION_NAME, Workspace: mocks.TF_WORKSPACE_NAME},
+			}, nil)
+			testSuite.MockStreamClient.EXPECT().ListMRRunMeta(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return(tt.runs, nil)
+			testSuite.MockApiClient.EXPECT().GetRun("run-applied").Return(&tfe.Run{ID: "run-applied", Status: tfe.RunApplied}, nil)
+			testSuite.MockApiClient.EXPECT().GetRun("run-planned").Return(&tfe.Run{ID: "run-planned", Status: tfe.RunPolicyChecked}, nil).AnyTimes()
+			tt.expect(testSuite)
+			testSuite.InitTestSuite()
+
+			trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+				Action:                   tt.action,
+				Branch:                   "test-branch",
+				CommitSHA:                "abcd12233",
+				ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+				MergeRequestIID:          testSuite.MetaData.MRIID,
+				TriggerSource:            tfc_trigger.CommentTrigger,
+			})
+			triggeredWS, err := trigger.TriggerTFCEvents()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.executed && len(triggeredWS.Executed) != 1 {
+				t.Fatal("expected the run to be "+tt.action.String()+"ed", triggeredWS.Errored)
+			}
+			if !tt.executed && len(triggeredWS.Errored) != 1 {
+				t.Fatal("expected the workspace to error", triggeredWS.Executed)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package tfc_trigger
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+)
+
+// checkCostApproval blocks applies while the total monthly cost increase of the MR commit's plans is above the
+// project's threshold and the MR hasn't been approved by one of the cost approvers. The cost estimates are read from
+// TFC, and the apply is refused until every plan of the commit has a finished cost estimate.
+func (t *TFCTrigger) checkCostApproval(workspaces []*TFCWorkspace, ca *CostApproval) error {
+	if ca == nil {
+		return nil
+	}
+	delta, err := t.commitCostDelta(workspaces)
+	if err != nil {
+		return err
+	}
+	if delta <= ca.Threshold {
+		return nil
+	}
+
+	approvals, err := t.gl.GetMergeRequestApprovals(t.cfg.GetMergeRequestIID(), t.cfg.GetProjectNameWithNamespace())
+	if err != nil {
+		return t.handleError(err, "could not read MR approvals to check the cost approval")
+	}
+	for _, approver := range approvals.GetApprovers() {
+		for _, a := range ca.Approvers {
+			if strings.EqualFold(approver, a) {
+				return nil
+			}
+		}
+	}
+	return t.handleError(ErrCostApprovalMissing, fmt.Sprintf(
+		"the monthly cost increases by $%.2f, above the threshold of $%.2f. Applying requires the approval of one of: %s",
+		delta, ca.Threshold, strings.Join(ca.Approvers, ", "),
+	))
+}
+
+// commitCostDelta returns the total monthly cost change of the latest run of each workspace planned for the MR commit.
+// The triggered workspaces must have been planned for the commit.
+func (t *TFCTrigger) commitCostDelta(workspaces []*TFCWorkspace) (float64, error) {
+	entries, err := t.runstream.ListMRRuns(t.cfg.GetProjectNameWithNamespace(), t.cfg.GetMergeRequestIID())
+	if err != nil {
+		return 0, t.handleError(transientStreamError(err), "could not list the MR runs to check the cost estimate")
+	}
+	// the entries are ordered newest first, keep the latest run of each workspace
+	latest := map[string]*runstream.RunIndexEntry{}
+	for _, entry := range entries {
+		key := fmt.Sprintf("%s/%s", entry.Organization, entry.Workspace)
+		if _, ok := latest[key]; ok || entry.CommitSHA != t.cfg.GetCommitSHA() {
+			continue
+		}
+		latest[key] = entry
+	}
+
+	missing := []string{}
+	for _, ws := range workspaces {
+		if _, ok := latest[fmt.Sprintf("%s/%s", ws.Organization, ws.Name)]; !ok {
+			missing = append(missing, ws.Name)
+		}
+	}
+
+	delta := 0.0
+	for _, entry := range latest {
+		run, err := t.tfc.GetRun(entry.RunID)
+		if err != nil {
+			return 0, t.handleError(transientTFCError(err), "could not read the cost estimate of run "+entry.RunID)
+		}
+		if run.CostEstimate == nil || run.CostEstimate.Status != tfe.CostEstimateFinished {
+			missing = append(missing, entry.Workspace)
+			continue
+		}
+		d, err := strconv.ParseFloat(run.CostEstimate.DeltaMonthlyCost, 64)
+		if err != nil {
+			return 0, t.handleError(err, "could not parse the cost estimate of run "+entry.RunID)
+		}
+		delta += d
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return 0, t.handleError(ErrCostEstimateMissing, fmt.Sprintf(
+			"the project requires a cost approval, plan the MR and wait for the cost estimate of %s before applying",
+			strings.Join(missing, ", "),
+		))
+	}
+	return delta, nil
+}
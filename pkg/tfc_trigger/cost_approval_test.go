@@ -0,0 +1,128 @@
+package tfc_trigger_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-tfe"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+)
+
+func TestTFCEvents_ApplyCostApproval(t *testing.T) {
+	finished := func(delta string) *tfe.CostEstimate {
+		return &tfe.CostEstimate{Status: tfe.CostEstimateFinished, DeltaMonthlyCost: delta}
+	}
+	tests := []struct {
+		name         string
+		commitSHA    string
+		vcsProvider  string
+		olderCommit  bool
+		costEstimate *tfe.CostEstimate
+		approvers    []string
+		wantErr      error
+		wantComment  string
+	}{
+		{name: "below threshold", costEstimate: finished("80")},
+		{name: "approved by cost approver", costEstimate: finished("150"), approvers: []string{"bob", "FinOps-Alice"}},
+		{
+			name:         "missing cost approval",
+			costEstimate: finished("150"),
+			approvers:    []string{"bob"},
+			wantErr:      tfc_trigger.ErrCostApprovalMissing,
+			wantComment:  "Error: the monthly cost increases by $150.00, above the threshold of $100.00. Applying requires the approval of one of: finops-alice: " + tfc_trigger.ErrCostApprovalMissing.Error(),
+		},
+		{
+			name:         "pending cost estimate",
+			costEstimate: &tfe.CostEstimate{Status: tfe.CostEstimateQueued},
+			wantErr:      tfc_trigger.ErrCostEstimateMissing,
+			wantComment:  "Error: the project requires a cost approval, plan the MR and wait for the cost estimate of service-tfbuddy before applying: " + tfc_trigger.ErrCostEstimateMissing.Error(),
+		},
+		{
+			// the PR head commit is applied, not the plans of the older commits of the PR
+			name:         "github PR head commit",
+			vcsProvider:  "github",
+			olderCommit:  true,
+			costEstimate: finished("80"),
+		},
+		{
+			name:        "not planned for the commit",
+			commitSHA:   "older",
+			wantErr:     tfc_trigger.ErrCostEstimateMissing,
+			wantComment: "Error: the project requires a cost approval, plan the MR and wait for the cost estimate of service-tfbuddy before applying: " + tfc_trigger.ErrCostEstimateMissing.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &tfc_trigger.ProjectConfig{
+				CostApproval: &tfc_trigger.CostApproval{Threshold: 100, Approvers: []string{"finops-alice"}},
+				Workspaces: []*tfc_trigger.TFCWorkspace{{
+					Name:         "service-tfbuddy",
+					Organization: "zapier-test",
+					Mode:         "apply-before-merge",
+				}}}
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{ProjectConfig: ws}, t)
+
+			commitSHA := "abcd12233"
+			if tt.commitSHA != "" {
+				commitSHA = tt.commitSHA
+			}
+			entries := []*runstream.RunIndexEntry{
+				{RunID: "run-2", Organization: "zapier-test", Workspace: "service-tfbuddy", CommitSHA: commitSHA},
+				{RunID: "run-1", Organization: "zapier-test", Workspace: "service-tfbuddy", CommitSHA: commitSHA},
+			}
+			if tt.olderCommit {
+				entries = append(entries, &runstream.RunIndexEntry{RunID: "run-0", Organization: "zapier-test", Workspace: "service-tfbuddy", CommitSHA: "older"})
+			}
+			testSuite.MockStreamClient.EXPECT().ListMRRuns(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return(entries, nil)
+			testSuite.MockApiClient.EXPECT().GetRun("run-0").Times(0)
+			if tt.costEstimate != nil {
+				testSuite.MockApiClient.EXPECT().GetRun("run-2").Return(&tfe.Run{ID: "run-2", CostEstimate: tt.costEstimate}, nil)
+			}
+			if tt.approvers != nil {
+				approvals := mocks.NewMockMRApproved(mockCtrl)
+				approvals.EXPECT().GetApprovers().Return(tt.approvers)
+				testSuite.MockGitClient.EXPECT().GetMergeRequestApprovals(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS).Return(approvals, nil)
+			}
+			if tt.wantErr != nil {
+				testSuite.MockGitClient.EXPECT().CreateMergeRequestComment(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS, tt.wantComment)
+			} else {
+				testSuite.MockApiClient.EXPECT().CreateRunFromSource(gomock.Any()).Return(&tfe.Run{
+					ID: "101",
+					Workspace: &tfe.Workspace{Name: "service-tfbuddy",
+						Organization: &tfe.Organization{Name: "zapier-test"},
+					},
+					ConfigurationVersion: &tfe.ConfigurationVersion{Speculative: false}}, nil)
+			}
+			testSuite.InitTestSuite()
+
+			trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+				Action:                   tfc_trigger.ApplyAction,
+				Branch:                   "test-branch",
+				CommitSHA:                "abcd12233",
+				ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+				MergeRequestIID:          testSuite.MetaData.MRIID,
+				TriggerSource:            tfc_trigger.CommentTrigger,
+				VcsProvider:              tt.vcsProvider,
+			})
+			triggeredWS, err := trigger.TriggerTFCEvents()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatal("expected error", tt.wantErr, "got", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(triggeredWS.Executed) != 1 {
+				t.Fatal("expected the workspace to be triggered", triggeredWS.Errored)
+			}
+		})
+	}
+}
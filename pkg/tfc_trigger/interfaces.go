@@ -11,6 +11,7 @@ type TriggerSource int
 type TriggerConfig interface {
 	GetAction() TriggerAction
 	SetAction(action TriggerAction)
+	GetAllowDestroy() bool
 	GetBranch() string
 	GetCommitSHA() string
 	GetProjectNameWithNamespace() string
@@ -23,4 +24,7 @@ type TriggerConfig interface {
 	GetWorkspace() string
 	SetWorkspace(workspace string)
 	GetVcsProvider() string
+	GetSlackChannel() string
+	GetSlackThreadTS() string
+	GetRunSlotID() string
 }
@@ -0,0 +1,105 @@
+package tfc_trigger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/go-tfe"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/comment_formatter"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/vcs"
+)
+
+// mrSummaryClaimTTL is how long a worker may take to create the summary note, before another worker takes over its
+// claim
+const mrSummaryClaimTTL = time.Minute
+
+var errSummaryNoteInProgress = errors.New("MR summary note is being created by another worker")
+
+// UpdateMRSummary updates the consolidated MR summary note with the latest status of a run. The summary is shared by
+// all workspaces of a MR commit, so updates use optimistic locking and are retried.
+func UpdateMRSummary(client vcs.GitClient, rs runstream.StreamClient, run *tfe.Run, rmd runstream.RunMetadata) {
+	row := &runstream.WorkspaceRunSummary{
+		Organization: rmd.GetOrganization(),
+		Workspace:    rmd.GetWorkspace(),
+		RunID:        rmd.GetRunID(),
+		RunURL:       fmt.Sprintf("https://app.terraform.io/app/%s/workspaces/%s/runs/%s", rmd.GetOrganization(), rmd.GetWorkspace(), rmd.GetRunID()),
+		Action:       rmd.GetAction(),
+		Status:       string(run.Status),
+	}
+	if run.Apply != nil && run.Status == tfe.RunApplied {
+		row.Additions = run.Apply.ResourceAdditions
+		row.Changes = run.Apply.ResourceChanges
+		row.Destructions = run.Apply.ResourceDestructions
+	} else if run.Plan != nil {
+		row.Additions = run.Plan.ResourceAdditions
+		row.Changes = run.Plan.ResourceChanges
+		row.Destructions = run.Plan.ResourceDestructions
+	}
+	if run.CostEstimate != nil && run.CostEstimate.Status == tfe.CostEstimateFinished {
+		row.DeltaMonthlyCost = run.CostEstimate.DeltaMonthlyCost
+	}
+
+	templates := comment_formatter.ProjectTemplates(rs, rmd)
+	update := func() error {
+		summary, err := rs.GetMRSummary(rmd.GetMRProjectNameWithNamespace(), rmd.GetMRInternalID(), rmd.GetCommitSHA())
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+		summary.SetWorkspaceRun(row)
+		summary.CostThreshold = rmd.GetCostThreshold()
+		summary.VcsProvider = rmd.GetVcsProvider()
+		if rmd.GetMRWebURL() != "" {
+			summary.MergeRequestWebURL = rmd.GetMRWebURL()
+		}
+
+		if summary.NoteID == 0 {
+			if summary.NoteClaimed(mrSummaryClaimTTL) {
+				return errSummaryNoteInProgress
+			}
+			// claim the summary before creating the note, so only one worker creates it
+			summary.ClaimedAt = time.Now()
+			if err := rs.UpdateMRSummary(summary); err != nil {
+				return err
+			}
+			disc, err := client.CreateMergeRequestDiscussion(
+				rmd.GetMRInternalID(),
+				rmd.GetMRProjectNameWithNamespace(),
+				comment_formatter.FormatMRSummaryBody(summary, templates),
+			)
+			if err != nil {
+				// release the claim, so the next run event creates the note
+				summary.ClaimedAt = time.Time{}
+				if err := rs.UpdateMRSummary(summary); err != nil {
+					log.Error().Err(err).Str("runID", rmd.GetRunID()).Msg("could not release MR summary claim")
+				}
+				return backoff.Permanent(err)
+			}
+			summary.DiscussionID = disc.GetDiscussionID()
+			if notes := disc.GetMRNotes(); len(notes) > 0 {
+				summary.NoteID = notes[0].GetNoteID()
+			}
+			return rs.UpdateMRSummary(summary)
+		}
+
+		if err := rs.UpdateMRSummary(summary); err != nil {
+			return err
+		}
+		_, err = client.UpdateMergeRequestDiscussionNote(
+			rmd.GetMRInternalID(),
+			int(summary.NoteID),
+			rmd.GetMRProjectNameWithNamespace(),
+			summary.DiscussionID,
+			comment_formatter.FormatMRSummaryBody(summary, templates),
+		)
+		return err
+	}
+
+	err := backoff.Retry(update, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 5))
+	if err != nil {
+		log.Error().Err(err).Str("runID", rmd.GetRunID()).Msg("could not update MR summary note")
+	}
+}
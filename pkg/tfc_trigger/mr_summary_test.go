@@ -0,0 +1,77 @@
+package tfc_trigger_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+	"github.com/zapier/tfbuddy/pkg/vcs"
+)
+
+func TestUpdateMRSummary_Claim(t *testing.T) {
+	rmd := &runstream.TFRunMetadata{
+		RunID:                                "run-123",
+		Organization:                         "zapier",
+		Workspace:                            "service-tfbuddy",
+		Action:                               "plan",
+		CommitSHA:                            "abcd1234",
+		MergeRequestProjectNameWithNamespace: "zapier/tfbuddy",
+		MergeRequestIID:                      101,
+	}
+	run := &tfe.Run{ID: "run-123", Status: tfe.RunPlanning}
+
+	t.Run("stale claim is taken over", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		gc := mocks.NewMockGitClient(mockCtrl)
+		rs := mocks.NewMockStreamClient(mockCtrl)
+
+		rs.EXPECT().GetMRSummary("zapier/tfbuddy", 101, "abcd1234").Return(&runstream.TFMRSummary{
+			ProjectNameWithNamespace: "zapier/tfbuddy",
+			MergeRequestIID:          101,
+			CommitSHA:                "abcd1234",
+			ClaimedAt:                time.Now().Add(-time.Hour),
+			Revision:                 3,
+		}, nil)
+		rs.EXPECT().UpdateMRSummary(gomock.Any()).Times(2)
+		note := mocks.NewMockMRNote(mockCtrl)
+		note.EXPECT().GetNoteID().Return(int64(301))
+		disc := mocks.NewMockMRDiscussionNotes(mockCtrl)
+		disc.EXPECT().GetDiscussionID().Return("disc-1")
+		disc.EXPECT().GetMRNotes().Return([]vcs.MRNote{note})
+		gc.EXPECT().CreateMergeRequestDiscussion(101, "zapier/tfbuddy", gomock.Any()).Return(disc, nil)
+
+		tfc_trigger.UpdateMRSummary(gc, rs, run, rmd)
+	})
+
+	t.Run("claim is released when the note can't be created", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		gc := mocks.NewMockGitClient(mockCtrl)
+		rs := mocks.NewMockStreamClient(mockCtrl)
+
+		rs.EXPECT().GetMRSummary("zapier/tfbuddy", 101, "abcd1234").Return(&runstream.TFMRSummary{
+			ProjectNameWithNamespace: "zapier/tfbuddy",
+			MergeRequestIID:          101,
+			CommitSHA:                "abcd1234",
+		}, nil)
+		var claims []time.Time
+		rs.EXPECT().UpdateMRSummary(gomock.Any()).Times(2).DoAndReturn(func(s *runstream.TFMRSummary) error {
+			claims = append(claims, s.ClaimedAt)
+			return nil
+		})
+		gc.EXPECT().CreateMergeRequestDiscussion(101, "zapier/tfbuddy", gomock.Any()).Return(nil, errors.New("gitlab is down"))
+
+		tfc_trigger.UpdateMRSummary(gc, rs, run, rmd)
+		if assert.Len(t, claims, 2) {
+			assert.False(t, claims[0].IsZero(), "expected the summary to be claimed")
+			assert.True(t, claims[1].IsZero(), "expected the claim to be released")
+		}
+	})
+}
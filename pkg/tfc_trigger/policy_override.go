@@ -0,0 +1,44 @@
+package tfc_trigger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-tfe"
+)
+
+// overridePolicies overrides the soft failed TFC policy checks of the workspace's latest run for the MR.
+func (t *TFCTrigger) overridePolicies(org, wsName string) error {
+	run, err := t.findMergeRequestRun(org, wsName)
+	if err != nil {
+		return t.handleError(err, fmt.Sprintf("could not find the run of workspace %s/%s", org, wsName))
+	}
+
+	comment := fmt.Sprintf("TF Buddy: overridden from MR !%d", t.cfg.GetMergeRequestIID())
+	if err := t.tfc.OverridePolicies(context.Background(), run.ID, comment); err != nil {
+		return t.handleError(err, fmt.Sprintf("could not override the policies of run %s", run.ID))
+	}
+
+	_, err = t.gl.CreateMergeRequestDiscussion(t.cfg.GetMergeRequestIID(),
+		t.cfg.GetProjectNameWithNamespace(),
+		fmt.Sprintf("Overrode the failed policies of run `%s` for Workspace `%s/%s`.", run.ID, org, wsName),
+	)
+	if err != nil {
+		return t.handleError(err, "Error posting successful policy override")
+	}
+	return nil
+}
+
+// findMergeRequestRun returns the most recent run of the workspace created for the MR, looked up in the run index.
+func (t *TFCTrigger) findMergeRequestRun(org, wsName string) (*tfe.Run, error) {
+	entries, err := t.runstream.ListMRRuns(t.cfg.GetProjectNameWithNamespace(), t.cfg.GetMergeRequestIID())
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Organization == org && entry.Workspace == wsName {
+			return t.tfc.GetRun(entry.RunID)
+		}
+	}
+	return nil, ErrNoRunForMR
+}
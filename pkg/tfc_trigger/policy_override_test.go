@@ -0,0 +1,45 @@
+package tfc_trigger_test
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/hashicorp/go-tfe"
+	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
+)
+
+func TestTFCEvents_OverridePolicy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{}, t)
+
+	testSuite.MockStreamClient.EXPECT().ListMRRuns(testSuite.MetaData.ProjectNameNS, testSuite.MetaData.MRIID).Return([]*runstream.RunIndexEntry{
+		{RunID: "run-other", Organization: "zapier-test", Workspace: "service-other"},
+		{RunID: "run-latest", Organization: "zapier-test", Workspace: "service-tfbuddy"},
+		{RunID: "run-older", Organization: "zapier-test", Workspace: "service-tfbuddy"},
+	}, nil).Times(2)
+	testSuite.MockApiClient.EXPECT().GetRun("run-latest").Return(&tfe.Run{ID: "run-latest"}, nil)
+	testSuite.MockApiClient.EXPECT().OverridePolicies(gomock.Any(), "run-latest", "TF Buddy: overridden from MR !101").Return(nil)
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS,
+		"Overrode the failed policies of run `run-latest` for Workspace `zapier-test/service-tfbuddy`.").Return(testSuite.MockGitDisc, nil)
+	testSuite.InitTestSuite()
+
+	trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+		Action:                   tfc_trigger.OverridePolicyAction,
+		Branch:                   "test-branch",
+		CommitSHA:                "abcd12233",
+		ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:          testSuite.MetaData.MRIID,
+		TriggerSource:            tfc_trigger.CommentTrigger,
+		Workspace:                "service-tfbuddy",
+	})
+	triggeredWS, err := trigger.TriggerTFCEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggeredWS.Executed) != 1 {
+		t.Fatal("expected the workspace to be overridden", triggeredWS.Errored)
+	}
+}
@@ -6,10 +6,14 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/creasty/defaults"
 	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/comment_formatter"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
 	"github.com/zapier/tfbuddy/pkg/vcs"
 	"gopkg.in/dealancer/validate.v2"
 	"gopkg.in/yaml.v2"
@@ -19,6 +23,36 @@ const ProjectConfigFilename = `.tfbuddy.yaml`
 
 type ProjectConfig struct {
 	Workspaces []*TFCWorkspace `yaml:"workspaces"`
+	// PlanFormat is the default plan rendering for all workspaces of the project (markdown or diff)
+	PlanFormat string `yaml:"planFormat"`
+	// Templates maps comment template names to template files in the repo, see docs/templates.md
+	Templates map[string]string `yaml:"templates"`
+	// ProtectedResources are the default protected resources for all workspaces of the project
+	ProtectedResources *terraform_plan.ProtectedResources `yaml:"protectedResources"`
+	// Policies are Rego policy files in the repo, evaluated against the plans of all workspaces of the project
+	Policies []string `yaml:"policies"`
+	// CostApproval requires a cost approver to approve MRs that increase the monthly cost estimate above a threshold
+	CostApproval *CostApproval `yaml:"costApproval"`
+	// Notifications send events of all workspaces of the project to notification targets, see docs/architecture.md
+	Notifications []*runstream.NotificationRule `yaml:"notifications"`
+	// Debounce is how long pushes to a MR must be quiet before it is planned, e.g. 30s. It is read from the target
+	// branch, pushes are planned right away by default.
+	Debounce time.Duration `yaml:"debounce"`
+}
+
+// CostApproval is the threshold for the total monthly cost increase of a MR, in USD, above which applying requires
+// the approval of one of the approvers.
+type CostApproval struct {
+	Threshold float64  `yaml:"threshold"`
+	Approvers []string `yaml:"approvers"`
+}
+
+// GetThreshold returns the threshold, or 0 if no cost approval is configured.
+func (c *CostApproval) GetThreshold() float64 {
+	if c == nil {
+		return 0
+	}
+	return c.Threshold
 }
 
 func (cfg *ProjectConfig) workspaceForDir(dir string) *TFCWorkspace {
@@ -83,11 +117,21 @@ func (cfg *ProjectConfig) triggeredWorkspaces(modifiedFiles []string) []*TFCWork
 }
 
 type TFCWorkspace struct {
-	Name         string   `yaml:"name" validate:"empty=false"`
-	Organization string   `yaml:"organization" validate:"empty=false"`
-	Dir          string   `yaml:"dir"`
-	Mode         string   `yaml:"mode" default:"apply-before-merge" validate:"one_of=apply-before-merge,merge-before-apply,tfc-vcs-repo"`
-	TriggerDirs  []string `yaml:"triggerDirs"`
+	Name         string            `yaml:"name" validate:"empty=false"`
+	Organization string            `yaml:"organization" validate:"empty=false"`
+	Dir          string            `yaml:"dir"`
+	Mode         string            `yaml:"mode" default:"apply-before-merge" validate:"one_of=apply-before-merge,merge-before-apply,tfc-vcs-repo"`
+	TriggerDirs  []string          `yaml:"triggerDirs"`
+	PlanFormat   string            `yaml:"planFormat"`
+	Templates    map[string]string `yaml:"templates"`
+	// ProtectedResources may only be destroyed or replaced by `tfc apply --allow-destroy`
+	ProtectedResources *terraform_plan.ProtectedResources `yaml:"protectedResources"`
+	// Policies are Rego policy files in the repo, evaluated against the workspace's plans in addition to the project's
+	Policies []string `yaml:"policies"`
+	// CostApproval is copied from the project config, the cost increase is checked for the whole MR
+	CostApproval *CostApproval `yaml:"-"`
+	// Notifications send events of the workspace to notification targets, in addition to the project's
+	Notifications []*runstream.NotificationRule `yaml:"notifications"`
 }
 
 func getProjectConfigFile(gl vcs.GitClient, trigger *TFCTrigger) (*ProjectConfig, error) {
@@ -106,6 +150,67 @@ func getProjectConfigFile(gl vcs.GitClient, trigger *TFCTrigger) (*ProjectConfig
 	return nil, errors.New("could not retrieve .tfbuddy.yaml for repo")
 }
 
+// getGuardConfigFile reads the project config from the MR's target branch, or the default branch, to get the guard
+// settings of the triggered workspaces. They are never read from the MR's source branch, so a MR can't remove its own
+// protections. The config is nil if the project has no .tfbuddy.yaml on those branches yet.
+func getGuardConfigFile(gl vcs.GitClient, project, targetBranch string) (cfg *ProjectConfig, branch string, err error) {
+	branches := []string{targetBranch, "master", "main"}
+	for _, branch := range branches {
+		if branch == "" {
+			continue
+		}
+		b, err := gl.GetRepoFile(project, ProjectConfigFilename, branch)
+		if err != nil {
+			log.Info().Err(err).Msg(fmt.Sprintf("no file on branch %s", branch))
+			continue
+		}
+		cfg, err := loadProjectConfig(b)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not load %s from branch %s: %v", ProjectConfigFilename, branch, err)
+		}
+		return cfg, branch, nil
+	}
+	log.Warn().Str("targetBranch", targetBranch).Msg("no .tfbuddy.yaml on the target or default branch, workspaces have no guard settings")
+	return nil, "", nil
+}
+
+// maxDebounce bounds the debounce window, the pending plans of debounced pushes and the hooks waiting for them expire
+// after an hour.
+const maxDebounce = time.Hour
+
+// DebounceWindow returns the debounce window of the project, read from the MR's target branch like the guard settings
+// so a MR can't change how its own pushes are planned. It is 0 if the project has no .tfbuddy.yaml on those branches.
+func DebounceWindow(gl vcs.GitClient, project, targetBranch string) (time.Duration, error) {
+	cfg, _, err := getGuardConfigFile(gl, project, targetBranch)
+	if err != nil || cfg == nil {
+		return 0, err
+	}
+	return cfg.Debounce, nil
+}
+
+// applyGuardSettings replaces the protected resources, policies, cost approval & notifications of the workspaces with
+// the ones of the guard config. Workspaces missing from the guard config get its project level settings.
+func (cfg *ProjectConfig) applyGuardSettings(workspaces []*TFCWorkspace) {
+	for _, ws := range workspaces {
+		ws.ProtectedResources, ws.Policies, ws.CostApproval, ws.Notifications = nil, nil, nil, nil
+		if cfg == nil {
+			continue
+		}
+		ws.ProtectedResources = cfg.ProtectedResources
+		ws.Policies = cfg.Policies
+		ws.CostApproval = cfg.CostApproval
+		ws.Notifications = cfg.Notifications
+		for _, guard := range cfg.Workspaces {
+			if guard.Name == ws.Name && guard.Organization == ws.Organization {
+				ws.ProtectedResources = guard.ProtectedResources
+				ws.Policies = guard.Policies
+				ws.CostApproval = guard.CostApproval
+				ws.Notifications = guard.Notifications
+			}
+		}
+	}
+}
+
 func loadProjectConfig(b []byte) (*ProjectConfig, error) {
 	cfg := &ProjectConfig{}
 	err := yaml.Unmarshal(b, cfg)
@@ -113,11 +218,55 @@ func loadProjectConfig(b []byte) (*ProjectConfig, error) {
 		return nil, fmt.Errorf("could not parse Project config file (.tfbuddy.yaml): %v", err)
 	}
 
+	if cfg.Debounce < 0 || cfg.Debounce >= maxDebounce {
+		return nil, fmt.Errorf("debounce must be between 0 and %s", maxDebounce)
+	}
+
+	if ca := cfg.CostApproval; ca != nil && (ca.Threshold <= 0 || len(ca.Approvers) == 0) {
+		return nil, fmt.Errorf("costApproval needs a threshold greater than 0 and at least one approver")
+	}
+
 	defaultOrgName := getDefaultOrgName()
 	for _, ws := range cfg.Workspaces {
 		if ws.Organization == "" {
 			ws.Organization = defaultOrgName
 		}
+		if ws.PlanFormat == "" {
+			ws.PlanFormat = cfg.PlanFormat
+		}
+		if ws.ProtectedResources == nil {
+			ws.ProtectedResources = cfg.ProtectedResources
+		}
+		ws.CostApproval = cfg.CostApproval
+		if len(cfg.Policies) > 0 {
+			ws.Policies = append(append([]string{}, cfg.Policies...), ws.Policies...)
+		}
+		if len(cfg.Notifications) > 0 {
+			ws.Notifications = append(append([]*runstream.NotificationRule{}, cfg.Notifications...), ws.Notifications...)
+		}
+		for _, rule := range ws.Notifications {
+			if err := validateNotificationRule(rule); err != nil {
+				return nil, fmt.Errorf("invalid notification for workspace %s: %v", ws.Name, err)
+			}
+		}
+		for name, path := range cfg.Templates {
+			if _, ok := ws.Templates[name]; !ok {
+				if ws.Templates == nil {
+					ws.Templates = map[string]string{}
+				}
+				ws.Templates[name] = path
+			}
+		}
+		for name := range ws.Templates {
+			if !isTemplateName(name) {
+				return nil, fmt.Errorf("unknown template %q for workspace %s, must be one of: %s", name, ws.Name, strings.Join(comment_formatter.TemplateNames, ", "))
+			}
+		}
+		switch ws.PlanFormat {
+		case "", terraform_plan.PlanFormatMarkdown, terraform_plan.PlanFormatDiff:
+		default:
+			return nil, fmt.Errorf("invalid planFormat %q for workspace %s, must be one of: %s, %s", ws.PlanFormat, ws.Name, terraform_plan.PlanFormatMarkdown, terraform_plan.PlanFormatDiff)
+		}
 	}
 
 	if err := validate.Validate(cfg); err != nil {
@@ -141,6 +290,35 @@ func (s *TFCWorkspace) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// validateNotificationRule checks that a notification rule has a target and only known events.
+func validateNotificationRule(rule *runstream.NotificationRule) error {
+	if rule.Target == "" {
+		return fmt.Errorf("target is required")
+	}
+	if len(rule.Events) == 0 {
+		return fmt.Errorf("no events for target %s", rule.Target)
+	}
+	for _, event := range rule.Events {
+		known := false
+		for _, e := range runstream.NotificationEvents {
+			known = known || e == event
+		}
+		if !known {
+			return fmt.Errorf("unknown event %q for target %s, must be one of: %s", event, rule.Target, strings.Join(runstream.NotificationEvents, ", "))
+		}
+	}
+	return nil
+}
+
+func isTemplateName(name string) bool {
+	for _, n := range comment_formatter.TemplateNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 func getDefaultOrgName() string {
 	return os.Getenv(DefaultTfcOrganizationEnvName)
 }
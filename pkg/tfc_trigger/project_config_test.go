@@ -4,11 +4,14 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 
 	"github.com/kr/pretty"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/terraform_plan"
 )
 
 func TestProjectConfig_triggeredWorkspaces(t *testing.T) {
@@ -257,6 +260,191 @@ func Test_loadProjectConfig(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "plan-format",
+			args: args{b: []byte(tfbuddyYamlPlanFormat)},
+			want: &ProjectConfig{
+				PlanFormat: "diff",
+				Workspaces: []*TFCWorkspace{
+					{
+						Name:         "service-tfbuddy-dev",
+						Organization: "foo-corp",
+						Dir:          "terraform/dev/",
+						Mode:         "apply-before-merge",
+						PlanFormat:   "diff",
+					},
+					{
+						Name:         "service-tfbuddy-tooling",
+						Organization: "foo-corp",
+						Dir:          "terraform/tooling/",
+						Mode:         "apply-before-merge",
+						PlanFormat:   "markdown",
+					},
+				}},
+			wantErr: false,
+		},
+		{
+			name: "templates",
+			args: args{b: []byte(tfbuddyYamlTemplates)},
+			want: &ProjectConfig{
+				Templates: map[string]string{
+					"plan":         ".tfbuddy/plan.tpl",
+					"how_to_apply": ".tfbuddy/how_to_apply.tpl",
+				},
+				Workspaces: []*TFCWorkspace{
+					{
+						Name:         "service-tfbuddy-dev",
+						Organization: "foo-corp",
+						Dir:          "terraform/dev/",
+						Mode:         "apply-before-merge",
+						Templates: map[string]string{
+							"plan":         ".tfbuddy/plan-dev.tpl",
+							"how_to_apply": ".tfbuddy/how_to_apply.tpl",
+						},
+					},
+				}},
+			wantErr: false,
+		},
+		{
+			name: "protected-resources",
+			args: args{b: []byte(tfbuddyYamlProtectedResources)},
+			want: &ProjectConfig{
+				ProtectedResources: &terraform_plan.ProtectedResources{
+					Types: []string{"aws_db_instance"},
+				},
+				Workspaces: []*TFCWorkspace{
+					{
+						Name:         "service-tfbuddy-dev",
+						Organization: "foo-corp",
+						Dir:          "terraform/dev/",
+						Mode:         "apply-before-merge",
+						ProtectedResources: &terraform_plan.ProtectedResources{
+							Types: []string{"aws_db_instance"},
+						},
+					},
+					{
+						Name:         "service-tfbuddy-prod",
+						Organization: "foo-corp",
+						Dir:          "terraform/prod/",
+						Mode:         "apply-before-merge",
+						ProtectedResources: &terraform_plan.ProtectedResources{
+							Types:     []string{"aws_db_instance", "aws_s3_bucket"},
+							Addresses: []string{"module.vpc.*"},
+						},
+					},
+				}},
+			wantErr: false,
+		},
+		{
+			name: "policies",
+			args: args{b: []byte(tfbuddyYamlPolicies)},
+			want: &ProjectConfig{
+				Policies: []string{"policies/common.rego"},
+				Workspaces: []*TFCWorkspace{
+					{
+						Name:         "service-tfbuddy-dev",
+						Organization: "foo-corp",
+						Dir:          "terraform/dev/",
+						Mode:         "apply-before-merge",
+						Policies:     []string{"policies/common.rego"},
+					},
+					{
+						Name:         "service-tfbuddy-prod",
+						Organization: "foo-corp",
+						Dir:          "terraform/prod/",
+						Mode:         "apply-before-merge",
+						Policies:     []string{"policies/common.rego", "policies/prod.rego"},
+					},
+				}},
+			wantErr: false,
+		},
+		{
+			name: "cost-approval",
+			args: args{b: []byte(tfbuddyYamlCostApproval)},
+			want: &ProjectConfig{
+				CostApproval: &CostApproval{Threshold: 250, Approvers: []string{"finops-alice"}},
+				Workspaces: []*TFCWorkspace{
+					{
+						Name:         "service-tfbuddy-dev",
+						Organization: "foo-corp",
+						Dir:          "terraform/dev/",
+						Mode:         "apply-before-merge",
+						CostApproval: &CostApproval{Threshold: 250, Approvers: []string{"finops-alice"}},
+					},
+				}},
+			wantErr: false,
+		},
+		{
+			name: "notifications",
+			args: args{b: []byte(tfbuddyYamlNotifications)},
+			want: &ProjectConfig{
+				Notifications: []*runstream.NotificationRule{{Target: "platform-slack", Events: []string{"apply_failed"}}},
+				Workspaces: []*TFCWorkspace{
+					{
+						Name:         "service-tfbuddy-dev",
+						Organization: "foo-corp",
+						Dir:          "terraform/dev/",
+						Mode:         "apply-before-merge",
+						Notifications: []*runstream.NotificationRule{
+							{Target: "platform-slack", Events: []string{"apply_failed"}},
+							{Target: "audit", Events: []string{"apply_started", "destroy_planned"}},
+						},
+					},
+				}},
+			wantErr: false,
+		},
+		{
+			name:    "unknown-notification-event",
+			args:    args{b: []byte(tfbuddyYamlUnknownNotificationEvent)},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "cost-approval-without-approvers",
+			args:    args{b: []byte(tfbuddyYamlCostApprovalWithoutApprovers)},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "unknown-template",
+			args:    args{b: []byte(tfbuddyYamlUnknownTemplate)},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "invalid-plan-format",
+			args:    args{b: []byte(tfbuddyYamlInvalidPlanFormat)},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "debounce",
+			args: args{b: []byte(tfbuddyYamlDebounce)},
+			want: &ProjectConfig{
+				Debounce: 30 * time.Second,
+				Workspaces: []*TFCWorkspace{
+					{
+						Name:         "service-tfbuddy-dev",
+						Organization: "foo-corp",
+						Dir:          "terraform/dev/",
+						Mode:         "apply-before-merge",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid-debounce",
+			args:    args{b: []byte(tfbuddyYamlInvalidDebounce)},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "debounce-too-long",
+			args:    args{b: []byte(tfbuddyYamlDebounceTooLong)},
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name: "multiple-workspaces",
 			args: args{b: []byte(tfbuddyYamlMultipleWorkspaces)},
@@ -360,6 +548,160 @@ workspaces:
     mode: sausage
 `
 
+const tfbuddyYamlPlanFormat = `
+---
+planFormat: diff
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+  - name: service-tfbuddy-tooling
+    organization: foo-corp
+    dir: terraform/tooling/
+    planFormat: markdown
+`
+
+const tfbuddyYamlTemplates = `
+---
+templates:
+  plan: .tfbuddy/plan.tpl
+  how_to_apply: .tfbuddy/how_to_apply.tpl
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+    templates:
+      plan: .tfbuddy/plan-dev.tpl
+`
+
+const tfbuddyYamlProtectedResources = `
+---
+protectedResources:
+  types:
+    - aws_db_instance
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+  - name: service-tfbuddy-prod
+    organization: foo-corp
+    dir: terraform/prod/
+    protectedResources:
+      types:
+        - aws_db_instance
+        - aws_s3_bucket
+      addresses:
+        - module.vpc.*
+`
+
+const tfbuddyYamlPolicies = `
+---
+policies:
+  - policies/common.rego
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+  - name: service-tfbuddy-prod
+    organization: foo-corp
+    dir: terraform/prod/
+    policies:
+      - policies/prod.rego
+`
+
+const tfbuddyYamlCostApproval = `
+---
+costApproval:
+  threshold: 250
+  approvers:
+    - finops-alice
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+`
+
+const tfbuddyYamlNotifications = `
+---
+notifications:
+  - target: platform-slack
+    events: [apply_failed]
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+    notifications:
+      - target: audit
+        events: [apply_started, destroy_planned]
+`
+
+const tfbuddyYamlUnknownNotificationEvent = `
+---
+notifications:
+  - target: platform-slack
+    events: [apply_done]
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+`
+
+const tfbuddyYamlCostApprovalWithoutApprovers = `
+---
+costApproval:
+  threshold: 250
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+`
+
+const tfbuddyYamlUnknownTemplate = `
+---
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+    templates:
+      sausage: .tfbuddy/sausage.tpl
+`
+
+const tfbuddyYamlInvalidPlanFormat = `
+---
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+    planFormat: sausage
+`
+
+const tfbuddyYamlDebounce = `
+---
+debounce: 30s
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+`
+
+const tfbuddyYamlInvalidDebounce = `
+---
+debounce: soon
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+`
+
+const tfbuddyYamlDebounceTooLong = `
+---
+debounce: 1h
+workspaces:
+  - name: service-tfbuddy-dev
+    organization: foo-corp
+    dir: terraform/dev/
+`
+
 const tfbuddyYamlMultipleWorkspaces = `
 ---
 workspaces:
@@ -0,0 +1,250 @@
+package tfc_trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/nats-io/nuid"
+	"github.com/rs/zerolog/log"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+	"github.com/zapier/tfbuddy/pkg/vcs"
+)
+
+// runQueueDispatcherQueue is the consumer group of the run dispatchers, each run event is handled by a single TF Buddy
+// pod
+const runQueueDispatcherQueue = "run_queue"
+
+// runQueueDispatchInterval is how often queued runs are dispatched without run events, e.g. once the slots of runs
+// whose final status was never received expired
+const runQueueDispatchInterval = time.Minute
+
+// acquireRunSlot reserves a slot for the run under the TFC run concurrency limits. Without capacity, the trigger is
+// queued, its queue position is posted in the thread of the command, or a new MR thread, and queued is true; the run
+// is triggered again by the RunDispatcher once capacity frees up, from the queued commit. Runs are created right away
+// if the run queue can't be updated.
+func (t *TFCTrigger) acquireRunSlot(cfgWS *TFCWorkspace, ws *tfe.Workspace) (slotID string, queued bool) {
+	if id := t.cfg.GetRunSlotID(); id != "" {
+		// dispatched from the run queue, the slot is already reserved
+		return id, false
+	}
+	if !runstream.LoadRunLimits().Enabled() {
+		return "", false
+	}
+	trigger, err := json.Marshal(&TFCTriggerConfig{
+		Action:                   t.cfg.GetAction(),
+		AllowDestroy:             t.cfg.GetAllowDestroy(),
+		Branch:                   t.cfg.GetBranch(),
+		CommitSHA:                t.cfg.GetCommitSHA(),
+		ProjectNameWithNamespace: t.cfg.GetProjectNameWithNamespace(),
+		MergeRequestIID:          t.cfg.GetMergeRequestIID(),
+		MergeRequestDiscussionID: t.cfg.GetMergeRequestDiscussionID(),
+		MergeRequestRootNoteID:   t.cfg.GetMergeRequestRootNoteID(),
+		TriggerSource:            t.cfg.GetTriggerSource(),
+		VcsProvider:              t.cfg.GetVcsProvider(),
+		Workspace:                cfgWS.Name,
+		SlackChannel:             t.cfg.GetSlackChannel(),
+		SlackThreadTS:            t.cfg.GetSlackThreadTS(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("could not encode trigger for the run queue")
+		return "", false
+	}
+	qr := &runstream.QueuedRun{
+		ID:           nuid.Next(),
+		Organization: cfgWS.Organization,
+		Workspace:    cfgWS.Name,
+		AgentPool:    agentPoolID(ws),
+		Trigger:      trigger,
+	}
+	position, err := t.runstream.AcquireRunSlot(qr)
+	if err != nil {
+		log.Error().Err(err).Str("ws", cfgWS.Name).Msg("could not acquire TFC run slot, creating the run anyway")
+		return "", false
+	}
+	if position == 0 {
+		return qr.ID, false
+	}
+
+	tfcRunsQueued.WithLabelValues(cfgWS.Organization, cfgWS.Name).Inc()
+	msg := fmt.Sprintf(":hourglass: TFC %s for Workspace `%s/%s` queued at position %d, the TFC run concurrency limit of "+
+		"`%s` is reached. The %s will start once other runs finish.",
+		t.cfg.GetAction(), cfgWS.Organization, cfgWS.Name, position, cfgWS.Organization, t.cfg.GetAction())
+	if t.cfg.GetMergeRequestDiscussionID() != "" {
+		_, err = t.gl.AddMergeRequestDiscussionReply(t.cfg.GetMergeRequestIID(), t.cfg.GetProjectNameWithNamespace(), t.cfg.GetMergeRequestDiscussionID(), msg)
+	} else {
+		_, err = t.gl.CreateMergeRequestDiscussion(t.cfg.GetMergeRequestIID(), t.cfg.GetProjectNameWithNamespace(), msg)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("could not post run queue position")
+	}
+	return "", true
+}
+
+// releaseRunSlot frees the slot reserved for a run that could not be created.
+func (t *TFCTrigger) releaseRunSlot(slotID string) {
+	if slotID == "" {
+		return
+	}
+	if err := t.runstream.ReleaseRunSlot(slotID); err != nil {
+		log.Error().Err(err).Str("id", slotID).Msg("could not release TFC run slot")
+	}
+}
+
+func agentPoolID(ws *tfe.Workspace) string {
+	if ws.AgentPool == nil {
+		return ""
+	}
+	return ws.AgentPool.ID
+}
+
+// runSlotReleaseStatuses are the statuses of finished runs and of runs waiting for a user, e.g. for `tfc confirm` or a
+// policy override. Their slots are freed, so runs nobody confirms don't hold up the queue until their slots expire.
+var runSlotReleaseStatuses = map[tfe.RunStatus]bool{
+	tfe.RunApplied:                  true,
+	tfe.RunPlannedAndFinished:       true,
+	tfe.RunErrored:                  true,
+	tfe.RunDiscarded:                true,
+	tfe.RunCanceled:                 true,
+	tfe.RunPolicySoftFailed:         true,
+	tfe.RunPolicyOverride:           true,
+	tfe.RunPostPlanAwaitingDecision: true,
+	tfe.RunPlanned:                  true,
+	tfe.RunCostEstimated:            true,
+	tfe.RunPolicyChecked:            true,
+	// not defined by go-tfe yet
+	"force_canceled":    true,
+	"planned_and_saved": true,
+}
+
+// RunDispatcher frees the slots of finished runs and triggers the queued runs that fit within the TFC run concurrency
+// limits.
+type RunDispatcher struct {
+	vcs             map[string]vcs.GitClient
+	tfc             tfc_api.ApiClient
+	rs              runstream.StreamClient
+	triggerCreation func(gl vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient, cfg TriggerConfig) Trigger
+}
+
+func NewRunDispatcher(gl, gh vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient) *RunDispatcher {
+	return &RunDispatcher{
+		vcs: map[string]vcs.GitClient{
+			"gitlab": gl,
+			"github": gh,
+		},
+		tfc:             tfc,
+		rs:              rs,
+		triggerCreation: NewTFCTrigger,
+	}
+}
+
+// Start subscribes to the run events and dispatches queued runs periodically, the returned closer stops both. The run
+// events are consumed from a durable queue, so the slots of runs finishing while no TF Buddy pod is subscribed are
+// still freed.
+func (d *RunDispatcher) Start() (closer func(), err error) {
+	closeSubscription, err := d.rs.SubscribeAllTFRunEvents(runQueueDispatcherQueue, d.HandleRunEvent)
+	if err != nil {
+		return nil, err
+	}
+	ticker := time.NewTicker(runQueueDispatchInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				d.Dispatch()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		closeSubscription()
+	}, nil
+}
+
+// HandleRunEvent frees the slot of a finished or waiting run and dispatches the queued runs. It returns false if the
+// slot could not be freed, so the event is delivered again.
+func (d *RunDispatcher) HandleRunEvent(re runstream.RunEvent) bool {
+	if !runSlotReleaseStatuses[tfe.RunStatus(re.GetNewStatus())] {
+		return true
+	}
+	if err := d.rs.ReleaseRunSlot(re.GetRunID()); err != nil {
+		log.Error().Err(err).Str("runID", re.GetRunID()).Msg("could not release TFC run slot")
+		return false
+	}
+	d.Dispatch()
+	return true
+}
+
+// Dispatch triggers the queued runs that fit within the limits, until the queue is empty or the limits are reached.
+func (d *RunDispatcher) Dispatch() {
+	for {
+		dispatched, err := d.rs.DispatchQueuedRuns()
+		if err != nil {
+			log.Error().Err(err).Msg("could not dispatch queued TFC runs")
+			return
+		}
+		if len(dispatched) == 0 {
+			return
+		}
+		for _, qr := range dispatched {
+			d.trigger(qr)
+		}
+	}
+}
+
+// trigger runs a dispatched trigger in its reserved slot. The slot is released if the run could not be created, so
+// the next queued run can take it.
+func (d *RunDispatcher) trigger(qr *runstream.QueuedRun) {
+	log := log.With().Str("id", qr.ID).Str("org", qr.Organization).Str("ws", qr.Workspace).Logger()
+	cfg := &TFCTriggerConfig{}
+	if err := json.Unmarshal(qr.Trigger, cfg); err != nil {
+		log.Error().Err(err).Msg("could not decode queued trigger")
+		d.release(qr)
+		return
+	}
+	cfg.RunSlotID = qr.ID
+	provider := cfg.VcsProvider
+	if provider == "" {
+		provider = "gitlab"
+	}
+	gc, ok := d.vcs[provider]
+	if !ok {
+		log.Error().Str("vcs", provider).Msg("unsupported VCS provider for queued trigger")
+		d.release(qr)
+		return
+	}
+
+	log.Info().Dur("queued", time.Since(qr.QueuedAt)).Msg("dispatching queued TFC run")
+	triggered, err := d.triggerCreation(gc, d.tfc, d.rs, cfg).TriggerTFCEvents()
+	if err == nil && triggered != nil && len(triggered.Executed) > 0 {
+		return
+	}
+	d.release(qr)
+
+	msg := ":no_entry: The queued run could not be started"
+	if err != nil {
+		msg += fmt.Sprintf(": %v", err)
+	} else if triggered != nil && len(triggered.Errored) > 0 {
+		msg += fmt.Sprintf(": %s", triggered.Errored[0].Error)
+	}
+	if cfg.MergeRequestDiscussionID != "" {
+		_, err = gc.AddMergeRequestDiscussionReply(cfg.MergeRequestIID, cfg.ProjectNameWithNamespace, cfg.MergeRequestDiscussionID, msg+".")
+	} else {
+		_, err = gc.CreateMergeRequestDiscussion(cfg.MergeRequestIID, cfg.ProjectNameWithNamespace, msg+".")
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("could not post queued run failure")
+	}
+}
+
+func (d *RunDispatcher) release(qr *runstream.QueuedRun) {
+	if err := d.rs.ReleaseRunSlot(qr.ID); err != nil {
+		log.Error().Err(err).Str("id", qr.ID).Msg("could not release TFC run slot")
+	}
+}
@@ -0,0 +1,141 @@
+package tfc_trigger
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zapier/tfbuddy/pkg/runstream"
+	"github.com/zapier/tfbuddy/pkg/tfc_api"
+	"github.com/zapier/tfbuddy/pkg/vcs"
+)
+
+// testRunQueue dispatches the queued runs once & records the released slots.
+type testRunQueue struct {
+	runstream.StreamClient
+	queued     []*runstream.QueuedRun
+	released   []string
+	releaseErr error
+}
+
+func (q *testRunQueue) ReleaseRunSlot(id string) error {
+	if q.releaseErr != nil {
+		return q.releaseErr
+	}
+	q.released = append(q.released, id)
+	return nil
+}
+
+func (q *testRunQueue) DispatchQueuedRuns() ([]*runstream.QueuedRun, error) {
+	dispatched := q.queued
+	q.queued = nil
+	return dispatched, nil
+}
+
+// testReplies records the MR discussion replies.
+type testReplies struct {
+	vcs.GitClient
+	replies []string
+}
+
+func (r *testReplies) AddMergeRequestDiscussionReply(mrIID int, project, discussionID, comment string) (vcs.MRNote, error) {
+	r.replies = append(r.replies, comment)
+	return nil, nil
+}
+
+type testTrigger struct {
+	Trigger
+	triggered *TriggeredTFCWorkspaces
+}
+
+func (t *testTrigger) TriggerTFCEvents() (*TriggeredTFCWorkspaces, error) {
+	return t.triggered, nil
+}
+
+func TestRunDispatcher_HandleRunEvent(t *testing.T) {
+	queuedRun := func(id, ws string) *runstream.QueuedRun {
+		trigger, _ := json.Marshal(&TFCTriggerConfig{
+			Action:                   PlanAction,
+			ProjectNameWithNamespace: "zapier/tfbuddy",
+			MergeRequestIID:          101,
+			MergeRequestDiscussionID: "1010",
+			VcsProvider:              "gitlab",
+			Workspace:                ws,
+		})
+		return &runstream.QueuedRun{ID: id, Organization: "zapier", Workspace: ws, Trigger: trigger}
+	}
+	rs := &testRunQueue{queued: []*runstream.QueuedRun{queuedRun("q-1", "service-tfbuddy"), queuedRun("q-2", "locked-ws")}}
+	gl := &testReplies{}
+	triggered := []TriggerConfig{}
+	d := &RunDispatcher{
+		vcs: map[string]vcs.GitClient{"gitlab": gl},
+		rs:  rs,
+		triggerCreation: func(gl vcs.GitClient, tfc tfc_api.ApiClient, rs runstream.StreamClient, cfg TriggerConfig) Trigger {
+			triggered = append(triggered, cfg)
+			if cfg.GetWorkspace() == "locked-ws" {
+				return &testTrigger{triggered: &TriggeredTFCWorkspaces{
+					Errored: []*ErroredWorkspace{{Name: "locked-ws", Error: "could not trigger Run for Workspace"}},
+				}}
+			}
+			return &testTrigger{triggered: &TriggeredTFCWorkspaces{Executed: []string{cfg.GetWorkspace()}}}
+		},
+	}
+
+	// runs still in progress keep their slot
+	assert.True(t, d.HandleRunEvent(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "planning"}))
+	assert.Empty(t, rs.released)
+	assert.Empty(t, triggered)
+
+	// the event is delivered again if the slot can't be freed
+	rs.releaseErr = errors.New("nats: timeout")
+	assert.False(t, d.HandleRunEvent(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "planned_and_finished"}))
+	assert.Empty(t, triggered)
+
+	rs.releaseErr = nil
+	assert.True(t, d.HandleRunEvent(&runstream.TFRunEvent{RunID: "run-1", NewStatus: "planned_and_finished"}))
+	if assert.Len(t, triggered, 2) {
+		assert.Equal(t, "q-1", triggered[0].GetRunSlotID())
+		assert.Equal(t, "service-tfbuddy", triggered[0].GetWorkspace())
+		assert.Equal(t, "1010", triggered[0].GetMergeRequestDiscussionID())
+	}
+	assert.Equal(t, []string{"run-1", "q-2"}, rs.released, "the slot of the run that could not be created is released")
+	assert.Equal(t, []string{":no_entry: The queued run could not be started: could not trigger Run for Workspace."}, gl.replies)
+}
+
+func TestRunDispatcher_HandleRunEventStatuses(t *testing.T) {
+	tests := []struct {
+		status  string
+		release bool
+	}{
+		{"applied", true},
+		{"planned_and_finished", true},
+		{"planned_and_saved", true},
+		{"errored", true},
+		{"discarded", true},
+		{"canceled", true},
+		{"force_canceled", true},
+		{"policy_soft_failed", true},
+		{"policy_override", true},
+		{"post_plan_awaiting_decision", true},
+		{"planned", true},
+		{"cost_estimated", true},
+		{"policy_checked", true},
+		{"pending", false},
+		{"planning", false},
+		{"confirmed", false},
+		{"applying", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			rs := &testRunQueue{}
+			d := &RunDispatcher{rs: rs}
+			assert.True(t, d.HandleRunEvent(&runstream.TFRunEvent{RunID: "run-1", NewStatus: tt.status}))
+			if tt.release {
+				assert.Equal(t, []string{"run-1"}, rs.released)
+			} else {
+				assert.Empty(t, rs.released)
+			}
+		})
+	}
+}
@@ -16,11 +16,14 @@ import (
 	"github.com/hashicorp/go-tfe"
 	"github.com/rs/zerolog/log"
 
+	"github.com/zapier/tfbuddy/pkg/comment_formatter"
+	"github.com/zapier/tfbuddy/pkg/plan_policy"
 	"github.com/zapier/tfbuddy/pkg/runstream"
 	"github.com/zapier/tfbuddy/pkg/tfc_api"
 	"github.com/zapier/tfbuddy/pkg/vcs"
 )
 
+// actions are appended at the end, so the values of the existing ones don't change
 const (
 	ApplyAction TriggerAction = iota
 	DestroyAction
@@ -28,20 +31,45 @@ const (
 	PlanAction
 	RefreshAction
 	UnlockAction
+	OverridePolicyAction
+	ConfirmAction
+	DiscardAction
+	CancelAction
 )
 
 const tfPrefix = "tfbuddylock"
 
+// runMessagePrefixFormat prefixes the message of the runs created for a MR with the MR IID
+const runMessagePrefixFormat = "MR [!%d]: "
+
 var tagRegex = regexp.MustCompile(fmt.Sprintf("%s\\-(\\d+)", tfPrefix))
 
+// isRunCommand returns true for the actions on the runs TF Buddy already created for the MR, they don't depend on the
+// MR's changes.
+func (a TriggerAction) isRunCommand() bool {
+	switch a {
+	case CancelAction, ConfirmAction, DiscardAction, OverridePolicyAction:
+		return true
+	}
+	return false
+}
+
 func (a TriggerAction) String() string {
 	switch a {
 	case ApplyAction:
 		return "apply"
+	case CancelAction:
+		return "cancel"
+	case ConfirmAction:
+		return "confirm"
 	case DestroyAction:
 		return "destroy"
+	case DiscardAction:
+		return "discard"
 	case LockAction:
 		return "lock"
+	case OverridePolicyAction:
+		return "override-policy"
 	case PlanAction:
 		return "plan"
 	case RefreshAction:
@@ -56,6 +84,7 @@ func (a TriggerAction) String() string {
 const (
 	CommentTrigger TriggerSource = iota
 	MergeRequestEventTrigger
+	SlackTrigger
 )
 
 type TFCTrigger struct {
@@ -63,10 +92,13 @@ type TFCTrigger struct {
 	gl        vcs.GitClient
 	tfc       tfc_api.ApiClient
 	runstream runstream.StreamClient
+	// guardBranch is the branch the guard settings & policies of the triggered workspaces are read from
+	guardBranch string
 }
 
 type TFCTriggerConfig struct {
 	Action                   TriggerAction
+	AllowDestroy             bool
 	Branch                   string
 	CommitSHA                string
 	ProjectNameWithNamespace string
@@ -76,6 +108,11 @@ type TFCTriggerConfig struct {
 	TriggerSource            TriggerSource
 	VcsProvider              string
 	Workspace                string
+	// SlackChannel & SlackThreadTS are the Slack thread run updates are posted to, for runs triggered from Slack
+	SlackChannel  string
+	SlackThreadTS string
+	// RunSlotID is the queued run ID of runs dispatched from the run queue, their slot is already reserved
+	RunSlotID string
 }
 
 func NewTFCTrigger(
@@ -105,11 +142,31 @@ var (
 			"runType",
 		},
 	)
+	tfcRunsQueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tfbuddy_tfc_runs_queued",
+		Help: "Count of TFC runs queued because a run concurrency limit was reached",
+	},
+		[]string{
+			"organization",
+			"workspace",
+		},
+	)
+	tfcRunsSuperseded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tfbuddy_tfc_runs_superseded",
+		Help: "Count of TFC plans cancelled or discarded because a newer commit was planned",
+	},
+		[]string{
+			"organization",
+			"workspace",
+		},
+	)
 )
 
 func init() {
 	r := prometheus.DefaultRegisterer
 	r.MustRegister(tfcRunsStarted)
+	r.MustRegister(tfcRunsSuperseded)
+	r.MustRegister(tfcRunsQueued)
 }
 func (t *TFCTrigger) GetConfig() TriggerConfig {
 	return t.cfg
@@ -129,6 +186,9 @@ func (tC *TFCTriggerConfig) SetMergeRequestDiscussionID(mrDiscID string) {
 func (tC *TFCTriggerConfig) GetAction() TriggerAction {
 	return tC.Action
 }
+func (tC *TFCTriggerConfig) GetAllowDestroy() bool {
+	return tC.AllowDestroy
+}
 func (tC *TFCTriggerConfig) GetBranch() string {
 	return tC.Branch
 }
@@ -153,6 +213,19 @@ func (tC *TFCTriggerConfig) GetTriggerSource() TriggerSource {
 func (tC *TFCTriggerConfig) GetVcsProvider() string {
 	return tC.VcsProvider
 }
+
+func (tC *TFCTriggerConfig) GetSlackChannel() string {
+	return tC.SlackChannel
+}
+
+func (tC *TFCTriggerConfig) GetSlackThreadTS() string {
+	return tC.SlackThreadTS
+}
+
+func (tC *TFCTriggerConfig) GetRunSlotID() string {
+	return tC.RunSlotID
+}
+
 func (tC *TFCTriggerConfig) GetWorkspace() string {
 	return tC.Workspace
 }
@@ -163,8 +236,47 @@ var (
 	ErrNoChangesDetected   = errors.New("no changes detected for configured Terraform directories")
 	ErrWorkspaceLocked     = errors.New("workspace is already locked")
 	ErrWorkspaceUnlocked   = errors.New("workspace is already unlocked")
+	ErrCostApprovalMissing = errors.New("the monthly cost increase needs the approval of a cost approver")
+	ErrCostEstimateMissing = errors.New("the plans of this commit have no finished cost estimate")
+	ErrNoRunForMR          = errors.New("no run found for this MR")
+	ErrNoConfirmableRun    = errors.New("no run of this MR is waiting for confirmation")
+	ErrRunCheckedByTFBuddy = errors.New("the run is confirmed or discarded by TF Buddy once its plan has been checked")
+	ErrNoActiveRun         = errors.New("no run of this MR is in progress")
 )
 
+// TransientError wraps the error of a temporary failure, like publishing to NATS, reading the KV stores or a TFC server
+// error. Hooks failing with a transient error are processed again, other trigger errors are reported on the MR.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient returns true if the error is, or wraps, a TransientError.
+func IsTransient(err error) bool {
+	var te *TransientError
+	return errors.As(err, &te)
+}
+
+// transientStreamError marks an error of the NATS streams & KV stores as transient.
+func transientStreamError(err error) error {
+	return &TransientError{Err: err}
+}
+
+// transientTFCError marks an error of the TFC API as transient, unless TFC refused the request.
+func transientTFCError(err error) error {
+	if errors.Is(err, tfe.ErrResourceNotFound) || errors.Is(err, tfe.ErrUnauthorized) {
+		return err
+	}
+	return &TransientError{Err: err}
+}
+
 func FindLockingMR(tags []string, thisMR string) string {
 	for _, tag := range tags {
 		tag = strings.TrimSpace(tag)
@@ -189,9 +301,13 @@ func FindLockingMR(tags []string, thisMR string) string {
 }
 
 // handleError both logs an error and reports it back to the Merge Request via an MR comment.
-// the returned error is identical to the input parameter as a convenience
+// the returned error is identical to the input parameter as a convenience. Transient errors are only logged, the hook
+// is processed again.
 func (t *TFCTrigger) handleError(err error, msg string) error {
 	log.Error().Err(err).Msg(msg)
+	if IsTransient(err) {
+		return err
+	}
 	if err := t.gl.CreateMergeRequestComment(t.cfg.GetMergeRequestIID(), t.cfg.GetProjectNameWithNamespace(), fmt.Sprintf("Error: %s: %v", msg, err)); err != nil {
 		log.Error().Err(err).Msg("could not post error to Gitlab MR")
 	}
@@ -214,7 +330,7 @@ func (t *TFCTrigger) getLockingMR(workspace string) string {
 func (t *TFCTrigger) getTriggeredWorkspaces(modifiedFiles []string) ([]*TFCWorkspace, error) {
 	cfg, err := getProjectConfigFile(t.gl, t)
 	if err != nil {
-		if t.cfg.GetTriggerSource() == CommentTrigger {
+		if t.cfg.GetTriggerSource() != MergeRequestEventTrigger {
 			return nil, t.handleError(err, "could not read .tfbuddy.yml file for this repo")
 		}
 		// we got a webhook for a repo that has not enabled TFBuddy yet. Ignore.
@@ -250,6 +366,8 @@ type ErroredWorkspace struct {
 type TriggeredTFCWorkspaces struct {
 	Errored  []*ErroredWorkspace
 	Executed []string
+	// Queued are the workspaces whose run waits for capacity under the TFC run concurrency limits
+	Queued []string
 }
 
 func (t *TFCTrigger) getModifiedWorkspaceBetweenMergeBaseTargetBranch(mr vcs.MR, repo vcs.GitRepo) (map[string]byte, error) {
@@ -311,6 +429,9 @@ func (t *TFCTrigger) cloneGitRepo(mr vcs.MR) (vcs.GitRepo, error) {
 	return repo, nil
 }
 func (t *TFCTrigger) TriggerTFCEvents() (*TriggeredTFCWorkspaces, error) {
+	if t.cfg.GetAction().isRunCommand() {
+		return t.triggerRunCommand()
+	}
 	mr, err := t.gl.GetMergeRequest(t.cfg.GetMergeRequestIID(), t.cfg.GetProjectNameWithNamespace())
 	if err != nil {
 		return nil, t.handleError(err, "could not read MergeRequest data from Gitlab API")
@@ -319,17 +440,35 @@ func (t *TFCTrigger) TriggerTFCEvents() (*TriggeredTFCWorkspaces, error) {
 	if err != nil {
 		return nil, t.handleError(err, "could not read triggered workspaces")
 	}
+	if len(triggeredWorkspaces) > 0 {
+		if err := t.loadGuardSettings(triggeredWorkspaces, mr.GetTargetBranch()); err != nil {
+			return nil, err
+		}
+	}
 	workspaceStatus := &TriggeredTFCWorkspaces{
 		Errored:  make([]*ErroredWorkspace, 0),
 		Executed: make([]string, 0),
 	}
 	if len(triggeredWorkspaces) > 0 {
+		if t.cfg.GetAction() == ApplyAction || t.cfg.GetAction() == ConfirmAction {
+			// all workspaces share the project's cost approval config
+			if err := t.checkCostApproval(triggeredWorkspaces, triggeredWorkspaces[0].CostApproval); err != nil {
+				return nil, err
+			}
+		}
 
 		repo, err := t.cloneGitRepo(mr)
 		if err != nil {
 			return nil, t.handleError(err, "could not clone repo")
 		}
 		defer os.Remove(repo.GetLocalDirectory())
+		if t.cfg.GetRunSlotID() != "" {
+			// dispatched from the run queue, the run is created from the commit it was queued for, not the newer
+			// commits pushed meanwhile
+			if err := repo.CheckoutCommit(t.cfg.GetCommitSHA()); err != nil {
+				return nil, t.handleError(err, "could not check out the queued commit")
+			}
+		}
 
 		modifiedWSMap, err := t.getModifiedWorkspaceBetweenMergeBaseTargetBranch(mr, repo)
 		if err != nil {
@@ -355,7 +494,8 @@ func (t *TFCTrigger) TriggerTFCEvents() (*TriggeredTFCWorkspaces, error) {
 				})
 				continue
 			}
-			if err := t.triggerRunForWorkspace(cfgWS, mr, repo.GetLocalDirectory()); err != nil {
+			queued, err := t.triggerRunForWorkspace(cfgWS, mr, repo.GetLocalDirectory())
+			if err != nil {
 				log.Error().Err(err).Msg("could not trigger Run for Workspace")
 				workspaceStatus.Errored = append(workspaceStatus.Errored, &ErroredWorkspace{
 					Name:  cfgWS.Name,
@@ -363,10 +503,14 @@ func (t *TFCTrigger) TriggerTFCEvents() (*TriggeredTFCWorkspaces, error) {
 				})
 				continue
 			}
+			if queued {
+				workspaceStatus.Queued = append(workspaceStatus.Queued, cfgWS.Name)
+				continue
+			}
 			workspaceStatus.Executed = append(workspaceStatus.Executed, cfgWS.Name)
 		}
 
-	} else if t.cfg.GetTriggerSource() == CommentTrigger {
+	} else if t.cfg.GetTriggerSource() != MergeRequestEventTrigger {
 		return nil, t.handleError(ErrNoChangesDetected, "")
 
 	} else {
@@ -377,6 +521,83 @@ func (t *TFCTrigger) TriggerTFCEvents() (*TriggeredTFCWorkspaces, error) {
 	return workspaceStatus, nil
 }
 
+// loadGuardSettings replaces the guard settings of the workspaces read from the MR branch with the ones of the target
+// branch, see getGuardConfigFile.
+func (t *TFCTrigger) loadGuardSettings(workspaces []*TFCWorkspace, targetBranch string) error {
+	cfg, branch, err := getGuardConfigFile(t.gl, t.cfg.GetProjectNameWithNamespace(), targetBranch)
+	if err != nil {
+		return t.handleError(err, "could not read the protected resources, policies & cost approval of the target branch")
+	}
+	cfg.applyGuardSettings(workspaces)
+	t.guardBranch = branch
+	return nil
+}
+
+// triggerRunCommand runs the action on the workspaces TF Buddy created runs in for the MR, looked up in the run index,
+// or on the workspace given with -w.
+func (t *TFCTrigger) triggerRunCommand() (*TriggeredTFCWorkspaces, error) {
+	entries, err := t.runstream.ListMRRuns(t.cfg.GetProjectNameWithNamespace(), t.cfg.GetMergeRequestIID())
+	if err != nil {
+		return nil, t.handleError(transientStreamError(err), "could not read the runs of the MR")
+	}
+	workspaceStatus := &TriggeredTFCWorkspaces{
+		Errored:  make([]*ErroredWorkspace, 0),
+		Executed: make([]string, 0),
+	}
+	seen := map[string]bool{}
+	workspaces := []*TFCWorkspace{}
+	for _, entry := range entries {
+		key := fmt.Sprintf("%s/%s", entry.Organization, entry.Workspace)
+		if seen[key] || (t.cfg.GetWorkspace() != "" && t.cfg.GetWorkspace() != entry.Workspace) {
+			continue
+		}
+		seen[key] = true
+		workspaces = append(workspaces, &TFCWorkspace{Name: entry.Workspace, Organization: entry.Organization})
+	}
+	if len(workspaces) > 0 && t.cfg.GetAction() == ConfirmAction {
+		// confirming applies the run, it needs the same cost approval as tfc apply
+		mr, err := t.gl.GetMergeRequest(t.cfg.GetMergeRequestIID(), t.cfg.GetProjectNameWithNamespace())
+		if err != nil {
+			return nil, t.handleError(err, "could not read MergeRequest data from Gitlab API")
+		}
+		if err := t.loadGuardSettings(workspaces, mr.GetTargetBranch()); err != nil {
+			return nil, err
+		}
+		if err := t.checkCostApproval(workspaces, workspaces[0].CostApproval); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, ws := range workspaces {
+		var err error
+		switch t.cfg.GetAction() {
+		case OverridePolicyAction:
+			err = t.overridePolicies(ws.Organization, ws.Name)
+		case ConfirmAction, DiscardAction:
+			err = t.confirmOrDiscardRun(ws.Organization, ws.Name, t.cfg.GetAction() == ConfirmAction)
+		case CancelAction:
+			err = t.cancelRuns(ws.Organization, ws.Name)
+		}
+		if IsTransient(err) {
+			// the hook is processed again, the error isn't reported for the workspace
+			return nil, err
+		}
+		if err != nil {
+			log.Error().Err(err).Str("ws", ws.Name).Msgf("could not %s the runs of the workspace", t.cfg.GetAction())
+			workspaceStatus.Errored = append(workspaceStatus.Errored, &ErroredWorkspace{
+				Name:  ws.Name,
+				Error: fmt.Sprintf("could not %s the runs of the workspace", t.cfg.GetAction()),
+			})
+			continue
+		}
+		workspaceStatus.Executed = append(workspaceStatus.Executed, ws.Name)
+	}
+	if len(workspaces) == 0 {
+		return nil, t.handleError(ErrNoRunForMR, fmt.Sprintf("could not %s the runs of the MR", t.cfg.GetAction()))
+	}
+	return workspaceStatus, nil
+}
+
 func (t *TFCTrigger) TriggerCleanupEvent() error {
 	mr, err := t.gl.GetMergeRequest(t.cfg.GetMergeRequestIID(), t.cfg.GetProjectNameWithNamespace())
 	if err != nil {
@@ -446,19 +667,21 @@ func (t *TFCTrigger) LockUnlockWorkspace(ws *tfe.Workspace, mr vcs.DetailedMR, l
 	return ErrWorkspaceUnlocked
 }
 
-func (t *TFCTrigger) triggerRunForWorkspace(cfgWS *TFCWorkspace, mr vcs.DetailedMR, cloneDir string) error {
+// triggerRunForWorkspace creates the run of the workspace. queued is true if the run waits for capacity under the TFC
+// run concurrency limits, the RunDispatcher triggers it again once capacity frees up.
+func (t *TFCTrigger) triggerRunForWorkspace(cfgWS *TFCWorkspace, mr vcs.DetailedMR, cloneDir string) (queued bool, err error) {
 	org := cfgWS.Organization
 	wsName := cfgWS.Name
 
 	// retrieve TFC workspace details, so we can sanity check this request.
 	ws, err := t.tfc.GetWorkspaceByName(context.Background(), org, wsName)
 	if err != nil {
-		return t.handleError(err, "could not get Workspace from TFC API")
+		return false, t.handleError(err, "could not get Workspace from TFC API")
 	}
 
 	// Check if workspace allows API driven runs
 	if ws.VCSRepo != nil && t.cfg.GetAction() == ApplyAction {
-		return t.handleError(
+		return false, t.handleError(
 			fmt.Errorf("cannot trigger apply for VCS workspace"),
 			"TFC workspace is configured with a VCS backend, must merge to trigger an Apply.",
 		)
@@ -469,16 +692,16 @@ func (t *TFCTrigger) triggerRunForWorkspace(cfgWS *TFCWorkspace, mr vcs.Detailed
 	if t.cfg.GetAction() == LockAction || t.cfg.GetAction() == UnlockAction {
 		err = t.LockUnlockWorkspace(ws, mr, t.cfg.GetAction() == LockAction)
 		if err != nil {
-			return t.handleError(err, "Error modifying the TFC lock on the workspace")
+			return false, t.handleError(err, "Error modifying the TFC lock on the workspace")
 		}
 		_, err := t.gl.CreateMergeRequestDiscussion(mr.GetInternalID(),
 			t.cfg.GetProjectNameWithNamespace(),
 			fmt.Sprintf("Successfully %sed Workspace `%s/%s`", t.cfg.GetAction(), org, wsName),
 		)
 		if err != nil {
-			return t.handleError(err, "Error posting successful lock modification status")
+			return false, t.handleError(err, "Error posting successful lock modification status")
 		}
-		return nil
+		return false, nil
 	}
 
 	pkgDir := filepath.Join(cloneDir, cfgWS.Dir)
@@ -491,25 +714,41 @@ func (t *TFCTrigger) triggerRunForWorkspace(cfgWS *TFCWorkspace, mr vcs.Detailed
 	if t.cfg.GetAction() == ApplyAction {
 		isApply = true
 	} else if t.cfg.GetAction() != PlanAction {
-		return t.handleError(nil, "Run action was not apply or plan")
+		return false, t.handleError(nil, "Run action was not apply or plan")
+	}
+	// the policies are loaded before the workspace is locked and the MR discussion is created, so a policy that can't be
+	// loaded leaves neither behind
+	policies, err := t.loadRepoPolicies(cfgWS)
+	if err != nil {
+		return false, err
 	}
 	// If the workspace is locked tell the user and don't queue a run
 	// Otherwise, TFC wil queue an apply, which might put them out of order
 	if isApply {
 		lockingMR := t.getLockingMR(ws.ID)
 		if ws.Locked {
-			return t.handleError(nil, "Refusing to Apply changes to a locked workspace")
+			t.notifyLockContention(cfgWS, "the workspace is locked in TFC")
+			return false, t.handleError(nil, "Refusing to Apply changes to a locked workspace")
 		} else if lockingMR != "" {
-			return t.handleError(nil, fmt.Sprintf("Workspace is locked by another MR! %s", lockingMR))
-		} else {
-			err = t.tfc.AddTags(context.Background(),
-				ws.ID,
-				tfPrefix,
-				fmt.Sprintf("%d", t.cfg.GetMergeRequestIID()),
-			)
-			if err != nil {
-				return t.handleError(err, "Error adding tags to workspace")
-			}
+			t.notifyLockContention(cfgWS, fmt.Sprintf("the workspace is locked by MR !%s", lockingMR))
+			return false, t.handleError(nil, fmt.Sprintf("Workspace is locked by another MR! %s", lockingMR))
+		}
+	}
+	// the slot is acquired before the workspace is locked and the MR discussion is created, a queued run does neither
+	// until it is dispatched
+	slotID, queued := t.acquireRunSlot(cfgWS, ws)
+	if queued {
+		return true, nil
+	}
+	if isApply {
+		err = t.tfc.AddTags(context.Background(),
+			ws.ID,
+			tfPrefix,
+			fmt.Sprintf("%d", t.cfg.GetMergeRequestIID()),
+		)
+		if err != nil {
+			t.releaseRunSlot(slotID)
+			return false, t.handleError(err, "Error adding tags to workspace")
 		}
 	}
 	// create a new Merge Request discussion thread where status updates will be nested
@@ -518,7 +757,8 @@ func (t *TFCTrigger) triggerRunForWorkspace(cfgWS *TFCWorkspace, mr vcs.Detailed
 		fmt.Sprintf("Starting TFC %v for Workspace: `%s/%s`.", t.cfg.GetAction(), org, wsName),
 	)
 	if err != nil {
-		return t.handleError(err, "could not create MR discussion thread for TFC run status updates")
+		t.releaseRunSlot(slotID)
+		return false, t.handleError(err, "could not create MR discussion thread for TFC run status updates")
 	}
 	t.GetConfig().SetMergeRequestDiscussionID(disc.GetDiscussionID())
 	if len(disc.GetMRNotes()) > 0 {
@@ -527,16 +767,27 @@ func (t *TFCTrigger) triggerRunForWorkspace(cfgWS *TFCWorkspace, mr vcs.Detailed
 		log.Debug().Msg("No MR Notes found")
 	}
 
-	// create new TFC run
+	// create new TFC run. Applies to workspaces with protected resources or policies are confirmed once the plan has
+	// been checked, unless the user acknowledged destroying protected resources with --allow-destroy.
+	protected := !cfgWS.ProtectedResources.IsEmpty() && !t.cfg.GetAllowDestroy()
+	confirmApply := isApply && (protected || len(plan_policy.Policies(policies)) > 0)
+
 	run, err := t.tfc.CreateRunFromSource(&tfc_api.ApiRunOptions{
-		IsApply:      isApply,
-		Path:         pkgDir,
-		Message:      fmt.Sprintf("MR [!%d]: %s", t.cfg.GetMergeRequestIID(), mr.GetTitle()),
-		Organization: org,
-		Workspace:    wsName,
+		IsApply:             isApply,
+		RequireConfirmation: confirmApply,
+		Path:                pkgDir,
+		Message:             fmt.Sprintf(runMessagePrefixFormat+"%s", t.cfg.GetMergeRequestIID(), mr.GetTitle()),
+		Organization:        org,
+		Workspace:           wsName,
 	})
 	if err != nil {
-		return t.handleError(err, "could not create TFC run")
+		t.releaseRunSlot(slotID)
+		return false, t.handleError(err, "could not create TFC run")
+	}
+	if slotID != "" {
+		if err := t.runstream.AssignRunSlot(slotID, run.ID); err != nil {
+			log.Error().Err(err).Str("runID", run.ID).Msg("could not assign TFC run slot")
+		}
 	}
 
 	tfcRunsStarted.WithLabelValues(org, wsName, t.cfg.GetAction().String()).Inc()
@@ -547,27 +798,129 @@ func (t *TFCTrigger) triggerRunForWorkspace(cfgWS *TFCWorkspace, mr vcs.Detailed
 		Bool("speculative", run.ConfigurationVersion.Speculative).
 		Msg("created TFC run")
 
-	return t.publishRunToStream(run)
+	return false, t.publishRunToStream(run, mr, cfgWS, policies, confirmApply)
+}
+
+// runSource is the trigger source recorded in the run metadata, runs triggered from Slack have a Slack thread.
+func (t *TFCTrigger) runSource() string {
+	if t.cfg.GetSlackChannel() != "" {
+		return "slack"
+	}
+	return "merge_request"
+}
+
+// notifyLockContention notifies the workspace's notification targets that an apply of the MR was refused because the
+// workspace is locked.
+func (t *TFCTrigger) notifyLockContention(cfgWS *TFCWorkspace, reason string) {
+	if len(cfgWS.Notifications) == 0 {
+		return
+	}
+	err := t.runstream.PublishNotificationEvent(&runstream.NotificationEvent{
+		Event:                                runstream.NotifyLockContention,
+		Organization:                         cfgWS.Organization,
+		Workspace:                            cfgWS.Name,
+		MergeRequestProjectNameWithNamespace: t.cfg.GetProjectNameWithNamespace(),
+		MergeRequestIID:                      t.cfg.GetMergeRequestIID(),
+		VcsProvider:                          t.cfg.GetVcsProvider(),
+		Message: fmt.Sprintf("Apply of `%s/%s` from %s!%d was refused, %s.",
+			cfgWS.Organization, cfgWS.Name, t.cfg.GetProjectNameWithNamespace(), t.cfg.GetMergeRequestIID(), reason),
+		Notifications: cfgWS.Notifications,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("could not publish lock contention notification")
+	}
+}
+
+// supersedePreviousPlan records the run as the latest plan of the workspace for the MR. The previous plan, of an older
+// commit, is cancelled or discarded if it is still in progress and its MR thread is marked as superseded, so pushing
+// several commits quickly doesn't leave stale plans running and posting updates.
+func (t *TFCTrigger) supersedePreviousPlan(rmd *runstream.TFRunMetadata) {
+	previous, err := t.runstream.SetLatestRun(rmd)
+	if err != nil {
+		log.Error().Err(err).Str("runID", rmd.RunID).Msg("could not record latest plan of workspace")
+		return
+	}
+	if previous == nil || previous.RunID == rmd.RunID || previous.CommitSHA == rmd.CommitSHA {
+		return
+	}
+	log := log.With().Str("runID", previous.RunID).Str("supersededBy", rmd.CommitSHA).Logger()
+
+	run, err := t.tfc.GetRun(previous.RunID)
+	if err != nil {
+		log.Error().Err(err).Msg("could not get superseded run")
+		return
+	}
+	comment := fmt.Sprintf("TF Buddy: superseded by %s", rmd.CommitSHA)
+	switch {
+	case run.Actions != nil && run.Actions.IsCancelable:
+		err = t.tfc.CancelRun(context.Background(), run.ID, comment)
+	case run.Actions != nil && run.Actions.IsDiscardable:
+		err = t.tfc.DiscardRun(context.Background(), run.ID, comment)
+	default:
+		// the run has finished, its updates were all posted
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("could not cancel superseded run")
+		return
+	}
+	tfcRunsSuperseded.WithLabelValues(rmd.Organization, rmd.Workspace).Inc()
+
+	prevRmd, err := t.runstream.GetRunMeta(previous.RunID)
+	if err != nil {
+		log.Error().Err(err).Msg("could not get superseded run metadata")
+		return
+	}
+	msg := fmt.Sprintf(":fast_forward: Superseded by %s, updates of this run won't be posted.", rmd.CommitSHA)
+	if prevRmd.GetDiscussionID() == "" {
+		// GitHub runs have no MR thread, the PR gets a comment instead
+		if err := t.gl.CreateMergeRequestComment(prevRmd.GetMRInternalID(), prevRmd.GetMRProjectNameWithNamespace(), msg); err != nil {
+			log.Error().Err(err).Msg("could not comment superseded run on MR")
+		}
+		return
+	}
+	if _, err := t.gl.AddMergeRequestDiscussionReply(
+		prevRmd.GetMRInternalID(),
+		prevRmd.GetMRProjectNameWithNamespace(),
+		prevRmd.GetDiscussionID(),
+		msg,
+	); err != nil {
+		log.Error().Err(err).Msg("could not mark MR thread of superseded run")
+	}
 }
 
-func (t *TFCTrigger) publishRunToStream(run *tfe.Run) error {
+func (t *TFCTrigger) publishRunToStream(run *tfe.Run, mr vcs.DetailedMR, cfgWS *TFCWorkspace, policies map[string]string, confirmApply bool) error {
 	rmd := &runstream.TFRunMetadata{
 		RunID:                                run.ID,
 		Organization:                         run.Workspace.Organization.Name,
 		Workspace:                            run.Workspace.Name,
-		Source:                               "merge_request",
+		Source:                               t.runSource(),
 		Action:                               t.cfg.GetAction().String(),
 		CommitSHA:                            t.cfg.GetCommitSHA(),
 		MergeRequestProjectNameWithNamespace: t.cfg.GetProjectNameWithNamespace(),
 		MergeRequestIID:                      t.cfg.GetMergeRequestIID(),
+		MergeRequestWebURL:                   mr.GetWebURL(),
 		DiscussionID:                         t.cfg.GetMergeRequestDiscussionID(),
 		RootNoteID:                           t.cfg.GetMergeRequestRootNoteID(),
 		VcsProvider:                          t.cfg.GetVcsProvider(),
+		PlanFormat:                           cfgWS.PlanFormat,
+		TemplateRefs:                         t.loadRepoTemplates(cfgWS),
+		ProtectedResources:                   cfgWS.ProtectedResources,
+		AllowDestroy:                         t.cfg.GetAllowDestroy(),
+		Policies:                             policies,
+		ConfirmApply:                         confirmApply,
+		CostThreshold:                        cfgWS.CostApproval.GetThreshold(),
+		Notifications:                        cfgWS.Notifications,
+		SlackChannel:                         t.cfg.GetSlackChannel(),
+		SlackThreadTS:                        t.cfg.GetSlackThreadTS(),
 	}
 	err := t.runstream.AddRunMeta(rmd)
 	if err != nil {
 		return t.handleError(err, "Could not publish Run metadata to event stream, updates may not be posted to MR")
 	}
+	if t.cfg.GetAction() == PlanAction {
+		t.supersedePreviousPlan(rmd)
+	}
 
 	if run.ConfigurationVersion.Speculative {
 		// TFC doesn't send Notification webhooks for speculative plans, so we need to poll for updates.
@@ -582,3 +935,50 @@ func (t *TFCTrigger) publishRunToStream(run *tfe.Run) error {
 
 	return nil
 }
+
+// loadRepoTemplates reads the comment templates configured for the workspace from the MR branch and stores them in the
+// event stream, returning their references by name. Templates that cannot be read, are invalid or can't be stored are
+// reported on the MR, and the default template is used instead.
+func (t *TFCTrigger) loadRepoTemplates(cfgWS *TFCWorkspace) map[string]string {
+	if len(cfgWS.Templates) == 0 {
+		return nil
+	}
+	templates := map[string]string{}
+	for name, path := range cfgWS.Templates {
+		b, err := t.gl.GetRepoFile(t.cfg.GetProjectNameWithNamespace(), path, t.cfg.GetBranch())
+		if err == nil {
+			err = comment_formatter.ValidateTemplate(name, string(b))
+		}
+		var ref string
+		if err == nil {
+			ref, err = t.runstream.PutTemplate(string(b))
+		}
+		if err != nil {
+			t.handleError(err, fmt.Sprintf("could not load %s template from %s, using the default template", name, path))
+			continue
+		}
+		templates[name] = ref
+	}
+	return templates
+}
+
+// loadRepoPolicies reads the Rego policies configured for the workspace from the branch the guard settings were read
+// from, so that the MR can't change or remove them. A policy that cannot be read or is invalid is reported on the MR and
+// fails the trigger.
+func (t *TFCTrigger) loadRepoPolicies(cfgWS *TFCWorkspace) (map[string]string, error) {
+	if len(cfgWS.Policies) == 0 {
+		return nil, nil
+	}
+	policies := map[string]string{}
+	for _, path := range cfgWS.Policies {
+		b, err := t.gl.GetRepoFile(t.cfg.GetProjectNameWithNamespace(), path, t.guardBranch)
+		if err == nil {
+			err = plan_policy.ValidatePolicy(path, string(b))
+		}
+		if err != nil {
+			return nil, t.handleError(err, fmt.Sprintf("could not load policy %s", path))
+		}
+		policies[path] = string(b)
+	}
+	return policies, nil
+}
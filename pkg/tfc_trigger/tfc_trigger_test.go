@@ -1,6 +1,8 @@
 package tfc_trigger_test
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -10,6 +12,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/rzajac/zltest"
 	"github.com/zapier/tfbuddy/pkg/mocks"
+	"github.com/zapier/tfbuddy/pkg/runstream"
 	"github.com/zapier/tfbuddy/pkg/tfc_api"
 	"github.com/zapier/tfbuddy/pkg/tfc_trigger"
 )
@@ -43,6 +46,23 @@ func TestTriggerAction_String(t *testing.T) {
 	}
 }
 
+func TestTriggerAction_Values(t *testing.T) {
+	// the values are persisted with queued runs, new actions must be appended
+	actions := []tfc_trigger.TriggerAction{
+		tfc_trigger.ApplyAction,
+		tfc_trigger.DestroyAction,
+		tfc_trigger.LockAction,
+		tfc_trigger.PlanAction,
+		tfc_trigger.RefreshAction,
+		tfc_trigger.UnlockAction,
+	}
+	for want, a := range actions {
+		if int(a) != want {
+			t.Fatalf("%v = %d, want %d", a, a, want)
+		}
+	}
+}
+
 func TestFindLockingMR(t *testing.T) {
 	tests := []struct {
 		name string
@@ -141,6 +161,331 @@ func TestTFCEvents_SingleWorkspacePlan(t *testing.T) {
 	}
 }
 
+func TestTFCEvents_SingleWorkspacePlanSupersedesOlderCommit(t *testing.T) {
+	tests := []struct {
+		name         string
+		discussionID string
+		cancelErr    error
+	}{
+		{
+			name:         "MR thread",
+			discussionID: "200",
+		},
+		{
+			name: "PR comment",
+		},
+		{
+			name:      "cancel failed",
+			cancelErr: errors.New("tfc unavailable"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &tfc_trigger.ProjectConfig{
+				Workspaces: []*tfc_trigger.TFCWorkspace{{
+					Name:         "service-tfbuddy",
+					Organization: "zapier-test",
+					Mode:         "apply-before-merge",
+				}}}
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{ProjectConfig: ws}, t)
+			testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS, "Starting TFC plan for Workspace: `zapier-test/service-tfbuddy`.").Return(testSuite.MockGitDisc, nil)
+			testSuite.MockApiClient.EXPECT().CreateRunFromSource(gomock.Any()).Return(&tfe.Run{
+				ID: "101",
+				Workspace: &tfe.Workspace{Name: "service-tfbuddy",
+					Organization: &tfe.Organization{Name: "zapier-test"},
+				},
+				ConfigurationVersion: &tfe.ConfigurationVersion{Speculative: true}}, nil)
+
+			mockRunPollingTask := mocks.NewMockRunPollingTask(mockCtrl)
+			mockRunPollingTask.EXPECT().Schedule()
+			testSuite.MockStreamClient.EXPECT().NewTFRunPollingTask(gomock.Any(), time.Second*1).Return(mockRunPollingTask)
+
+			// the plan of the previous commit is still running
+			testSuite.MockStreamClient.EXPECT().SetLatestRun(gomock.Any()).Return(&runstream.LatestRun{RunID: "100", CommitSHA: "0000aaaa"}, nil)
+			testSuite.MockApiClient.EXPECT().GetRun("100").Return(&tfe.Run{ID: "100", Status: tfe.RunPlanning, Actions: &tfe.RunActions{IsCancelable: true}}, nil)
+			testSuite.MockApiClient.EXPECT().CancelRun(gomock.Any(), "100", "TF Buddy: superseded by abcd12233").Return(tt.cancelErr)
+			superseded := ":fast_forward: Superseded by abcd12233, updates of this run won't be posted."
+			switch {
+			case tt.cancelErr != nil:
+				// the run goes on, so it isn't marked as superseded
+			case tt.discussionID != "":
+				testSuite.MockStreamClient.EXPECT().GetRunMeta("100").Return(&runstream.TFRunMetadata{
+					RunID:                                "100",
+					MergeRequestIID:                      testSuite.MetaData.MRIID,
+					MergeRequestProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+					DiscussionID:                         tt.discussionID,
+				}, nil)
+				testSuite.MockGitClient.EXPECT().AddMergeRequestDiscussionReply(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS, tt.discussionID, superseded).Return(testSuite.MockMRNote, nil)
+			default:
+				testSuite.MockStreamClient.EXPECT().GetRunMeta("100").Return(&runstream.TFRunMetadata{
+					RunID:                                "100",
+					MergeRequestIID:                      testSuite.MetaData.MRIID,
+					MergeRequestProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+				}, nil)
+				testSuite.MockGitClient.EXPECT().CreateMergeRequestComment(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS, superseded).Return(nil)
+			}
+
+			testSuite.InitTestSuite()
+
+			trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+				Action:                   tfc_trigger.PlanAction,
+				Branch:                   testSuite.MetaData.SourceBranch,
+				CommitSHA:                "abcd12233",
+				ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+				MergeRequestIID:          testSuite.MetaData.MRIID,
+				TriggerSource:            tfc_trigger.CommentTrigger,
+			})
+			triggeredWS, err := trigger.TriggerTFCEvents()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(triggeredWS.Executed) != 1 {
+				t.Fatal("expected a single TF workspace run", triggeredWS.Errored)
+			}
+		})
+	}
+}
+
+func TestTFCEvents_GithubPlanSupersedesOlderHeadCommit(t *testing.T) {
+	tests := []struct {
+		name           string
+		previousCommit string
+		supersede      bool
+	}{
+		{
+			name:           "new head commit",
+			previousCommit: "head1111",
+			supersede:      true,
+		},
+		{
+			name:           "same head commit",
+			previousCommit: "head2222",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &tfc_trigger.ProjectConfig{
+				Workspaces: []*tfc_trigger.TFCWorkspace{{
+					Name:         "service-tfbuddy",
+					Organization: "zapier-test",
+					Mode:         "apply-before-merge",
+				}}}
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{ProjectConfig: ws}, t)
+			testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS, "Starting TFC plan for Workspace: `zapier-test/service-tfbuddy`.").Return(testSuite.MockGitDisc, nil)
+			testSuite.MockApiClient.EXPECT().CreateRunFromSource(gomock.Any()).Return(&tfe.Run{
+				ID: "101",
+				Workspace: &tfe.Workspace{Name: "service-tfbuddy",
+					Organization: &tfe.Organization{Name: "zapier-test"},
+				},
+				ConfigurationVersion: &tfe.ConfigurationVersion{Speculative: true}}, nil)
+
+			mockRunPollingTask := mocks.NewMockRunPollingTask(mockCtrl)
+			mockRunPollingTask.EXPECT().Schedule()
+			testSuite.MockStreamClient.EXPECT().NewTFRunPollingTask(gomock.Any(), time.Second*1).Return(mockRunPollingTask)
+
+			// the latest plan of the PR is recorded for its head commit, the PRs of the same base don't share it
+			testSuite.MockStreamClient.EXPECT().SetLatestRun(gomock.Any()).DoAndReturn(func(rmd runstream.RunMetadata) (*runstream.LatestRun, error) {
+				if rmd.GetCommitSHA() != "head2222" || rmd.GetVcsProvider() != "github" {
+					t.Errorf("unexpected latest run %s of %s", rmd.GetCommitSHA(), rmd.GetVcsProvider())
+				}
+				return &runstream.LatestRun{RunID: "100", CommitSHA: tt.previousCommit}, nil
+			})
+			if tt.supersede {
+				testSuite.MockApiClient.EXPECT().GetRun("100").Return(&tfe.Run{ID: "100", Status: tfe.RunPlanning, Actions: &tfe.RunActions{IsCancelable: true}}, nil)
+				testSuite.MockApiClient.EXPECT().CancelRun(gomock.Any(), "100", "TF Buddy: superseded by head2222").Return(nil)
+				testSuite.MockStreamClient.EXPECT().GetRunMeta("100").Return(&runstream.TFRunMetadata{
+					RunID:                                "100",
+					MergeRequestIID:                      testSuite.MetaData.MRIID,
+					MergeRequestProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+					VcsProvider:                          "github",
+				}, nil)
+				testSuite.MockGitClient.EXPECT().CreateMergeRequestComment(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS,
+					":fast_forward: Superseded by head2222, updates of this run won't be posted.").Return(nil)
+			} else {
+				testSuite.MockApiClient.EXPECT().CancelRun(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+
+			testSuite.InitTestSuite()
+
+			trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+				Action:                   tfc_trigger.PlanAction,
+				Branch:                   testSuite.MetaData.SourceBranch,
+				CommitSHA:                "head2222",
+				ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+				MergeRequestIID:          testSuite.MetaData.MRIID,
+				TriggerSource:            tfc_trigger.CommentTrigger,
+				VcsProvider:              "github",
+			})
+			triggeredWS, err := trigger.TriggerTFCEvents()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(triggeredWS.Executed) != 1 {
+				t.Fatal("expected a single TF workspace run", triggeredWS.Errored)
+			}
+		})
+	}
+}
+
+func TestTFCEvents_SingleWorkspacePlanQueued(t *testing.T) {
+	t.Setenv(runstream.OrgRunLimitsEnvName, "zapier-test=1")
+
+	ws := &tfc_trigger.ProjectConfig{
+		Workspaces: []*tfc_trigger.TFCWorkspace{{
+			Name:         "service-tfbuddy",
+			Organization: "zapier-test",
+			Mode:         "apply-before-merge",
+		}}}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{ProjectConfig: ws}, t)
+	// a queued run doesn't open its MR thread until it is dispatched
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS, "Starting TFC plan for Workspace: `zapier-test/service-tfbuddy`.").Times(0)
+	testSuite.MockStreamClient.EXPECT().AcquireRunSlot(gomock.Any()).DoAndReturn(func(qr *runstream.QueuedRun) (int, error) {
+		if qr.Organization != "zapier-test" || qr.Workspace != "service-tfbuddy" || qr.ID == "" {
+			t.Errorf("unexpected queued run %+v", qr)
+		}
+		cfg := &tfc_trigger.TFCTriggerConfig{}
+		if err := json.Unmarshal(qr.Trigger, cfg); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Workspace != "service-tfbuddy" || cfg.MergeRequestDiscussionID != "" || cfg.CommitSHA != "abcd12233" {
+			t.Errorf("unexpected queued trigger %+v", cfg)
+		}
+		return 2, nil
+	})
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS,
+		":hourglass: TFC plan for Workspace `zapier-test/service-tfbuddy` queued at position 2, the TFC run concurrency limit of "+
+			"`zapier-test` is reached. The plan will start once other runs finish.").Return(testSuite.MockGitDisc, nil)
+	// no run is created until the queued run is dispatched
+	testSuite.MockApiClient.EXPECT().CreateRunFromSource(gomock.Any()).Times(0)
+
+	testSuite.InitTestSuite()
+
+	trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+		Action:                   tfc_trigger.PlanAction,
+		Branch:                   testSuite.MetaData.SourceBranch,
+		CommitSHA:                "abcd12233",
+		ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:          testSuite.MetaData.MRIID,
+		TriggerSource:            tfc_trigger.CommentTrigger,
+	})
+	triggeredWS, err := trigger.TriggerTFCEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggeredWS.Queued) != 1 || len(triggeredWS.Executed) != 0 {
+		t.Fatal("expected the run to be queued", triggeredWS.Errored)
+	}
+}
+
+func TestTFCEvents_SingleWorkspacePlanDispatched(t *testing.T) {
+	t.Setenv(runstream.OrgRunLimitsEnvName, "zapier-test=1")
+
+	ws := &tfc_trigger.ProjectConfig{
+		Workspaces: []*tfc_trigger.TFCWorkspace{{
+			Name:         "service-tfbuddy",
+			Organization: "zapier-test",
+			Mode:         "apply-before-merge",
+		}}}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{ProjectConfig: ws}, t)
+	// the dispatched run uses the queued commit, not the branch HEAD
+	testSuite.MockGitRepo.EXPECT().CheckoutCommit("abcd12233").Return(nil)
+	testSuite.MockApiClient.EXPECT().CreateRunFromSource(gomock.Any()).Return(&tfe.Run{
+		ID: "101",
+		Workspace: &tfe.Workspace{Name: "service-tfbuddy",
+			Organization: &tfe.Organization{Name: "zapier-test"},
+		},
+		ConfigurationVersion: &tfe.ConfigurationVersion{Speculative: true}}, nil)
+	testSuite.MockStreamClient.EXPECT().AssignRunSlot("slot-1", "101").Return(nil)
+	mockRunPollingTask := mocks.NewMockRunPollingTask(mockCtrl)
+	mockRunPollingTask.EXPECT().Schedule()
+	testSuite.MockStreamClient.EXPECT().NewTFRunPollingTask(gomock.Any(), time.Second*1).Return(mockRunPollingTask)
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS, "Starting TFC plan for Workspace: `zapier-test/service-tfbuddy`.").Return(testSuite.MockGitDisc, nil)
+
+	testSuite.InitTestSuite()
+
+	trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+		Action:                   tfc_trigger.PlanAction,
+		Branch:                   testSuite.MetaData.SourceBranch,
+		CommitSHA:                "abcd12233",
+		ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:          testSuite.MetaData.MRIID,
+		TriggerSource:            tfc_trigger.CommentTrigger,
+		RunSlotID:                "slot-1",
+	})
+	triggeredWS, err := trigger.TriggerTFCEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggeredWS.Executed) != 1 {
+		t.Fatal("expected the queued run to be created", triggeredWS.Errored)
+	}
+}
+
+func TestRunDispatcher_DispatchGithubRun(t *testing.T) {
+	t.Setenv(runstream.OrgRunLimitsEnvName, "zapier-test=1")
+
+	ws := &tfc_trigger.ProjectConfig{
+		Workspaces: []*tfc_trigger.TFCWorkspace{{
+			Name:         "service-tfbuddy",
+			Organization: "zapier-test",
+			Mode:         "apply-before-merge",
+		}}}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{ProjectConfig: ws}, t)
+
+	// a PR comment queued for the PR head commit
+	queuedTrigger, err := json.Marshal(&tfc_trigger.TFCTriggerConfig{
+		Action:                   tfc_trigger.PlanAction,
+		Branch:                   testSuite.MetaData.SourceBranch,
+		CommitSHA:                "head1234",
+		ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:          testSuite.MetaData.MRIID,
+		TriggerSource:            tfc_trigger.CommentTrigger,
+		VcsProvider:              "github",
+		Workspace:                "service-tfbuddy",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gomock.InOrder(
+		testSuite.MockStreamClient.EXPECT().DispatchQueuedRuns().Return([]*runstream.QueuedRun{{
+			ID: "slot-1", Organization: "zapier-test", Workspace: "service-tfbuddy", Trigger: queuedTrigger,
+		}}, nil),
+		testSuite.MockStreamClient.EXPECT().DispatchQueuedRuns().Return(nil, nil),
+	)
+	testSuite.MockGitRepo.EXPECT().CheckoutCommit("head1234").Return(nil)
+	testSuite.MockApiClient.EXPECT().CreateRunFromSource(gomock.Any()).Return(&tfe.Run{
+		ID: "101",
+		Workspace: &tfe.Workspace{Name: "service-tfbuddy",
+			Organization: &tfe.Organization{Name: "zapier-test"},
+		},
+		ConfigurationVersion: &tfe.ConfigurationVersion{Speculative: true}}, nil)
+	testSuite.MockStreamClient.EXPECT().AssignRunSlot("slot-1", "101").Return(nil)
+	mockRunPollingTask := mocks.NewMockRunPollingTask(mockCtrl)
+	mockRunPollingTask.EXPECT().Schedule()
+	testSuite.MockStreamClient.EXPECT().NewTFRunPollingTask(gomock.Any(), time.Second*1).Return(mockRunPollingTask)
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS, "Starting TFC plan for Workspace: `zapier-test/service-tfbuddy`.").Return(testSuite.MockGitDisc, nil)
+
+	testSuite.InitTestSuite()
+
+	gitlab := mocks.NewMockGitClient(mockCtrl)
+	tfc_trigger.NewRunDispatcher(gitlab, testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient).Dispatch()
+}
+
 func TestTFCEvents_SingleWorkspacePlanError(t *testing.T) {
 
 	ws := &tfc_trigger.ProjectConfig{
@@ -252,6 +597,47 @@ func TestTFCEvents_SingleWorkspaceApply(t *testing.T) {
 
 }
 
+func TestTFCEvents_SingleWorkspaceApplyLockContention(t *testing.T) {
+	notifications := []*runstream.NotificationRule{{Target: "platform-slack", Events: []string{"lock_contention"}}}
+	ws := &tfc_trigger.ProjectConfig{
+		Workspaces: []*tfc_trigger.TFCWorkspace{{
+			Name:          "service-tfbuddy",
+			Organization:  "zapier-test",
+			Mode:          "apply-before-merge",
+			Notifications: notifications,
+		}}}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{ProjectConfig: ws}, t)
+	testSuite.MockApiClient.EXPECT().GetTagsByQuery(gomock.Any(), "service-tfbuddy", "tfbuddylock").Return([]string{"tfbuddylock-7"}, nil)
+	testSuite.MockStreamClient.EXPECT().PublishNotificationEvent(&runstream.NotificationEvent{
+		Event:                                "lock_contention",
+		Organization:                         "zapier-test",
+		Workspace:                            "service-tfbuddy",
+		MergeRequestProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:                      testSuite.MetaData.MRIID,
+		Message:                              "Apply of `zapier-test/service-tfbuddy` from zapier/tfbuddy!101 was refused, the workspace is locked by MR !7.",
+		Notifications:                        notifications,
+	}).Return(nil)
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestComment(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS,
+		"Error: Workspace is locked by another MR! 7: <nil>").Return(nil)
+	testSuite.InitTestSuite()
+
+	trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+		Action:                   tfc_trigger.ApplyAction,
+		Branch:                   "test-branch",
+		CommitSHA:                "abcd12233",
+		ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:          testSuite.MetaData.MRIID,
+		TriggerSource:            tfc_trigger.CommentTrigger,
+	})
+	// no run is created, the strict mocks fail the test otherwise
+	if _, err := trigger.TriggerTFCEvents(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestTFCEvents_MultiWorkspaceApply(t *testing.T) {
 
 	ws := &tfc_trigger.ProjectConfig{
@@ -378,6 +764,48 @@ func TestTFCEvents_SingleWorkspaceApplyError(t *testing.T) {
 		t.Fatal("expected no triggered workspaces")
 	}
 }
+func TestTFCEvents_SingleWorkspaceApplyPolicyError(t *testing.T) {
+	ws := &tfc_trigger.ProjectConfig{
+		Policies: []string{"policies/common.rego"},
+		Workspaces: []*tfc_trigger.TFCWorkspace{{
+			Name:         "service-tfbuddy",
+			Organization: "zapier-test",
+			Mode:         "apply-before-merge",
+		}}}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	testSuite := mocks.CreateTestSuite(mockCtrl, mocks.TestOverrides{ProjectConfig: ws}, t)
+	testSuite.MockGitRepo.EXPECT().GetModifiedFileNamesBetweenCommits(testSuite.MetaData.CommonSHA, "main").Return([]string{}, nil)
+
+	// policies are read from the target branch, before the workspace is locked or the MR discussion is created
+	testSuite.MockGitClient.EXPECT().GetRepoFile(testSuite.MetaData.ProjectNameNS, "policies/common.rego", testSuite.MetaData.TargetBranch).Return(nil, fmt.Errorf("file not found"))
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestComment(testSuite.MetaData.MRIID, testSuite.MetaData.ProjectNameNS, "Error: could not load policy policies/common.rego: file not found")
+	testSuite.MockApiClient.EXPECT().AddTags(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(...interface{}) {
+		t.Error("expected the workspace not to be locked")
+	}).AnyTimes()
+	testSuite.MockGitClient.EXPECT().CreateMergeRequestDiscussion(gomock.Any(), gomock.Any(), gomock.Any()).Do(func(...interface{}) {
+		t.Error("expected no MR discussion")
+	}).AnyTimes()
+
+	testSuite.InitTestSuite()
+
+	trigger := tfc_trigger.NewTFCTrigger(testSuite.MockGitClient, testSuite.MockApiClient, testSuite.MockStreamClient, &tfc_trigger.TFCTriggerConfig{
+		Action:                   tfc_trigger.ApplyAction,
+		Branch:                   "test-branch",
+		CommitSHA:                "abcd12233",
+		ProjectNameWithNamespace: testSuite.MetaData.ProjectNameNS,
+		MergeRequestIID:          testSuite.MetaData.MRIID,
+		TriggerSource:            tfc_trigger.CommentTrigger,
+	})
+	triggeredWS, err := trigger.TriggerTFCEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggeredWS.Errored) != 1 || len(triggeredWS.Executed) != 0 {
+		t.Fatal("expected the workspace to fail", triggeredWS)
+	}
+}
 func TestTFCEvents_MultiWorkspaceApplyError(t *testing.T) {
 
 	ws := &tfc_trigger.ProjectConfig{
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/zapier/tfbuddy/pkg/comment_formatter"
 	"github.com/zapier/tfbuddy/pkg/gitlab"
 	"github.com/zapier/tfbuddy/pkg/tfc_api"
 
@@ -116,7 +117,7 @@ func postRunSummary(commitStatus *gogitlab.CommitStatus, wsName, runID string) {
 
 	switch run.Status {
 	case tfe.RunErrored:
-		description = failedPlanSummaryFormat
+		description = comment_formatter.FormatErrorLog(comment_formatter.RunErrorLog(tfcClient, run)) + failedPlanSummaryFormat
 	default:
 		description = fmt.Sprintf(successPlanSummaryFormat, run.Plan.ResourceAdditions, run.Plan.ResourceChanges, run.Plan.ResourceDestructions)
 	}
@@ -21,9 +21,13 @@ type GitRepo interface {
 	GetMergeBase(oldest, newest string) (string, error)
 	GetModifiedFileNamesBetweenCommits(oldest, newest string) ([]string, error)
 	GetLocalDirectory() string
+	// CheckoutCommit checks out a commit of the cloned branch, e.g. the commit a queued run was triggered for
+	CheckoutCommit(sha string) error
 }
 type MRApproved interface {
 	IsApproved() bool
+	// GetApprovers returns the usernames of the users that approved the MR
+	GetApprovers() []string
 }
 
 type MRDiscussion interface {
@@ -41,6 +45,7 @@ type DetailedMR interface {
 	MR
 	GetWebURL() string
 	GetTitle() string
+	GetHeadSHA() string
 }
 type MR interface {
 	MRBranches
@@ -81,6 +86,8 @@ type MRCommentEvent interface {
 	GetMR() MR
 	GetAttributes() MRAttributes
 	GetLastCommit() Commit
+	// GetUser returns the user that wrote the comment
+	GetUser() MRAuthor
 }
 
 type MRAttributes interface {